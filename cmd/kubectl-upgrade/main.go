@@ -0,0 +1,7 @@
+package main
+
+import kubectlupgrade "github.com/heathcliff26/kube-upgrade/pkg/kubectl-upgrade"
+
+func main() {
+	kubectlupgrade.Execute()
+}
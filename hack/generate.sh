@@ -51,6 +51,8 @@ cat "${generated_dir}/webhooks.yaml" \
     | sed 's/PLACEHOLDER_FULLNAME/{{ include "kube-upgrade.fullname" . }}/g' \
     | sed 's/PLACEHOLDER_NAMESPACE/{{ .Release.Namespace }}/g' \
     | sed 's/PLACEHOLDER_LABELS: "true"/{{- include "kube-upgrade.labels" . | nindent 4 }}/g' \
+    | sed 's/^  annotations:$/  {{- if .Values.webhooks.certManager.enabled }}\n  annotations:/g' \
+    | sed 's/^\(    cert-manager.io\/inject-ca-from: .*\)$/\1\n  {{- end }}/g' \
     >> "${helm_dir}/webhooks.yaml"
 echo "{{- end }}" >> "${helm_dir}/webhooks.yaml"
 
@@ -10,6 +10,7 @@ export KUBE_UPGRADE_NAMESPACE="${KUBE_UPGRADE_NAMESPACE:-kube-upgrade}"
 
 output_dir="${base_dir}/manifests/release"
 upgrade_controller_file="${output_dir}/upgrade-controller.yaml"
+node_webhook_file="${output_dir}/node-webhook.yaml"
 
 if [[ "${RELEASE_VERSION}" != "" ]] && [[ "${TAG}" == "latest" ]]; then
     TAG="${RELEASE_VERSION}"
@@ -47,6 +48,18 @@ helm template "${base_dir}/manifests/helm" \
     | grep -v 'app.kubernetes.io/managed-by: Helm' \
     | sed "s/v0.0.0/${TAG}/g" >> "${upgrade_controller_file}"
 
+echo "Creating optional node webhook from helm chart"
+helm template "${base_dir}/manifests/helm" \
+    --set fullnameOverride=kube-upgrade \
+    --set webhooks.nodeAnnotations.enabled=true \
+    --show-only templates/node-webhook.yaml \
+    --name-template kube-upgrade \
+    --namespace "${KUBE_UPGRADE_NAMESPACE}" \
+    | grep -v '# Source: kube-upgrade/templates' \
+    | grep -v 'helm.sh/chart: kube-upgrade' \
+    | grep -v 'app.kubernetes.io/managed-by: Helm' \
+    | sed "s/v0.0.0/${TAG}/g" > "${node_webhook_file}"
+
 echo "Fetching latest kubernetes version"
 # shellcheck disable=SC2155
 export kube_version_latest="$(curl -L -s https://dl.k8s.io/release/stable.txt)"
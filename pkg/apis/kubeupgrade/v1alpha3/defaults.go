@@ -1,5 +1,10 @@
 package v1alpha3
 
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
 const (
 	DefaultStatus                 = "Unknown"
 	DefaultUpgradedStream         = "ghcr.io/heathcliff26/fcos-k8s"
@@ -8,6 +13,29 @@ const (
 	DefaultUpgradedRetryInterval  = "1m"
 	DefaultUpgradedLogLevel       = "info"
 	DefaultUpgradedKubeletConfig  = "/etc/kubernetes/kubelet.conf"
+
+	DefaultKubeadmDownloadURL           = "https://dl.k8s.io/release"
+	DefaultKubeadmImagePath             = "/usr/bin/kubeadm"
+	DefaultKubeadmCertificateIdentity   = "krel-staging@k8s-releng-prod.iam.gserviceaccount.com"
+	DefaultKubeadmCertificateOIDCIssuer = "https://accounts.google.com"
+	DefaultKubeadmRekorURL              = "https://rekor.sigstore.dev"
+
+	DefaultKubeadmCacheMaxEntries = 3
+	DefaultKubeadmCacheMaxSize    = "512Mi"
+
+	DefaultRetryMaxInterval     = "30m"
+	DefaultRetryKubeadmAttempts = 1
+	DefaultRetryRebaseAttempts  = 1
+
+	DefaultVersionChannelURL      = "https://dl.k8s.io/release"
+	DefaultVersionChannelInterval = "1h"
+
+	DefaultFailurePolicyMaxAttempts = 3
+	DefaultFailurePolicyBackoff     = "5m"
+	DefaultFailurePolicyMaxFailures = 0
+
+	DefaultNodeHooksFailurePolicy = NodeHooksFailurePolicyError
+	DefaultNodeHooksTimeout       = "10m"
 )
 
 func SetObjectDefaults_KubeUpgradeSpec(spec *KubeUpgradeSpec) {
@@ -18,9 +46,37 @@ func SetObjectDefaults_KubeUpgradeSpec(spec *KubeUpgradeSpec) {
 		if group.Labels == nil {
 			group.Labels = make(map[string]string)
 		}
+		if group.FailurePolicy != nil {
+			SetObjectDefaults_FailurePolicy(group.FailurePolicy)
+		}
 		spec.Groups[name] = group
 	}
 	SetObjectDefaults_UpgradedConfig(&spec.Upgraded)
+	if spec.VersionChannel != nil {
+		SetObjectDefaults_VersionChannelConfig(spec.VersionChannel)
+	}
+}
+
+func SetObjectDefaults_VersionChannelConfig(cfg *VersionChannelConfig) {
+	if cfg.URL == "" {
+		cfg.URL = DefaultVersionChannelURL
+	}
+	if cfg.Interval == "" {
+		cfg.Interval = DefaultVersionChannelInterval
+	}
+}
+
+func SetObjectDefaults_FailurePolicy(policy *FailurePolicy) {
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = DefaultFailurePolicyMaxAttempts
+	}
+	if policy.Backoff == "" {
+		policy.Backoff = DefaultFailurePolicyBackoff
+	}
+	if policy.MaxFailures == nil {
+		maxFailures := intstr.FromInt32(DefaultFailurePolicyMaxFailures)
+		policy.MaxFailures = &maxFailures
+	}
 }
 
 func SetObjectDefaults_UpgradedConfig(cfg *UpgradedConfig) {
@@ -42,4 +98,71 @@ func SetObjectDefaults_UpgradedConfig(cfg *UpgradedConfig) {
 	if cfg.KubeletConfig == "" {
 		cfg.KubeletConfig = DefaultUpgradedKubeletConfig
 	}
+	if cfg.KubeadmDownload != nil {
+		SetObjectDefaults_KubeadmDownloadConfig(cfg.KubeadmDownload)
+	}
+	if cfg.KubeadmCache != nil {
+		SetObjectDefaults_KubeadmCacheConfig(cfg.KubeadmCache)
+	}
+	if cfg.Retry != nil {
+		SetObjectDefaults_RetryConfig(cfg.Retry)
+	}
+	if cfg.NodeHooks != nil {
+		SetObjectDefaults_NodeHooksConfig(cfg.NodeHooks)
+	}
+}
+
+func SetObjectDefaults_NodeHooksConfig(cfg *NodeHooksConfig) {
+	if cfg.FailurePolicy == "" {
+		cfg.FailurePolicy = DefaultNodeHooksFailurePolicy
+	}
+	if cfg.Timeout == "" {
+		cfg.Timeout = DefaultNodeHooksTimeout
+	}
+}
+
+func SetObjectDefaults_RetryConfig(cfg *RetryConfig) {
+	if cfg.MaxInterval == "" {
+		cfg.MaxInterval = DefaultRetryMaxInterval
+	}
+	if cfg.KubeadmAttempts == 0 {
+		cfg.KubeadmAttempts = DefaultRetryKubeadmAttempts
+	}
+	if cfg.RebaseAttempts == 0 {
+		cfg.RebaseAttempts = DefaultRetryRebaseAttempts
+	}
+}
+
+func SetObjectDefaults_KubeadmCacheConfig(cfg *KubeadmCacheConfig) {
+	if cfg.MaxEntries == 0 {
+		cfg.MaxEntries = DefaultKubeadmCacheMaxEntries
+	}
+	if cfg.MaxSize == nil {
+		maxSize := resource.MustParse(DefaultKubeadmCacheMaxSize)
+		cfg.MaxSize = &maxSize
+	}
+}
+
+func SetObjectDefaults_KubeadmDownloadConfig(cfg *KubeadmDownloadConfig) {
+	if cfg.Source == "" {
+		cfg.Source = KubeadmSourceURL
+	}
+	if cfg.URL == "" {
+		cfg.URL = DefaultKubeadmDownloadURL
+	}
+	if cfg.ImagePath == "" {
+		cfg.ImagePath = DefaultKubeadmImagePath
+	}
+	if cfg.Verification == "" {
+		cfg.Verification = KubeadmVerificationSignature
+	}
+	if cfg.CertificateIdentity == "" {
+		cfg.CertificateIdentity = DefaultKubeadmCertificateIdentity
+	}
+	if cfg.RekorURL == "" {
+		cfg.RekorURL = DefaultKubeadmRekorURL
+	}
+	if cfg.CertificateOIDCIssuer == "" {
+		cfg.CertificateOIDCIssuer = DefaultKubeadmCertificateOIDCIssuer
+	}
 }
@@ -1,21 +1,62 @@
 package v1alpha3
 
 import (
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 const (
 	PlanStatusUnknown     = "Unknown"
 	PlanStatusProgressing = "Progressing"
+	PlanStatusStalled     = "Stalled"
 	PlanStatusWaiting     = "Waiting"
 	PlanStatusComplete    = "Complete"
 	PlanStatusError       = "Error"
+	PlanStatusDryRun      = "DryRun"
+)
+
+const (
+	// The rollout is stuck, as nodes exceeded the timeout of their phase or have no live daemon
+	PlanConditionStalled = "Stalled"
+
+	PlanReasonNodeTimeout  = "NodeTimeout"
+	PlanReasonNoLiveDaemon = "NoLiveDaemon"
+	PlanReasonNoTimeout    = "NoTimeout"
+
+	// Nodes used up their upgrade attempts, but the groups completed within their failure budget
+	PlanConditionNodesFailed = "NodesFailed"
+
+	PlanReasonAttemptsExhausted = "AttemptsExhausted"
+	PlanReasonNoFailedNodes     = "NoFailedNodes"
+)
+
+const (
+	HookPhaseRunning   = "Running"
+	HookPhaseSucceeded = "Succeeded"
+	HookPhaseFailed    = "Failed"
+)
+
+const (
+	KubeadmSourceURL   = "url"
+	KubeadmSourceImage = "image"
+
+	KubeadmVerificationSignature = "signature"
+	KubeadmVerificationChecksum  = "checksum"
+)
+
+const (
+	NodeHooksFailurePolicyError  = "Error"
+	NodeHooksFailurePolicyAbort  = "Abort"
+	NodeHooksFailurePolicyIgnore = "Ignore"
 )
 
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +kubebuilder:printcolumn:JSONPath=.spec.kubernetesVersion,name=Version,type=string,description="The targeted kubernetes version"
+// +kubebuilder:printcolumn:JSONPath=.status.kubernetesVersion,name=Resolved,type=string,priority=1,description="The kubernetes version resolved from the version channel"
 // +kubebuilder:printcolumn:JSONPath=.status.summary,name=Status,type=string,description="A summary of the overall status of the cluster"
 // +kubebuilder:resource:scope=Cluster,shortName=plan
 // +kubebuilder:object:root=true
@@ -36,16 +77,35 @@ type KubeUpgradePlan struct {
 type KubeUpgradeSpec struct {
 	// The kubernetes version the cluster should be at.
 	// If the actual version differs, the cluster will be upgraded.
+	// Can also be a version channel like "stable-1.35" or "latest-1.35", in which case the cluster follows the newest patch release of the channel.
 	// +required
-	// +kubebuilder:example=v1.31.0
+	// +kubebuilder:example=v1.31.0;stable-1.35
 	KubernetesVersion string `json:"kubernetesVersion"`
 
+	// Configure how version channels are resolved. Only used when kubernetesVersion is a version channel.
+	// +optional
+	// +nullable
+	VersionChannel *VersionChannelConfig `json:"versionChannel,omitempty"`
+
 	// Allow downgrading to older kubernetes versions.
 	// Only enable if you know what you are doing.
 	// +optional
 	// +default=false
 	AllowDowngrade bool `json:"allowDowngrade,omitempty"`
 
+	// Allow changing the kubernetes version while a previous rollout is still in progress.
+	// Only applies to the update changing the version and has to be unset again afterwards.
+	// Only enable if you know what you are doing.
+	// +optional
+	// +default=false
+	Force bool `json:"force,omitempty"`
+
+	// Compute the rollout without changing any nodes, ConfigMaps or DaemonSets.
+	// The rollout the controller would perform is shown in status.dryRun.
+	// +optional
+	// +default=false
+	DryRun bool `json:"dryRun,omitempty"`
+
 	// The different groups in which the nodes will be upgraded.
 	// At minimum needs to separate control-plane from compute nodes, to ensure that control-plane nodes will be upgraded first.
 	// +required
@@ -55,6 +115,57 @@ type KubeUpgradeSpec struct {
 	// The configuration for all upgraded daemons. Can be overwritten by group specific config.
 	// +required
 	Upgraded UpgradedConfig `json:"upgraded,omitempty"`
+
+	// The maximum time a node may spend in each phase of the upgrade.
+	// Nodes exceeding it are set to error and retried according to the failure policy of their group.
+	// +optional
+	// +nullable
+	Timeouts *PhaseTimeouts `json:"timeouts,omitempty"`
+
+	// Job to run in the namespace of the controller before the first node is upgraded to a new version.
+	// No group is upgraded until the Job succeeded. Delete a failed Job to run it again.
+	// +optional
+	// +nullable
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	PreUpgrade *batchv1.JobTemplateSpec `json:"preUpgrade,omitempty"`
+
+	// Job to run in the namespace of the controller after all groups completed the upgrade.
+	// The plan is complete once the Job succeeded. Delete a failed Job to run it again.
+	// +optional
+	// +nullable
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	PostUpgrade *batchv1.JobTemplateSpec `json:"postUpgrade,omitempty"`
+}
+
+// Phases without a timeout are never timed out.
+type PhaseTimeouts struct {
+	// The time a node may stay pending before the daemon starts the upgrade, including the wait for the fleetlock.
+	// +optional
+	// +kubebuilder:validation:Format=go-duration
+	// +kubebuilder:example="6h"
+	Pending string `json:"pending,omitempty"`
+
+	// The time a node may spend upgrading kubernetes with kubeadm.
+	// +optional
+	// +kubebuilder:validation:Format=go-duration
+	// +kubebuilder:example="1h;30m"
+	Upgrading string `json:"upgrading,omitempty"`
+
+	// The time a node may spend rebasing, including the reboot until the new version booted.
+	// +optional
+	// +kubebuilder:validation:Format=go-duration
+	// +kubebuilder:example="1h;30m"
+	Rebasing string `json:"rebasing,omitempty"`
+
+	// The time a node may spend verifying the new version after it booted, including the post-boot node hooks.
+	// +optional
+	// +kubebuilder:validation:Format=go-duration
+	// +kubebuilder:example="30m"
+	Verifying string `json:"verifying,omitempty"`
 }
 
 type KubeUpgradePlanGroup struct {
@@ -79,14 +190,177 @@ type KubeUpgradePlanGroup struct {
 	// +optional
 	// +nullable
 	Upgraded *UpgradedConfig `json:"upgraded,omitempty"`
+
+	// How the controller handles nodes that failed to upgrade.
+	// +optional
+	// +nullable
+	FailurePolicy *FailurePolicy `json:"failurePolicy,omitempty"`
+
+	// Job to run in the namespace of the controller before the first node of the group is upgraded to a new version.
+	// Runs after the dependencies of the group completed, the nodes of the group are not upgraded until the Job succeeded.
+	// +optional
+	// +nullable
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	PreUpgrade *batchv1.JobTemplateSpec `json:"preUpgrade,omitempty"`
+
+	// Job to run in the namespace of the controller after all nodes of the group are upgraded.
+	// The group, and with it the groups depending on it, only completes once the Job succeeded.
+	// +optional
+	// +nullable
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	PostUpgrade *batchv1.JobTemplateSpec `json:"postUpgrade,omitempty"`
+}
+
+type FailurePolicy struct {
+	// The maximum number of upgrade attempts per node. Errored nodes are reset to pending until it is reached.
+	// Set to 1 to disable automatic retries.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:example=3
+	MaxAttempts int32 `json:"maxAttempts,omitempty"`
+
+	// The time to wait before retrying an errored node, doubled with every attempt.
+	// +optional
+	// +kubebuilder:validation:Format=go-duration
+	// +kubebuilder:example="5m;1h"
+	Backoff string `json:"backoff,omitempty"`
+
+	// The number or percentage of nodes in the group that may fail after all attempts.
+	// The group completes despite the failed nodes as long as they are within the budget,
+	// otherwise the group and all groups depending on it are stopped.
+	// +optional
+	// +kubebuilder:validation:XIntOrString
+	// +kubebuilder:example="1;10%"
+	MaxFailures *intstr.IntOrString `json:"maxFailures,omitempty"`
+}
+
+type VersionChannelConfig struct {
+	// The base URL serving the channel files in the format of the upstream kubernetes releases, e.g. "<url>/stable-1.35.txt".
+	// +optional
+	// +kubebuilder:example="https://dl.k8s.io/release"
+	URL string `json:"url,omitempty"`
+
+	// Name of a ConfigMap in the namespace of the controller that maps channels to versions, e.g. "stable-1.35: v1.35.2".
+	// Takes precedence over the url, intended for air-gapped setups.
+	// +optional
+	// +kubebuilder:example="kube-upgrade-channels"
+	ConfigMap string `json:"configMap,omitempty"`
+
+	// The interval between checks for new releases in the channel
+	// +optional
+	// +kubebuilder:validation:Format=go-duration
+	// +kubebuilder:example="1h;24h"
+	Interval string `json:"interval,omitempty"`
 }
 
 type KubeUpgradeStatus struct {
 	// A summary of the overall status of the cluster
 	Summary string `json:"summary,omitempty"`
 
+	// The kubernetes version the cluster is upgraded to.
+	// Contains the resolved version when spec.kubernetesVersion is a version channel.
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+
+	// The last time the version channel has been resolved successfully
+	// +optional
+	// +nullable
+	LastChannelCheck *metav1.Time `json:"lastChannelCheck,omitempty"`
+
 	// The current status of each group
 	Groups map[string]string `json:"groups,omitempty"`
+
+	// The hooks of the plan for the current rollout
+	// +optional
+	// +nullable
+	Hooks *HooksStatus `json:"hooks,omitempty"`
+
+	// The hooks of each group for the current rollout
+	// +optional
+	GroupHooks map[string]HooksStatus `json:"groupHooks,omitempty"`
+
+	// The rollout the controller would perform, only set when spec.dryRun is enabled
+	// +optional
+	// +nullable
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// The latest observations of the state of the plan
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+type HooksStatus struct {
+	// The kubernetes version of the rollout the hooks run for.
+	// Hooks run once per version, starting when the first node needs to be upgraded to it.
+	KubernetesVersion string `json:"kubernetesVersion"`
+
+	// The state of the preUpgrade hook, unset when there is none or it has not started yet
+	// +optional
+	// +nullable
+	PreUpgrade *HookStatus `json:"preUpgrade,omitempty"`
+
+	// The state of the postUpgrade hook, unset when there is none or it has not started yet
+	// +optional
+	// +nullable
+	PostUpgrade *HookStatus `json:"postUpgrade,omitempty"`
+}
+
+type HookStatus struct {
+	// The name of the Job running the hook
+	Job string `json:"job"`
+
+	// The phase of the Job
+	// +kubebuilder:validation:Enum=Running;Succeeded;Failed
+	Phase string `json:"phase"`
+
+	// The reason the Job failed
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// The time the Job has been created
+	// +optional
+	// +nullable
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// The time the Job succeeded or failed
+	// +optional
+	// +nullable
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+type DryRunStatus struct {
+	// The kubernetes version the nodes would be upgraded to
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+
+	// The rollout of each group
+	Groups map[string]DryRunGroupStatus `json:"groups,omitempty"`
+}
+
+type DryRunGroupStatus struct {
+	// The step in which the group would be upgraded, starting at 1.
+	// Groups with the same step are upgraded in parallel, after all groups of the previous steps completed.
+	// Is 0 if the group can never be upgraded due to circular dependencies.
+	Step int32 `json:"step"`
+
+	// The dependencies that have not completed yet, the group would wait on them
+	// +optional
+	// +listType=atomic
+	WaitingOn []string `json:"waitingOn,omitempty"`
+
+	// The nodes that would be upgraded, one at a time as coordinated by fleetlock
+	// +optional
+	// +listType=atomic
+	Nodes []string `json:"nodes,omitempty"`
+
+	// The nodes that are newer than the target version and would fail the rollout, as downgrades are disabled
+	// +optional
+	// +listType=atomic
+	DowngradeNodes []string `json:"downgradeNodes,omitempty"`
 }
 
 type UpgradedConfig struct {
@@ -122,11 +396,19 @@ type UpgradedConfig struct {
 	// +kubebuilder:example="debug;info;warn;error"
 	LogLevel string `json:"logLevel,omitempty"`
 
-	// The path to the kubelet config file on the node
+	// The path to the kubelet config file on the node.
+	// Only used when no serviceAccountName is provided.
 	// +optional
 	// +kubebuilder:example="/etc/kubernetes/kubelet.conf"
 	KubeletConfig string `json:"kubeletConfig,omitempty"`
 
+	// Name of a ServiceAccount in the namespace of the controller.
+	// When set, upgraded uses the token of the ServiceAccount instead of the kubelet credentials of the node.
+	// The ServiceAccount needs to be allowed to update nodes and read the kubeadm-config.
+	// +optional
+	// +kubebuilder:example="kube-upgraded"
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
 	// The path to the kubeadm binary on the node. Upgraded will download kubeadm if no path is provided.
 	// +optional
 	// +kubebuilder:example="/usr/bin/kubeadm"
@@ -135,6 +417,205 @@ type UpgradedConfig struct {
 	// Allow unsigned ostree images for rebase. It is recommended to use signed images instead.
 	// +optional
 	AllowUnsignedOstreeImages bool `json:"allowUnsignedOstreeImages,omitempty"`
+
+	// Taint nodes with NoSchedule while they are upgrading or rebasing.
+	// Requires serviceAccountName, as nodes may not change their own taints with the kubelet credentials.
+	// +optional
+	TaintUpgradingNodes bool `json:"taintUpgradingNodes,omitempty"`
+
+	// Name of a Secret of type kubernetes.io/dockerconfigjson in the namespace of the controller.
+	// The credentials are used when pulling the stream images and are updated on the host when the Secret changes.
+	// +optional
+	// +kubebuilder:example="fcos-k8s-pull-secret"
+	PullSecret string `json:"pullSecret,omitempty"`
+
+	// The signature policy for the stream images. It is installed on the host and checked before rebasing.
+	// +optional
+	// +nullable
+	SignaturePolicy *SignaturePolicy `json:"signaturePolicy,omitempty"`
+
+	// Configure where kubeadm is acquired from when no kubeadmPath is provided.
+	// +optional
+	// +nullable
+	KubeadmDownload *KubeadmDownloadConfig `json:"kubeadmDownload,omitempty"`
+
+	// Limits for the cache of kubeadm binaries on the host.
+	// +optional
+	// +nullable
+	KubeadmCache *KubeadmCacheConfig `json:"kubeadmCache,omitempty"`
+
+	// Configure the backoff and the attempts when an operation fails.
+	// +optional
+	// +nullable
+	Retry *RetryConfig `json:"retry,omitempty"`
+
+	// Hooks run by upgraded on the host around each phase of the upgrade.
+	// +optional
+	// +nullable
+	NodeHooks *NodeHooksConfig `json:"nodeHooks,omitempty"`
+}
+
+type NodeHooksConfig struct {
+	// Directory on the host with executables to run, in lexical order.
+	// +optional
+	// +kubebuilder:example="/etc/kube-upgraded/hooks.d"
+	Dir string `json:"dir,omitempty"`
+
+	// Name of a ConfigMap in the namespace of the controller. Every key is a shell script to run, in lexical order after the executables of dir.
+	// +optional
+	// +kubebuilder:example="kube-upgraded-hooks"
+	ConfigMap string `json:"configMap,omitempty"`
+
+	// What happens when a hook exits with an error.
+	// "Error" sets the node to error, so the controller retries it according to the failure policy of the group.
+	// "Abort" stops the current attempt, upgraded tries again after the retry interval.
+	// "Ignore" only logs the error and continues with the upgrade.
+	// Failures before os upgrades always abort the os upgrade, unless they are ignored.
+	// +optional
+	// +kubebuilder:validation:Enum=Error;Abort;Ignore
+	// +kubebuilder:example="Error;Abort;Ignore"
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+
+	// The time a single hook may run, before it is killed and counts as failed.
+	// +optional
+	// +kubebuilder:validation:Format=go-duration
+	// +kubebuilder:example="10m;1h"
+	Timeout string `json:"timeout,omitempty"`
+}
+
+type RetryConfig struct {
+	// The maximum interval between retries. The interval starts at retryInterval and doubles after every failed attempt.
+	// +optional
+	// +kubebuilder:validation:Format=go-duration
+	// +kubebuilder:example="30m;1h"
+	MaxInterval string `json:"maxInterval,omitempty"`
+
+	// The attempts to acquire the fleetlock before giving up, 0 retries until successful.
+	// When upgrading the node, it is set to error afterwards.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:example=10
+	LockAttempts int32 `json:"lockAttempts,omitempty"`
+
+	// The attempts to acquire and run kubeadm before the node is set to error, default 1
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:example=3
+	KubeadmAttempts int32 `json:"kubeadmAttempts,omitempty"`
+
+	// The attempts to rebase the node before it is set to error, default 1
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:example=3
+	RebaseAttempts int32 `json:"rebaseAttempts,omitempty"`
+
+	// The attempts to check for and apply os upgrades, 0 retries until successful.
+	// Afterwards the daemon waits for the next regular check.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:example=10
+	OSCheckAttempts int32 `json:"osCheckAttempts,omitempty"`
+}
+
+type KubeadmCacheConfig struct {
+	// The maximum number of kubeadm versions to keep
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:example=3
+	MaxEntries int32 `json:"maxEntries,omitempty"`
+
+	// The maximum total size of the cached binaries
+	// +optional
+	// +kubebuilder:example="512Mi"
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+}
+
+type SignaturePolicy struct {
+	// PEM encoded public key the stream images are signed with.
+	// +optional
+	PublicKey string `json:"publicKey,omitempty"`
+
+	// Verify keyless signatures issued by fulcio instead of a public key.
+	// +optional
+	// +nullable
+	Keyless *KeylessSignaturePolicy `json:"keyless,omitempty"`
+}
+
+type KeylessSignaturePolicy struct {
+	// The email address in the signing certificate
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:example="release@example.com"
+	SubjectEmail string `json:"subjectEmail"`
+
+	// The OIDC issuer in the signing certificate
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:example="https://accounts.google.com"
+	OIDCIssuer string `json:"oidcIssuer"`
+
+	// PEM encoded certificates of the fulcio instance. Defaults to the sigstore public good instance.
+	// +optional
+	FulcioCA string `json:"fulcioCA,omitempty"`
+
+	// PEM encoded public key of the rekor instance. Defaults to the sigstore public good instance.
+	// +optional
+	RekorPublicKey string `json:"rekorPublicKey,omitempty"`
+}
+
+type KubeadmDownloadConfig struct {
+	// Where to get kubeadm from. Either download it from the url or extract it from the image of the target stream.
+	// Extracting it from the image requires signed images, so it can't be used with allowUnsignedOstreeImages.
+	// +optional
+	// +kubebuilder:validation:Enum=url;image
+	// +kubebuilder:example="url;image"
+	Source string `json:"source,omitempty"`
+
+	// The base url to download kubeadm from. The binary is expected under "<url>/<version>/bin/linux/<arch>/kubeadm".
+	// +optional
+	// +kubebuilder:example="https://dl.k8s.io/release"
+	URL string `json:"url,omitempty"`
+
+	// The proxy to use for downloads. Defaults to the proxy environment variables.
+	// +optional
+	// +kubebuilder:example="http://proxy.example.com:3128"
+	Proxy string `json:"proxy,omitempty"`
+
+	// Name of a Secret in the namespace of the controller used for downloads.
+	// Can contain the keys "ca.crt" for a custom CA, "username" and "password" for basic auth or "token" for bearer auth.
+	// +optional
+	// +kubebuilder:example="kubeadm-mirror"
+	Secret string `json:"secret,omitempty"`
+
+	// The path of the kubeadm binary inside the stream image
+	// +optional
+	// +kubebuilder:example="/usr/bin/kubeadm"
+	ImagePath string `json:"imagePath,omitempty"`
+
+	// How to verify downloaded binaries. Either with the signature and certificate or with the sha512 checksum published next to the binary.
+	// +optional
+	// +kubebuilder:validation:Enum=signature;checksum
+	// +kubebuilder:example="signature;checksum"
+	Verification string `json:"verification,omitempty"`
+
+	// The identity expected in the signing certificate
+	// +optional
+	// +kubebuilder:example="krel-staging@k8s-releng-prod.iam.gserviceaccount.com"
+	CertificateIdentity string `json:"certificateIdentity,omitempty"`
+
+	// The OIDC issuer expected in the signing certificate
+	// +optional
+	// +kubebuilder:example="https://accounts.google.com"
+	CertificateOIDCIssuer string `json:"certificateOidcIssuer,omitempty"`
+
+	// The rekor instance the signatures are looked up in, e.g. a mirror of the transparency log
+	// +optional
+	// +kubebuilder:example="https://rekor.sigstore.dev"
+	RekorURL string `json:"rekorUrl,omitempty"`
+
+	// PEM encoded public key of the rekor instance. Defaults to the key of the sigstore public good instance.
+	// +optional
+	RekorPublicKey string `json:"rekorPublicKey,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -1,11 +1,17 @@
 package v1alpha3
 
 import (
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"net/url"
+	"path"
 	"time"
 
 	"golang.org/x/mod/semver"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 func ValidateObject_KubeUpgradePlan(plan *KubeUpgradePlan) error {
@@ -13,11 +19,20 @@ func ValidateObject_KubeUpgradePlan(plan *KubeUpgradePlan) error {
 }
 
 func ValidateObject_KubeUpgradeSpec(spec KubeUpgradeSpec) error {
-	if !semver.IsValid(spec.KubernetesVersion) {
-		return fmt.Errorf("invalid input for spec.kubernetesVersion, \"%s\" is not a valid semantic version", spec.KubernetesVersion)
+	if !IsVersionChannel(spec.KubernetesVersion) {
+		if !semver.IsValid(spec.KubernetesVersion) {
+			return fmt.Errorf("invalid input for spec.kubernetesVersion, \"%s\" is not a valid semantic version or version channel", spec.KubernetesVersion)
+		}
+		if semver.Prerelease(spec.KubernetesVersion) == "" && semver.Canonical(spec.KubernetesVersion) != spec.KubernetesVersion {
+			return fmt.Errorf("invalid input for spec.kubernetesVersion, \"%s\" needs to be a full version like vX.Y.Z", spec.KubernetesVersion)
+		}
 	}
-	if semver.Prerelease(spec.KubernetesVersion) == "" && semver.Canonical(spec.KubernetesVersion) != spec.KubernetesVersion {
-		return fmt.Errorf("invalid input for spec.kubernetesVersion, \"%s\" needs to be a full version like vX.Y.Z", spec.KubernetesVersion)
+
+	if spec.VersionChannel != nil {
+		err := ValidateObject_VersionChannelConfig(*spec.VersionChannel)
+		if err != nil {
+			return err
+		}
 	}
 
 	if len(spec.Groups) < 1 {
@@ -40,12 +55,34 @@ func ValidateObject_KubeUpgradeSpec(spec KubeUpgradeSpec) error {
 				return fmt.Errorf("group \"%s\" has an invalid upgraded config: %v", name, err)
 			}
 		}
+
+		if group.FailurePolicy != nil {
+			err := ValidateObject_FailurePolicy(*group.FailurePolicy)
+			if err != nil {
+				return fmt.Errorf("group \"%s\" has an invalid failure policy: %v", name, err)
+			}
+		}
+
+		err := validateHooks(group.PreUpgrade, group.PostUpgrade)
+		if err != nil {
+			return fmt.Errorf("group \"%s\" has an invalid hook: %v", name, err)
+		}
 	}
 
 	err := ValidateObject_UpgradedConfig(spec.Upgraded)
 	if err != nil {
 		return err
 	}
+	if spec.Timeouts != nil {
+		err = ValidateObject_PhaseTimeouts(*spec.Timeouts)
+		if err != nil {
+			return err
+		}
+	}
+	err = validateHooks(spec.PreUpgrade, spec.PostUpgrade)
+	if err != nil {
+		return err
+	}
 	if spec.Upgraded.FleetlockURL == "" {
 		return fmt.Errorf("missing parameter spec.upgraded.fleetlockUrl")
 	}
@@ -82,5 +119,262 @@ func ValidateObject_UpgradedConfig(cfg UpgradedConfig) error {
 		}
 	}
 
+	if cfg.KubeadmDownload != nil {
+		err := ValidateObject_KubeadmDownloadConfig(*cfg.KubeadmDownload)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.SignaturePolicy != nil {
+		err := ValidateObject_SignaturePolicy(*cfg.SignaturePolicy)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.KubeadmCache != nil {
+		if cfg.KubeadmCache.MaxEntries < 0 {
+			return fmt.Errorf("invalid input \"%d\" for kubeadmCache.maxEntries, needs to be at least 1", cfg.KubeadmCache.MaxEntries)
+		}
+		if cfg.KubeadmCache.MaxSize != nil && cfg.KubeadmCache.MaxSize.Sign() <= 0 {
+			return fmt.Errorf("invalid input \"%s\" for kubeadmCache.maxSize, needs to be greater than 0", cfg.KubeadmCache.MaxSize.String())
+		}
+	}
+
+	if cfg.Retry != nil {
+		err := ValidateObject_RetryConfig(*cfg.Retry)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.NodeHooks != nil {
+		err := ValidateObject_NodeHooksConfig(*cfg.NodeHooks)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ValidateObject_NodeHooksConfig(cfg NodeHooksConfig) error {
+	if cfg.Dir != "" && !path.IsAbs(cfg.Dir) {
+		return fmt.Errorf("invalid input \"%s\" for nodeHooks.dir, needs to be an absolute path", cfg.Dir)
+	}
+
+	switch cfg.FailurePolicy {
+	case "", NodeHooksFailurePolicyError, NodeHooksFailurePolicyAbort, NodeHooksFailurePolicyIgnore:
+	default:
+		return fmt.Errorf("invalid input \"%s\" for nodeHooks.failurePolicy, needs to be one of [%s, %s, %s]", cfg.FailurePolicy, NodeHooksFailurePolicyError, NodeHooksFailurePolicyAbort, NodeHooksFailurePolicyIgnore)
+	}
+
+	if cfg.Timeout != "" {
+		timeout, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid input \"%s\" for nodeHooks.timeout: %v", cfg.Timeout, err)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("invalid input \"%s\" for nodeHooks.timeout, needs to be greater than 0", cfg.Timeout)
+		}
+	}
+	return nil
+}
+
+func validateHooks(pre, post *batchv1.JobTemplateSpec) error {
+	for name, template := range map[string]*batchv1.JobTemplateSpec{
+		"preUpgrade":  pre,
+		"postUpgrade": post,
+	} {
+		if template == nil {
+			continue
+		}
+		podSpec := template.Spec.Template.Spec
+		if len(podSpec.Containers) < 1 {
+			return fmt.Errorf("%s needs at least one container", name)
+		}
+		switch podSpec.RestartPolicy {
+		case "", corev1.RestartPolicyNever, corev1.RestartPolicyOnFailure:
+		default:
+			return fmt.Errorf("invalid input \"%s\" for %s.spec.template.spec.restartPolicy, needs to be one of [%s, %s]", podSpec.RestartPolicy, name, corev1.RestartPolicyNever, corev1.RestartPolicyOnFailure)
+		}
+	}
+	return nil
+}
+
+func ValidateObject_PhaseTimeouts(timeouts PhaseTimeouts) error {
+	for name, value := range map[string]string{
+		"pending":   timeouts.Pending,
+		"upgrading": timeouts.Upgrading,
+		"rebasing":  timeouts.Rebasing,
+		"verifying": timeouts.Verifying,
+	} {
+		if value == "" {
+			continue
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid input \"%s\" for timeouts.%s: %v", value, name, err)
+		}
+		if timeout < 0 {
+			return fmt.Errorf("invalid input \"%s\" for timeouts.%s, can't be negative", value, name)
+		}
+	}
+	return nil
+}
+
+func ValidateObject_RetryConfig(cfg RetryConfig) error {
+	if cfg.MaxInterval != "" {
+		maxInterval, err := time.ParseDuration(cfg.MaxInterval)
+		if err != nil {
+			return fmt.Errorf("invalid input \"%s\" for retry.maxInterval: %v", cfg.MaxInterval, err)
+		}
+		if maxInterval <= 0 {
+			return fmt.Errorf("invalid input \"%s\" for retry.maxInterval, needs to be greater than 0", cfg.MaxInterval)
+		}
+	}
+
+	if cfg.LockAttempts < 0 {
+		return fmt.Errorf("invalid input \"%d\" for retry.lockAttempts, can't be negative", cfg.LockAttempts)
+	}
+	if cfg.KubeadmAttempts < 0 {
+		return fmt.Errorf("invalid input \"%d\" for retry.kubeadmAttempts, needs to be at least 1", cfg.KubeadmAttempts)
+	}
+	if cfg.RebaseAttempts < 0 {
+		return fmt.Errorf("invalid input \"%d\" for retry.rebaseAttempts, needs to be at least 1", cfg.RebaseAttempts)
+	}
+	if cfg.OSCheckAttempts < 0 {
+		return fmt.Errorf("invalid input \"%d\" for retry.osCheckAttempts, can't be negative", cfg.OSCheckAttempts)
+	}
+
+	return nil
+}
+
+func ValidateObject_KubeadmDownloadConfig(cfg KubeadmDownloadConfig) error {
+	switch cfg.Source {
+	case "", KubeadmSourceURL, KubeadmSourceImage:
+	default:
+		return fmt.Errorf("invalid input \"%s\" for kubeadmDownload.source, needs to be one of [%s, %s]", cfg.Source, KubeadmSourceURL, KubeadmSourceImage)
+	}
+
+	if cfg.URL != "" {
+		_, err := url.ParseRequestURI(cfg.URL)
+		if err != nil {
+			return fmt.Errorf("invalid input \"%s\" for kubeadmDownload.url: %v", cfg.URL, err)
+		}
+	}
+
+	if cfg.Proxy != "" {
+		_, err := url.ParseRequestURI(cfg.Proxy)
+		if err != nil {
+			return fmt.Errorf("invalid input \"%s\" for kubeadmDownload.proxy: %v", cfg.Proxy, err)
+		}
+	}
+
+	switch cfg.Verification {
+	case "", KubeadmVerificationSignature, KubeadmVerificationChecksum:
+	default:
+		return fmt.Errorf("invalid input \"%s\" for kubeadmDownload.verification, needs to be one of [%s, %s]", cfg.Verification, KubeadmVerificationSignature, KubeadmVerificationChecksum)
+	}
+
+	if cfg.RekorURL != "" {
+		_, err := url.ParseRequestURI(cfg.RekorURL)
+		if err != nil {
+			return fmt.Errorf("invalid input \"%s\" for kubeadmDownload.rekorUrl: %v", cfg.RekorURL, err)
+		}
+	}
+	if cfg.RekorPublicKey != "" {
+		block, _ := pem.Decode([]byte(cfg.RekorPublicKey))
+		if block == nil {
+			return fmt.Errorf("invalid input for kubeadmDownload.rekorPublicKey, no PEM encoded key found")
+		}
+	}
+
+	return nil
+}
+
+func ValidateObject_VersionChannelConfig(cfg VersionChannelConfig) error {
+	if cfg.URL != "" {
+		_, err := url.ParseRequestURI(cfg.URL)
+		if err != nil {
+			return fmt.Errorf("invalid input \"%s\" for versionChannel.url: %v", cfg.URL, err)
+		}
+	}
+
+	if cfg.Interval != "" {
+		_, err := time.ParseDuration(cfg.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid input \"%s\" for versionChannel.interval: %v", cfg.Interval, err)
+		}
+	}
+
+	return nil
+}
+
+func ValidateObject_FailurePolicy(policy FailurePolicy) error {
+	if policy.MaxAttempts < 0 {
+		return fmt.Errorf("invalid input \"%d\" for failurePolicy.maxAttempts, needs to be at least 1", policy.MaxAttempts)
+	}
+
+	if policy.Backoff != "" {
+		backoff, err := time.ParseDuration(policy.Backoff)
+		if err != nil {
+			return fmt.Errorf("invalid input \"%s\" for failurePolicy.backoff: %v", policy.Backoff, err)
+		}
+		if backoff < 0 {
+			return fmt.Errorf("invalid input \"%s\" for failurePolicy.backoff, can't be negative", policy.Backoff)
+		}
+	}
+
+	if policy.MaxFailures != nil {
+		maxFailures, err := intstr.GetScaledValueFromIntOrPercent(policy.MaxFailures, 100, false)
+		if err != nil {
+			return fmt.Errorf("invalid input \"%s\" for failurePolicy.maxFailures: %v", policy.MaxFailures.String(), err)
+		}
+		if maxFailures < 0 {
+			return fmt.Errorf("invalid input \"%s\" for failurePolicy.maxFailures, can't be negative", policy.MaxFailures.String())
+		}
+	}
+
+	return nil
+}
+
+func ValidateObject_SignaturePolicy(policy SignaturePolicy) error {
+	if (policy.PublicKey == "") == (policy.Keyless == nil) {
+		return fmt.Errorf("invalid input for signaturePolicy, exactly one of publicKey or keyless needs to be set")
+	}
+
+	if policy.PublicKey != "" {
+		block, _ := pem.Decode([]byte(policy.PublicKey))
+		if block == nil {
+			return fmt.Errorf("invalid input for signaturePolicy.publicKey, no PEM encoded key found")
+		}
+		_, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("invalid input for signaturePolicy.publicKey: %v", err)
+		}
+		return nil
+	}
+
+	if policy.Keyless.SubjectEmail == "" {
+		return fmt.Errorf("invalid input for signaturePolicy.keyless.subjectEmail, can't be empty")
+	}
+	if policy.Keyless.OIDCIssuer == "" {
+		return fmt.Errorf("invalid input for signaturePolicy.keyless.oidcIssuer, can't be empty")
+	}
+	if policy.Keyless.FulcioCA != "" {
+		block, _ := pem.Decode([]byte(policy.Keyless.FulcioCA))
+		if block == nil {
+			return fmt.Errorf("invalid input for signaturePolicy.keyless.fulcioCA, no PEM encoded certificate found")
+		}
+	}
+	if policy.Keyless.RekorPublicKey != "" {
+		block, _ := pem.Decode([]byte(policy.Keyless.RekorPublicKey))
+		if block == nil {
+			return fmt.Errorf("invalid input for signaturePolicy.keyless.rekorPublicKey, no PEM encoded key found")
+		}
+	}
+
 	return nil
 }
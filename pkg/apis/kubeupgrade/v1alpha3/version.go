@@ -0,0 +1,23 @@
+package v1alpha3
+
+import (
+	"regexp"
+	"strings"
+)
+
+var versionChannelRegex = regexp.MustCompile(`^(stable|latest)-[1-9][0-9]*\.(0|[1-9][0-9]*)$`)
+
+// Check if the given version is a version channel like "stable-1.35" instead of a fixed version.
+func IsVersionChannel(version string) bool {
+	return versionChannelRegex.MatchString(version)
+}
+
+// Return the version the channel is pinned to in the form "vX.Y".
+// Returns an empty string when the given version is not a channel.
+func VersionChannelMajorMinor(channel string) string {
+	if !IsVersionChannel(channel) {
+		return ""
+	}
+	_, majorMinor, _ := strings.Cut(channel, "-")
+	return "v" + majorMinor
+}
@@ -6,10 +6,154 @@
 package v1alpha3
 
 import (
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DryRunGroupStatus) DeepCopyInto(out *DryRunGroupStatus) {
+	*out = *in
+	if in.WaitingOn != nil {
+		in, out := &in.WaitingOn, &out.WaitingOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DowngradeNodes != nil {
+		in, out := &in.DowngradeNodes, &out.DowngradeNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DryRunGroupStatus.
+func (in *DryRunGroupStatus) DeepCopy() *DryRunGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DryRunGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DryRunStatus) DeepCopyInto(out *DryRunStatus) {
+	*out = *in
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make(map[string]DryRunGroupStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DryRunStatus.
+func (in *DryRunStatus) DeepCopy() *DryRunStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DryRunStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailurePolicy) DeepCopyInto(out *FailurePolicy) {
+	*out = *in
+	if in.MaxFailures != nil {
+		in, out := &in.MaxFailures, &out.MaxFailures
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailurePolicy.
+func (in *FailurePolicy) DeepCopy() *FailurePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(FailurePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HookStatus) DeepCopyInto(out *HookStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HookStatus.
+func (in *HookStatus) DeepCopy() *HookStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HookStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HooksStatus) DeepCopyInto(out *HooksStatus) {
+	*out = *in
+	if in.PreUpgrade != nil {
+		in, out := &in.PreUpgrade, &out.PreUpgrade
+		*out = new(HookStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PostUpgrade != nil {
+		in, out := &in.PostUpgrade, &out.PostUpgrade
+		*out = new(HookStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HooksStatus.
+func (in *HooksStatus) DeepCopy() *HooksStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HooksStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeylessSignaturePolicy) DeepCopyInto(out *KeylessSignaturePolicy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeylessSignaturePolicy.
+func (in *KeylessSignaturePolicy) DeepCopy() *KeylessSignaturePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(KeylessSignaturePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KubeUpgradePlan) DeepCopyInto(out *KubeUpgradePlan) {
 	*out = *in
@@ -63,7 +207,22 @@ func (in *KubeUpgradePlanGroup) DeepCopyInto(out *KubeUpgradePlanGroup) {
 	if in.Upgraded != nil {
 		in, out := &in.Upgraded, &out.Upgraded
 		*out = new(UpgradedConfig)
-		**out = **in
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FailurePolicy != nil {
+		in, out := &in.FailurePolicy, &out.FailurePolicy
+		*out = new(FailurePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PreUpgrade != nil {
+		in, out := &in.PreUpgrade, &out.PreUpgrade
+		*out = new(batchv1.JobTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PostUpgrade != nil {
+		in, out := &in.PostUpgrade, &out.PostUpgrade
+		*out = new(batchv1.JobTemplateSpec)
+		(*in).DeepCopyInto(*out)
 	}
 	return
 }
@@ -114,6 +273,11 @@ func (in *KubeUpgradePlanList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KubeUpgradeSpec) DeepCopyInto(out *KubeUpgradeSpec) {
 	*out = *in
+	if in.VersionChannel != nil {
+		in, out := &in.VersionChannel, &out.VersionChannel
+		*out = new(VersionChannelConfig)
+		**out = **in
+	}
 	if in.Groups != nil {
 		in, out := &in.Groups, &out.Groups
 		*out = make(map[string]KubeUpgradePlanGroup, len(*in))
@@ -121,7 +285,22 @@ func (in *KubeUpgradeSpec) DeepCopyInto(out *KubeUpgradeSpec) {
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
-	out.Upgraded = in.Upgraded
+	in.Upgraded.DeepCopyInto(&out.Upgraded)
+	if in.Timeouts != nil {
+		in, out := &in.Timeouts, &out.Timeouts
+		*out = new(PhaseTimeouts)
+		**out = **in
+	}
+	if in.PreUpgrade != nil {
+		in, out := &in.PreUpgrade, &out.PreUpgrade
+		*out = new(batchv1.JobTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PostUpgrade != nil {
+		in, out := &in.PostUpgrade, &out.PostUpgrade
+		*out = new(batchv1.JobTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -138,6 +317,10 @@ func (in *KubeUpgradeSpec) DeepCopy() *KubeUpgradeSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KubeUpgradeStatus) DeepCopyInto(out *KubeUpgradeStatus) {
 	*out = *in
+	if in.LastChannelCheck != nil {
+		in, out := &in.LastChannelCheck, &out.LastChannelCheck
+		*out = (*in).DeepCopy()
+	}
 	if in.Groups != nil {
 		in, out := &in.Groups, &out.Groups
 		*out = make(map[string]string, len(*in))
@@ -145,6 +328,30 @@ func (in *KubeUpgradeStatus) DeepCopyInto(out *KubeUpgradeStatus) {
 			(*out)[key] = val
 		}
 	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = new(HooksStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GroupHooks != nil {
+		in, out := &in.GroupHooks, &out.GroupHooks
+		*out = make(map[string]HooksStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.DryRun != nil {
+		in, out := &in.DryRun, &out.DryRun
+		*out = new(DryRunStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -158,9 +365,140 @@ func (in *KubeUpgradeStatus) DeepCopy() *KubeUpgradeStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeadmCacheConfig) DeepCopyInto(out *KubeadmCacheConfig) {
+	*out = *in
+	if in.MaxSize != nil {
+		in, out := &in.MaxSize, &out.MaxSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeadmCacheConfig.
+func (in *KubeadmCacheConfig) DeepCopy() *KubeadmCacheConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeadmCacheConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeadmDownloadConfig) DeepCopyInto(out *KubeadmDownloadConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeadmDownloadConfig.
+func (in *KubeadmDownloadConfig) DeepCopy() *KubeadmDownloadConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeadmDownloadConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeHooksConfig) DeepCopyInto(out *NodeHooksConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeHooksConfig.
+func (in *NodeHooksConfig) DeepCopy() *NodeHooksConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeHooksConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PhaseTimeouts) DeepCopyInto(out *PhaseTimeouts) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PhaseTimeouts.
+func (in *PhaseTimeouts) DeepCopy() *PhaseTimeouts {
+	if in == nil {
+		return nil
+	}
+	out := new(PhaseTimeouts)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetryConfig) DeepCopyInto(out *RetryConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryConfig.
+func (in *RetryConfig) DeepCopy() *RetryConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SignaturePolicy) DeepCopyInto(out *SignaturePolicy) {
+	*out = *in
+	if in.Keyless != nil {
+		in, out := &in.Keyless, &out.Keyless
+		*out = new(KeylessSignaturePolicy)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SignaturePolicy.
+func (in *SignaturePolicy) DeepCopy() *SignaturePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(SignaturePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UpgradedConfig) DeepCopyInto(out *UpgradedConfig) {
 	*out = *in
+	if in.SignaturePolicy != nil {
+		in, out := &in.SignaturePolicy, &out.SignaturePolicy
+		*out = new(SignaturePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KubeadmDownload != nil {
+		in, out := &in.KubeadmDownload, &out.KubeadmDownload
+		*out = new(KubeadmDownloadConfig)
+		**out = **in
+	}
+	if in.KubeadmCache != nil {
+		in, out := &in.KubeadmCache, &out.KubeadmCache
+		*out = new(KubeadmCacheConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(RetryConfig)
+		**out = **in
+	}
+	if in.NodeHooks != nil {
+		in, out := &in.NodeHooks, &out.NodeHooks
+		*out = new(NodeHooksConfig)
+		**out = **in
+	}
 	return
 }
 
@@ -173,3 +511,19 @@ func (in *UpgradedConfig) DeepCopy() *UpgradedConfig {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VersionChannelConfig) DeepCopyInto(out *VersionChannelConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VersionChannelConfig.
+func (in *VersionChannelConfig) DeepCopy() *VersionChannelConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(VersionChannelConfig)
+	in.DeepCopyInto(out)
+	return out
+}
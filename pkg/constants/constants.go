@@ -10,19 +10,70 @@ const (
 	NodeKubernetesVersion = NodePrefix + "kubernetesVersion"
 	NodeUpgradeStatus     = NodePrefix + "status"
 	NodeUpgradedVersion   = NodePrefix + "upgradedVersion"
+	NodeUpgradeAttempts   = NodePrefix + "attempts"
+	NodeLastError         = NodePrefix + "lastError"
+	NodeLastErrorTime     = NodePrefix + "lastErrorTime"
+	NodeLastErrorReason   = NodePrefix + "lastErrorReason"
+	NodeStatusTime        = NodePrefix + "statusTime"
+	NodeHeartbeat         = NodePrefix + "heartbeat"
+	NodeLockHeld          = NodePrefix + "lockHeld"
+)
+
+// Set by operators to "true" to control the upgrade of a single node
+const (
+	// Exclude the node from the current rollout, without blocking the completion of its group
+	NodeHold = NodePrefix + "hold"
+	// Treat the node as upgraded by other means
+	NodeSkip = NodePrefix + "skip"
+	// Reset the node from error to pending, removed by the controller once handled
+	NodeRetry = NodePrefix + "retry"
 )
 
 const (
 	NodeUpgradeStatusPending   = "pending"
 	NodeUpgradeStatusRebasing  = "rebasing"
+	NodeUpgradeStatusVerifying = "verifying"
 	NodeUpgradeStatusUpgrading = "upgrading"
 	NodeUpgradeStatusCompleted = "completed"
 	NodeUpgradeStatusError     = "error"
 )
 
+// Reasons for a node to be in error
+const (
+	// The node exceeded the timeout of its phase
+	NodeErrorReasonTimeout = "Timeout"
+	// The fleetlock could not be acquired
+	NodeErrorReasonLockFailed = "LockFailed"
+	// The host can't verify the image of the stream
+	NodeErrorReasonStreamUnverifiable = "StreamUnverifiable"
+	// The credentials for the registry could not be synced to the host
+	NodeErrorReasonRegistryAuthFailed = "RegistryAuthFailed"
+	// kubeadm could not be downloaded or extracted from the image
+	NodeErrorReasonKubeadmDownloadFailed = "KubeadmDownloadFailed"
+	// The downloaded kubeadm was rejected by the signature or checksum verification
+	NodeErrorReasonKubeadmVerificationFailed = "KubeadmVerificationFailed"
+	// The kubeadm-config could not be fetched or parsed
+	NodeErrorReasonKubeadmConfigInvalid = "KubeadmConfigInvalid"
+	// kubeadm upgrade exited with an error
+	NodeErrorReasonKubeadmUpgradeFailed = "KubeadmUpgradeFailed"
+	// rpm-ostree failed to rebase the node
+	NodeErrorReasonRebaseFailed = "RebaseFailed"
+	// A node hook exited with an error
+	NodeErrorReasonHookFailed = "HookFailed"
+)
+
+// Set by upgraded while a node is upgrading or rebasing, so others can react with standard kubernetes primitives
+const (
+	// Node condition, true while the upgrade is in progress
+	NodeConditionUpgradeInProgress = "KubeUpgradeInProgress"
+	// NoSchedule taint, only set when enabled in the config of upgraded
+	NodeTaintUpgrading = BaseDomain + "upgrading"
+)
+
 const (
 	LabelPlanName  = BaseDomain + "plan"
 	LabelNodeGroup = BaseDomain + "group"
+	LabelHook      = BaseDomain + "hook"
 )
 
 const (
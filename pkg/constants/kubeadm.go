@@ -0,0 +1,13 @@
+package constants
+
+// The kubeadm-config written by kubeadm, read by the controller and upgraded
+const (
+	KubeadmConfigMapNamespace      = "kube-system"
+	KubeadmConfigMapName           = "kubeadm-config"
+	KubeadmClusterConfigurationKey = "ClusterConfiguration"
+)
+
+// The fields of the ClusterConfiguration in the kubeadm-config used by kube-upgrade
+type KubeadmClusterConfiguration struct {
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+}
@@ -0,0 +1,75 @@
+package kubectlupgrade
+
+import (
+	"fmt"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const defaultNamespace = "kube-upgrade"
+
+// Options for connecting to the cluster, shared by all commands
+type clientOptions struct {
+	Kubeconfig string
+	Context    string
+	Namespace  string
+
+	// Used instead of connecting to the cluster, when set
+	clients *clients
+}
+
+// The clients used by the commands
+type clients struct {
+	// Reads plans and nodes
+	client.Client
+	// Used for watches and logs, which are not supported by the controller-runtime client
+	kube kubernetes.Interface
+	// Namespace of kube-upgrade
+	namespace string
+}
+
+// Create the clients from the kubeconfig, following the same loading rules as kubectl
+func (o *clientOptions) newClients() (*clients, error) {
+	if o.clients != nil {
+		return o.clients, nil
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = o.Kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{
+		CurrentContext: o.Context,
+	}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %v", err)
+	}
+
+	scheme := runtime.NewScheme()
+	err = api.AddToScheme(scheme)
+	if err != nil {
+		return nil, err
+	}
+	err = clientgoscheme.AddToScheme(scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %v", err)
+	}
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %v", err)
+	}
+
+	return &clients{
+		Client:    c,
+		kube:      kube,
+		namespace: o.Namespace,
+	}, nil
+}
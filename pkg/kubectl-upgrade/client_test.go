@@ -0,0 +1,133 @@
+package kubectlupgrade
+
+import (
+	"testing"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestNode(name, group string, annotations map[string]string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{"node-role.kubernetes.io/" + group: "true"},
+			Annotations: annotations,
+		},
+		Status: corev1.NodeStatus{
+			NodeInfo: corev1.NodeSystemInfo{
+				KubeletVersion: "v1.30.0",
+			},
+		},
+	}
+}
+
+func newTestPlan() *api.KubeUpgradePlan {
+	return &api.KubeUpgradePlan{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "upgrade-plan",
+		},
+		Spec: api.KubeUpgradeSpec{
+			KubernetesVersion: "stable-1.31",
+			Groups: map[string]api.KubeUpgradePlanGroup{
+				"control-plane": {
+					Labels: map[string]string{"node-role.kubernetes.io/control-plane": "true"},
+				},
+				"compute": {
+					DependsOn: []string{"control-plane"},
+					Labels:    map[string]string{"node-role.kubernetes.io/compute": "true"},
+				},
+				"infra": {
+					Labels: map[string]string{"node-role.kubernetes.io/infra": "true"},
+				},
+			},
+		},
+		Status: api.KubeUpgradeStatus{
+			Summary:           api.PlanStatusProgressing + ": Upgrading groups [compute]",
+			KubernetesVersion: "v1.31.2",
+			Groups: map[string]string{
+				"control-plane": api.PlanStatusComplete,
+				"compute":       api.PlanStatusProgressing + ": 0/2 nodes upgraded",
+			},
+		},
+	}
+}
+
+func newTestNodes() []client.Object {
+	return []client.Object{
+		newTestNode("control-1", "control-plane", map[string]string{
+			constants.NodeKubernetesVersion: "v1.31.2",
+			constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusCompleted,
+			constants.NodeUpgradedVersion:   "v0.7.0",
+		}),
+		newTestNode("compute-2", "compute", map[string]string{
+			constants.NodeKubernetesVersion: "v1.31.2",
+			constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusError,
+		}),
+		newTestNode("compute-1", "compute", map[string]string{
+			constants.NodeKubernetesVersion: "v1.31.2",
+			constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusUpgrading,
+		}),
+	}
+}
+
+func newTestClients(t *testing.T, objs ...client.Object) *clients {
+	scheme := runtime.NewScheme()
+	require.NoError(t, api.AddToScheme(scheme))
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+
+	// The kubernetes client only knows the built-in types
+	runtimeObjs := make([]runtime.Object, 0, len(objs))
+	for _, obj := range objs {
+		if _, ok := obj.(*api.KubeUpgradePlan); !ok {
+			runtimeObjs = append(runtimeObjs, obj.DeepCopyObject())
+		}
+	}
+
+	return &clients{
+		Client:    fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		kube:      kubefake.NewClientset(runtimeObjs...),
+		namespace: defaultNamespace,
+	}
+}
+
+func TestNewClients(t *testing.T) {
+	t.Run("Kubeconfig", func(t *testing.T) {
+		assert := assert.New(t)
+
+		opts := &clientOptions{Kubeconfig: "testdata/kubeconfig.yaml", Namespace: "test"}
+
+		c, err := opts.newClients()
+
+		assert.NoError(err, "Should create the clients")
+		if !assert.NotNil(c, "Should return clients") {
+			t.FailNow()
+		}
+		assert.NotNil(c.Client, "Should create the client")
+		assert.NotNil(c.kube, "Should create the kubernetes client")
+		assert.Equal("test", c.namespace, "Should use the namespace")
+	})
+	t.Run("MissingKubeconfig", func(t *testing.T) {
+		opts := &clientOptions{Kubeconfig: "testdata/not-a-file.yaml"}
+
+		_, err := opts.newClients()
+
+		assert.Error(t, err, "Should fail to load the kubeconfig")
+	})
+	t.Run("UnknownContext", func(t *testing.T) {
+		opts := &clientOptions{Kubeconfig: "testdata/kubeconfig.yaml", Context: "not-a-context"}
+
+		_, err := opts.newClients()
+
+		assert.Error(t, err, "Should fail to find the context")
+	})
+}
@@ -0,0 +1,83 @@
+package kubectlupgrade
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Name of the container in the upgraded DaemonSets
+const upgradedContainer = "upgraded"
+
+// Create the command printing the log of upgraded on a node
+func newLogsCommand(opts *clientOptions) *cobra.Command {
+	var follow bool
+	var tail int64
+
+	cmd := &cobra.Command{
+		Use:   "logs <node>",
+		Short: "Print the log of the upgraded pod running on the node",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.newClients()
+			if err != nil {
+				return err
+			}
+			return printUpgradedLogs(cmd.Context(), cmd.OutOrStdout(), c.kube, c.namespace, args[0], follow, tail)
+		},
+	}
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Stream the log")
+	cmd.Flags().Int64Var(&tail, "tail", -1, "Number of lines to show from the end of the log, -1 shows all lines")
+
+	return cmd
+}
+
+// Copy the log of the upgraded pod on the node to the writer
+func printUpgradedLogs(ctx context.Context, out io.Writer, kube kubernetes.Interface, namespace, node string, follow bool, tail int64) error {
+	pod, err := findUpgradedPod(ctx, kube, namespace, node)
+	if err != nil {
+		return err
+	}
+
+	logOptions := &corev1.PodLogOptions{
+		Container: upgradedContainer,
+		Follow:    follow,
+	}
+	if tail >= 0 {
+		logOptions.TailLines = &tail
+	}
+
+	stream, err := kube.CoreV1().Pods(namespace).GetLogs(pod, logOptions).Stream(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get log of pod %s: %v", pod, err)
+	}
+	defer stream.Close()
+
+	_, err = io.Copy(out, stream)
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to read log of pod %s: %v", pod, err)
+	}
+	return nil
+}
+
+// Return the name of the upgraded pod running on the node
+func findUpgradedPod(ctx context.Context, kube kubernetes.Interface, namespace, node string) (string, error) {
+	pods, err := kube.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: constants.LabelPlanName,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list upgraded pods: %v", err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == node {
+			return pod.GetName(), nil
+		}
+	}
+	return "", fmt.Errorf("no upgraded pod found on node %s in namespace %s", node, namespace)
+}
@@ -0,0 +1,63 @@
+package kubectlupgrade
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newTestUpgradedPod(name, namespace, node string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.PodSpec{
+			NodeName: node,
+		},
+	}
+}
+
+func TestPrintUpgradedLogs(t *testing.T) {
+	upgradedLabels := map[string]string{
+		constants.LabelPlanName:  "upgrade-plan",
+		constants.LabelNodeGroup: "compute",
+	}
+	objs := []client.Object{
+		newTestUpgradedPod("upgraded-compute-abcde", defaultNamespace, "compute-1", upgradedLabels),
+		newTestUpgradedPod("other-pod", defaultNamespace, "compute-2", nil),
+		newTestUpgradedPod("upgraded-compute-fghij", "other", "compute-2", upgradedLabels),
+	}
+
+	t.Run("Found", func(t *testing.T) {
+		c := newTestClients(t, objs...)
+		var out bytes.Buffer
+
+		err := printUpgradedLogs(t.Context(), &out, c.kube, c.namespace, "compute-1", false, 10)
+
+		assert.NoError(t, err, "Should print the log")
+		assert.Equal(t, "fake logs", out.String(), "Should print the log of the pod")
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		c := newTestClients(t, objs...)
+
+		err := printUpgradedLogs(t.Context(), &bytes.Buffer{}, c.kube, c.namespace, "compute-2", false, -1)
+
+		assert.ErrorContains(t, err, "no upgraded pod found on node compute-2", "Should only use upgraded pods in the namespace")
+	})
+}
+
+func TestFindUpgradedPod(t *testing.T) {
+	c := newTestClients(t, newTestUpgradedPod("upgraded-compute-abcde", defaultNamespace, "compute-1", map[string]string{constants.LabelPlanName: "upgrade-plan"}))
+
+	pod, err := findUpgradedPod(t.Context(), c.kube, defaultNamespace, "compute-1")
+
+	assert.NoError(t, err, "Should find the pod")
+	assert.Equal(t, "upgraded-compute-abcde", pod)
+}
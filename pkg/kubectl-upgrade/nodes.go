@@ -0,0 +1,119 @@
+package kubectlupgrade
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const none = "<none>"
+
+// The kube-upgrade state of a node, read from its annotations
+type nodeState struct {
+	Name     string
+	Target   string
+	Phase    string
+	Upgraded string
+	Kubelet  string
+}
+
+func newNodeState(node *corev1.Node) nodeState {
+	return nodeState{
+		Name:     node.GetName(),
+		Target:   node.Annotations[constants.NodeKubernetesVersion],
+		Phase:    node.Annotations[constants.NodeUpgradeStatus],
+		Upgraded: node.Annotations[constants.NodeUpgradedVersion],
+		Kubelet:  node.Status.NodeInfo.KubeletVersion,
+	}
+}
+
+// Check if the kube-upgrade annotations of the nodes differ
+func (s nodeState) changed(other nodeState) bool {
+	return s.Target != other.Target || s.Phase != other.Phase || s.Upgraded != other.Upgraded
+}
+
+// Create the command listing the nodes with their kube-upgrade state
+func newNodesCommand(opts *clientOptions) *cobra.Command {
+	var selector string
+
+	cmd := &cobra.Command{
+		Use:   "nodes",
+		Short: "List the nodes with their target version, phase and upgraded version",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, err := opts.newClients()
+			if err != nil {
+				return err
+			}
+			labels, err := parseSelector(selector)
+			if err != nil {
+				return err
+			}
+			return printNodes(cmd.Context(), cmd.OutOrStdout(), c, labels)
+		},
+	}
+	cmd.Flags().StringVarP(&selector, "selector", "l", "", "Only list nodes matching the labels, e.g. key1=value1,key2=value2")
+
+	return cmd
+}
+
+// Print a table of the nodes matching the labels
+func printNodes(ctx context.Context, out io.Writer, c *clients, labels map[string]string) error {
+	nodes, err := listNodes(ctx, c, labels)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTARGET\tPHASE\tUPGRADED\tKUBELET")
+	for _, node := range nodes {
+		s := newNodeState(&node)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Name, orNone(s.Target), orNone(s.Phase), orNone(s.Upgraded), orNone(s.Kubelet))
+	}
+	return w.Flush()
+}
+
+// List the nodes matching the labels, sorted by name
+func listNodes(ctx context.Context, c *clients, labels map[string]string) ([]corev1.Node, error) {
+	nodeList := &corev1.NodeList{}
+	err := c.List(ctx, nodeList, client.MatchingLabels(labels))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+	slices.SortFunc(nodeList.Items, func(a, b corev1.Node) int {
+		return strings.Compare(a.GetName(), b.GetName())
+	})
+	return nodeList.Items, nil
+}
+
+// Parse a simple equality based label selector like key1=value1,key2=value2
+func parseSelector(selector string) (map[string]string, error) {
+	if selector == "" {
+		return nil, nil
+	}
+	labels := make(map[string]string)
+	for _, requirement := range strings.Split(selector, ",") {
+		key, value, ok := strings.Cut(requirement, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label selector \"%s\", expected key=value", requirement)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// Return the value or a placeholder if it is empty
+func orNone(value string) string {
+	if value == "" {
+		return none
+	}
+	return value
+}
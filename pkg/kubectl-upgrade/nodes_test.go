@@ -0,0 +1,69 @@
+package kubectlupgrade
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintNodes(t *testing.T) {
+	t.Run("All", func(t *testing.T) {
+		assert := assert.New(t)
+
+		c := newTestClients(t, newTestNodes()...)
+		var out bytes.Buffer
+
+		err := printNodes(t.Context(), &out, c, nil)
+
+		assert.NoError(err, "Should print the nodes")
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if !assert.Len(lines, 4, "Should print a header and all nodes") {
+			t.FailNow()
+		}
+		assert.Equal([]string{"NAME", "TARGET", "PHASE", "UPGRADED", "KUBELET"}, strings.Fields(lines[0]), "Should print the header")
+		assert.Equal([]string{"compute-1", "v1.31.2", "upgrading", none, "v1.30.0"}, strings.Fields(lines[1]), "Should sort the nodes")
+		assert.Equal([]string{"compute-2", "v1.31.2", "error", none, "v1.30.0"}, strings.Fields(lines[2]))
+		assert.Equal([]string{"control-1", "v1.31.2", "completed", "v0.7.0", "v1.30.0"}, strings.Fields(lines[3]))
+	})
+	t.Run("Selector", func(t *testing.T) {
+		c := newTestClients(t, newTestNodes()...)
+		var out bytes.Buffer
+
+		err := printNodes(t.Context(), &out, c, map[string]string{"node-role.kubernetes.io/control-plane": "true"})
+
+		require.NoError(t, err, "Should print the nodes")
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		assert.Len(t, lines, 2, "Should only print matching nodes")
+	})
+}
+
+func TestParseSelector(t *testing.T) {
+	tMatrix := []struct {
+		Name     string
+		Selector string
+		Result   map[string]string
+		Error    bool
+	}{
+		{Name: "Empty"},
+		{Name: "Single", Selector: "foo=bar", Result: map[string]string{"foo": "bar"}},
+		{Name: "Multiple", Selector: "foo=bar,node-role.kubernetes.io/compute=", Result: map[string]string{"foo": "bar", "node-role.kubernetes.io/compute": ""}},
+		{Name: "MissingValue", Selector: "foo", Error: true},
+		{Name: "MissingKey", Selector: "=bar", Error: true},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			labels, err := parseSelector(tCase.Selector)
+
+			if tCase.Error {
+				assert.Error(t, err, "Should fail to parse the selector")
+				return
+			}
+			assert.NoError(t, err, "Should parse the selector")
+			assert.Equal(t, tCase.Result, labels)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package kubectlupgrade
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Create the command marking errored nodes for retry, so upgraded retries the upgrade
+func newRetryCommand(opts *clientOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "retry <node>...",
+		Short: "Mark errored nodes for retry, the controller resets them to pending",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := opts.newClients()
+			if err != nil {
+				return err
+			}
+			for _, name := range args {
+				err = retryNode(cmd.Context(), c, name)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "node/%s marked for retry\n", name)
+			}
+			return nil
+		},
+	}
+}
+
+// Annotate the node for retry, the controller then resets it from error to pending and counts the attempt.
+// Fails if the node is not in the error status or has been changed concurrently.
+func retryNode(ctx context.Context, c *clients, name string) error {
+	node := &corev1.Node{}
+	err := c.Get(ctx, client.ObjectKey{Name: name}, node)
+	if err != nil {
+		return fmt.Errorf("failed to get node %s: %v", name, err)
+	}
+
+	status := node.Annotations[constants.NodeUpgradeStatus]
+	if status != constants.NodeUpgradeStatusError {
+		return fmt.Errorf("node %s has status \"%s\", only nodes with status \"%s\" can be retried", name, status, constants.NodeUpgradeStatusError)
+	}
+
+	patch := client.MergeFromWithOptions(node.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	node.Annotations[constants.NodeRetry] = "true"
+	err = c.Patch(ctx, node, patch)
+	if err != nil {
+		return fmt.Errorf("failed to update node %s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package kubectlupgrade
+
+import (
+	"testing"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRetryNode(t *testing.T) {
+	t.Run("ErroredNode", func(t *testing.T) {
+		c := newTestClients(t, newTestNodes()...)
+
+		err := retryNode(t.Context(), c, "compute-2")
+		require.NoError(t, err, "Should mark the node for retry")
+
+		node := &corev1.Node{}
+		require.NoError(t, c.Get(t.Context(), client.ObjectKey{Name: "compute-2"}, node))
+		assert.Equal(t, "true", node.Annotations[constants.NodeRetry], "Should request a retry")
+		assert.Equal(t, constants.NodeUpgradeStatusError, node.Annotations[constants.NodeUpgradeStatus], "Should leave resetting the status to the controller")
+		assert.Equal(t, "v1.31.2", node.Annotations[constants.NodeKubernetesVersion], "Should keep the target version")
+	})
+	t.Run("NotErrored", func(t *testing.T) {
+		c := newTestClients(t, newTestNodes()...)
+
+		err := retryNode(t.Context(), c, "compute-1")
+		assert.ErrorContains(t, err, "has status \"upgrading\"", "Should only reset errored nodes")
+
+		node := &corev1.Node{}
+		require.NoError(t, c.Get(t.Context(), client.ObjectKey{Name: "compute-1"}, node))
+		assert.Equal(t, constants.NodeUpgradeStatusUpgrading, node.Annotations[constants.NodeUpgradeStatus], "Should not change the status")
+		assert.NotContains(t, node.Annotations, constants.NodeRetry, "Should not request a retry")
+	})
+	t.Run("MissingNode", func(t *testing.T) {
+		c := newTestClients(t, newTestNodes()...)
+
+		err := retryNode(t.Context(), c, "not-a-node")
+
+		assert.Error(t, err, "Should fail for unknown nodes")
+	})
+}
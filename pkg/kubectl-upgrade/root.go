@@ -0,0 +1,57 @@
+package kubectlupgrade
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/version"
+
+	"github.com/spf13/cobra"
+)
+
+const Name = "kubectl-upgrade"
+
+func Execute() {
+	cmd := NewKubectlUpgrade()
+	err := cmd.Execute()
+	if err != nil {
+		slog.Error("Command exited with error", "err", err)
+		os.Exit(1)
+	}
+}
+
+func NewKubectlUpgrade() *cobra.Command {
+	cobra.AddTemplateFunc(
+		"ProgramName", func() string {
+			return Name
+		},
+	)
+
+	opts := &clientOptions{}
+
+	rootCmd := &cobra.Command{
+		Use:   Name,
+		Short: "kubectl plugin to inspect and operate kube-upgrade",
+		// Errors are logged by Execute, usage is only printed for invalid flags
+		SilenceErrors: true,
+		PersistentPreRun: func(cmd *cobra.Command, _ []string) {
+			cmd.SilenceUsage = true
+		},
+	}
+
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&opts.Kubeconfig, "kubeconfig", "", "Path to the kubeconfig, defaults to the same loading rules as kubectl")
+	flags.StringVar(&opts.Context, "context", "", "The kubeconfig context to use")
+	flags.StringVarP(&opts.Namespace, "namespace", "n", defaultNamespace, "Namespace of kube-upgrade")
+
+	rootCmd.AddCommand(
+		newStatusCommand(opts),
+		newNodesCommand(opts),
+		newRetryCommand(opts),
+		newWatchCommand(opts),
+		newLogsCommand(opts),
+		version.NewCommand(Name),
+	)
+
+	return rootCmd
+}
@@ -0,0 +1,19 @@
+package kubectlupgrade
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRootCommand(t *testing.T) {
+	cmd := NewKubectlUpgrade()
+
+	assert.Equal(t, Name, cmd.Use)
+	for _, name := range []string{"status", "nodes", "retry", "watch", "logs", "version"} {
+		sub, _, err := cmd.Find([]string{name})
+		if assert.NoError(t, err, "Should have subcommand %s", name) {
+			assert.Equal(t, name, sub.Name())
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package kubectlupgrade
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/spf13/cobra"
+)
+
+// Create the command showing the plan with the state of each group and node
+func newStatusCommand(opts *clientOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the status of the plan with its groups and nodes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, err := opts.newClients()
+			if err != nil {
+				return err
+			}
+			return printStatus(cmd.Context(), cmd.OutOrStdout(), c)
+		},
+	}
+}
+
+// Print the status of all plans with a table of their groups and nodes
+func printStatus(ctx context.Context, out io.Writer, c *clients) error {
+	planList := &api.KubeUpgradePlanList{}
+	err := c.List(ctx, planList)
+	if err != nil {
+		return fmt.Errorf("failed to list KubeUpgradePlans: %v", err)
+	}
+	if len(planList.Items) == 0 {
+		return fmt.Errorf("no KubeUpgradePlan found")
+	}
+
+	for i, plan := range planList.Items {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		err = printPlanStatus(ctx, out, c, &plan)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Print the status of the plan with a table of its groups and nodes
+func printPlanStatus(ctx context.Context, out io.Writer, c *clients, plan *api.KubeUpgradePlan) error {
+	version := plan.Spec.KubernetesVersion
+	if plan.Status.KubernetesVersion != "" && plan.Status.KubernetesVersion != version {
+		version = fmt.Sprintf("%s (%s)", version, plan.Status.KubernetesVersion)
+	}
+	fmt.Fprintf(out, "Plan:     %s\n", plan.GetName())
+	fmt.Fprintf(out, "Version:  %s\n", version)
+	fmt.Fprintf(out, "Status:   %s\n\n", orNone(plan.Status.Summary))
+
+	groups := make([]string, 0, len(plan.Spec.Groups))
+	for name := range plan.Spec.Groups {
+		groups = append(groups, name)
+	}
+	slices.Sort(groups)
+
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tSTATUS\tNODE\tTARGET\tPHASE\tUPGRADED")
+	for _, group := range groups {
+		status := orNone(plan.Status.Groups[group])
+
+		nodes, err := listNodes(ctx, c, plan.Spec.Groups[group].Labels)
+		if err != nil {
+			return err
+		}
+		if len(nodes) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", group, status, none, none, none, none)
+			continue
+		}
+		for _, node := range nodes {
+			s := newNodeState(&node)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", group, status, s.Name, orNone(s.Target), orNone(s.Phase), orNone(s.Upgraded))
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,43 @@
+package kubectlupgrade
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestPrintStatus(t *testing.T) {
+	t.Run("Plan", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		c := newTestClients(t, append(newTestNodes(), client.Object(newTestPlan()))...)
+		var out bytes.Buffer
+
+		err := printStatus(t.Context(), &out, c)
+		require.NoError(err, "Should print the status")
+
+		header, table, ok := strings.Cut(out.String(), "\n\n")
+		require.True(ok, "Should print the plan and the table")
+		assert.Equal("Plan:     upgrade-plan\nVersion:  stable-1.31 (v1.31.2)\nStatus:   Progressing: Upgrading groups [compute]", header, "Should print the plan")
+
+		lines := strings.Split(strings.TrimSpace(table), "\n")
+		require.Len(lines, 5, "Should print a header and a row per node")
+		assert.Equal([]string{"GROUP", "STATUS", "NODE", "TARGET", "PHASE", "UPGRADED"}, strings.Fields(lines[0]))
+		assert.Equal([]string{"compute", "Progressing:", "0/2", "nodes", "upgraded", "compute-1", "v1.31.2", "upgrading", none}, strings.Fields(lines[1]))
+		assert.Equal([]string{"compute", "Progressing:", "0/2", "nodes", "upgraded", "compute-2", "v1.31.2", "error", none}, strings.Fields(lines[2]))
+		assert.Equal([]string{"control-plane", "Complete", "control-1", "v1.31.2", "completed", "v0.7.0"}, strings.Fields(lines[3]))
+		assert.Equal([]string{"infra", none, none, none, none, none}, strings.Fields(lines[4]), "Should print groups without nodes")
+	})
+	t.Run("NoPlan", func(t *testing.T) {
+		c := newTestClients(t, newTestNodes()...)
+
+		err := printStatus(t.Context(), &bytes.Buffer{}, c)
+
+		assert.Error(t, err, "Should fail without a plan")
+	})
+}
@@ -0,0 +1,110 @@
+package kubectlupgrade
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Create the command streaming the phase changes of the nodes
+func newWatchCommand(opts *clientOptions) *cobra.Command {
+	var selector string
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Stream the phase changes of the nodes until interrupted",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, err := opts.newClients()
+			if err != nil {
+				return err
+			}
+			if _, err = parseSelector(selector); err != nil {
+				return err
+			}
+
+			ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer cancel()
+			return watchNodes(ctx, cmd.OutOrStdout(), c.kube, selector)
+		},
+	}
+	cmd.Flags().StringVarP(&selector, "selector", "l", "", "Only watch nodes matching the labels, e.g. key1=value1,key2=value2")
+
+	return cmd
+}
+
+// Print the current state of the nodes and every change of their kube-upgrade annotations until the context is cancelled.
+// The watch is restarted when it is closed by the server.
+func watchNodes(ctx context.Context, out io.Writer, kube kubernetes.Interface, selector string) error {
+	fmt.Fprintf(out, "%-10s %-30s %-12s %-12s %s\n", "TIME", "NODE", "TARGET", "PHASE", "UPGRADED")
+
+	last := make(map[string]nodeState)
+	for {
+		w, err := kube.CoreV1().Nodes().Watch(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to watch nodes: %v", err)
+		}
+
+		done, err := handleNodeEvents(ctx, out, w, last)
+		w.Stop()
+		if done || err != nil {
+			return err
+		}
+	}
+}
+
+// Print the node events until the watch is closed.
+// Returns true when the context has been cancelled.
+func handleNodeEvents(ctx context.Context, out io.Writer, w watch.Interface, last map[string]nodeState) (bool, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return true, nil
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return false, nil
+			}
+
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				node, ok := event.Object.(*corev1.Node)
+				if !ok {
+					continue
+				}
+				state := newNodeState(node)
+				if prev, ok := last[state.Name]; ok && !state.changed(prev) {
+					continue
+				}
+				last[state.Name] = state
+				printNodeEvent(out, state.Name, orNone(state.Target), orNone(state.Phase), orNone(state.Upgraded))
+			case watch.Deleted:
+				node, ok := event.Object.(*corev1.Node)
+				if !ok {
+					continue
+				}
+				delete(last, node.GetName())
+				printNodeEvent(out, node.GetName(), none, "<deleted>", none)
+			case watch.Error:
+				return true, fmt.Errorf("watch failed: %v", errors.FromObject(event.Object))
+			}
+		}
+	}
+}
+
+func printNodeEvent(out io.Writer, node, target, phase, upgraded string) {
+	fmt.Fprintf(out, "%-10s %-30s %-12s %-12s %s\n", time.Now().Format(time.TimeOnly), node, target, phase, upgraded)
+}
@@ -0,0 +1,92 @@
+package kubectlupgrade
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestHandleNodeEvents(t *testing.T) {
+	t.Run("Changes", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		w := watch.NewFakeWithChanSize(10, false)
+		node := newTestNode("compute-1", "compute", map[string]string{
+			constants.NodeKubernetesVersion: "v1.31.2",
+			constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusPending,
+		})
+		w.Add(node.DeepCopy())
+		// Unrelated change, should not be printed
+		node.Labels["foo"] = "bar"
+		w.Modify(node.DeepCopy())
+		node.Annotations[constants.NodeUpgradeStatus] = constants.NodeUpgradeStatusUpgrading
+		w.Modify(node.DeepCopy())
+		w.Delete(node.DeepCopy())
+		w.Stop()
+
+		var out bytes.Buffer
+		done, err := handleNodeEvents(t.Context(), &out, w, make(map[string]nodeState))
+
+		require.NoError(err, "Should not fail")
+		assert.False(done, "Should restart the watch when it is closed")
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(lines, 3, "Should only print changes of the kube-upgrade annotations")
+		assert.Equal([]string{"compute-1", "v1.31.2", "pending", none}, strings.Fields(lines[0])[1:])
+		assert.Equal([]string{"compute-1", "v1.31.2", "upgrading", none}, strings.Fields(lines[1])[1:])
+		assert.Equal([]string{"compute-1", none, "<deleted>", none}, strings.Fields(lines[2])[1:])
+	})
+	t.Run("KnownState", func(t *testing.T) {
+		w := watch.NewFakeWithChanSize(10, false)
+		node := newTestNode("compute-1", "compute", map[string]string{
+			constants.NodeUpgradeStatus: constants.NodeUpgradeStatusPending,
+		})
+		w.Add(node)
+		w.Stop()
+
+		var out bytes.Buffer
+		last := map[string]nodeState{"compute-1": newNodeState(node)}
+		_, err := handleNodeEvents(t.Context(), &out, w, last)
+
+		assert.NoError(t, err, "Should not fail")
+		assert.Empty(t, out.String(), "Should not print the state again after the watch has been restarted")
+	})
+	t.Run("Error", func(t *testing.T) {
+		w := watch.NewFakeWithChanSize(10, false)
+		w.Error(&metav1.Status{Status: metav1.StatusFailure, Message: "expired", Reason: metav1.StatusReasonExpired})
+
+		done, err := handleNodeEvents(t.Context(), &bytes.Buffer{}, w, make(map[string]nodeState))
+
+		assert.True(t, done, "Should stop")
+		assert.ErrorContains(t, err, "expired", "Should return the error")
+	})
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		done, err := handleNodeEvents(ctx, &bytes.Buffer{}, watch.NewFake(), make(map[string]nodeState))
+
+		assert.True(t, done, "Should stop")
+		assert.NoError(t, err, "Should not fail")
+	})
+}
+
+func TestWatchNodes(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	c := newTestClients(t)
+	var out bytes.Buffer
+
+	err := watchNodes(ctx, &out, c.kube, "")
+
+	assert.NoError(t, err, "Should stop when the context is cancelled")
+	assert.Equal(t, []string{"TIME", "NODE", "TARGET", "PHASE", "UPGRADED"}, strings.Fields(out.String()), "Should print the header")
+}
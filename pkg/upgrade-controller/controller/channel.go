@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"golang.org/x/mod/semver"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Return the kubernetes version the cluster should be upgraded to.
+// Resolves the version channel when necessary and records the result in the plan status.
+func (c *controller) reconcileKubernetesVersion(ctx context.Context, plan *api.KubeUpgradePlan, logger *slog.Logger) (string, error) {
+	channel := plan.Spec.KubernetesVersion
+	if !api.IsVersionChannel(channel) {
+		plan.Status.KubernetesVersion = plan.Spec.KubernetesVersion
+		plan.Status.LastChannelCheck = nil
+		return plan.Spec.KubernetesVersion, nil
+	}
+
+	logger = logger.With("channel", channel)
+	cfg := versionChannelConfig(plan.Spec)
+	current := plan.Status.KubernetesVersion
+	currentInChannel := semver.MajorMinor(current) == api.VersionChannelMajorMinor(channel)
+
+	if currentInChannel && plan.Status.LastChannelCheck != nil && time.Since(plan.Status.LastChannelCheck.Time) < channelInterval(cfg) {
+		return current, nil
+	}
+
+	version, err := c.resolveVersionChannel(ctx, channel, cfg)
+	if err == nil {
+		err = c.checkStreamsContainVersion(ctx, plan, version)
+	}
+	if err != nil {
+		if !currentInChannel {
+			return "", fmt.Errorf("failed to resolve version channel %s: %v", channel, err)
+		}
+		logger.Warn("Failed to resolve version channel, keeping current version", "version", current, "err", err)
+		return current, nil
+	}
+
+	now := metav1.Now()
+	plan.Status.LastChannelCheck = &now
+
+	if currentInChannel && semver.Compare(version, current) < 0 && !plan.Spec.AllowDowngrade {
+		logger.Warn("Version channel resolved to an older version, keeping current version", "version", current, "resolved", version)
+		return current, nil
+	}
+	if version != current {
+		logger.Info("Resolved new kubernetes version from channel", "version", version)
+	}
+	plan.Status.KubernetesVersion = version
+	return version, nil
+}
+
+// Fetch the current version of the channel, either from the configured ConfigMap or the url.
+func (c *controller) resolveVersionChannel(ctx context.Context, channel string, cfg api.VersionChannelConfig) (string, error) {
+	var version string
+	if cfg.ConfigMap != "" {
+		cm := &corev1.ConfigMap{}
+		err := c.Get(ctx, client.ObjectKey{Namespace: c.namespace, Name: cfg.ConfigMap}, cm)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch ConfigMap %s: %v", cfg.ConfigMap, err)
+		}
+		version = cm.Data[channel]
+		if version == "" {
+			return "", fmt.Errorf("ConfigMap %s contains no version for channel %s", cfg.ConfigMap, channel)
+		}
+	} else {
+		channelURL := strings.TrimSuffix(cfg.URL, "/") + "/" + channel + ".txt"
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, channelURL, nil)
+		if err != nil {
+			return "", err
+		}
+		res, err := c.getHTTPClient().Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("failed to fetch '%s', received status code %d", channelURL, res.StatusCode)
+		}
+		body, err := io.ReadAll(io.LimitReader(res.Body, 1024))
+		if err != nil {
+			return "", fmt.Errorf("failed to read '%s': %v", channelURL, err)
+		}
+		version = string(body)
+	}
+
+	version = strings.TrimSpace(version)
+	if !semver.IsValid(version) {
+		return "", fmt.Errorf("channel %s resolved to invalid version \"%s\"", channel, version)
+	}
+	if semver.MajorMinor(version) != api.VersionChannelMajorMinor(channel) {
+		return "", fmt.Errorf("channel %s resolved to version %s outside of the channel", channel, version)
+	}
+	return version, nil
+}
+
+// Ensure all streams used by the plan contain an image for the given version.
+// Uses the pull secret configured for the stream, the same as upgraded on the nodes.
+func (c *controller) checkStreamsContainVersion(ctx context.Context, plan *api.KubeUpgradePlan, version string) error {
+	type streamSource struct {
+		stream, pullSecret string
+	}
+	streams := make(map[streamSource]bool, len(plan.Spec.Groups)+1)
+	streams[streamSource{plan.Spec.Upgraded.Stream, plan.Spec.Upgraded.PullSecret}] = true
+	for _, group := range plan.Spec.Groups {
+		cfg := combineConfig(plan.Spec.Upgraded, group.Upgraded)
+		streams[streamSource{cfg.Stream, cfg.PullSecret}] = true
+	}
+
+	for source := range streams {
+		stream := source.stream
+		if stream == "" {
+			continue
+		}
+		var creds *registryCredentials
+		if source.pullSecret != "" {
+			var err error
+			creds, err = c.getRegistryCredentials(ctx, source.pullSecret)
+			if err != nil {
+				return fmt.Errorf("failed to check stream %s for version %s: %v", stream, version, err)
+			}
+		}
+		ok, err := imageTagExists(ctx, c.getHTTPClient(), stream, version, creds)
+		if err != nil {
+			return fmt.Errorf("failed to check stream %s for version %s: %v", stream, version, err)
+		}
+		if !ok {
+			return fmt.Errorf("version %s is not available in stream %s", version, stream)
+		}
+	}
+	return nil
+}
+
+// Return the version channel config of the spec with defaults applied.
+func versionChannelConfig(spec api.KubeUpgradeSpec) api.VersionChannelConfig {
+	var cfg api.VersionChannelConfig
+	if spec.VersionChannel != nil {
+		cfg = *spec.VersionChannel
+	}
+	api.SetObjectDefaults_VersionChannelConfig(&cfg)
+	return cfg
+}
+
+// Return the interval between version channel checks.
+func channelInterval(cfg api.VersionChannelConfig) time.Duration {
+	interval, err := time.ParseDuration(cfg.Interval)
+	if err != nil {
+		// Should not happen, as the config is validated by the webhook.
+		slog.Warn("Invalid version channel interval, using default", "interval", cfg.Interval, "err", err)
+		interval, _ = time.ParseDuration(api.DefaultVersionChannelInterval)
+	}
+	return interval
+}
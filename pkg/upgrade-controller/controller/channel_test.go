@@ -0,0 +1,215 @@
+package controller
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReconcileKubernetesVersion(t *testing.T) {
+	registry := newFakeRegistry(t, "v1.35.1", "v1.35.2")
+	stream := strings.TrimPrefix(registry.URL, "https://") + "/heathcliff26/fcos-k8s"
+
+	newPlan := func(version, channelVersion string) *api.KubeUpgradePlan {
+		plan := &api.KubeUpgradePlan{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "upgrade-plan",
+			},
+			Spec: api.KubeUpgradeSpec{
+				KubernetesVersion: version,
+				VersionChannel: &api.VersionChannelConfig{
+					URL: newFakeChannelServer(t, channelVersion).URL,
+				},
+				Groups: map[string]api.KubeUpgradePlanGroup{
+					groupControl: {
+						Labels: map[string]string{labelControl: labelValue},
+					},
+				},
+				Upgraded: api.UpgradedConfig{
+					Stream: stream,
+				},
+			},
+		}
+		return plan
+	}
+
+	t.Run("FixedVersion", func(t *testing.T) {
+		assert := assert.New(t)
+		plan := newPlan("v1.35.0", "")
+		plan.Status.LastChannelCheck = &metav1.Time{Time: time.Now()}
+		c := createFakeController(nil, nil, nil, plan)
+
+		version, err := c.reconcileKubernetesVersion(t.Context(), plan, slog.Default())
+
+		assert.NoError(err, "Should not return an error")
+		assert.Equal("v1.35.0", version, "Should return the version from the spec")
+		assert.Equal("v1.35.0", plan.Status.KubernetesVersion, "Should record the version in the status")
+		assert.Nil(plan.Status.LastChannelCheck, "Should reset the last channel check")
+	})
+	t.Run("ResolveChannel", func(t *testing.T) {
+		assert := assert.New(t)
+		plan := newPlan("stable-1.35", "v1.35.2")
+		c := createFakeController(nil, nil, nil, plan)
+		c.httpClient = registry.Client()
+
+		version, err := c.reconcileKubernetesVersion(t.Context(), plan, slog.Default())
+
+		assert.NoError(err, "Should not return an error")
+		assert.Equal("v1.35.2", version, "Should return the resolved version")
+		assert.Equal("v1.35.2", plan.Status.KubernetesVersion, "Should record the resolved version in the status")
+		assert.NotNil(plan.Status.LastChannelCheck, "Should record the time of the check")
+	})
+	t.Run("SkipUntilInterval", func(t *testing.T) {
+		assert := assert.New(t)
+		plan := newPlan("stable-1.35", "v1.35.2")
+		plan.Status.KubernetesVersion = "v1.35.1"
+		plan.Status.LastChannelCheck = &metav1.Time{Time: time.Now()}
+		c := createFakeController(nil, nil, nil, plan)
+		c.httpClient = registry.Client()
+
+		version, err := c.reconcileKubernetesVersion(t.Context(), plan, slog.Default())
+
+		assert.NoError(err, "Should not return an error")
+		assert.Equal("v1.35.1", version, "Should keep the current version until the next check")
+	})
+	t.Run("NewPatchRelease", func(t *testing.T) {
+		assert := assert.New(t)
+		plan := newPlan("stable-1.35", "v1.35.2")
+		plan.Status.KubernetesVersion = "v1.35.1"
+		plan.Status.LastChannelCheck = &metav1.Time{Time: time.Now().Add(-2 * time.Hour)}
+		c := createFakeController(nil, nil, nil, plan)
+		c.httpClient = registry.Client()
+
+		version, err := c.reconcileKubernetesVersion(t.Context(), plan, slog.Default())
+
+		assert.NoError(err, "Should not return an error")
+		assert.Equal("v1.35.2", version, "Should upgrade to the new patch release")
+	})
+	t.Run("MissingInStream", func(t *testing.T) {
+		assert := assert.New(t)
+		plan := newPlan("stable-1.35", "v1.35.3")
+		plan.Status.KubernetesVersion = "v1.35.2"
+		c := createFakeController(nil, nil, nil, plan)
+		c.httpClient = registry.Client()
+
+		version, err := c.reconcileKubernetesVersion(t.Context(), plan, slog.Default())
+
+		assert.NoError(err, "Should not return an error")
+		assert.Equal("v1.35.2", version, "Should keep the current version")
+		assert.Nil(plan.Status.LastChannelCheck, "Should retry on the next reconcile")
+	})
+	t.Run("NoPreviousVersion", func(t *testing.T) {
+		assert := assert.New(t)
+		plan := newPlan("stable-1.35", "v1.35.3")
+		c := createFakeController(nil, nil, nil, plan)
+		c.httpClient = registry.Client()
+
+		_, err := c.reconcileKubernetesVersion(t.Context(), plan, slog.Default())
+
+		assert.ErrorContains(err, "version v1.35.3 is not available in stream", "Should fail without a version to fall back to")
+	})
+	t.Run("NoDowngrade", func(t *testing.T) {
+		assert := assert.New(t)
+		plan := newPlan("stable-1.35", "v1.35.1")
+		plan.Status.KubernetesVersion = "v1.35.2"
+		c := createFakeController(nil, nil, nil, plan)
+		c.httpClient = registry.Client()
+
+		version, err := c.reconcileKubernetesVersion(t.Context(), plan, slog.Default())
+
+		assert.NoError(err, "Should not return an error")
+		assert.Equal("v1.35.2", version, "Should not downgrade when the channel goes back")
+	})
+	t.Run("PrivateStream", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+		privateRegistry := newFakePrivateRegistry(t, "user", "secret", "v1.35.2")
+		host := strings.TrimPrefix(privateRegistry.URL, "https://")
+		plan := newPlan("stable-1.35", "v1.35.2")
+		plan.Spec.Upgraded.Stream = host + "/heathcliff26/fcos-k8s"
+		c := createFakeController(nil, nil, nil, plan)
+		c.httpClient = privateRegistry.Client()
+
+		_, err := c.reconcileKubernetesVersion(t.Context(), plan, slog.Default())
+		assert.Error(err, "Should fail without the pull secret")
+
+		plan.Spec.Upgraded.PullSecret = "pull-secret"
+		require.NoError(c.Create(t.Context(), newFakePullSecret(c.namespace, "pull-secret", host, "user", "secret")))
+
+		version, err := c.reconcileKubernetesVersion(t.Context(), plan, slog.Default())
+		assert.NoError(err, "Should use the pull secret of the plan")
+		assert.Equal("v1.35.2", version, "Should return the resolved version")
+	})
+}
+
+func TestResolveVersionChannel(t *testing.T) {
+	t.Run("URL", func(t *testing.T) {
+		assert := assert.New(t)
+		c := &controller{}
+		cfg := api.VersionChannelConfig{URL: newFakeChannelServer(t, "v1.35.2\n").URL}
+
+		version, err := c.resolveVersionChannel(t.Context(), "stable-1.35", cfg)
+
+		assert.NoError(err, "Should resolve the channel")
+		assert.Equal("v1.35.2", version, "Should trim the version")
+
+		_, err = c.resolveVersionChannel(t.Context(), "stable-1.34", cfg)
+		assert.ErrorContains(err, "outside of the channel", "Should not accept versions of another minor")
+
+		_, err = c.resolveVersionChannel(t.Context(), "stable-1.36", api.VersionChannelConfig{URL: newFakeChannelServer(t, "").URL})
+		assert.ErrorContains(err, "received status code 404", "Should fail for unknown channels")
+	})
+	t.Run("ConfigMap", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+		c := createFakeController(nil, nil, nil, &api.KubeUpgradePlan{ObjectMeta: metav1.ObjectMeta{Name: "upgrade-plan"}})
+		require.NoError(c.Create(t.Context(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "channels",
+				Namespace: c.namespace,
+			},
+			Data: map[string]string{
+				"stable-1.35": "v1.35.2",
+				"stable-1.34": "not-a-version",
+			},
+		}), "Should create channel ConfigMap")
+		cfg := api.VersionChannelConfig{ConfigMap: "channels"}
+
+		version, err := c.resolveVersionChannel(t.Context(), "stable-1.35", cfg)
+		assert.NoError(err, "Should resolve the channel")
+		assert.Equal("v1.35.2", version, "Should return the version from the ConfigMap")
+
+		_, err = c.resolveVersionChannel(t.Context(), "stable-1.34", cfg)
+		assert.ErrorContains(err, "resolved to invalid version", "Should validate the version")
+
+		_, err = c.resolveVersionChannel(t.Context(), "stable-1.33", cfg)
+		assert.ErrorContains(err, "contains no version for channel", "Should fail for missing channels")
+
+		_, err = c.resolveVersionChannel(t.Context(), "stable-1.35", api.VersionChannelConfig{ConfigMap: "not-found"})
+		assert.ErrorContains(err, "failed to fetch ConfigMap", "Should fail when the ConfigMap does not exist")
+	})
+}
+
+// Create a server returning the given version for the channels "stable-1.35.txt" and "stable-1.34.txt".
+func newFakeChannelServer(t *testing.T, version string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if version == "" || (req.URL.Path != "/stable-1.35.txt" && req.URL.Path != "/stable-1.34.txt") {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = rw.Write([]byte(version))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
@@ -33,9 +33,33 @@ func combineConfig(global api.UpgradedConfig, group *api.UpgradedConfig) *api.Up
 	if group.KubeletConfig != "" {
 		cfg.KubeletConfig = group.KubeletConfig
 	}
+	if group.ServiceAccountName != "" {
+		cfg.ServiceAccountName = group.ServiceAccountName
+	}
 	if group.KubeadmPath != "" {
 		cfg.KubeadmPath = group.KubeadmPath
 	}
+	if group.TaintUpgradingNodes {
+		cfg.TaintUpgradingNodes = true
+	}
+	if group.PullSecret != "" {
+		cfg.PullSecret = group.PullSecret
+	}
+	if group.SignaturePolicy != nil {
+		cfg.SignaturePolicy = group.SignaturePolicy
+	}
+	if group.KubeadmDownload != nil {
+		cfg.KubeadmDownload = group.KubeadmDownload
+	}
+	if group.KubeadmCache != nil {
+		cfg.KubeadmCache = group.KubeadmCache
+	}
+	if group.Retry != nil {
+		cfg.Retry = group.Retry
+	}
+	if group.NodeHooks != nil {
+		cfg.NodeHooks = group.NodeHooks
+	}
 
 	return &cfg
 }
@@ -17,34 +17,80 @@ func TestCombineConfig(t *testing.T) {
 		{
 			Name: "OverrideAll",
 			Global: api.UpgradedConfig{
-				Stream:         "registry.example.org/test-stream",
-				FleetlockURL:   "https://fleetlock.example.org",
-				FleetlockGroup: "not-default",
-				CheckInterval:  "10m",
-				RetryInterval:  "15m",
-				LogLevel:       "error",
-				KubeletConfig:  "/foo/kubelet.conf",
-				KubeadmPath:    "/foo/kubeadm",
+				Stream:             "registry.example.org/test-stream",
+				FleetlockURL:       "https://fleetlock.example.org",
+				FleetlockGroup:     "not-default",
+				CheckInterval:      "10m",
+				RetryInterval:      "15m",
+				LogLevel:           "error",
+				KubeletConfig:      "/foo/kubelet.conf",
+				KubeadmPath:        "/foo/kubeadm",
+				PullSecret:         "pull-secret-org",
+				ServiceAccountName: "upgraded-org",
+				KubeadmDownload: &api.KubeadmDownloadConfig{
+					URL: "https://mirror.example.org/kubernetes",
+				},
+				SignaturePolicy: &api.SignaturePolicy{
+					PublicKey: "org-key",
+				},
+				Retry: &api.RetryConfig{
+					MaxInterval: "30m",
+				},
+				NodeHooks: &api.NodeHooksConfig{
+					Dir: "/etc/kube-upgraded/hooks.d",
+				},
 			},
 			Group: &api.UpgradedConfig{
-				Stream:         "registry.example.com/test-stream",
-				FleetlockURL:   "https://fleetlock.example.com",
-				FleetlockGroup: "default",
-				CheckInterval:  "2m",
-				RetryInterval:  "3m",
-				LogLevel:       "debug",
-				KubeletConfig:  "/foo/bar/kubelet.conf",
-				KubeadmPath:    "/foo/bar/kubeadm",
+				Stream:             "registry.example.com/test-stream",
+				FleetlockURL:       "https://fleetlock.example.com",
+				FleetlockGroup:     "default",
+				CheckInterval:      "2m",
+				RetryInterval:      "3m",
+				LogLevel:           "debug",
+				KubeletConfig:      "/foo/bar/kubelet.conf",
+				KubeadmPath:        "/foo/bar/kubeadm",
+				PullSecret:         "pull-secret-com",
+				ServiceAccountName: "upgraded-com",
+				KubeadmDownload: &api.KubeadmDownloadConfig{
+					Source: api.KubeadmSourceImage,
+				},
+				SignaturePolicy: &api.SignaturePolicy{
+					PublicKey: "com-key",
+				},
+				Retry: &api.RetryConfig{
+					MaxInterval:  "1h",
+					LockAttempts: 10,
+				},
+				NodeHooks: &api.NodeHooksConfig{
+					ConfigMap:     "node-hooks-com",
+					FailurePolicy: api.NodeHooksFailurePolicyAbort,
+				},
 			},
 			Result: &api.UpgradedConfig{
-				Stream:         "registry.example.com/test-stream",
-				FleetlockURL:   "https://fleetlock.example.com",
-				FleetlockGroup: "default",
-				CheckInterval:  "2m",
-				RetryInterval:  "3m",
-				LogLevel:       "debug",
-				KubeletConfig:  "/foo/bar/kubelet.conf",
-				KubeadmPath:    "/foo/bar/kubeadm",
+				Stream:             "registry.example.com/test-stream",
+				FleetlockURL:       "https://fleetlock.example.com",
+				FleetlockGroup:     "default",
+				CheckInterval:      "2m",
+				RetryInterval:      "3m",
+				LogLevel:           "debug",
+				KubeletConfig:      "/foo/bar/kubelet.conf",
+				KubeadmPath:        "/foo/bar/kubeadm",
+				PullSecret:         "pull-secret-com",
+				ServiceAccountName: "upgraded-com",
+				KubeadmDownload: &api.KubeadmDownloadConfig{
+					Source: api.KubeadmSourceImage,
+				},
+				SignaturePolicy: &api.SignaturePolicy{
+					PublicKey: "com-key",
+				},
+				Retry: &api.RetryConfig{
+					MaxInterval:  "1h",
+					LockAttempts: 10,
+				},
+				NodeHooks: &api.NodeHooksConfig{
+					ConfigMap:     "node-hooks-com",
+					FailurePolicy: api.NodeHooksFailurePolicyAbort,
+				},
 			},
 		},
 		{
@@ -152,6 +198,19 @@ func TestCombineConfig(t *testing.T) {
 				KubeadmPath:    "/foo/kubeadm",
 			},
 		},
+		{
+			Name: "GroupEnablesTaint",
+			Global: api.UpgradedConfig{
+				Stream: "registry.example.com/test-stream",
+			},
+			Group: &api.UpgradedConfig{
+				TaintUpgradingNodes: true,
+			},
+			Result: &api.UpgradedConfig{
+				Stream:              "registry.example.com/test-stream",
+				TaintUpgradingNodes: true,
+			},
+		},
 		{
 			Name:   "AllNil",
 			Result: &api.UpgradedConfig{},
@@ -4,26 +4,39 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
 	"github.com/heathcliff26/kube-upgrade/pkg/constants"
 	"golang.org/x/mod/semver"
 	appv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/rest"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
 const (
 	defaultUpgradedImage = "ghcr.io/heathcliff26/kube-upgraded"
 	upgradedImageEnv     = "UPGRADED_IMAGE"
 	upgradedTagEnv       = "UPGRADED_TAG"
+
+	defaultServiceAccountName = "kube-upgrade"
+	serviceAccountNameEnv     = "SERVICE_ACCOUNT_NAME"
 )
 
 type controller struct {
@@ -31,6 +44,11 @@ type controller struct {
 	manager       manager.Manager
 	namespace     string
 	upgradedImage string
+	httpClient    *http.Client
+	recorder      events.EventRecorder
+
+	disableWebhooks bool
+	webhookCertDir  string
 }
 
 // Run make generate when changing these comments
@@ -38,17 +56,21 @@ type controller struct {
 // +kubebuilder:rbac:groups=kubeupgrade.heathcliff.eu,resources=kubeupgradeplans/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=nodes,verbs=list;watch;update
 // +kubebuilder:rbac:groups="",namespace=kube-upgrade,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="events.k8s.io",resources=events,verbs=create;patch
 // +kubebuilder:rbac:groups="coordination.k8s.io",namespace=kube-upgrade,resources=leases,verbs=create;get;update
 // +kubebuilder:rbac:groups="apps",namespace=kube-upgrade,resources=daemonsets,verbs=list;watch;create;update;delete
 // +kubebuilder:rbac:groups="",namespace=kube-upgrade,resources=configmaps,verbs=list;watch;create;update;delete
+// +kubebuilder:rbac:groups="batch",namespace=kube-upgrade,resources=jobs,verbs=list;watch;create
+// +kubebuilder:rbac:groups="",namespace=kube-system,resources=configmaps,resourceNames=kubeadm-config,verbs=get
+// +kubebuilder:rbac:groups="",namespace=kube-upgrade,resources=secrets,verbs=get;create;update
 
-func NewController(name string) (*controller, error) {
-	config, err := rest.InClusterConfig()
+func NewController(name string, opts Options) (*controller, error) {
+	config, err := opts.restConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	ns, err := GetNamespace()
+	ns, err := opts.namespace()
 	if err != nil {
 		return nil, err
 	}
@@ -58,16 +80,29 @@ func NewController(name string) (*controller, error) {
 		return nil, err
 	}
 
+	webhookHost, webhookPort, err := opts.webhookHostPort()
+	if err != nil {
+		return nil, err
+	}
+
 	mgr, err := ctrl.NewManager(config, manager.Options{
 		Scheme:                        scheme,
-		LeaderElection:                true,
+		LeaderElection:                opts.LeaderElection,
 		LeaderElectionNamespace:       ns,
 		LeaderElectionID:              name,
 		LeaderElectionReleaseOnCancel: true,
-		LeaseDuration:                 Pointer(time.Minute),
-		RenewDeadline:                 Pointer(10 * time.Second),
-		RetryPeriod:                   Pointer(5 * time.Second),
-		HealthProbeBindAddress:        ":9090",
+		LeaseDuration:                 Pointer(opts.LeaseDuration),
+		RenewDeadline:                 Pointer(opts.RenewDeadline),
+		RetryPeriod:                   Pointer(opts.RetryPeriod),
+		HealthProbeBindAddress:        opts.HealthProbeBindAddress,
+		Metrics: metricsserver.Options{
+			BindAddress: opts.MetricsBindAddress,
+		},
+		WebhookServer: webhook.NewServer(webhook.Options{
+			Host:    webhookHost,
+			Port:    webhookPort,
+			CertDir: opts.WebhookCertDir,
+		}),
 		Cache: cache.Options{
 			DefaultNamespaces: map[string]cache.Config{ns: {}},
 		},
@@ -85,34 +120,72 @@ func NewController(name string) (*controller, error) {
 	}
 
 	return &controller{
-		Client:        mgr.GetClient(),
-		manager:       mgr,
-		namespace:     ns,
-		upgradedImage: GetUpgradedImage(),
+		Client:          mgr.GetClient(),
+		manager:         mgr,
+		namespace:       ns,
+		upgradedImage:   GetUpgradedImage(),
+		recorder:        mgr.GetEventRecorder(name),
+		disableWebhooks: opts.DisableWebhooks,
+		webhookCertDir:  opts.WebhookCertDir,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
 	}, nil
 }
 
 func (c *controller) Run() error {
+	ctx := signals.SetupSignalHandler()
+
 	err := ctrl.NewControllerManagedBy(c.manager).
 		For(&api.KubeUpgradePlan{}).
 		Owns(&appv1.DaemonSet{}).
 		Owns(&corev1.ConfigMap{}).
+		Owns(&batchv1.Job{}).
 		Complete(c)
 	if err != nil {
 		return err
 	}
 
+	if c.disableWebhooks {
+		slog.Warn("Webhooks are disabled, plans and node annotations will not be validated")
+		return c.manager.Start(ctx)
+	}
+
+	certManager := newWebhookCertManager(c.Client, c.manager.GetAPIReader(), c.namespace, c.webhookCertDir)
+	if certManager != nil {
+		// The webhook server needs the certificates on startup
+		err = certManager.Sync(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create webhook certificates: %v", err)
+		}
+		err = c.manager.Add(certManager)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = ctrl.NewWebhookManagedBy(c.manager, &api.KubeUpgradePlan{}).
 		WithDefaulter(&planMutatingHook{}).
 		WithValidator(&planValidatingHook{
-			Client: c.Client,
+			Client:    c.Client,
+			APIReader: c.manager.GetAPIReader(),
 		}).
 		Complete()
 	if err != nil {
 		return err
 	}
 
-	return c.manager.Start(signals.SetupSignalHandler())
+	err = ctrl.NewWebhookManagedBy(c.manager, &corev1.Node{}).
+		WithValidator(&nodeValidatingHook{
+			ControllerUsername: GetServiceAccountUsername(c.namespace),
+			Namespace:          c.namespace,
+		}).
+		Complete()
+	if err != nil {
+		return err
+	}
+
+	return c.manager.Start(ctx)
 }
 
 func (c *controller) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, err error) {
@@ -136,8 +209,10 @@ func (c *controller) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.
 		return
 	}
 
-	if plan.Status.Summary != api.PlanStatusComplete {
+	if !statusComplete(plan.Status.Summary) {
 		res.RequeueAfter = time.Minute
+	} else if api.IsVersionChannel(plan.Spec.KubernetesVersion) {
+		res.RequeueAfter = channelInterval(versionChannelConfig(plan.Spec))
 	}
 	return
 }
@@ -147,8 +222,19 @@ func (c *controller) reconcile(ctx context.Context, plan *api.KubeUpgradePlan, l
 		plan.Status.Groups = make(map[string]string, len(plan.Spec.Groups))
 	}
 
+	kubeVersion, err := c.reconcileKubernetesVersion(ctx, plan, logger)
+	if err != nil {
+		logger.Error("Failed to determine kubernetes version", "err", err)
+		return err
+	}
+
+	if plan.Spec.DryRun {
+		return c.reconcileDryRun(ctx, plan, logger, kubeVersion)
+	}
+	plan.Status.DryRun = nil
+
 	cmList := &corev1.ConfigMapList{}
-	err := c.List(ctx, cmList, client.InNamespace(c.namespace), client.MatchingLabels{
+	err = c.List(ctx, cmList, client.InNamespace(c.namespace), client.MatchingLabels{
 		constants.LabelPlanName: plan.Name,
 	})
 	if err != nil {
@@ -195,8 +281,16 @@ func (c *controller) reconcile(ctx context.Context, plan *api.KubeUpgradePlan, l
 		}
 	}
 
+	now := time.Now()
+	timeouts := phaseTimeouts(plan.Spec)
 	nodesToUpdate := make(map[string][]corev1.Node, len(plan.Spec.Groups))
 	newGroupStatus := make(map[string]string, len(plan.Spec.Groups))
+	timedOutNodes := make(map[string][]*corev1.Node, len(plan.Spec.Groups))
+	rolloutPending := make(map[string]bool, len(plan.Spec.Groups))
+	planRolloutPending := false
+	stalledNodes := make([]string, 0)
+	noDaemonNodes := make([]string, 0)
+	failedNodes := make([]string, 0)
 
 	for name, cfg := range plan.Spec.Groups {
 		logger := logger.With("group", name)
@@ -218,21 +312,68 @@ func (c *controller) reconcile(ctx context.Context, plan *api.KubeUpgradePlan, l
 			return err
 		}
 
-		status, update, nodes, err := c.reconcileNodes(plan.Spec.KubernetesVersion, plan.Spec.AllowDowngrade, nodeList.Items)
+		rolloutPending[name] = groupRolloutPending(kubeVersion, nodeList.Items)
+		planRolloutPending = planRolloutPending || rolloutPending[name]
+		timedOutNodes[name] = markTimedOutNodes(kubeVersion, timeouts, now, nodeList.Items)
+
+		status, update, nodes, err := c.reconcileNodes(kubeVersion, plan.Spec.AllowDowngrade, failurePolicy(cfg), now, nodeList.Items)
 		if err != nil {
 			logger.Error("Failed to reconcile nodes for group", "err", err)
 			return err
 		}
 
 		newGroupStatus[name] = status
+		stalledNodes = append(stalledNodes, nodesStalledByTimeout(kubeVersion, nodes)...)
+		if strings.HasPrefix(status, api.PlanStatusStalled) {
+			noDaemonNodes = append(noDaemonNodes, nodesWithoutDaemon(now, nodes)...)
+		}
+		failedNodes = append(failedNodes, nodesFailed(kubeVersion, failurePolicy(cfg), nodes)...)
 
-		if update {
+		if update || len(timedOutNodes[name]) > 0 {
 			nodesToUpdate[name] = nodes
 		} else if plan.Status.Groups[name] != newGroupStatus[name] {
 			logger.Info("Group changed status", "status", newGroupStatus[name])
 		}
 	}
 
+	// No group is upgraded, while the plan is held by its preUpgrade hook
+	var planHooks *api.HooksStatus
+	planHeld := ""
+	if plan.Spec.PreUpgrade != nil || plan.Spec.PostUpgrade != nil {
+		planHooks = hooksForVersion(plan.Status.Hooks, kubeVersion, planRolloutPending)
+	}
+	if planHooks != nil {
+		planHeld, err = c.runHook(ctx, plan, logger, planHooks, "", hookPreUpgrade, plan.Spec.PreUpgrade, plan.Spec.PostUpgrade)
+		if err != nil {
+			return err
+		}
+		plan.Status.Hooks = planHooks
+	}
+
+	// Completed groups need to finish their postUpgrade hook, before groups depending on them can start
+	groupHooks := make(map[string]*api.HooksStatus, len(plan.Spec.Groups))
+	for name, cfg := range plan.Spec.Groups {
+		if cfg.PreUpgrade == nil && cfg.PostUpgrade == nil {
+			continue
+		}
+		var current *api.HooksStatus
+		if hooks, ok := plan.Status.GroupHooks[name]; ok {
+			current = &hooks
+		}
+		groupHooks[name] = hooksForVersion(current, kubeVersion, rolloutPending[name])
+		if groupHooks[name] == nil || !statusComplete(newGroupStatus[name]) {
+			continue
+		}
+
+		held, err := c.runHook(ctx, plan, logger.With("group", name), groupHooks[name], name, hookPostUpgrade, cfg.PreUpgrade, cfg.PostUpgrade)
+		if err != nil {
+			return err
+		}
+		if held != "" {
+			newGroupStatus[name] = held
+		}
+	}
+
 	for name, nodes := range nodesToUpdate {
 		logger := logger.With("group", name)
 
@@ -240,7 +381,25 @@ func (c *controller) reconcile(ctx context.Context, plan *api.KubeUpgradePlan, l
 			logger.Info("Group is waiting on dependencies")
 			newGroupStatus[name] = api.PlanStatusWaiting
 			continue
-		} else if plan.Status.Groups[name] != newGroupStatus[name] {
+		} else if planHeld != "" {
+			logger.Info("Group is waiting on the preUpgrade hook of the plan")
+			newGroupStatus[name] = api.PlanStatusWaiting
+			continue
+		}
+
+		if hooks := groupHooks[name]; hooks != nil {
+			cfg := plan.Spec.Groups[name]
+			held, err := c.runHook(ctx, plan, logger, hooks, name, hookPreUpgrade, cfg.PreUpgrade, cfg.PostUpgrade)
+			if err != nil {
+				return err
+			}
+			if held != "" {
+				newGroupStatus[name] = held
+				continue
+			}
+		}
+
+		if plan.Status.Groups[name] != newGroupStatus[name] {
 			logger.Info("Group changed status", "status", newGroupStatus[name])
 		}
 
@@ -251,55 +410,340 @@ func (c *controller) reconcile(ctx context.Context, plan *api.KubeUpgradePlan, l
 				return fmt.Errorf("failed to update node %s: %v", node.GetName(), err)
 			}
 		}
+
+		for _, node := range timedOutNodes[name] {
+			logger.Warn("Node exceeded the timeout of its phase", "node", node.GetName(), "reason", node.Annotations[constants.NodeLastError])
+			c.recorder.Eventf(plan, node, corev1.EventTypeWarning, api.PlanReasonNodeTimeout, "SetNodeError", "Node %s %s", node.GetName(), node.Annotations[constants.NodeLastError])
+		}
+	}
+
+	for name, hooks := range groupHooks {
+		if hooks == nil {
+			continue
+		}
+		if plan.Status.GroupHooks == nil {
+			plan.Status.GroupHooks = make(map[string]api.HooksStatus, len(groupHooks))
+		}
+		plan.Status.GroupHooks[name] = *hooks
+	}
+	for name := range plan.Status.GroupHooks {
+		if _, ok := plan.Spec.Groups[name]; !ok {
+			delete(plan.Status.GroupHooks, name)
+		}
 	}
 
 	plan.Status.Groups = newGroupStatus
 	plan.Status.Summary = createStatusSummary(plan.Status.Groups)
+	if planHooks != nil && planHeld == "" && statusComplete(plan.Status.Summary) {
+		planHeld, err = c.runHook(ctx, plan, logger, planHooks, "", hookPostUpgrade, plan.Spec.PreUpgrade, plan.Spec.PostUpgrade)
+		if err != nil {
+			return err
+		}
+	}
+	if planHeld != "" {
+		plan.Status.Summary = planHeld
+	}
+	setStalledCondition(plan, stalledNodes, noDaemonNodes)
+	setNodesFailedCondition(plan, failedNodes)
 
 	return nil
 }
 
-func (c *controller) reconcileNodes(kubeVersion string, downgrade bool, nodes []corev1.Node) (string, bool, []corev1.Node, error) {
+func (c *controller) reconcileNodes(kubeVersion string, downgrade bool, policy api.FailurePolicy, now time.Time, nodes []corev1.Node) (string, bool, []corev1.Node, error) {
 	if len(nodes) == 0 {
 		return api.PlanStatusUnknown, false, nil, nil
 	}
 
+	total := 0
 	completed := 0
 	needUpdate := false
-	errorNodes := make([]string, 0)
+	failedNodes := make([]string, 0)
+	retryingNodes := make([]string, 0)
+	retryDue := make([]*corev1.Node, 0)
+	errorReasons := make([]string, 0)
+	// Excluded nodes that still hold the lock block the other nodes of the fleetlock group
+	lockedNodes := make([]string, 0)
 
 	for i := range nodes {
 		if nodes[i].Annotations == nil {
 			nodes[i].Annotations = make(map[string]string)
 		}
 
+		if nodeExcludedFromRollout(&nodes[i]) && nodeAnnotationEnabled(&nodes[i], constants.NodeLockHeld) {
+			lockedNodes = append(lockedNodes, nodes[i].GetName())
+		}
+
+		// Held nodes are not part of the rollout, skipped nodes are upgraded by other means
+		if nodeAnnotationEnabled(&nodes[i], constants.NodeHold) {
+			continue
+		}
+		total++
+		if nodeAnnotationEnabled(&nodes[i], constants.NodeSkip) {
+			completed++
+			continue
+		}
+
 		if !downgrade && semver.Compare(kubeVersion, nodes[i].Status.NodeInfo.KubeletVersion) < 0 {
 			return api.PlanStatusError, false, nil, fmt.Errorf("node %s version %s is newer than %s, but downgrade is disabled", nodes[i].GetName(), nodes[i].Status.NodeInfo.KubeletVersion, kubeVersion)
 		}
 
 		if nodes[i].Annotations[constants.NodeKubernetesVersion] == kubeVersion {
+			if _, ok := nodes[i].Annotations[constants.NodeRetry]; ok {
+				retryNode(&nodes[i], now)
+				needUpdate = true
+			}
+
 			switch nodes[i].Annotations[constants.NodeUpgradeStatus] {
 			case constants.NodeUpgradeStatusCompleted:
 				completed++
 			case constants.NodeUpgradeStatusError:
-				errorNodes = append(errorNodes, nodes[i].GetName())
+				if reason := nodes[i].Annotations[constants.NodeLastErrorReason]; reason != "" {
+					errorReasons = append(errorReasons, reason)
+				}
+				attempts := nodeUpgradeAttempts(&nodes[i])
+				if nodeFailed(&nodes[i], kubeVersion, policy) {
+					failedNodes = append(failedNodes, nodes[i].GetName())
+				} else if now.Before(nodeRetryTime(&nodes[i], attempts, policy)) {
+					retryingNodes = append(retryingNodes, nodes[i].GetName())
+				} else {
+					retryDue = append(retryDue, &nodes[i])
+				}
 			}
 			continue
 		}
 
 		nodes[i].Annotations[constants.NodeKubernetesVersion] = kubeVersion
 		nodes[i].Annotations[constants.NodeUpgradeStatus] = constants.NodeUpgradeStatusPending
+		nodes[i].Annotations[constants.NodeStatusTime] = now.UTC().Format(time.RFC3339)
+		delete(nodes[i].Annotations, constants.NodeUpgradeAttempts)
+		delete(nodes[i].Annotations, constants.NodeRetry)
+		delete(nodes[i].Annotations, constants.NodeLastError)
+		delete(nodes[i].Annotations, constants.NodeLastErrorTime)
+		delete(nodes[i].Annotations, constants.NodeLastErrorReason)
+
+		needUpdate = true
+	}
 
+	maxFailures, err := intstr.GetScaledValueFromIntOrPercent(policy.MaxFailures, total, false)
+	if err != nil {
+		// Should not happen, as the policy is validated by the webhook.
+		slog.Warn("Invalid failure budget, no failures are allowed", "maxFailures", policy.MaxFailures.String(), "err", err)
+		maxFailures = 0
+	}
+
+	// The group is stopped once the failure budget is exceeded, so no more retries are made
+	reasons := summarizeErrorReasons(errorReasons)
+	if reasons != "" {
+		reasons = " (" + reasons + ")"
+	}
+
+	locked := ""
+	if len(lockedNodes) > 0 {
+		locked = fmt.Sprintf(", excluded nodes %v still hold the lock", lockedNodes)
+	}
+
+	if len(failedNodes) > maxFailures {
+		return fmt.Sprintf("%s: The nodes %v are reporting errors%s%s", api.PlanStatusError, failedNodes, reasons, locked), needUpdate, nodes, nil
+	}
+
+	for _, node := range retryDue {
+		retryNode(node, now)
 		needUpdate = true
 	}
 
 	var status string
-	if len(errorNodes) > 0 {
-		status = fmt.Sprintf("%s: The nodes %v are reporting errors", api.PlanStatusError, errorNodes)
-	} else if len(nodes) == completed {
+	// The group is not complete until the lock has been released, as it could still block other groups
+	if total == completed+len(failedNodes) && len(lockedNodes) == 0 {
 		status = api.PlanStatusComplete
+		if len(failedNodes) > 0 {
+			status = fmt.Sprintf("%s: %d nodes failed %v%s", api.PlanStatusComplete, len(failedNodes), failedNodes, reasons)
+		}
 	} else {
-		status = fmt.Sprintf("%s: %d/%d nodes upgraded", api.PlanStatusProgressing, completed, len(nodes))
+		// Nodes without a live daemon are not making progress, the group is stalled when they are the only ones left
+		withoutDaemon := nodesWithoutDaemon(now, nodes)
+		prefix := api.PlanStatusProgressing
+		if len(withoutDaemon) > 0 && len(withoutDaemon) == total-completed-len(failedNodes) {
+			prefix = api.PlanStatusStalled
+		}
+		status = fmt.Sprintf("%s: %d/%d nodes upgraded", prefix, completed, total)
+		if len(retryingNodes) > 0 {
+			status += fmt.Sprintf(", waiting to retry %v", retryingNodes)
+		}
+		if len(failedNodes) > 0 {
+			status += fmt.Sprintf(", failed %v", failedNodes)
+		}
+		status += reasons
+		if len(withoutDaemon) > 0 {
+			status += fmt.Sprintf(", no live daemon on %v", withoutDaemon)
+		}
+		if outdated := nodesWithOutdatedDaemon(c.expectedUpgradedVersion(), nodes); len(outdated) > 0 {
+			status += fmt.Sprintf(", outdated daemon on %v", outdated)
+		}
+		status += locked
 	}
 	return status, needUpdate, nodes, nil
 }
+
+// Return the names of the nodes that should be upgrading, but have no live daemon to do so.
+// They are not making any progress, until the daemon is running again.
+func nodesWithoutDaemon(now time.Time, nodes []corev1.Node) []string {
+	withoutDaemon := make([]string, 0)
+	for i := range nodes {
+		node := &nodes[i]
+		if nodeAnnotationEnabled(node, constants.NodeHold) || nodeAnnotationEnabled(node, constants.NodeSkip) {
+			continue
+		}
+		switch node.Annotations[constants.NodeUpgradeStatus] {
+		case constants.NodeUpgradeStatusPending, constants.NodeUpgradeStatusUpgrading, constants.NodeUpgradeStatusRebasing, constants.NodeUpgradeStatusVerifying:
+			if !nodeDaemonAlive(node, now) {
+				withoutDaemon = append(withoutDaemon, node.GetName())
+			}
+		}
+	}
+	return withoutDaemon
+}
+
+// Return the names of the nodes, where the daemon runs a different version than the controller deploys
+func nodesWithOutdatedDaemon(expectedVersion string, nodes []corev1.Node) []string {
+	outdated := make([]string, 0)
+	if expectedVersion == "" {
+		return outdated
+	}
+	for _, node := range nodes {
+		version := node.Annotations[constants.NodeUpgradedVersion]
+		if version != "" && version != expectedVersion {
+			outdated = append(outdated, node.GetName())
+		}
+	}
+	return outdated
+}
+
+// Return the version of upgraded the controller deploys.
+// Returns an empty string, if the image is not tagged with a version.
+func (c *controller) expectedUpgradedVersion() string {
+	image := c.upgradedImage[strings.LastIndex(c.upgradedImage, "/")+1:]
+	_, tag, ok := strings.Cut(image, ":")
+	if !ok || !semver.IsValid(tag) {
+		return ""
+	}
+	return tag
+}
+
+// Reset an errored node to pending and count the attempt.
+// Removes the retry annotation in any case.
+func retryNode(node *corev1.Node, now time.Time) {
+	delete(node.Annotations, constants.NodeRetry)
+	if node.Annotations[constants.NodeUpgradeStatus] != constants.NodeUpgradeStatusError {
+		return
+	}
+
+	node.Annotations[constants.NodeUpgradeStatus] = constants.NodeUpgradeStatusPending
+	node.Annotations[constants.NodeStatusTime] = now.UTC().Format(time.RFC3339)
+	node.Annotations[constants.NodeUpgradeAttempts] = strconv.Itoa(nodeUpgradeAttempts(node) + 1)
+	delete(node.Annotations, constants.NodeLastErrorReason)
+}
+
+// Set the nodes to error that exceeded the timeout of their current status.
+// Nodes without the time of their last status change are ignored.
+// Returns the nodes that have been set to error.
+func markTimedOutNodes(kubeVersion string, timeouts map[string]time.Duration, now time.Time, nodes []corev1.Node) []*corev1.Node {
+	timedOut := make([]*corev1.Node, 0)
+	for i := range nodes {
+		node := &nodes[i]
+		if node.Annotations[constants.NodeKubernetesVersion] != kubeVersion || nodeAnnotationEnabled(node, constants.NodeHold) || nodeAnnotationEnabled(node, constants.NodeSkip) {
+			continue
+		}
+
+		status := node.Annotations[constants.NodeUpgradeStatus]
+		timeout, ok := timeouts[status]
+		if !ok {
+			continue
+		}
+		since, err := time.Parse(time.RFC3339, node.Annotations[constants.NodeStatusTime])
+		if err != nil || now.Sub(since) <= timeout {
+			continue
+		}
+
+		node.Annotations[constants.NodeUpgradeStatus] = constants.NodeUpgradeStatusError
+		node.Annotations[constants.NodeStatusTime] = now.UTC().Format(time.RFC3339)
+		node.Annotations[constants.NodeLastError] = fmt.Sprintf("exceeded the %s timeout of %s", status, timeout)
+		node.Annotations[constants.NodeLastErrorReason] = constants.NodeErrorReasonTimeout
+		node.Annotations[constants.NodeLastErrorTime] = now.UTC().Format(time.RFC3339)
+		timedOut = append(timedOut, node)
+	}
+	return timedOut
+}
+
+// Return the names of the nodes that are in error, because they exceeded the timeout of their phase
+func nodesStalledByTimeout(kubeVersion string, nodes []corev1.Node) []string {
+	stalled := make([]string, 0)
+	for _, node := range nodes {
+		if node.Annotations[constants.NodeKubernetesVersion] == kubeVersion &&
+			node.Annotations[constants.NodeUpgradeStatus] == constants.NodeUpgradeStatusError &&
+			node.Annotations[constants.NodeLastErrorReason] == constants.NodeErrorReasonTimeout {
+			stalled = append(stalled, node.GetName())
+		}
+	}
+	return stalled
+}
+
+// Return the names of the nodes that used up their attempts to upgrade to the kubernetes version.
+// Excluded nodes are not part of the rollout and don't count as failed.
+func nodesFailed(kubeVersion string, policy api.FailurePolicy, nodes []corev1.Node) []string {
+	failed := make([]string, 0)
+	for i := range nodes {
+		if !nodeExcludedFromRollout(&nodes[i]) && nodeFailed(&nodes[i], kubeVersion, policy) {
+			failed = append(failed, nodes[i].GetName())
+		}
+	}
+	return failed
+}
+
+// Check if the node is in error after using up its attempts to upgrade to the kubernetes version
+func nodeFailed(node *corev1.Node, kubeVersion string, policy api.FailurePolicy) bool {
+	return node.Annotations[constants.NodeKubernetesVersion] == kubeVersion &&
+		node.Annotations[constants.NodeUpgradeStatus] == constants.NodeUpgradeStatusError &&
+		nodeUpgradeAttempts(node) >= int(policy.MaxAttempts)
+}
+
+// Set the NodesFailed condition of the plan, depending on if there are nodes that used up their attempts
+func setNodesFailedCondition(plan *api.KubeUpgradePlan, failedNodes []string) {
+	condition := metav1.Condition{
+		Type:               api.PlanConditionNodesFailed,
+		Status:             metav1.ConditionFalse,
+		Reason:             api.PlanReasonNoFailedNodes,
+		Message:            "No node used up its upgrade attempts",
+		ObservedGeneration: plan.Generation,
+	}
+	if len(failedNodes) > 0 {
+		slices.Sort(failedNodes)
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = api.PlanReasonAttemptsExhausted
+		condition.Message = fmt.Sprintf("The nodes %v used up their upgrade attempts", failedNodes)
+	}
+	meta.SetStatusCondition(&plan.Status.Conditions, condition)
+}
+
+// Set the Stalled condition of the plan, depending on if there are nodes that exceeded their timeout
+// or groups where only nodes without a live daemon are left to upgrade
+func setStalledCondition(plan *api.KubeUpgradePlan, stalledNodes, noDaemonNodes []string) {
+	condition := metav1.Condition{
+		Type:               api.PlanConditionStalled,
+		Status:             metav1.ConditionFalse,
+		Reason:             api.PlanReasonNoTimeout,
+		Message:            "No node exceeded the timeout of its phase",
+		ObservedGeneration: plan.Generation,
+	}
+	if len(stalledNodes) > 0 {
+		slices.Sort(stalledNodes)
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = api.PlanReasonNodeTimeout
+		condition.Message = fmt.Sprintf("The nodes %v exceeded the timeout of their phase", stalledNodes)
+	} else if len(noDaemonNodes) > 0 {
+		slices.Sort(noDaemonNodes)
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = api.PlanReasonNoLiveDaemon
+		condition.Message = fmt.Sprintf("No node is making progress, the nodes %v have no live daemon", noDaemonNodes)
+	}
+	meta.SetStatusCondition(&plan.Status.Conditions, condition)
+}
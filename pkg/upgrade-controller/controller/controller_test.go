@@ -1,8 +1,11 @@
 package controller
 
 import (
+	"fmt"
 	"log/slog"
+	"maps"
 	"testing"
+	"time"
 
 	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
 	"github.com/heathcliff26/kube-upgrade/pkg/constants"
@@ -10,9 +13,13 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	appv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/events"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	controllerFake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
@@ -34,12 +41,44 @@ const (
 )
 
 func TestNewController(t *testing.T) {
-	c, err := NewController("test")
+	t.Run("NotInCluster", func(t *testing.T) {
+		c, err := NewController("test", DefaultOptions())
 
-	assert := assert.New(t)
+		assert := assert.New(t)
+
+		assert.Nil(c, "Should not return a client")
+		assert.Error(err, "Client creation should fail")
+	})
+	t.Run("Kubeconfig", func(t *testing.T) {
+		assert := assert.New(t)
+
+		opts := DefaultOptions()
+		opts.Kubeconfig = "testdata/kubeconfig.yaml"
+		opts.Namespace = "dev"
+		opts.MetricsBindAddress = "0"
+		opts.HealthProbeBindAddress = "0"
+		opts.LeaderElection = false
+		opts.DisableWebhooks = true
+
+		c, err := NewController("test", opts)
 
-	assert.Nil(c, "Should not return a client")
-	assert.Error(err, "Client creation should fail")
+		assert.NoError(err, "Should create the controller")
+		if !assert.NotNil(c, "Should return a controller") {
+			t.FailNow()
+		}
+		assert.Equal("dev", c.namespace, "Should use the namespace from the options")
+		assert.True(c.disableWebhooks, "Should disable the webhooks")
+	})
+	t.Run("InvalidWebhookBindAddress", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Kubeconfig = "testdata/kubeconfig.yaml"
+		opts.WebhookBindAddress = "9443"
+
+		c, err := NewController("test", opts)
+
+		assert.Nil(t, c, "Should not return a controller")
+		assert.Error(t, err, "Should fail to parse the bind address")
+	})
 }
 
 func TestReconcile(t *testing.T) {
@@ -451,6 +490,7 @@ func TestReconcile(t *testing.T) {
 			AnnotationsControl: map[string]string{
 				constants.NodeKubernetesVersion: "v1.31.0",
 				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusError,
+				constants.NodeUpgradeAttempts:   "3",
 			},
 			ExpectedSummary: api.PlanStatusError + ": Some groups encountered errors [control-plane]",
 			ExpectedGroupStatus: map[string]string{
@@ -459,6 +499,196 @@ func TestReconcile(t *testing.T) {
 			ExpectedAnnotationsControl: map[string]string{
 				constants.NodeKubernetesVersion: "v1.31.0",
 				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusError,
+				constants.NodeUpgradeAttempts:   "3",
+			},
+		},
+		{
+			Name: "RetryErrorNode",
+			Plan: api.KubeUpgradePlan{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "upgrade-plan",
+				},
+				Spec: api.KubeUpgradeSpec{
+					KubernetesVersion: "v1.31.0",
+					Groups: map[string]api.KubeUpgradePlanGroup{
+						groupControl: {
+							Labels: map[string]string{labelControl: labelValue},
+						},
+						groupCompute: {
+							DependsOn: []string{groupControl},
+							Labels:    map[string]string{labelCompute: labelValue},
+						},
+					},
+				},
+			},
+			AnnotationsControl: map[string]string{
+				constants.NodeKubernetesVersion: "v1.31.0",
+				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusError,
+				constants.NodeUpgradeAttempts:   "2",
+				constants.NodeRetry:             "true",
+			},
+			ExpectedSummary: api.PlanStatusProgressing + ": Upgrading groups [control-plane]",
+			ExpectedGroupStatus: map[string]string{
+				groupControl: api.PlanStatusProgressing + ": 0/1 nodes upgraded",
+				groupCompute: api.PlanStatusWaiting,
+			},
+			ExpectedAnnotationsControl: map[string]string{
+				constants.NodeKubernetesVersion: "v1.31.0",
+				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusPending,
+				constants.NodeUpgradeAttempts:   "3",
+			},
+		},
+		{
+			Name: "RetryNodeWithoutError",
+			Plan: api.KubeUpgradePlan{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "upgrade-plan",
+				},
+				Spec: api.KubeUpgradeSpec{
+					KubernetesVersion: "v1.31.0",
+					Groups: map[string]api.KubeUpgradePlanGroup{
+						groupControl: {
+							Labels: map[string]string{labelControl: labelValue},
+						},
+						groupCompute: {
+							DependsOn: []string{groupControl},
+							Labels:    map[string]string{labelCompute: labelValue},
+						},
+					},
+				},
+			},
+			AnnotationsControl: map[string]string{
+				constants.NodeKubernetesVersion: "v1.31.0",
+				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusCompleted,
+				constants.NodeRetry:             "true",
+			},
+			ExpectedSummary: api.PlanStatusProgressing + ": Upgrading groups [compute]",
+			ExpectedGroupStatus: map[string]string{
+				groupControl: api.PlanStatusComplete,
+				groupCompute: api.PlanStatusProgressing + ": 0/1 nodes upgraded",
+			},
+			ExpectedAnnotationsControl: map[string]string{
+				constants.NodeKubernetesVersion: "v1.31.0",
+				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusCompleted,
+			},
+			ExpectedAnnotationsCompute: map[string]string{
+				constants.NodeKubernetesVersion: "v1.31.0",
+				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusPending,
+			},
+		},
+		{
+			Name: "HeldNodeDoesNotBlockGroup",
+			Plan: api.KubeUpgradePlan{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "upgrade-plan",
+				},
+				Spec: api.KubeUpgradeSpec{
+					KubernetesVersion: "v1.31.0",
+					Groups: map[string]api.KubeUpgradePlanGroup{
+						groupControl: {
+							Labels: map[string]string{labelControl: labelValue},
+						},
+						groupCompute: {
+							DependsOn: []string{groupControl},
+							Labels:    map[string]string{labelCompute: labelValue},
+						},
+					},
+				},
+			},
+			AnnotationsControl: map[string]string{
+				constants.NodeKubernetesVersion: "v1.30.4",
+				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusError,
+				constants.NodeHold:              "true",
+			},
+			ExpectedSummary: api.PlanStatusProgressing + ": Upgrading groups [compute]",
+			ExpectedGroupStatus: map[string]string{
+				groupControl: api.PlanStatusComplete,
+				groupCompute: api.PlanStatusProgressing + ": 0/1 nodes upgraded",
+			},
+			ExpectedAnnotationsControl: map[string]string{
+				constants.NodeKubernetesVersion: "v1.30.4",
+				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusError,
+				constants.NodeHold:              "true",
+			},
+			ExpectedAnnotationsCompute: map[string]string{
+				constants.NodeKubernetesVersion: "v1.31.0",
+				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusPending,
+			},
+		},
+		{
+			Name: "HeldNodeHoldingLockBlocksGroup",
+			Plan: api.KubeUpgradePlan{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "upgrade-plan",
+				},
+				Spec: api.KubeUpgradeSpec{
+					KubernetesVersion: "v1.31.0",
+					Groups: map[string]api.KubeUpgradePlanGroup{
+						groupControl: {
+							Labels: map[string]string{labelControl: labelValue},
+						},
+						groupCompute: {
+							DependsOn: []string{groupControl},
+							Labels:    map[string]string{labelCompute: labelValue},
+						},
+					},
+				},
+			},
+			AnnotationsControl: map[string]string{
+				constants.NodeKubernetesVersion: "v1.31.0",
+				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusError,
+				constants.NodeHold:              "true",
+				constants.NodeLockHeld:          "true",
+			},
+			ExpectedSummary: api.PlanStatusProgressing + ": Upgrading groups [control-plane]",
+			ExpectedGroupStatus: map[string]string{
+				groupControl: api.PlanStatusProgressing + ": 0/0 nodes upgraded, excluded nodes [" + nodeControlName + "] still hold the lock",
+				groupCompute: api.PlanStatusWaiting,
+			},
+			ExpectedAnnotationsControl: map[string]string{
+				constants.NodeKubernetesVersion: "v1.31.0",
+				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusError,
+				constants.NodeHold:              "true",
+				constants.NodeLockHeld:          "true",
+			},
+		},
+		{
+			Name: "SkippedNodeCountsAsUpgraded",
+			Plan: api.KubeUpgradePlan{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "upgrade-plan",
+				},
+				Spec: api.KubeUpgradeSpec{
+					KubernetesVersion: "v1.31.0",
+					Groups: map[string]api.KubeUpgradePlanGroup{
+						groupControl: {
+							Labels: map[string]string{labelControl: labelValue},
+						},
+						groupCompute: {
+							DependsOn: []string{groupControl},
+							Labels:    map[string]string{labelCompute: labelValue},
+						},
+					},
+				},
+			},
+			AnnotationsCompute: map[string]string{
+				constants.NodeSkip: "true",
+			},
+			AnnotationsControl: map[string]string{
+				constants.NodeKubernetesVersion: "v1.31.0",
+				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusCompleted,
+			},
+			ExpectedSummary: api.PlanStatusComplete,
+			ExpectedGroupStatus: map[string]string{
+				groupControl: api.PlanStatusComplete,
+				groupCompute: api.PlanStatusComplete,
+			},
+			ExpectedAnnotationsControl: map[string]string{
+				constants.NodeKubernetesVersion: "v1.31.0",
+				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusCompleted,
+			},
+			ExpectedAnnotationsCompute: map[string]string{
+				constants.NodeSkip: "true",
 			},
 		},
 	}
@@ -484,6 +714,15 @@ func TestReconcile(t *testing.T) {
 			_ = c.Get(ctx, types.NamespacedName{Name: nodeComputeName}, nodeCompute)
 			_ = c.Get(ctx, types.NamespacedName{Name: nodeInfraName}, nodeInfra)
 
+			// The time of the status change is set by the controller when moving the node to pending
+			for _, node := range []*corev1.Node{nodeControl, nodeCompute, nodeInfra} {
+				if statusTime, ok := node.Annotations[constants.NodeStatusTime]; ok {
+					_, err := time.Parse(time.RFC3339, statusTime)
+					assert.NoError(err, "Node %s should have a valid status time", node.GetName())
+					delete(node.Annotations, constants.NodeStatusTime)
+				}
+			}
+
 			assert.Equal(tCase.ExpectedAnnotationsControl, nodeControl.GetAnnotations(), "Control group should have expected annotations")
 			assert.Equal(tCase.ExpectedAnnotationsCompute, nodeCompute.GetAnnotations(), "Compute group should have expected annotations")
 			assert.Equal(tCase.ExpectedAnnotationsInfra, nodeInfra.GetAnnotations(), "Infra group should have expected annotations")
@@ -511,14 +750,14 @@ func TestReconcileNodes(t *testing.T) {
 
 	assert := assert.New(t)
 
-	status, needUpdate, nodes, err := c.reconcileNodes("v1.31.0", false, []corev1.Node{*nodeControl})
+	status, needUpdate, nodes, err := c.reconcileNodes("v1.31.0", false, failurePolicy(api.KubeUpgradePlanGroup{}), time.Now(), []corev1.Node{*nodeControl})
 
 	assert.Equal(api.PlanStatusError, status, "Should return error status")
 	assert.False(needUpdate, "Should not request update")
 	assert.Nil(nodes, "Should not return nodes")
 	assert.Error(err, "Should return an error")
 
-	status, needUpdate, nodes, err = c.reconcileNodes("v1.31.0", true, []corev1.Node{*nodeControl})
+	status, needUpdate, nodes, err = c.reconcileNodes("v1.31.0", true, failurePolicy(api.KubeUpgradePlanGroup{}), time.Now(), []corev1.Node{*nodeControl})
 
 	assert.NotEqual(api.PlanStatusError, status, "Should not return error status")
 	assert.True(needUpdate, "Should request update")
@@ -681,6 +920,659 @@ func TestReconcileUpgradedDaemons(t *testing.T) {
 		assert.NoError(err, "Should get daemonset without error")
 		assert.Contains(cm.Data[upgradedconfig.DefaultConfigFile], api.DefaultUpgradedStream, "ConfigMap data should be updated")
 	})
+	t.Run("MountSecrets", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		plan := &api.KubeUpgradePlan{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "upgrade-plan",
+			},
+			Spec: api.KubeUpgradeSpec{
+				KubernetesVersion: "v1.31.0",
+				Upgraded: api.UpgradedConfig{
+					PullSecret: "fcos-k8s-pull-secret",
+					KubeadmDownload: &api.KubeadmDownloadConfig{
+						Secret: "kubeadm-mirror",
+					},
+				},
+				Groups: map[string]api.KubeUpgradePlanGroup{
+					groupControl: {
+						Labels: map[string]string{labelControl: labelValue},
+					},
+				},
+			},
+		}
+		c := createFakeController(nil, nil, nil, plan)
+
+		assert.NoError(c.reconcile(t.Context(), plan, slog.Default()), "Reconcile should succeed")
+
+		daemon := &appv1.DaemonSet{}
+		err := c.Get(t.Context(), client.ObjectKey{Name: "upgraded-" + groupControl, Namespace: c.namespace}, daemon)
+		require.NoError(err, "Should get daemonset without error")
+
+		secrets := map[string]string{}
+		for _, vol := range daemon.Spec.Template.Spec.Volumes {
+			if vol.Secret != nil {
+				secrets[vol.Name] = vol.Secret.SecretName
+			}
+		}
+		assert.Equal(map[string]string{"kubeadm-download": "kubeadm-mirror", "pull-secret": "fcos-k8s-pull-secret"}, secrets, "Should mount the configured secrets")
+		assert.Contains(daemon.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      "kubeadm-download",
+			MountPath: upgradedconfig.KubeadmDownloadSecretDir,
+			ReadOnly:  true,
+		}, "Should mount the kubeadm download secret into the container")
+		assert.Contains(daemon.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      "pull-secret",
+			MountPath: upgradedconfig.PullSecretDir,
+			ReadOnly:  true,
+		}, "Should mount the pull secret into the container")
+		assert.Empty(daemon.Spec.Template.Spec.ServiceAccountName, "Should not use a ServiceAccount by default")
+		assert.True(hasVolume(daemon, "kubelet-pki"), "Should mount the kubelet pki for the kubelet credentials")
+		assert.False(hasVolume(daemon, "node-hooks"), "Should not mount node hooks when none are configured")
+	})
+	t.Run("MountNodeHooks", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		plan := &api.KubeUpgradePlan{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "upgrade-plan",
+			},
+			Spec: api.KubeUpgradeSpec{
+				KubernetesVersion: "v1.31.0",
+				Groups: map[string]api.KubeUpgradePlanGroup{
+					groupControl: {
+						Labels: map[string]string{labelControl: labelValue},
+						Upgraded: &api.UpgradedConfig{
+							NodeHooks: &api.NodeHooksConfig{
+								ConfigMap: "kube-upgraded-hooks",
+							},
+						},
+					},
+				},
+			},
+		}
+		c := createFakeController(nil, nil, nil, plan)
+
+		assert.NoError(c.reconcile(t.Context(), plan, slog.Default()), "Reconcile should succeed")
+
+		daemon := &appv1.DaemonSet{}
+		err := c.Get(t.Context(), client.ObjectKey{Name: "upgraded-" + groupControl, Namespace: c.namespace}, daemon)
+		require.NoError(err, "Should get daemonset without error")
+
+		require.True(hasVolume(daemon, "node-hooks"), "Should add the node hooks volume")
+		for _, vol := range daemon.Spec.Template.Spec.Volumes {
+			if vol.Name == "node-hooks" {
+				require.NotNil(vol.ConfigMap, "Should be a ConfigMap volume")
+				assert.Equal("kube-upgraded-hooks", vol.ConfigMap.Name)
+			}
+		}
+		assert.Contains(daemon.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      "node-hooks",
+			MountPath: upgradedconfig.NodeHooksConfigMapDir,
+			ReadOnly:  true,
+		}, "Should mount the node hooks into the container")
+	})
+	t.Run("ServiceAccount", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		plan := &api.KubeUpgradePlan{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "upgrade-plan",
+			},
+			Spec: api.KubeUpgradeSpec{
+				KubernetesVersion: "v1.31.0",
+				Upgraded: api.UpgradedConfig{
+					ServiceAccountName: "kube-upgraded",
+				},
+				Groups: map[string]api.KubeUpgradePlanGroup{
+					groupControl: {
+						Labels: map[string]string{labelControl: labelValue},
+					},
+				},
+			},
+		}
+		c := createFakeController(nil, nil, nil, plan)
+
+		assert.NoError(c.reconcile(t.Context(), plan, slog.Default()), "Reconcile should succeed")
+
+		daemon := &appv1.DaemonSet{}
+		err := c.Get(t.Context(), client.ObjectKey{Name: "upgraded-" + groupControl, Namespace: c.namespace}, daemon)
+		require.NoError(err, "Should get daemonset without error")
+
+		assert.Equal("kube-upgraded", daemon.Spec.Template.Spec.ServiceAccountName, "Should use the ServiceAccount")
+		assert.False(hasVolume(daemon, "kubelet-pki"), "Should not mount the kubelet pki")
+	})
+}
+
+func TestReconcileNodesFailurePolicy(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	errorNode := func(attempts int, errorAgo time.Duration) map[string]string {
+		return map[string]string{
+			constants.NodeKubernetesVersion: "v1.31.0",
+			constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusError,
+			constants.NodeUpgradeAttempts:   fmt.Sprint(attempts),
+			constants.NodeLastError:         "failed run kubeadm",
+			constants.NodeLastErrorTime:     now.Add(-errorAgo).Format(time.RFC3339),
+		}
+	}
+	completedNode := map[string]string{
+		constants.NodeKubernetesVersion: "v1.31.0",
+		constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusCompleted,
+	}
+	pendingNode := map[string]string{
+		constants.NodeKubernetesVersion: "v1.31.0",
+		constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusPending,
+	}
+
+	tMatrix := []struct {
+		Name           string
+		Policy         api.FailurePolicy
+		Nodes          []map[string]string
+		Status         string
+		NeedUpdate     bool
+		ExpectedStatus []string
+	}{
+		{
+			Name:           "RetryAfterBackoff",
+			Nodes:          []map[string]string{errorNode(1, 6*time.Minute)},
+			Status:         api.PlanStatusProgressing + ": 0/1 nodes upgraded",
+			NeedUpdate:     true,
+			ExpectedStatus: []string{constants.NodeUpgradeStatusPending},
+		},
+		{
+			Name:           "WaitForDoubledBackoff",
+			Nodes:          []map[string]string{errorNode(2, 6*time.Minute)},
+			Status:         api.PlanStatusProgressing + ": 0/1 nodes upgraded, waiting to retry [node-0]",
+			ExpectedStatus: []string{constants.NodeUpgradeStatusError},
+		},
+		{
+			Name:           "AttemptsExhausted",
+			Nodes:          []map[string]string{errorNode(3, time.Hour)},
+			Status:         api.PlanStatusError + ": The nodes [node-0] are reporting errors",
+			ExpectedStatus: []string{constants.NodeUpgradeStatusError},
+		},
+		{
+			Name: "RetriesDisabled",
+			Policy: api.FailurePolicy{
+				MaxAttempts: 1,
+			},
+			Nodes:          []map[string]string{errorNode(1, time.Hour)},
+			Status:         api.PlanStatusError + ": The nodes [node-0] are reporting errors",
+			ExpectedStatus: []string{constants.NodeUpgradeStatusError},
+		},
+		{
+			Name: "CompleteWithinFailureBudget",
+			Policy: api.FailurePolicy{
+				MaxFailures: Pointer(intstr.FromInt32(1)),
+			},
+			Nodes:          []map[string]string{errorNode(3, time.Hour), completedNode, completedNode},
+			Status:         api.PlanStatusComplete + ": 1 nodes failed [node-0]",
+			ExpectedStatus: []string{constants.NodeUpgradeStatusError, constants.NodeUpgradeStatusCompleted, constants.NodeUpgradeStatusCompleted},
+		},
+		{
+			Name: "ContinueWithinPercentageBudget",
+			Policy: api.FailurePolicy{
+				MaxFailures: Pointer(intstr.FromString("50%")),
+			},
+			Nodes:          []map[string]string{errorNode(3, time.Hour), completedNode, pendingNode},
+			Status:         api.PlanStatusProgressing + ": 1/3 nodes upgraded, failed [node-0]",
+			ExpectedStatus: []string{constants.NodeUpgradeStatusError, constants.NodeUpgradeStatusCompleted, constants.NodeUpgradeStatusPending},
+		},
+		{
+			Name: "AggregateErrorReasons",
+			Nodes: []map[string]string{
+				withAnnotations(errorNode(3, time.Hour), map[string]string{constants.NodeLastErrorReason: constants.NodeErrorReasonKubeadmUpgradeFailed}),
+				withAnnotations(errorNode(3, time.Hour), map[string]string{constants.NodeLastErrorReason: constants.NodeErrorReasonRebaseFailed}),
+				withAnnotations(errorNode(2, time.Minute), map[string]string{constants.NodeLastErrorReason: constants.NodeErrorReasonKubeadmUpgradeFailed}),
+			},
+			Status:         api.PlanStatusError + ": The nodes [node-0 node-1] are reporting errors (2 nodes: KubeadmUpgradeFailed, 1 node: RebaseFailed)",
+			ExpectedStatus: []string{constants.NodeUpgradeStatusError, constants.NodeUpgradeStatusError, constants.NodeUpgradeStatusError},
+		},
+		{
+			Name: "ErrorReasonsWhileProgressing",
+			Nodes: []map[string]string{
+				withAnnotations(errorNode(2, time.Minute), map[string]string{constants.NodeLastErrorReason: constants.NodeErrorReasonRebaseFailed}),
+				pendingNode,
+			},
+			Status:         api.PlanStatusProgressing + ": 0/2 nodes upgraded, waiting to retry [node-0] (1 node: RebaseFailed)",
+			ExpectedStatus: []string{constants.NodeUpgradeStatusError, constants.NodeUpgradeStatusPending},
+		},
+		{
+			Name: "NoLiveDaemon",
+			Nodes: []map[string]string{completedNode, withAnnotations(pendingNode, map[string]string{
+				constants.NodeHeartbeat: now.Add(-10 * time.Minute).Format(time.RFC3339),
+			})},
+			Status:         api.PlanStatusStalled + ": 1/2 nodes upgraded, no live daemon on [node-1]",
+			ExpectedStatus: []string{constants.NodeUpgradeStatusCompleted, constants.NodeUpgradeStatusPending},
+		},
+		{
+			Name: "NoLiveDaemonOnSomeNodes",
+			Nodes: []map[string]string{pendingNode, withAnnotations(pendingNode, map[string]string{
+				constants.NodeHeartbeat: now.Add(-10 * time.Minute).Format(time.RFC3339),
+			})},
+			Status:         api.PlanStatusProgressing + ": 0/2 nodes upgraded, no live daemon on [node-1]",
+			ExpectedStatus: []string{constants.NodeUpgradeStatusPending, constants.NodeUpgradeStatusPending},
+		},
+		{
+			Name: "DaemonNeverStarted",
+			Nodes: []map[string]string{withAnnotations(pendingNode, map[string]string{
+				constants.NodeStatusTime: now.Add(-10 * time.Minute).Format(time.RFC3339),
+			})},
+			Status:         api.PlanStatusStalled + ": 0/1 nodes upgraded, no live daemon on [node-0]",
+			ExpectedStatus: []string{constants.NodeUpgradeStatusPending},
+		},
+		{
+			Name: "DaemonStarting",
+			Nodes: []map[string]string{withAnnotations(pendingNode, map[string]string{
+				constants.NodeStatusTime: now.Add(-time.Minute).Format(time.RFC3339),
+			})},
+			Status:         api.PlanStatusProgressing + ": 0/1 nodes upgraded",
+			ExpectedStatus: []string{constants.NodeUpgradeStatusPending},
+		},
+		{
+			Name: "LiveDaemon",
+			Nodes: []map[string]string{withAnnotations(pendingNode, map[string]string{
+				constants.NodeStatusTime: now.Add(-time.Hour).Format(time.RFC3339),
+				constants.NodeHeartbeat:  now.Add(-time.Minute).Format(time.RFC3339),
+			})},
+			Status:         api.PlanStatusProgressing + ": 0/1 nodes upgraded",
+			ExpectedStatus: []string{constants.NodeUpgradeStatusPending},
+		},
+		{
+			Name: "OutdatedDaemon",
+			Nodes: []map[string]string{
+				withAnnotations(completedNode, map[string]string{constants.NodeUpgradedVersion: "v0.9.0"}),
+				withAnnotations(pendingNode, map[string]string{constants.NodeUpgradedVersion: "v1.0.0"}),
+			},
+			Status:         api.PlanStatusProgressing + ": 1/2 nodes upgraded, outdated daemon on [node-0]",
+			ExpectedStatus: []string{constants.NodeUpgradeStatusCompleted, constants.NodeUpgradeStatusPending},
+		},
+		{
+			Name:           "BudgetExceededStopsRetries",
+			Nodes:          []map[string]string{errorNode(3, time.Hour), errorNode(1, time.Hour)},
+			Status:         api.PlanStatusError + ": The nodes [node-0] are reporting errors",
+			ExpectedStatus: []string{constants.NodeUpgradeStatusError, constants.NodeUpgradeStatusError},
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			nodes := make([]corev1.Node, 0, len(tCase.Nodes))
+			for i, annotations := range tCase.Nodes {
+				nodes = append(nodes, corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        fmt.Sprintf("node-%d", i),
+						Annotations: maps.Clone(annotations),
+					},
+				})
+			}
+			policy := tCase.Policy
+			api.SetObjectDefaults_FailurePolicy(&policy)
+
+			c := &controller{
+				upgradedImage: defaultUpgradedImage + ":v1.0.0",
+			}
+			status, needUpdate, nodes, err := c.reconcileNodes("v1.31.0", false, policy, now, nodes)
+
+			assert.NoError(err, "Should succeed")
+			assert.Equal(tCase.Status, status, "Should return the group status")
+			assert.Equal(tCase.NeedUpdate, needUpdate, "Should only update nodes when retrying")
+			for i, expected := range tCase.ExpectedStatus {
+				assert.Equal(expected, nodes[i].Annotations[constants.NodeUpgradeStatus], "Node %d should have the expected status", i)
+			}
+		})
+	}
+
+	t.Run("RetryCountsAttempt", func(t *testing.T) {
+		assert := assert.New(t)
+
+		nodes := []corev1.Node{{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-0", Annotations: errorNode(1, time.Hour)},
+		}}
+
+		c := &controller{}
+		_, _, nodes, err := c.reconcileNodes("v1.31.0", false, failurePolicy(api.KubeUpgradePlanGroup{}), now, nodes)
+
+		assert.NoError(err, "Should succeed")
+		assert.Equal("2", nodes[0].Annotations[constants.NodeUpgradeAttempts], "Should count the attempt")
+		assert.Equal("failed run kubeadm", nodes[0].Annotations[constants.NodeLastError], "Should keep the last error")
+		assert.Equal(now.Format(time.RFC3339), nodes[0].Annotations[constants.NodeStatusTime], "Should record the time the node was reset to pending")
+	})
+	t.Run("NewVersionResetsAttempts", func(t *testing.T) {
+		assert := assert.New(t)
+
+		nodes := []corev1.Node{{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-0", Annotations: errorNode(3, time.Hour)},
+		}}
+
+		c := &controller{}
+		_, _, nodes, err := c.reconcileNodes("v1.31.1", false, failurePolicy(api.KubeUpgradePlanGroup{}), now, nodes)
+
+		assert.NoError(err, "Should succeed")
+		assert.Equal(map[string]string{
+			constants.NodeKubernetesVersion: "v1.31.1",
+			constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusPending,
+			constants.NodeStatusTime:        now.Format(time.RFC3339),
+		}, nodes[0].Annotations, "Should start over for the new version")
+	})
+}
+
+// Groups completing with failed nodes within their budget don't block the rollout, so dependent groups and the hooks continue
+func TestReconcileCompleteWithFailedNodes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const version = "v1.31.0"
+	plan := &api.KubeUpgradePlan{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "upgrade-plan",
+		},
+		Spec: api.KubeUpgradeSpec{
+			KubernetesVersion: version,
+			Groups: map[string]api.KubeUpgradePlanGroup{
+				groupControl: {
+					Labels:        map[string]string{labelControl: labelValue},
+					FailurePolicy: &api.FailurePolicy{MaxFailures: Pointer(intstr.FromInt32(1))},
+					PostUpgrade:   newTestHookTemplate(),
+				},
+				groupCompute: {
+					DependsOn: []string{groupControl},
+					Labels:    map[string]string{labelCompute: labelValue},
+				},
+			},
+		},
+	}
+	c := createFakeController(nil, nil, nil, plan)
+
+	require.NoError(c.reconcile(t.Context(), plan, slog.Default()), "Reconcile should succeed")
+
+	node := &corev1.Node{}
+	require.NoError(c.Get(t.Context(), types.NamespacedName{Name: nodeControlName}, node), "Should get node")
+	node.Annotations[constants.NodeUpgradeStatus] = constants.NodeUpgradeStatusError
+	node.Annotations[constants.NodeUpgradeAttempts] = "3"
+	node.Annotations[constants.NodeLastErrorReason] = constants.NodeErrorReasonRebaseFailed
+	require.NoError(c.Update(t.Context(), node), "Should update node")
+
+	require.NoError(c.reconcile(t.Context(), plan, slog.Default()), "Reconcile should succeed")
+
+	postUpgrade := hookJobName(plan.Name, groupControl, hookPostUpgrade, version)
+	assert.Equal(api.PlanStatusProgressing+": Running postUpgrade hook job "+postUpgrade, plan.Status.Groups[groupControl], "Should run the postUpgrade hook of the group")
+	assert.Equal(api.PlanStatusWaiting, plan.Status.Groups[groupCompute], "Should wait for the postUpgrade hook")
+
+	condition := meta.FindStatusCondition(plan.Status.Conditions, api.PlanConditionNodesFailed)
+	require.NotNil(condition, "Should set the NodesFailed condition")
+	assert.Equal(metav1.ConditionTrue, condition.Status)
+	assert.Equal(api.PlanReasonAttemptsExhausted, condition.Reason)
+	assert.Equal("The nodes ["+nodeControlName+"] used up their upgrade attempts", condition.Message)
+
+	finishHookJob(t, c, postUpgrade, batchv1.JobComplete)
+	require.NoError(c.reconcile(t.Context(), plan, slog.Default()), "Reconcile should succeed")
+
+	assert.Equal(api.PlanStatusComplete+": 1 nodes failed ["+nodeControlName+"] (1 node: RebaseFailed)", plan.Status.Groups[groupControl], "Should report the failed nodes")
+	assert.Equal(api.PlanStatusProgressing+": 0/1 nodes upgraded", plan.Status.Groups[groupCompute], "Should start the dependent group")
+
+	setNodeUpgradeStatus(t, c, nodeComputeName, version, constants.NodeUpgradeStatusCompleted)
+	require.NoError(c.reconcile(t.Context(), plan, slog.Default()), "Reconcile should succeed")
+
+	assert.Equal(api.PlanStatusComplete+": Some groups have failed nodes ["+groupControl+"]", plan.Status.Summary, "Should complete the plan with the failed nodes")
+	assert.True(meta.IsStatusConditionTrue(plan.Status.Conditions, api.PlanConditionNodesFailed), "Should keep the condition while the nodes are failed")
+}
+
+func TestMarkTimedOutNodes(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	node := func(status string, since time.Duration) map[string]string {
+		return map[string]string{
+			constants.NodeKubernetesVersion: "v1.31.0",
+			constants.NodeUpgradeStatus:     status,
+			constants.NodeStatusTime:        now.Add(-since).Format(time.RFC3339),
+		}
+	}
+	timeouts := phaseTimeouts(api.KubeUpgradeSpec{
+		Timeouts: &api.PhaseTimeouts{
+			Pending:   "6h",
+			Upgrading: "1h",
+			Rebasing:  "1h",
+			Verifying: "30m",
+		},
+	})
+
+	tMatrix := []struct {
+		Name        string
+		Annotations map[string]string
+		TimedOut    bool
+	}{
+		{
+			Name:        "UpgradingWithinTimeout",
+			Annotations: node(constants.NodeUpgradeStatusUpgrading, 30*time.Minute),
+		},
+		{
+			Name:        "UpgradingTimedOut",
+			Annotations: node(constants.NodeUpgradeStatusUpgrading, 2*time.Hour),
+			TimedOut:    true,
+		},
+		{
+			Name:        "RebasingTimedOut",
+			Annotations: node(constants.NodeUpgradeStatusRebasing, 2*time.Hour),
+			TimedOut:    true,
+		},
+		{
+			Name:        "VerifyingTimedOut",
+			Annotations: node(constants.NodeUpgradeStatusVerifying, time.Hour),
+			TimedOut:    true,
+		},
+		{
+			Name:        "PendingWithinTimeout",
+			Annotations: node(constants.NodeUpgradeStatusPending, 2*time.Hour),
+		},
+		{
+			Name:        "PendingTimedOut",
+			Annotations: node(constants.NodeUpgradeStatusPending, 7*time.Hour),
+			TimedOut:    true,
+		},
+		{
+			Name:        "CompletedHasNoTimeout",
+			Annotations: node(constants.NodeUpgradeStatusCompleted, 24*time.Hour),
+		},
+		{
+			Name: "MissingStatusTime",
+			Annotations: map[string]string{
+				constants.NodeKubernetesVersion: "v1.31.0",
+				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusUpgrading,
+			},
+		},
+		{
+			Name: "OtherVersion",
+			Annotations: map[string]string{
+				constants.NodeKubernetesVersion: "v1.30.0",
+				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusUpgrading,
+				constants.NodeStatusTime:        now.Add(-2 * time.Hour).Format(time.RFC3339),
+			},
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			nodes := []corev1.Node{{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-0", Annotations: maps.Clone(tCase.Annotations)},
+			}}
+
+			timedOut := markTimedOutNodes("v1.31.0", timeouts, now, nodes)
+
+			if !tCase.TimedOut {
+				assert.Empty(timedOut, "Should not time out")
+				assert.Equal(tCase.Annotations, nodes[0].Annotations, "Should not change the node")
+				return
+			}
+			assert.Len(timedOut, 1, "Should time out")
+			assert.Equal(constants.NodeUpgradeStatusError, nodes[0].Annotations[constants.NodeUpgradeStatus], "Should set the node to error")
+			assert.Equal(constants.NodeErrorReasonTimeout, nodes[0].Annotations[constants.NodeLastErrorReason], "Should record the reason")
+			assert.Contains(nodes[0].Annotations[constants.NodeLastError], "exceeded the "+tCase.Annotations[constants.NodeUpgradeStatus]+" timeout", "Should record the phase that timed out")
+			assert.Equal(now.Format(time.RFC3339), nodes[0].Annotations[constants.NodeLastErrorTime], "Should record the time of the error")
+			assert.Equal([]string{"node-0"}, nodesStalledByTimeout("v1.31.0", nodes), "Should report the node as stalled")
+		})
+	}
+}
+
+func TestReconcileNoLiveDaemon(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	plan := &api.KubeUpgradePlan{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "upgrade-plan",
+		},
+		Spec: api.KubeUpgradeSpec{
+			KubernetesVersion: "v1.31.0",
+			Groups: map[string]api.KubeUpgradePlanGroup{
+				groupControl: {
+					Labels: map[string]string{labelControl: labelValue},
+				},
+			},
+		},
+	}
+	c := createFakeController(map[string]string{
+		constants.NodeKubernetesVersion: "v1.31.0",
+		constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusPending,
+		constants.NodeStatusTime:        time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+	}, nil, nil, plan)
+
+	require.NoError(c.reconcile(t.Context(), plan, slog.Default()), "Reconcile should succeed")
+
+	assert.Equal(api.PlanStatusStalled+": 0/1 nodes upgraded, no live daemon on ["+nodeControlName+"]", plan.Status.Groups[groupControl], "Should not count the node as progressing")
+	assert.Equal(api.PlanStatusStalled+": No node is making progress in groups ["+groupControl+"]", plan.Status.Summary, "Should report the plan as stalled")
+
+	condition := meta.FindStatusCondition(plan.Status.Conditions, api.PlanConditionStalled)
+	require.NotNil(condition, "Should set the stalled condition")
+	assert.Equal(metav1.ConditionTrue, condition.Status, "Should be stalled")
+	assert.Equal(api.PlanReasonNoLiveDaemon, condition.Reason, "Should be stalled by the missing daemon")
+	assert.Contains(condition.Message, nodeControlName, "Should name the node without a daemon")
+
+	t.Run("DaemonRecovers", func(t *testing.T) {
+		node := &corev1.Node{}
+		require.NoError(c.Get(t.Context(), types.NamespacedName{Name: nodeControlName}, node), "Should get node")
+		node.Annotations[constants.NodeHeartbeat] = time.Now().UTC().Format(time.RFC3339)
+		require.NoError(c.Update(t.Context(), node), "Should update node")
+
+		require.NoError(c.reconcile(t.Context(), plan, slog.Default()), "Reconcile should succeed")
+
+		assert.Equal(api.PlanStatusProgressing+": 0/1 nodes upgraded", plan.Status.Groups[groupControl], "Should progress again")
+		assert.False(meta.IsStatusConditionTrue(plan.Status.Conditions, api.PlanConditionStalled), "Should no longer be stalled")
+	})
+}
+
+func TestMarkTimedOutNodesWithoutTimeouts(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	annotations := map[string]string{
+		constants.NodeKubernetesVersion: "v1.31.0",
+		constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusRebasing,
+		constants.NodeStatusTime:        now.Add(-24 * time.Hour).Format(time.RFC3339),
+	}
+	nodes := []corev1.Node{{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-0", Annotations: maps.Clone(annotations)},
+	}}
+
+	timeouts := phaseTimeouts(api.KubeUpgradeSpec{})
+	assert.Empty(timeouts, "Should not have default timeouts")
+	assert.Empty(markTimedOutNodes("v1.31.0", timeouts, now, nodes), "Should not time out without a configured timeout")
+	assert.Equal(annotations, nodes[0].Annotations, "Should not change the node")
+}
+
+func TestReconcileTimeouts(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	plan := &api.KubeUpgradePlan{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "upgrade-plan",
+		},
+		Spec: api.KubeUpgradeSpec{
+			KubernetesVersion: "v1.31.0",
+			Groups: map[string]api.KubeUpgradePlanGroup{
+				groupControl: {
+					Labels: map[string]string{labelControl: labelValue},
+				},
+			},
+			Timeouts: &api.PhaseTimeouts{
+				Rebasing: "1h",
+			},
+		},
+	}
+	c := createFakeController(map[string]string{
+		constants.NodeKubernetesVersion: "v1.31.0",
+		constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusRebasing,
+		constants.NodeStatusTime:        time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339),
+	}, nil, nil, plan)
+
+	require.NoError(c.reconcile(t.Context(), plan, slog.Default()), "Reconcile should succeed")
+
+	node := &corev1.Node{}
+	require.NoError(c.Get(t.Context(), types.NamespacedName{Name: nodeControlName}, node), "Should get node")
+	assert.Equal(constants.NodeUpgradeStatusError, node.Annotations[constants.NodeUpgradeStatus], "Should set the node to error")
+	assert.Equal(constants.NodeErrorReasonTimeout, node.Annotations[constants.NodeLastErrorReason], "Should record the timeout")
+
+	condition := meta.FindStatusCondition(plan.Status.Conditions, api.PlanConditionStalled)
+	require.NotNil(condition, "Should set the stalled condition")
+	assert.Equal(metav1.ConditionTrue, condition.Status, "Should be stalled")
+	assert.Equal(api.PlanReasonNodeTimeout, condition.Reason, "Should be stalled by the timeout")
+	assert.Contains(condition.Message, nodeControlName, "Should name the stalled node")
+
+	recorder := c.recorder.(*events.FakeRecorder)
+	require.Len(recorder.Events, 1, "Should emit an event")
+	assert.Contains(<-recorder.Events, "Warning NodeTimeout Node node-control exceeded the rebasing timeout of 1h0m0s", "Should emit a warning for the node")
+
+	t.Run("RetryClearsCondition", func(t *testing.T) {
+		node.Annotations[constants.NodeLastErrorTime] = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		require.NoError(c.Update(t.Context(), node), "Should update node")
+
+		require.NoError(c.reconcile(t.Context(), plan, slog.Default()), "Reconcile should succeed")
+
+		condition := meta.FindStatusCondition(plan.Status.Conditions, api.PlanConditionStalled)
+		require.NotNil(condition, "Should keep the stalled condition")
+		assert.Equal(metav1.ConditionFalse, condition.Status, "Should no longer be stalled once the node is retried")
+	})
+}
+
+func hasVolume(ds *appv1.DaemonSet, name string) bool {
+	for _, vol := range ds.Spec.Template.Spec.Volumes {
+		if vol.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExpectedUpgradedVersion(t *testing.T) {
+	tMatrix := map[string]string{
+		defaultUpgradedImage + ":v1.0.0":            "v1.0.0",
+		"registry.example.com:5000/upgraded:v1.2.3": "v1.2.3",
+		"registry.example.com:5000/upgraded":        "",
+		defaultUpgradedImage + ":latest":            "",
+		defaultUpgradedImage + ":(devel)":           "",
+	}
+
+	for image, expected := range tMatrix {
+		t.Run(image, func(t *testing.T) {
+			c := &controller{upgradedImage: image}
+			assert.Equal(t, expected, c.expectedUpgradedVersion())
+		})
+	}
+}
+
+// Return a copy of the annotations with the additional annotations set
+func withAnnotations(annotations, additional map[string]string) map[string]string {
+	result := maps.Clone(annotations)
+	maps.Copy(result, additional)
+	return result
 }
 
 func createFakeController(annotationsControl, annotationsCompute, annotationsInfra map[string]string, plan *api.KubeUpgradePlan) *controller {
@@ -721,6 +1613,7 @@ func createFakeController(annotationsControl, annotationsCompute, annotationsInf
 	c := &controller{
 		Client:    fakeCtrlClient,
 		namespace: "kube-upgrade",
+		recorder:  events.NewFakeRecorder(10),
 	}
 
 	return c
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"golang.org/x/mod/semver"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Compute the rollout for the plan without changing anything in the cluster.
+// The result is written to the status of the plan.
+func (c *controller) reconcileDryRun(ctx context.Context, plan *api.KubeUpgradePlan, logger *slog.Logger, kubeVersion string) error {
+	steps := groupSteps(plan.Spec.Groups)
+
+	dryRun := &api.DryRunStatus{
+		KubernetesVersion: kubeVersion,
+		Groups:            make(map[string]api.DryRunGroupStatus, len(plan.Spec.Groups)),
+	}
+	newGroupStatus := make(map[string]string, len(plan.Spec.Groups))
+
+	for name, cfg := range plan.Spec.Groups {
+		nodeList := &corev1.NodeList{}
+		err := c.List(ctx, nodeList, client.MatchingLabels(cfg.Labels))
+		if err != nil {
+			logger.Error("Failed to get nodes for group", "group", name, "err", err)
+			return err
+		}
+
+		groupDryRun := api.DryRunGroupStatus{
+			Step: steps[name],
+		}
+		for _, node := range nodeList.Items {
+			if nodeAnnotationEnabled(&node, constants.NodeHold) || nodeAnnotationEnabled(&node, constants.NodeSkip) {
+				continue
+			}
+			if !plan.Spec.AllowDowngrade && semver.Compare(kubeVersion, node.Status.NodeInfo.KubeletVersion) < 0 {
+				groupDryRun.DowngradeNodes = append(groupDryRun.DowngradeNodes, node.GetName())
+			} else if node.Annotations[constants.NodeKubernetesVersion] != kubeVersion {
+				groupDryRun.Nodes = append(groupDryRun.Nodes, node.GetName())
+			}
+		}
+		slices.Sort(groupDryRun.Nodes)
+		slices.Sort(groupDryRun.DowngradeNodes)
+
+		// Works on copies of the nodes, the changed annotations are discarded
+		status, _, _, err := c.reconcileNodes(kubeVersion, plan.Spec.AllowDowngrade, failurePolicy(cfg), time.Now(), nodeList.Items)
+		if err != nil {
+			status = fmt.Sprintf("%s: %v", api.PlanStatusError, err)
+		}
+		newGroupStatus[name] = status
+		dryRun.Groups[name] = groupDryRun
+	}
+
+	nodeCount := 0
+	for name, groupDryRun := range dryRun.Groups {
+		if len(groupDryRun.Nodes) == 0 {
+			continue
+		}
+		nodeCount += len(groupDryRun.Nodes)
+
+		for _, dep := range plan.Spec.Groups[name].DependsOn {
+			if !statusComplete(newGroupStatus[dep]) {
+				groupDryRun.WaitingOn = append(groupDryRun.WaitingOn, dep)
+			}
+		}
+		slices.Sort(groupDryRun.WaitingOn)
+		if len(groupDryRun.WaitingOn) > 0 && !strings.HasPrefix(newGroupStatus[name], api.PlanStatusError) {
+			newGroupStatus[name] = api.PlanStatusWaiting
+		}
+		dryRun.Groups[name] = groupDryRun
+	}
+
+	summary := fmt.Sprintf("%s: %d nodes would be upgraded to %s", api.PlanStatusDryRun, nodeCount, kubeVersion)
+	if plan.Status.Summary != summary {
+		logger.Info("Computed dry-run rollout", "nodes", nodeCount, "version", kubeVersion)
+	}
+
+	plan.Status.Groups = newGroupStatus
+	plan.Status.Summary = summary
+	plan.Status.DryRun = dryRun
+	return nil
+}
+
+// Return the step in which each group is upgraded, based on the dependencies between the groups.
+// Groups without dependencies are upgraded in the first step, groups with circular dependencies get 0.
+func groupSteps(groups map[string]api.KubeUpgradePlanGroup) map[string]int32 {
+	steps := make(map[string]int32, len(groups))
+	visiting := make(map[string]bool, len(groups))
+
+	var step func(name string) int32
+	step = func(name string) int32 {
+		if s, ok := steps[name]; ok {
+			return s
+		}
+		if visiting[name] {
+			return 0
+		}
+		visiting[name] = true
+		defer delete(visiting, name)
+
+		result := int32(1)
+		for _, dep := range groups[name].DependsOn {
+			depStep := step(dep)
+			if depStep == 0 {
+				result = 0
+				break
+			}
+			result = max(result, depStep+1)
+		}
+		steps[name] = result
+		return result
+	}
+
+	for name := range groups {
+		step(name)
+	}
+	return steps
+}
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"log/slog"
+	"testing"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newTestDryRunPlan() *api.KubeUpgradePlan {
+	return &api.KubeUpgradePlan{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "upgrade-plan",
+		},
+		Spec: api.KubeUpgradeSpec{
+			KubernetesVersion: "v1.31.0",
+			DryRun:            true,
+			Groups: map[string]api.KubeUpgradePlanGroup{
+				groupControl: {
+					Labels: map[string]string{labelControl: labelValue},
+				},
+				groupCompute: {
+					DependsOn: []string{groupControl, groupInfra},
+					Labels:    map[string]string{labelCompute: labelValue},
+				},
+				groupInfra: {
+					DependsOn: []string{groupControl},
+					Labels:    map[string]string{labelInfra: labelValue},
+				},
+			},
+		},
+	}
+}
+
+func TestReconcileDryRun(t *testing.T) {
+	t.Run("InitialRollout", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		plan := newTestDryRunPlan()
+		c := createFakeController(nil, nil, nil, plan)
+		ctx := t.Context()
+
+		err := c.reconcile(ctx, plan, slog.Default())
+		require.NoError(err, "Reconcile should succeed")
+
+		assert.Equal(api.PlanStatusDryRun+": 3 nodes would be upgraded to v1.31.0", plan.Status.Summary, "Should summarize the rollout")
+		assert.Equal(map[string]string{
+			groupControl: api.PlanStatusProgressing + ": 0/1 nodes upgraded",
+			groupCompute: api.PlanStatusWaiting,
+			groupInfra:   api.PlanStatusWaiting,
+		}, plan.Status.Groups, "Should show the group status")
+		require.NotNil(plan.Status.DryRun, "Should set the dry-run status")
+		assert.Equal("v1.31.0", plan.Status.DryRun.KubernetesVersion, "Should contain the target version")
+		assert.Equal(map[string]api.DryRunGroupStatus{
+			groupControl: {Step: 1, Nodes: []string{nodeControlName}},
+			groupInfra:   {Step: 2, Nodes: []string{nodeInfraName}, WaitingOn: []string{groupControl}},
+			groupCompute: {Step: 3, Nodes: []string{nodeComputeName}, WaitingOn: []string{groupControl, groupInfra}},
+		}, plan.Status.DryRun.Groups, "Should show the rollout")
+
+		for _, name := range []string{nodeControlName, nodeComputeName, nodeInfraName} {
+			node := &corev1.Node{}
+			require.NoError(c.Get(ctx, types.NamespacedName{Name: name}, node))
+			assert.Empty(node.GetAnnotations(), "Should not annotate node %s", name)
+		}
+
+		dsList := &appv1.DaemonSetList{}
+		require.NoError(c.List(ctx, dsList))
+		assert.Empty(dsList.Items, "Should not create DaemonSets")
+		cmList := &corev1.ConfigMapList{}
+		require.NoError(c.List(ctx, cmList))
+		assert.Empty(cmList.Items, "Should not create ConfigMaps")
+	})
+	t.Run("PartiallyUpgraded", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		plan := newTestDryRunPlan()
+		completed := map[string]string{
+			constants.NodeKubernetesVersion: "v1.31.0",
+			constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusCompleted,
+		}
+		c := createFakeController(completed, nil, nil, plan)
+
+		err := c.reconcile(t.Context(), plan, slog.Default())
+		require.NoError(err, "Reconcile should succeed")
+
+		assert.Equal(api.PlanStatusDryRun+": 2 nodes would be upgraded to v1.31.0", plan.Status.Summary, "Should only count nodes that need an upgrade")
+		assert.Empty(plan.Status.DryRun.Groups[groupControl].Nodes, "Should not upgrade completed nodes")
+		assert.Empty(plan.Status.DryRun.Groups[groupInfra].WaitingOn, "Should not wait on completed groups")
+		assert.Equal([]string{groupInfra}, plan.Status.DryRun.Groups[groupCompute].WaitingOn, "Should wait on incomplete groups")
+	})
+	t.Run("Downgrade", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		plan := newTestDryRunPlan()
+		c := createFakeController(nil, nil, nil, plan)
+		ctx := t.Context()
+
+		node := &corev1.Node{}
+		require.NoError(c.Get(ctx, types.NamespacedName{Name: nodeInfraName}, node))
+		node.Status.NodeInfo.KubeletVersion = "v1.32.0"
+		require.NoError(c.Status().Update(ctx, node))
+
+		err := c.reconcile(ctx, plan, slog.Default())
+		require.NoError(err, "Reconcile should succeed in dry-run mode")
+
+		assert.Equal([]string{nodeInfraName}, plan.Status.DryRun.Groups[groupInfra].DowngradeNodes, "Should report the node failing the downgrade check")
+		assert.Empty(plan.Status.DryRun.Groups[groupInfra].Nodes, "Should not upgrade the node")
+		assert.Contains(plan.Status.Groups[groupInfra], api.PlanStatusError, "Group should show the error")
+	})
+	t.Run("Disabled", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		plan := newTestDryRunPlan()
+		c := createFakeController(nil, nil, nil, plan)
+		ctx := t.Context()
+
+		require.NoError(c.reconcile(ctx, plan, slog.Default()), "Reconcile should succeed")
+		require.NotNil(plan.Status.DryRun, "Should set the dry-run status")
+
+		plan.Spec.DryRun = false
+		require.NoError(c.reconcile(ctx, plan, slog.Default()), "Reconcile should succeed")
+
+		assert.Nil(plan.Status.DryRun, "Should clear the dry-run status")
+		node := &corev1.Node{}
+		require.NoError(c.Get(ctx, types.NamespacedName{Name: nodeControlName}, node))
+		assert.Equal("v1.31.0", node.GetAnnotations()[constants.NodeKubernetesVersion], "Should start the rollout")
+	})
+}
+
+func TestGroupSteps(t *testing.T) {
+	tMatrix := []struct {
+		Name   string
+		Groups map[string]api.KubeUpgradePlanGroup
+		Result map[string]int32
+	}{
+		{
+			Name: "NoDependencies",
+			Groups: map[string]api.KubeUpgradePlanGroup{
+				"a": {},
+				"b": {},
+			},
+			Result: map[string]int32{"a": 1, "b": 1},
+		},
+		{
+			Name: "Chain",
+			Groups: map[string]api.KubeUpgradePlanGroup{
+				"a": {},
+				"b": {DependsOn: []string{"a"}},
+				"c": {DependsOn: []string{"b"}},
+			},
+			Result: map[string]int32{"a": 1, "b": 2, "c": 3},
+		},
+		{
+			Name: "LongestPath",
+			Groups: map[string]api.KubeUpgradePlanGroup{
+				"a": {},
+				"b": {DependsOn: []string{"a"}},
+				"c": {DependsOn: []string{"a", "b"}},
+				"d": {DependsOn: []string{"a"}},
+			},
+			Result: map[string]int32{"a": 1, "b": 2, "c": 3, "d": 2},
+		},
+		{
+			Name: "Circular",
+			Groups: map[string]api.KubeUpgradePlanGroup{
+				"a": {},
+				"b": {DependsOn: []string{"a", "c"}},
+				"c": {DependsOn: []string{"b"}},
+				"d": {DependsOn: []string{"c"}},
+			},
+			Result: map[string]int32{"a": 1, "b": 0, "c": 0, "d": 0},
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert.Equal(t, tCase.Result, groupSteps(tCase.Groups))
+		})
+	}
+}
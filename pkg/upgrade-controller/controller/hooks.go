@@ -0,0 +1,270 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	hookPreUpgrade  = "preUpgrade"
+	hookPostUpgrade = "postUpgrade"
+
+	eventReasonHookFailed = "HookFailed"
+
+	// The maximum length of a Job name, as it is used for the job-name label of its pods
+	maxJobNameLength = 63
+)
+
+// Environment variables passed to all containers of a hook Job
+const (
+	hookEnvPlan    = "KUBE_UPGRADE_PLAN"
+	hookEnvGroup   = "KUBE_UPGRADE_GROUP"
+	hookEnvHook    = "KUBE_UPGRADE_HOOK"
+	hookEnvVersion = "KUBE_UPGRADE_VERSION"
+)
+
+// Return the hooks status for the kubernetes version.
+// A new status is started when there are nodes to upgrade to the version.
+// Returns nil when there is no rollout of the version, as hooks added during a rollout only run for the next one.
+func hooksForVersion(current *api.HooksStatus, kubeVersion string, pending bool) *api.HooksStatus {
+	if current != nil && current.KubernetesVersion == kubeVersion {
+		return current
+	}
+	if !pending {
+		return nil
+	}
+	return &api.HooksStatus{KubernetesVersion: kubeVersion}
+}
+
+// Check if any node of the group needs to be upgraded to the kubernetes version
+func groupRolloutPending(kubeVersion string, nodes []corev1.Node) bool {
+	for i := range nodes {
+		if nodeAnnotationEnabled(&nodes[i], constants.NodeHold) || nodeAnnotationEnabled(&nodes[i], constants.NodeSkip) {
+			continue
+		}
+		if nodes[i].Annotations[constants.NodeKubernetesVersion] != kubeVersion {
+			return true
+		}
+	}
+	return false
+}
+
+// Run the hook for the plan, or the group if given, unless it already succeeded.
+// The preUpgrade hook always needs to succeed before the postUpgrade hook is run.
+// Returns an empty string once the hook succeeded or if there is none,
+// otherwise the status of the plan or group while it is held by the hook.
+func (c *controller) runHook(ctx context.Context, plan *api.KubeUpgradePlan, logger *slog.Logger, hooks *api.HooksStatus, group, hook string, pre, post *batchv1.JobTemplateSpec) (string, error) {
+	template, status := pre, &hooks.PreUpgrade
+	if hook == hookPostUpgrade {
+		held, err := c.runHook(ctx, plan, logger, hooks, group, hookPreUpgrade, pre, post)
+		if err != nil || held != "" {
+			return held, err
+		}
+		template, status = post, &hooks.PostUpgrade
+	}
+	if template == nil || (*status != nil && (*status).Phase == api.HookPhaseSucceeded) {
+		return "", nil
+	}
+
+	logger = logger.With("hook", hook)
+	name := hookJobName(plan.Name, group, hook, hooks.KubernetesVersion)
+
+	job := &batchv1.Job{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: c.namespace, Name: name}, job)
+	if apierrors.IsNotFound(err) {
+		// Checked again here, as the webhook may be disabled
+		err = validateHookServiceAccounts(plan, getServiceAccountName())
+		if err != nil {
+			if *status == nil || (*status).Phase != api.HookPhaseFailed {
+				logger.Error("Refusing to run hook", "err", err)
+				c.recorder.Eventf(plan, nil, corev1.EventTypeWarning, eventReasonHookFailed, "RunHook", "Refusing to run the %s hook: %v", hook, err)
+			}
+			*status = &api.HookStatus{Job: name, Phase: api.HookPhaseFailed, Message: err.Error()}
+			return fmt.Sprintf("%s: Refusing to run the %s hook: %v", api.PlanStatusError, hook, err), nil
+		}
+
+		job = c.newHookJob(plan.Name, group, hook, hooks.KubernetesVersion, template)
+		err = controllerutil.SetControllerReference(plan, job, c.Scheme())
+		if err != nil {
+			return "", err
+		}
+		err = c.Create(ctx, job)
+		if err != nil {
+			return "", fmt.Errorf("failed to create Job %s for %s hook: %v", name, hook, err)
+		}
+		logger.Info("Started hook", "job", name)
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get Job %s for %s hook: %v", name, hook, err)
+	}
+
+	previous := *status
+	*status = hookStatusFromJob(job)
+
+	switch (*status).Phase {
+	case api.HookPhaseSucceeded:
+		logger.Info("Hook succeeded", "job", name)
+		return "", nil
+	case api.HookPhaseFailed:
+		if previous == nil || previous.Phase != api.HookPhaseFailed {
+			logger.Error("Hook failed", "job", name, "reason", (*status).Message)
+			c.recorder.Eventf(plan, job, corev1.EventTypeWarning, eventReasonHookFailed, "RunHook", "The %s hook job %s failed: %s", hook, name, (*status).Message)
+		}
+		return fmt.Sprintf("%s: The %s hook job %s failed", api.PlanStatusError, hook, name), nil
+	default:
+		return fmt.Sprintf("%s: Running %s hook job %s", api.PlanStatusProgressing, hook, name), nil
+	}
+}
+
+// Create the Job for the hook from the template.
+// The Job is named after the kubernetes version, so it only runs once per rollout.
+// Does not contain the owner reference.
+func (c *controller) newHookJob(plan, group, hook, kubeVersion string, template *batchv1.JobTemplateSpec) *batchv1.Job {
+	labels := maps.Clone(template.Labels)
+	if labels == nil {
+		labels = make(map[string]string, 3)
+	}
+	labels[constants.LabelPlanName] = plan
+	labels[constants.LabelHook] = hook
+	if group != "" {
+		labels[constants.LabelNodeGroup] = group
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        hookJobName(plan, group, hook, kubeVersion),
+			Namespace:   c.namespace,
+			Labels:      labels,
+			Annotations: maps.Clone(template.Annotations),
+		},
+		Spec: *template.Spec.DeepCopy(),
+	}
+
+	podSpec := &job.Spec.Template.Spec
+	if podSpec.RestartPolicy == "" {
+		podSpec.RestartPolicy = corev1.RestartPolicyNever
+	}
+	// Hooks without their own ServiceAccount don't need the token of the default ServiceAccount
+	if podSpec.ServiceAccountName == "" && podSpec.DeprecatedServiceAccount == "" && podSpec.AutomountServiceAccountToken == nil {
+		podSpec.AutomountServiceAccountToken = ptr.To(false)
+	}
+	env := []corev1.EnvVar{
+		{Name: hookEnvPlan, Value: plan},
+		{Name: hookEnvGroup, Value: group},
+		{Name: hookEnvHook, Value: hook},
+		{Name: hookEnvVersion, Value: kubeVersion},
+	}
+	for i := range podSpec.Containers {
+		podSpec.Containers[i].Env = append(podSpec.Containers[i].Env, env...)
+	}
+	return job
+}
+
+// Check that no hook of the plan runs as the controller or upgraded, as the hook would gain their permissions.
+// The hooks run in the namespace of the controller, where these ServiceAccounts live.
+func validateHookServiceAccounts(plan *api.KubeUpgradePlan, controllerServiceAccount string) error {
+	forbidden := []string{controllerServiceAccount}
+	if plan.Spec.Upgraded.ServiceAccountName != "" {
+		forbidden = append(forbidden, plan.Spec.Upgraded.ServiceAccountName)
+	}
+	for _, group := range plan.Spec.Groups {
+		if group.Upgraded != nil && group.Upgraded.ServiceAccountName != "" {
+			forbidden = append(forbidden, group.Upgraded.ServiceAccountName)
+		}
+	}
+
+	check := func(field string, template *batchv1.JobTemplateSpec) error {
+		if template == nil {
+			return nil
+		}
+		podSpec := template.Spec.Template.Spec
+		for _, name := range []string{podSpec.ServiceAccountName, podSpec.DeprecatedServiceAccount} {
+			if name != "" && slices.Contains(forbidden, name) {
+				return fmt.Errorf("%s may not use the ServiceAccount %s of kube-upgrade", field, name)
+			}
+		}
+		return nil
+	}
+
+	err := errors.Join(check("spec.preUpgrade", plan.Spec.PreUpgrade), check("spec.postUpgrade", plan.Spec.PostUpgrade))
+	if err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(plan.Spec.Groups)) {
+		group := plan.Spec.Groups[name]
+		err = errors.Join(check("spec.groups."+name+".preUpgrade", group.PreUpgrade), check("spec.groups."+name+".postUpgrade", group.PostUpgrade))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Return the name of the Job for the hook.
+// The name is shortened to fit the limit, the hash keeps it unique.
+func hookJobName(plan, group, hook, kubeVersion string) string {
+	// The arguments are strings, so creating the hash can't fail
+	hash, _ := createHash(plan, group, hook, kubeVersion)
+	hash = hash[:8]
+
+	name := plan
+	if group != "" {
+		name += "-" + group
+	}
+	name += "-" + strings.ToLower(strings.TrimSuffix(hook, "Upgrade")) + "-upgrade"
+	if len(name) > maxJobNameLength-len(hash)-1 {
+		name = strings.TrimRight(name[:maxJobNameLength-len(hash)-1], "-.")
+	}
+	return name + "-" + hash
+}
+
+// Return the status of the hook from the conditions of its Job
+func hookStatusFromJob(job *batchv1.Job) *api.HookStatus {
+	status := &api.HookStatus{
+		Job:       job.GetName(),
+		Phase:     api.HookPhaseRunning,
+		StartTime: job.Status.StartTime,
+	}
+	if status.StartTime == nil && !job.CreationTimestamp.IsZero() {
+		status.StartTime = &job.CreationTimestamp
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			status.Phase = api.HookPhaseSucceeded
+		case batchv1.JobFailed:
+			status.Phase = api.HookPhaseFailed
+			status.Message = condition.Message
+			if status.Message == "" {
+				status.Message = condition.Reason
+			}
+		default:
+			continue
+		}
+		completion := condition.LastTransitionTime
+		if job.Status.CompletionTime != nil {
+			completion = *job.Status.CompletionTime
+		}
+		status.CompletionTime = &completion
+		break
+	}
+	return status
+}
@@ -0,0 +1,349 @@
+package controller
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+)
+
+func TestReconcileHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	const version = "v1.31.0"
+	plan := &api.KubeUpgradePlan{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "upgrade-plan",
+		},
+		Spec: api.KubeUpgradeSpec{
+			KubernetesVersion: version,
+			PreUpgrade:        newTestHookTemplate(),
+			PostUpgrade:       newTestHookTemplate(),
+			Groups: map[string]api.KubeUpgradePlanGroup{
+				groupControl: {
+					Labels:      map[string]string{labelControl: labelValue},
+					PreUpgrade:  newTestHookTemplate(),
+					PostUpgrade: newTestHookTemplate(),
+				},
+				groupCompute: {
+					DependsOn: []string{groupControl},
+					Labels:    map[string]string{labelCompute: labelValue},
+				},
+			},
+		},
+	}
+	c := createFakeController(nil, nil, nil, plan)
+
+	planPre := hookJobName(plan.Name, "", hookPreUpgrade, version)
+	planPost := hookJobName(plan.Name, "", hookPostUpgrade, version)
+	groupPre := hookJobName(plan.Name, groupControl, hookPreUpgrade, version)
+	groupPost := hookJobName(plan.Name, groupControl, hookPostUpgrade, version)
+
+	reconcile := func(t *testing.T) {
+		require.NoError(t, c.reconcile(t.Context(), plan, slog.Default()), "Reconcile should succeed")
+	}
+	nodeStatus := func(t *testing.T, name string) string {
+		node := &corev1.Node{}
+		require.NoError(t, c.Get(t.Context(), types.NamespacedName{Name: name}, node), "Should get node")
+		return node.Annotations[constants.NodeUpgradeStatus]
+	}
+
+	t.Run("PlanPreUpgrade", func(t *testing.T) {
+		reconcile(t)
+
+		job := getHookJob(t, c, planPre)
+		assert.Equal(plan.Name, job.Labels[constants.LabelPlanName], "Should label the job with the plan")
+		assert.Equal(hookPreUpgrade, job.Labels[constants.LabelHook], "Should label the job with the hook")
+		assert.Equal(corev1.RestartPolicyNever, job.Spec.Template.Spec.RestartPolicy, "Should default the restart policy")
+		assert.Contains(job.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{Name: hookEnvVersion, Value: version}, "Should pass the version to the hook")
+		assert.Len(job.OwnerReferences, 1, "Should be owned by the plan")
+
+		assert.Equal(api.PlanStatusProgressing+": Running preUpgrade hook job "+planPre, plan.Status.Summary)
+		assert.Equal(api.PlanStatusWaiting, plan.Status.Groups[groupControl], "Groups should wait on the hook of the plan")
+		assert.Equal(version, plan.Status.Hooks.KubernetesVersion)
+		assert.Equal(api.HookPhaseRunning, plan.Status.Hooks.PreUpgrade.Phase)
+		assert.Empty(nodeStatus(t, nodeControlName), "Should not upgrade nodes yet")
+	})
+	t.Run("GroupPreUpgrade", func(t *testing.T) {
+		finishHookJob(t, c, planPre, batchv1.JobComplete)
+		reconcile(t)
+
+		assert.Equal(api.HookPhaseSucceeded, plan.Status.Hooks.PreUpgrade.Phase)
+		assert.NotNil(plan.Status.Hooks.PreUpgrade.CompletionTime, "Should record the completion time")
+		getHookJob(t, c, groupPre)
+		assert.Equal(api.PlanStatusProgressing+": Running preUpgrade hook job "+groupPre, plan.Status.Groups[groupControl])
+		assert.Empty(nodeStatus(t, nodeControlName), "Should not upgrade nodes yet")
+	})
+	t.Run("UpgradeGroup", func(t *testing.T) {
+		finishHookJob(t, c, groupPre, batchv1.JobComplete)
+		reconcile(t)
+
+		assert.Equal(api.HookPhaseSucceeded, plan.Status.GroupHooks[groupControl].PreUpgrade.Phase)
+		assert.Equal(api.PlanStatusProgressing+": 0/1 nodes upgraded", plan.Status.Groups[groupControl])
+		assert.Equal(constants.NodeUpgradeStatusPending, nodeStatus(t, nodeControlName), "Should upgrade the node")
+	})
+	t.Run("GroupPostUpgrade", func(t *testing.T) {
+		setNodeUpgradeStatus(t, c, nodeControlName, version, constants.NodeUpgradeStatusCompleted)
+		reconcile(t)
+
+		getHookJob(t, c, groupPost)
+		assert.Equal(api.PlanStatusProgressing+": Running postUpgrade hook job "+groupPost, plan.Status.Groups[groupControl])
+		assert.Equal(api.PlanStatusWaiting, plan.Status.Groups[groupCompute], "Dependent group should wait on the hook")
+		assert.Empty(nodeStatus(t, nodeComputeName), "Should not upgrade dependent nodes yet")
+	})
+	t.Run("FailedHook", func(t *testing.T) {
+		finishHookJob(t, c, groupPost, batchv1.JobFailed)
+		reconcile(t)
+
+		assert.Equal(api.PlanStatusError+": The postUpgrade hook job "+groupPost+" failed", plan.Status.Groups[groupControl])
+		assert.Equal(api.HookPhaseFailed, plan.Status.GroupHooks[groupControl].PostUpgrade.Phase)
+		assert.Equal("BackoffLimitExceeded", plan.Status.GroupHooks[groupControl].PostUpgrade.Message)
+		assert.Equal(api.PlanStatusWaiting, plan.Status.Groups[groupCompute], "Dependent group should wait on the hook")
+
+		recorder := c.recorder.(*events.FakeRecorder)
+		require.Len(t, recorder.Events, 1, "Should emit an event")
+		assert.Contains(<-recorder.Events, "Warning HookFailed The postUpgrade hook job "+groupPost+" failed")
+
+		reconcile(t)
+		assert.Empty(recorder.Events, "Should only emit the event once")
+	})
+	t.Run("RetryDeletedHook", func(t *testing.T) {
+		require.NoError(t, c.Delete(t.Context(), getHookJob(t, c, groupPost)), "Should delete job")
+		reconcile(t)
+
+		getHookJob(t, c, groupPost)
+		assert.Equal(api.HookPhaseRunning, plan.Status.GroupHooks[groupControl].PostUpgrade.Phase)
+	})
+	t.Run("DependentGroup", func(t *testing.T) {
+		finishHookJob(t, c, groupPost, batchv1.JobComplete)
+		reconcile(t)
+
+		assert.Equal(api.PlanStatusComplete, plan.Status.Groups[groupControl])
+		assert.Equal(constants.NodeUpgradeStatusPending, nodeStatus(t, nodeComputeName), "Should upgrade dependent nodes")
+	})
+	t.Run("PlanPostUpgrade", func(t *testing.T) {
+		setNodeUpgradeStatus(t, c, nodeComputeName, version, constants.NodeUpgradeStatusCompleted)
+		reconcile(t)
+
+		getHookJob(t, c, planPost)
+		assert.Equal(api.PlanStatusComplete, plan.Status.Groups[groupCompute])
+		assert.Equal(api.PlanStatusProgressing+": Running postUpgrade hook job "+planPost, plan.Status.Summary)
+	})
+	t.Run("Complete", func(t *testing.T) {
+		finishHookJob(t, c, planPost, batchv1.JobComplete)
+		reconcile(t)
+
+		assert.Equal(api.PlanStatusComplete, plan.Status.Summary)
+		assert.Equal(api.HookPhaseSucceeded, plan.Status.Hooks.PostUpgrade.Phase)
+	})
+	t.Run("SucceededHooksAreNotRerun", func(t *testing.T) {
+		require.NoError(t, c.Delete(t.Context(), getHookJob(t, c, planPre)), "Should delete job")
+		reconcile(t)
+
+		err := c.Get(t.Context(), types.NamespacedName{Namespace: c.namespace, Name: planPre}, &batchv1.Job{})
+		assert.True(apierrors.IsNotFound(err), "Should not recreate the job")
+		assert.Equal(api.PlanStatusComplete, plan.Status.Summary)
+	})
+}
+
+func TestReconcileHooksWithoutRollout(t *testing.T) {
+	plan := &api.KubeUpgradePlan{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "upgrade-plan",
+		},
+		Spec: api.KubeUpgradeSpec{
+			KubernetesVersion: "v1.31.0",
+			PreUpgrade:        newTestHookTemplate(),
+			Groups: map[string]api.KubeUpgradePlanGroup{
+				groupControl: {
+					Labels:      map[string]string{labelControl: labelValue},
+					PostUpgrade: newTestHookTemplate(),
+				},
+			},
+		},
+	}
+	c := createFakeController(map[string]string{
+		constants.NodeKubernetesVersion: "v1.31.0",
+		constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusCompleted,
+	}, nil, nil, plan)
+
+	require.NoError(t, c.reconcile(t.Context(), plan, slog.Default()), "Reconcile should succeed")
+
+	jobs := &batchv1.JobList{}
+	require.NoError(t, c.List(t.Context(), jobs), "Should list jobs")
+	assert.Empty(t, jobs.Items, "Should not run hooks when there is nothing to upgrade")
+	assert.Nil(t, plan.Status.Hooks)
+	assert.Empty(t, plan.Status.GroupHooks)
+	assert.Equal(t, api.PlanStatusComplete, plan.Status.Summary)
+}
+
+func TestReconcileHooksControllerServiceAccount(t *testing.T) {
+	assert := assert.New(t)
+
+	plan := &api.KubeUpgradePlan{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "upgrade-plan",
+		},
+		Spec: api.KubeUpgradeSpec{
+			KubernetesVersion: "v1.31.0",
+			PreUpgrade:        newTestHookTemplate(),
+			Groups: map[string]api.KubeUpgradePlanGroup{
+				groupControl: {
+					Labels: map[string]string{labelControl: labelValue},
+				},
+			},
+		},
+	}
+	plan.Spec.PreUpgrade.Spec.Template.Spec.ServiceAccountName = defaultServiceAccountName
+	c := createFakeController(nil, nil, nil, plan)
+
+	require.NoError(t, c.reconcile(t.Context(), plan, slog.Default()), "Reconcile should succeed")
+
+	jobs := &batchv1.JobList{}
+	require.NoError(t, c.List(t.Context(), jobs), "Should list jobs")
+	assert.Empty(jobs.Items, "Should not create a job running as the controller")
+	assert.Equal(api.HookPhaseFailed, plan.Status.Hooks.PreUpgrade.Phase)
+	assert.Contains(plan.Status.Summary, "may not use the ServiceAccount "+defaultServiceAccountName)
+}
+
+func TestNewHookJobServiceAccountToken(t *testing.T) {
+	assert := assert.New(t)
+
+	c := &controller{namespace: "kube-upgrade"}
+
+	job := c.newHookJob("upgrade-plan", "", hookPreUpgrade, "v1.31.0", newTestHookTemplate())
+	assert.Equal(ptr.To(false), job.Spec.Template.Spec.AutomountServiceAccountToken, "Should not mount the token of the default ServiceAccount")
+
+	template := newTestHookTemplate()
+	template.Spec.Template.Spec.ServiceAccountName = "hook"
+	job = c.newHookJob("upgrade-plan", "", hookPreUpgrade, "v1.31.0", template)
+	assert.Nil(job.Spec.Template.Spec.AutomountServiceAccountToken, "Should keep the token of a chosen ServiceAccount")
+}
+
+func TestHookJobName(t *testing.T) {
+	assert := assert.New(t)
+
+	name := hookJobName("upgrade-plan", "", hookPreUpgrade, "v1.31.0")
+	assert.True(strings.HasPrefix(name, "upgrade-plan-pre-upgrade-"), "Should contain plan and hook, got %s", name)
+	assert.True(strings.HasPrefix(hookJobName("upgrade-plan", groupControl, hookPostUpgrade, "v1.31.0"), "upgrade-plan-control-plane-post-upgrade-"), "Should contain the group")
+
+	assert.NotEqual(name, hookJobName("upgrade-plan", "", hookPreUpgrade, "v1.32.0"), "Should differ between versions")
+
+	long := hookJobName(strings.Repeat("a", 60), groupControl, hookPostUpgrade, "v1.31.0")
+	assert.LessOrEqual(len(long), maxJobNameLength, "Should not exceed the maximum length")
+	assert.NotEqual(long, hookJobName(strings.Repeat("a", 60), groupCompute, hookPostUpgrade, "v1.31.0"), "Shortened names should stay unique")
+}
+
+func TestHookStatusFromJob(t *testing.T) {
+	now := metav1.NewTime(time.Now().Truncate(time.Second))
+
+	tMatrix := map[string]struct {
+		Conditions []batchv1.JobCondition
+		Phase      string
+		Message    string
+	}{
+		"Running": {
+			Phase: api.HookPhaseRunning,
+		},
+		"Succeeded": {
+			Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobSuccessCriteriaMet, Status: corev1.ConditionTrue, LastTransitionTime: now},
+				{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: now},
+			},
+			Phase: api.HookPhaseSucceeded,
+		},
+		"Failed": {
+			Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded", Message: "Job has reached the specified backoff limit", LastTransitionTime: now},
+			},
+			Phase:   api.HookPhaseFailed,
+			Message: "Job has reached the specified backoff limit",
+		},
+		"FailureTargetNotFinished": {
+			Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailureTarget, Status: corev1.ConditionTrue, LastTransitionTime: now},
+			},
+			Phase: api.HookPhaseRunning,
+		},
+	}
+
+	for name, tCase := range tMatrix {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "hook", CreationTimestamp: now},
+				Status:     batchv1.JobStatus{Conditions: tCase.Conditions},
+			}
+			status := hookStatusFromJob(job)
+
+			assert.Equal("hook", status.Job)
+			assert.Equal(tCase.Phase, status.Phase)
+			assert.Equal(tCase.Message, status.Message)
+			assert.Equal(&now, status.StartTime, "Should fall back to the creation time")
+			if tCase.Phase == api.HookPhaseRunning {
+				assert.Nil(status.CompletionTime)
+			} else {
+				assert.Equal(&now, status.CompletionTime)
+			}
+		})
+	}
+}
+
+func newTestHookTemplate() *batchv1.JobTemplateSpec {
+	return &batchv1.JobTemplateSpec{
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "hook",
+							Image: "registry.example.com/hook:latest",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func getHookJob(t *testing.T, c *controller, name string) *batchv1.Job {
+	job := &batchv1.Job{}
+	require.NoError(t, c.Get(t.Context(), types.NamespacedName{Namespace: c.namespace, Name: name}, job), "Should create the hook job")
+	return job
+}
+
+// Set the job to finished with the given condition, as the job controller would
+func finishHookJob(t *testing.T, c *controller, name string, condition batchv1.JobConditionType) {
+	job := getHookJob(t, c, name)
+	jobCondition := batchv1.JobCondition{
+		Type:               condition,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+	}
+	if condition == batchv1.JobFailed {
+		jobCondition.Reason = "BackoffLimitExceeded"
+	}
+	job.Status.Conditions = append(job.Status.Conditions, jobCondition)
+	require.NoError(t, c.Status().Update(t.Context(), job), "Should update job status")
+}
+
+func setNodeUpgradeStatus(t *testing.T, c *controller, name, version, status string) {
+	node := &corev1.Node{}
+	require.NoError(t, c.Get(t.Context(), types.NamespacedName{Name: name}, node), "Should get node")
+	node.Annotations[constants.NodeKubernetesVersion] = version
+	node.Annotations[constants.NodeUpgradeStatus] = status
+	require.NoError(t, c.Update(t.Context(), node), "Should update node")
+}
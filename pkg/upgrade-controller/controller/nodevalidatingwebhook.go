@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	nodeUsernamePrefix = "system:node:"
+	nodesGroup         = "system:nodes"
+	// Set for tokens bound to a pod, contains the node the pod is running on
+	nodeNameExtraKey = "authentication.kubernetes.io/node-name"
+)
+
+// The status transitions upgraded is allowed to make.
+var nodeUpgradeStatusTransitions = map[string][]string{
+	constants.NodeUpgradeStatusPending:   {constants.NodeUpgradeStatusUpgrading, constants.NodeUpgradeStatusError},
+	constants.NodeUpgradeStatusUpgrading: {constants.NodeUpgradeStatusRebasing, constants.NodeUpgradeStatusCompleted, constants.NodeUpgradeStatusError},
+	constants.NodeUpgradeStatusRebasing:  {constants.NodeUpgradeStatusVerifying, constants.NodeUpgradeStatusCompleted, constants.NodeUpgradeStatusError},
+	constants.NodeUpgradeStatusVerifying: {constants.NodeUpgradeStatusCompleted, constants.NodeUpgradeStatusError},
+}
+
+// The annotations operators use to control the upgrade of a node
+var operatorNodeAnnotations = []string{constants.NodeHold, constants.NodeSkip, constants.NodeRetry}
+
+// The webhook configuration is not generated, as it is optional and deployed separately.
+// See manifests/helm/templates/node-webhook.yaml
+
+// nodeValidatingHook guards the kube-upgrade annotations of nodes
+type nodeValidatingHook struct {
+	// Username of the controller, which is allowed to change all annotations
+	ControllerUsername string
+	// ServiceAccounts in this namespace act as the node their pod is running on, as used by upgraded
+	Namespace string
+}
+
+// Check if the user is allowed to make the changes to the kube-upgrade annotations of the node
+func (h *nodeValidatingHook) validate(user authenticationv1.UserInfo, oldNode, newNode *corev1.Node) error {
+	for _, key := range changedNodeAnnotations(oldNode, newNode) {
+		oldValue, newValue := oldNode.Annotations[key], newNode.Annotations[key]
+		err := h.validateAnnotation(user, newNode.GetName(), key, oldValue, newValue)
+		if err != nil {
+			slog.Warn("Rejected change of kube-upgrade node annotation", slog.String("node", newNode.GetName()), slog.String("user", user.Username), slog.String("annotation", key), slog.String("old", oldValue), slog.String("new", newValue))
+			return err
+		}
+	}
+	return nil
+}
+
+// Check if the user is allowed to change the annotation from the old to the new value
+func (h *nodeValidatingHook) validateAnnotation(user authenticationv1.UserInfo, node, key, oldValue, newValue string) error {
+	if user.Username == h.ControllerUsername {
+		return nil
+	}
+
+	// Anyone allowed to update nodes may control the upgrade of a node with the operator annotations
+	if slices.Contains(operatorNodeAnnotations, key) {
+		return nil
+	}
+
+	if h.nodeIdentity(user) != node {
+		return fmt.Errorf("user \"%s\" is not allowed to change annotation %s of node %s from \"%s\" to \"%s\", only the kube-upgrade controller and the node itself may change it", user.Username, key, node, oldValue, newValue)
+	}
+
+	switch key {
+	case constants.NodeUpgradedVersion:
+		if newValue == "" {
+			return fmt.Errorf("node %s is not allowed to remove annotation %s", node, key)
+		}
+		return nil
+	case constants.NodeLastError, constants.NodeLastErrorTime, constants.NodeLastErrorReason, constants.NodeStatusTime, constants.NodeHeartbeat, constants.NodeLockHeld:
+		return nil
+	case constants.NodeUpgradeStatus:
+		if !slices.Contains(nodeUpgradeStatusTransitions[oldValue], newValue) {
+			return fmt.Errorf("node %s is not allowed to change annotation %s from \"%s\" to \"%s\", this is not a valid status transition", node, key, oldValue, newValue)
+		}
+		return nil
+	default:
+		return fmt.Errorf("node %s is not allowed to change annotation %s from \"%s\" to \"%s\", only the kube-upgrade controller may change it", node, key, oldValue, newValue)
+	}
+}
+
+// Return the node the user acts as, either with the kubelet credentials or a ServiceAccount of upgraded.
+// Returns an empty string if the user is not bound to a node.
+func (h *nodeValidatingHook) nodeIdentity(user authenticationv1.UserInfo) string {
+	if nodeName, ok := strings.CutPrefix(user.Username, nodeUsernamePrefix); ok && slices.Contains(user.Groups, nodesGroup) {
+		return nodeName
+	}
+
+	if h.Namespace == "" || !strings.HasPrefix(user.Username, "system:serviceaccount:"+h.Namespace+":") {
+		return ""
+	}
+	nodeName := user.Extra[nodeNameExtraKey]
+	if len(nodeName) != 1 {
+		return ""
+	}
+	return nodeName[0]
+}
+
+// Return the kube-upgrade node annotations that differ between the nodes
+func changedNodeAnnotations(oldNode, newNode *corev1.Node) []string {
+	var keys []string
+	for key, value := range newNode.Annotations {
+		if !strings.HasPrefix(key, constants.NodePrefix) {
+			continue
+		}
+		if oldValue, ok := oldNode.Annotations[key]; !ok || oldValue != value {
+			keys = append(keys, key)
+		}
+	}
+	for key := range oldNode.Annotations {
+		if !strings.HasPrefix(key, constants.NodePrefix) {
+			continue
+		}
+		if _, ok := newNode.Annotations[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// ValidateCreate validates the object on creation.
+// The optional warnings will be added to the response as warning messages.
+// Return an error if the object is invalid.
+func (*nodeValidatingHook) ValidateCreate(_ context.Context, _ *corev1.Node) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateUpdate validates the object on update.
+// The optional warnings will be added to the response as warning messages.
+// Return an error if the object is invalid.
+func (h *nodeValidatingHook) ValidateUpdate(ctx context.Context, oldNode *corev1.Node, newNode *corev1.Node) (admission.Warnings, error) {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admission request: %v", err)
+	}
+
+	return nil, h.validate(req.UserInfo, oldNode, newNode)
+}
+
+// ValidateDelete validates the object on deletion.
+// The optional warnings will be added to the response as warning messages.
+// Return an error if the object is invalid.
+func (*nodeValidatingHook) ValidateDelete(_ context.Context, _ *corev1.Node) (admission.Warnings, error) {
+	return nil, nil
+}
@@ -0,0 +1,300 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const testControllerUsername = "system:serviceaccount:kube-upgrade:kube-upgrade"
+
+func newTestAnnotatedNode(name string, annotations map[string]string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: annotations,
+		},
+	}
+}
+
+func TestNodeValidatingHookValidate(t *testing.T) {
+	pendingNode := map[string]string{
+		constants.NodeKubernetesVersion: "v1.31.0",
+		constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusPending,
+		"example.com/other":             "foo",
+	}
+	nodeGroups := []string{nodesGroup, "system:authenticated"}
+
+	tMatrix := []struct {
+		Name     string
+		User     string
+		Groups   []string
+		Extra    map[string]authenticationv1.ExtraValue
+		Old, New map[string]string
+		Error    string
+	}{
+		{
+			Name: "ControllerChangesVersion",
+			User: testControllerUsername,
+			Old:  map[string]string{constants.NodeKubernetesVersion: "v1.30.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusCompleted},
+			New:  pendingNode,
+		},
+		{
+			Name:   "OtherUserChangesOtherAnnotations",
+			User:   "admin",
+			Groups: []string{"system:masters"},
+			Old:    pendingNode,
+			New: map[string]string{
+				constants.NodeKubernetesVersion: "v1.31.0",
+				constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusPending,
+				"example.com/other":             "bar",
+			},
+		},
+		{
+			Name:   "OtherUserChangesVersion",
+			User:   "admin",
+			Groups: []string{"system:masters"},
+			Old:    pendingNode,
+			New:    map[string]string{constants.NodeKubernetesVersion: "v1.32.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusPending},
+			Error:  "user \"admin\" is not allowed to change annotation node.kube-upgrade.heathcliff.eu/kubernetesVersion of node node1 from \"v1.31.0\" to \"v1.32.0\"",
+		},
+		{
+			Name:   "OtherUserRemovesStatus",
+			User:   "admin",
+			Groups: []string{"system:masters"},
+			Old:    pendingNode,
+			New:    map[string]string{constants.NodeKubernetesVersion: "v1.31.0"},
+			Error:  "user \"admin\" is not allowed to change annotation node.kube-upgrade.heathcliff.eu/status of node node1 from \"pending\" to \"\"",
+		},
+		{
+			Name:   "OtherUserResetsErroredNode",
+			User:   "admin",
+			Groups: []string{"system:masters"},
+			Old:    map[string]string{constants.NodeKubernetesVersion: "v1.31.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusError},
+			New:    map[string]string{constants.NodeKubernetesVersion: "v1.31.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusPending},
+			Error:  "user \"admin\" is not allowed to change annotation node.kube-upgrade.heathcliff.eu/status of node node1 from \"error\" to \"pending\"",
+		},
+		{
+			Name:   "OtherUserRetriesErroredNode",
+			User:   "admin",
+			Groups: []string{"system:masters"},
+			Old:    map[string]string{constants.NodeKubernetesVersion: "v1.31.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusError},
+			New:    map[string]string{constants.NodeKubernetesVersion: "v1.31.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusError, constants.NodeRetry: "true"},
+		},
+		{
+			Name:   "OtherUserResetsUpgradingNode",
+			User:   "admin",
+			Groups: []string{"system:masters"},
+			Old:    map[string]string{constants.NodeKubernetesVersion: "v1.31.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusUpgrading},
+			New:    map[string]string{constants.NodeKubernetesVersion: "v1.31.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusPending},
+			Error:  "user \"admin\" is not allowed to change annotation node.kube-upgrade.heathcliff.eu/status of node node1 from \"upgrading\" to \"pending\"",
+		},
+		{
+			Name:   "OtherUserSetsOperatorAnnotations",
+			User:   "admin",
+			Groups: []string{"system:masters"},
+			Old:    pendingNode,
+			New:    map[string]string{constants.NodeKubernetesVersion: "v1.31.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusPending, constants.NodeHold: "true", constants.NodeSkip: "true", constants.NodeRetry: "true"},
+		},
+		{
+			Name:   "OtherUserChangesAttempts",
+			User:   "admin",
+			Groups: []string{"system:masters"},
+			Old:    pendingNode,
+			New:    map[string]string{constants.NodeKubernetesVersion: "v1.31.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusPending, constants.NodeUpgradeAttempts: "1"},
+			Error:  "user \"admin\" is not allowed to change annotation node.kube-upgrade.heathcliff.eu/attempts of node node1",
+		},
+		{
+			Name:   "NodeMovesStatus",
+			User:   nodeUsernamePrefix + "node1",
+			Groups: nodeGroups,
+			Old:    pendingNode,
+			New:    map[string]string{constants.NodeKubernetesVersion: "v1.31.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusUpgrading, "example.com/other": "foo"},
+		},
+		{
+			Name:   "NodeLeavesError",
+			User:   nodeUsernamePrefix + "node1",
+			Groups: nodeGroups,
+			Old:    map[string]string{constants.NodeUpgradeStatus: constants.NodeUpgradeStatusError},
+			New:    map[string]string{constants.NodeUpgradeStatus: constants.NodeUpgradeStatusUpgrading},
+			Error:  "node node1 is not allowed to change annotation node.kube-upgrade.heathcliff.eu/status from \"error\" to \"upgrading\", this is not a valid status transition",
+		},
+		{
+			Name:   "NodeVerifiesAfterReboot",
+			User:   nodeUsernamePrefix + "node1",
+			Groups: nodeGroups,
+			Old:    map[string]string{constants.NodeUpgradeStatus: constants.NodeUpgradeStatusRebasing},
+			New:    map[string]string{constants.NodeUpgradeStatus: constants.NodeUpgradeStatusVerifying},
+		},
+		{
+			Name:   "NodeCompletesVerification",
+			User:   nodeUsernamePrefix + "node1",
+			Groups: nodeGroups,
+			Old:    map[string]string{constants.NodeUpgradeStatus: constants.NodeUpgradeStatusVerifying},
+			New:    map[string]string{constants.NodeUpgradeStatus: constants.NodeUpgradeStatusCompleted},
+		},
+		{
+			Name:   "NodeReportsError",
+			User:   nodeUsernamePrefix + "node1",
+			Groups: nodeGroups,
+			Old:    map[string]string{constants.NodeUpgradeStatus: constants.NodeUpgradeStatusUpgrading},
+			New:    map[string]string{constants.NodeUpgradeStatus: constants.NodeUpgradeStatusError, constants.NodeLastError: "failed to rebase", constants.NodeLastErrorReason: constants.NodeErrorReasonRebaseFailed, constants.NodeLastErrorTime: "2026-01-01T00:00:00Z", constants.NodeStatusTime: "2026-01-01T00:00:00Z"},
+		},
+		{
+			Name:   "NodeSkipsStatus",
+			User:   nodeUsernamePrefix + "node1",
+			Groups: nodeGroups,
+			Old:    pendingNode,
+			New:    map[string]string{constants.NodeKubernetesVersion: "v1.31.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusCompleted, "example.com/other": "foo"},
+			Error:  "node node1 is not allowed to change annotation node.kube-upgrade.heathcliff.eu/status from \"pending\" to \"completed\", this is not a valid status transition",
+		},
+		{
+			Name:   "NodeResetsCompleted",
+			User:   nodeUsernamePrefix + "node1",
+			Groups: nodeGroups,
+			Old:    map[string]string{constants.NodeUpgradeStatus: constants.NodeUpgradeStatusCompleted},
+			New:    map[string]string{constants.NodeUpgradeStatus: constants.NodeUpgradeStatusPending},
+			Error:  "this is not a valid status transition",
+		},
+		{
+			Name:   "NodeChangesVersion",
+			User:   nodeUsernamePrefix + "node1",
+			Groups: nodeGroups,
+			Old:    pendingNode,
+			New:    map[string]string{constants.NodeKubernetesVersion: "v1.32.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusPending, "example.com/other": "foo"},
+			Error:  "node node1 is not allowed to change annotation node.kube-upgrade.heathcliff.eu/kubernetesVersion from \"v1.31.0\" to \"v1.32.0\", only the kube-upgrade controller may change it",
+		},
+		{
+			Name:   "NodeSetsUpgradedVersion",
+			User:   nodeUsernamePrefix + "node1",
+			Groups: nodeGroups,
+			Old:    nil,
+			New:    map[string]string{constants.NodeUpgradedVersion: "v0.8.0"},
+		},
+		{
+			Name:   "NodeRenewsHeartbeat",
+			User:   nodeUsernamePrefix + "node1",
+			Groups: nodeGroups,
+			Old:    map[string]string{constants.NodeHeartbeat: "2026-01-01T00:00:00Z"},
+			New:    map[string]string{constants.NodeHeartbeat: "2026-01-01T00:01:00Z"},
+		},
+		{
+			Name:   "NodeRecordsLock",
+			User:   nodeUsernamePrefix + "node1",
+			Groups: nodeGroups,
+			Old:    map[string]string{constants.NodeLockHeld: "false"},
+			New:    map[string]string{constants.NodeLockHeld: "true"},
+		},
+		{
+			Name:   "NodeRemovesUpgradedVersion",
+			User:   nodeUsernamePrefix + "node1",
+			Groups: nodeGroups,
+			Old:    map[string]string{constants.NodeUpgradedVersion: "v0.8.0"},
+			New:    nil,
+			Error:  "node node1 is not allowed to remove annotation node.kube-upgrade.heathcliff.eu/upgradedVersion",
+		},
+		{
+			Name:   "OtherNode",
+			User:   nodeUsernamePrefix + "node2",
+			Groups: nodeGroups,
+			Old:    pendingNode,
+			New:    map[string]string{constants.NodeKubernetesVersion: "v1.31.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusUpgrading, "example.com/other": "foo"},
+			Error:  "user \"system:node:node2\" is not allowed to change annotation node.kube-upgrade.heathcliff.eu/status of node node1",
+		},
+		{
+			Name:  "UpgradedServiceAccount",
+			User:  "system:serviceaccount:kube-upgrade:kube-upgraded",
+			Extra: map[string]authenticationv1.ExtraValue{nodeNameExtraKey: {"node1"}},
+			Old:   pendingNode,
+			New:   map[string]string{constants.NodeKubernetesVersion: "v1.31.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusUpgrading, "example.com/other": "foo"},
+		},
+		{
+			Name:  "UpgradedServiceAccountChangesVersion",
+			User:  "system:serviceaccount:kube-upgrade:kube-upgraded",
+			Extra: map[string]authenticationv1.ExtraValue{nodeNameExtraKey: {"node1"}},
+			Old:   pendingNode,
+			New:   map[string]string{constants.NodeKubernetesVersion: "v1.32.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusPending, "example.com/other": "foo"},
+			Error: "node node1 is not allowed to change annotation node.kube-upgrade.heathcliff.eu/kubernetesVersion",
+		},
+		{
+			Name:  "UpgradedServiceAccountOtherNode",
+			User:  "system:serviceaccount:kube-upgrade:kube-upgraded",
+			Extra: map[string]authenticationv1.ExtraValue{nodeNameExtraKey: {"node2"}},
+			Old:   pendingNode,
+			New:   map[string]string{constants.NodeKubernetesVersion: "v1.31.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusUpgrading, "example.com/other": "foo"},
+			Error: "user \"system:serviceaccount:kube-upgrade:kube-upgraded\" is not allowed to change annotation",
+		},
+		{
+			Name:  "ServiceAccountOtherNamespace",
+			User:  "system:serviceaccount:default:kube-upgraded",
+			Extra: map[string]authenticationv1.ExtraValue{nodeNameExtraKey: {"node1"}},
+			Old:   pendingNode,
+			New:   map[string]string{constants.NodeKubernetesVersion: "v1.31.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusUpgrading, "example.com/other": "foo"},
+			Error: "user \"system:serviceaccount:default:kube-upgraded\" is not allowed to change annotation",
+		},
+		{
+			Name:   "NodeUsernameWithoutGroup",
+			User:   nodeUsernamePrefix + "node1",
+			Groups: []string{"system:authenticated"},
+			Old:    pendingNode,
+			New:    map[string]string{constants.NodeKubernetesVersion: "v1.31.0", constants.NodeUpgradeStatus: constants.NodeUpgradeStatusUpgrading, "example.com/other": "foo"},
+			Error:  "user \"system:node:node1\" is not allowed to change annotation",
+		},
+	}
+
+	hook := &nodeValidatingHook{ControllerUsername: testControllerUsername, Namespace: "kube-upgrade"}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			user := authenticationv1.UserInfo{Username: tCase.User, Groups: tCase.Groups, Extra: tCase.Extra}
+			err := hook.validate(user, newTestAnnotatedNode("node1", tCase.Old), newTestAnnotatedNode("node1", tCase.New))
+
+			if tCase.Error == "" {
+				assert.NoError(t, err, "Should allow the change")
+			} else {
+				assert.ErrorContains(t, err, tCase.Error, "Should reject the change")
+			}
+		})
+	}
+}
+
+func TestNodeValidatingHookValidateUpdate(t *testing.T) {
+	hook := &nodeValidatingHook{ControllerUsername: testControllerUsername}
+	oldNode := newTestAnnotatedNode("node1", map[string]string{constants.NodeKubernetesVersion: "v1.30.0"})
+	newNode := newTestAnnotatedNode("node1", map[string]string{constants.NodeKubernetesVersion: "v1.31.0"})
+
+	t.Run("Controller", func(t *testing.T) {
+		ctx := admission.NewContextWithRequest(t.Context(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo: authenticationv1.UserInfo{Username: testControllerUsername},
+			},
+		})
+
+		warnings, err := hook.ValidateUpdate(ctx, oldNode, newNode)
+
+		assert.NoError(t, err, "Should allow the controller")
+		assert.Empty(t, warnings, "Should not return warnings")
+	})
+	t.Run("OtherUser", func(t *testing.T) {
+		ctx := admission.NewContextWithRequest(t.Context(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo: authenticationv1.UserInfo{Username: "admin"},
+			},
+		})
+
+		_, err := hook.ValidateUpdate(ctx, oldNode, newNode)
+
+		assert.ErrorContains(t, err, "user \"admin\" is not allowed", "Should reject other users")
+	})
+	t.Run("MissingRequest", func(t *testing.T) {
+		_, err := hook.ValidateUpdate(t.Context(), oldNode, newNode)
+
+		assert.ErrorContains(t, err, "failed to read admission request", "Should fail without request")
+	})
+}
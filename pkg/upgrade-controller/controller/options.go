@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Options for running the controller
+type Options struct {
+	// Path to a kubeconfig, uses the in-cluster config when empty
+	Kubeconfig string
+	// Namespace of the controller, read from the ServiceAccount when empty
+	Namespace string
+	// Bind address of the metrics server, "0" disables it
+	MetricsBindAddress string
+	// Bind address of the health probes, "0" disables them
+	HealthProbeBindAddress string
+	// Bind address of the webhook server
+	WebhookBindAddress string
+	// Directory containing the serving certificates of the webhook server
+	WebhookCertDir string
+	// Do not serve any webhooks
+	DisableWebhooks bool
+
+	LeaderElection bool
+	LeaseDuration  time.Duration
+	RenewDeadline  time.Duration
+	RetryPeriod    time.Duration
+}
+
+// Return the options used when running in the cluster
+func DefaultOptions() Options {
+	return Options{
+		MetricsBindAddress:     ":8080",
+		HealthProbeBindAddress: ":9090",
+		WebhookBindAddress:     ":9443",
+		WebhookCertDir:         webhookCertDir,
+		LeaderElection:         true,
+		LeaseDuration:          time.Minute,
+		RenewDeadline:          10 * time.Second,
+		RetryPeriod:            5 * time.Second,
+	}
+}
+
+// Create the client config from the kubeconfig, or the in-cluster config if none is given
+func (o Options) restConfig() (*rest.Config, error) {
+	if o.Kubeconfig == "" {
+		return rest.InClusterConfig()
+	}
+	config, err := clientcmd.BuildConfigFromFlags("", o.Kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig %s: %v", o.Kubeconfig, err)
+	}
+	return config, nil
+}
+
+// Return the namespace from the options, falls back to the ServiceAccount
+func (o Options) namespace() (string, error) {
+	if o.Namespace != "" {
+		return o.Namespace, nil
+	}
+	return GetNamespace()
+}
+
+// Split the webhook bind address into host and port
+func (o Options) webhookHostPort() (string, int, error) {
+	host, portStr, err := net.SplitHostPort(o.WebhookBindAddress)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid webhook bind address \"%s\": %v", o.WebhookBindAddress, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid webhook bind address \"%s\": invalid port \"%s\"", o.WebhookBindAddress, portStr)
+	}
+	return host, port, nil
+}
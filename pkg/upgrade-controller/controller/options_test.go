@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionsRestConfig(t *testing.T) {
+	t.Run("Kubeconfig", func(t *testing.T) {
+		assert := assert.New(t)
+
+		config, err := Options{Kubeconfig: "testdata/kubeconfig.yaml"}.restConfig()
+
+		assert.NoError(err, "Should load the kubeconfig")
+		if !assert.NotNil(config, "Should return a config") {
+			t.FailNow()
+		}
+		assert.Equal("https://127.0.0.1:6443", config.Host, "Should use the server from the kubeconfig")
+	})
+	t.Run("MissingKubeconfig", func(t *testing.T) {
+		_, err := Options{Kubeconfig: "testdata/not-a-file.yaml"}.restConfig()
+
+		assert.Error(t, err, "Should fail to load the kubeconfig")
+	})
+	t.Run("NotInCluster", func(t *testing.T) {
+		_, err := Options{}.restConfig()
+
+		assert.Error(t, err, "Should fail outside of a cluster")
+	})
+}
+
+func TestOptionsNamespace(t *testing.T) {
+	t.Run("FromOptions", func(t *testing.T) {
+		ns, err := Options{Namespace: "dev"}.namespace()
+
+		assert.NoError(t, err, "Should not fail")
+		assert.Equal(t, "dev", ns, "Should use the namespace from the options")
+	})
+	t.Run("Fallback", func(t *testing.T) {
+		oldFile := serviceAccountNamespaceFile
+		t.Cleanup(func() {
+			serviceAccountNamespaceFile = oldFile
+		})
+		serviceAccountNamespaceFile = "testdata/not-a-file"
+
+		ns, err := Options{}.namespace()
+
+		assert.NoError(t, err, "Should not fail")
+		assert.Equal(t, namespaceKubeUpgrade, ns, "Should fall back to the default namespace")
+	})
+}
+
+func TestOptionsWebhookHostPort(t *testing.T) {
+	tMatrix := []struct {
+		Name    string
+		Address string
+		Host    string
+		Port    int
+		Error   bool
+	}{
+		{Name: "PortOnly", Address: ":9443", Port: 9443},
+		{Name: "HostAndPort", Address: "127.0.0.1:8443", Host: "127.0.0.1", Port: 8443},
+		{Name: "IPv6", Address: "[::1]:8443", Host: "::1", Port: 8443},
+		{Name: "MissingPort", Address: "127.0.0.1", Error: true},
+		{Name: "InvalidPort", Address: ":https", Error: true},
+		{Name: "PortOutOfRange", Address: ":70000", Error: true},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			host, port, err := Options{WebhookBindAddress: tCase.Address}.webhookHostPort()
+
+			if tCase.Error {
+				assert.Error(err, "Should fail to parse the address")
+				return
+			}
+			assert.NoError(err, "Should parse the address")
+			assert.Equal(tCase.Host, host, "Should return the host")
+			assert.Equal(tCase.Port, port, "Should return the port")
+		})
+	}
+}
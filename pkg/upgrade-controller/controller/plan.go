@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"fmt"
+	"slices"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Decode a KubeUpgradePlan from yaml or json.
+// Unknown fields are rejected, as the apiserver would drop them silently.
+func DecodePlan(data []byte) (*api.KubeUpgradePlan, error) {
+	scheme, err := newScheme()
+	if err != nil {
+		return nil, err
+	}
+
+	obj, gvk, err := serializer.NewCodecFactory(scheme, serializer.EnableStrict).UniversalDeserializer().Decode(data, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode plan: %v", err)
+	}
+	plan, ok := obj.(*api.KubeUpgradePlan)
+	if !ok {
+		return nil, fmt.Errorf("expected a %s, got %s", api.SchemeGroupVersion.WithKind("KubeUpgradePlan"), gvk)
+	}
+	return plan, nil
+}
+
+// Apply the defaults to the plan and validate it the same way as the webhooks.
+// Checks that need the cluster, like the version skew, are skipped.
+// Returns the warnings the webhook would return.
+func ValidatePlan(plan *api.KubeUpgradePlan) ([]string, error) {
+	api.SetObjectDefaults_KubeUpgradePlan(plan)
+	return (&planValidatingHook{}).validate(plan)
+}
+
+// Render the ConfigMaps and DaemonSets the controller creates for each group of the plan, sorted by group.
+// The plan needs to be defaulted and valid, see ValidatePlan.
+// The objects do not contain the owner reference and the fields set by the apiserver.
+func RenderPlan(plan *api.KubeUpgradePlan, namespace, upgradedImage string) ([]client.Object, error) {
+	c := &controller{
+		namespace:     namespace,
+		upgradedImage: upgradedImage,
+	}
+
+	groups := make([]string, 0, len(plan.Spec.Groups))
+	for name := range plan.Spec.Groups {
+		groups = append(groups, name)
+	}
+	slices.Sort(groups)
+
+	objs := make([]client.Object, 0, 2*len(groups))
+	for _, name := range groups {
+		cm, err := c.expectedUpgradedConfigMap(plan, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render ConfigMap for group %s: %v", name, err)
+		}
+		cm.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+
+		ds := c.expectedUpgradedDaemonSet(plan, name, plan.Spec.Groups[name])
+		ds.SetGroupVersionKind(appv1.SchemeGroupVersion.WithKind("DaemonSet"))
+
+		objs = append(objs, cm, ds)
+	}
+	return objs, nil
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"os"
+	"testing"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	upgradedconfig "github.com/heathcliff26/kube-upgrade/pkg/upgraded/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func readTestPlan(t *testing.T) *api.KubeUpgradePlan {
+	data, err := os.ReadFile("testdata/plan.yaml")
+	require.NoError(t, err, "Should read test plan")
+	plan, err := DecodePlan(data)
+	require.NoError(t, err, "Should decode test plan")
+	return plan
+}
+
+func TestDecodePlan(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		plan := readTestPlan(t)
+
+		assert.Equal(t, "upgrade-plan", plan.Name, "Should decode the plan")
+		assert.Len(t, plan.Spec.Groups, 2, "Should decode the groups")
+	})
+	t.Run("UnknownField", func(t *testing.T) {
+		_, err := DecodePlan([]byte("apiVersion: kubeupgrade.heathcliff.eu/v1alpha3\nkind: KubeUpgradePlan\nspec:\n  kubernetesVersion: v1.36.1\n  foo: bar\n"))
+
+		assert.ErrorContains(t, err, "spec.foo", "Should reject unknown fields")
+	})
+	t.Run("WrongKind", func(t *testing.T) {
+		_, err := DecodePlan([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n"))
+
+		assert.Error(t, err, "Should reject other kinds")
+	})
+	t.Run("NotYaml", func(t *testing.T) {
+		_, err := DecodePlan([]byte("not: [valid"))
+
+		assert.Error(t, err, "Should reject invalid yaml")
+	})
+}
+
+func TestValidatePlan(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		assert := assert.New(t)
+
+		plan := readTestPlan(t)
+
+		warnings, err := ValidatePlan(plan)
+
+		assert.NoError(err, "Plan should be valid")
+		assert.Empty(warnings, "Should not return warnings")
+		assert.Equal(api.DefaultUpgradedStream, plan.Spec.Upgraded.Stream, "Should apply the defaults")
+	})
+	t.Run("Warnings", func(t *testing.T) {
+		plan := readTestPlan(t)
+		plan.Spec.AllowDowngrade = true
+
+		warnings, err := ValidatePlan(plan)
+
+		assert.NoError(t, err, "Plan should be valid")
+		assert.Len(t, warnings, 1, "Should return the webhook warnings")
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		plan := readTestPlan(t)
+		plan.Spec.KubernetesVersion = "not-a-version"
+
+		_, err := ValidatePlan(plan)
+
+		assert.Error(t, err, "Plan should be invalid")
+	})
+}
+
+func TestRenderPlan(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	plan := readTestPlan(t)
+	_, err := ValidatePlan(plan)
+	require.NoError(err, "Plan should be valid")
+
+	objs, err := RenderPlan(plan, "test-ns", "upgraded:test")
+	require.NoError(err, "Should render the plan")
+	require.Len(objs, 4, "Should render a ConfigMap and DaemonSet per group")
+
+	names := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		assert.Equal("test-ns", obj.GetNamespace(), "Should use the namespace")
+		assert.Empty(obj.GetOwnerReferences(), "Should not contain owner references")
+		names = append(names, obj.GetObjectKind().GroupVersionKind().Kind+"/"+obj.GetName())
+	}
+	assert.Equal([]string{"ConfigMap/upgraded-compute", "DaemonSet/upgraded-compute", "ConfigMap/upgraded-control-plane", "DaemonSet/upgraded-control-plane"}, names, "Should be sorted by group")
+
+	cm := objs[0].(*corev1.ConfigMap)
+	var cfg api.UpgradedConfig
+	require.NoError(yaml.Unmarshal([]byte(cm.Data[upgradedconfig.DefaultConfigFile]), &cfg), "Should contain the upgraded config")
+	assert.Equal(combineConfig(plan.Spec.Upgraded, plan.Spec.Groups["compute"].Upgraded), &cfg, "Should contain the combined config")
+
+	ds := objs[1].(*appv1.DaemonSet)
+	assert.Equal("upgraded:test", ds.Spec.Template.Spec.Containers[0].Image, "Should use the image")
+	assert.Equal(plan.Spec.Groups["compute"].Labels, ds.Spec.Template.Spec.NodeSelector, "Should set the node selector")
+	assert.Equal("kube-upgraded", ds.Spec.Template.Spec.ServiceAccountName, "Should use the ServiceAccount of the group")
+	assert.True(hasVolume(ds, "pull-secret"), "Should mount the pull secret")
+	assert.False(hasVolume(ds, "kubelet-pki"), "Should not mount the kubelet certificates")
+
+	ds = objs[3].(*appv1.DaemonSet)
+	assert.Equal(plan.Spec.Groups["control-plane"].Tolerations, ds.Spec.Template.Spec.Tolerations, "Should set the tolerations")
+	assert.True(hasVolume(ds, "kubelet-pki"), "Should mount the kubelet certificates")
+}
@@ -0,0 +1,275 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json/v2"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	dockerHubRegistry     = "docker.io"
+	dockerHubRegistryHost = "registry-1.docker.io"
+)
+
+// Media types accepted when requesting image manifests
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Hosts used for docker hub in the credentials of container runtimes
+var dockerHubAuthHosts = []string{"index.docker.io", dockerHubRegistry, dockerHubRegistryHost}
+
+type registryToken struct {
+	Token       string `json:"token,omitempty"`
+	AccessToken string `json:"access_token,omitempty"`
+}
+
+// Credentials per registry, as contained in a Secret of type kubernetes.io/dockerconfigjson
+type registryCredentials struct {
+	Auths map[string]registryAuth `json:"auths"`
+}
+
+type registryAuth struct {
+	// Base64 encoded "username:password"
+	Auth     string `json:"auth,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Read the registry credentials from the pull secret in the namespace of the controller.
+// Uses the APIReader, as secrets are not cached.
+func (c *controller) getRegistryCredentials(ctx context.Context, name string) (*registryCredentials, error) {
+	secret := &corev1.Secret{}
+	err := c.getAPIReader().Get(ctx, client.ObjectKey{Namespace: c.namespace, Name: name}, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pull secret %s: %v", name, err)
+	}
+	data, ok := secret.Data[corev1.DockerConfigJsonKey]
+	if !ok {
+		return nil, fmt.Errorf("pull secret %s is missing the key %s", name, corev1.DockerConfigJsonKey)
+	}
+	creds := &registryCredentials{}
+	err = json.Unmarshal(data, creds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pull secret %s: %v", name, err)
+	}
+	return creds, nil
+}
+
+// Return the username and password for the registry host, if there are any
+func (r *registryCredentials) lookup(host string) (string, string, bool) {
+	if r == nil {
+		return "", "", false
+	}
+	hosts := []string{host}
+	if host == dockerHubRegistryHost {
+		hosts = dockerHubAuthHosts
+	}
+	for key, auth := range r.Auths {
+		// Keys may be given as URL, e.g. https://index.docker.io/v1/
+		key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+		key, _, _ = strings.Cut(key, "/")
+		if !slices.Contains(hosts, key) {
+			continue
+		}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				continue
+			}
+			username, password, ok := strings.Cut(string(decoded), ":")
+			if ok {
+				return username, password, true
+			}
+			continue
+		}
+		if auth.Username != "" {
+			return auth.Username, auth.Password, true
+		}
+	}
+	return "", "", false
+}
+
+// Check if the given tag exists in the image repository by requesting its manifest.
+// Supports bearer token and basic authentication, anonymous unless credentials for the registry are given.
+func imageTagExists(ctx context.Context, httpClient *http.Client, repository, tag string, creds *registryCredentials) (bool, error) {
+	host, name := splitImageRepository(repository)
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, name, tag)
+
+	res, err := requestManifest(ctx, httpClient, manifestURL, "")
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		username, password, hasCreds := creds.lookup(host)
+		challenge := res.Header.Get("WWW-Authenticate")
+
+		var authorization string
+		scheme, _, _ := strings.Cut(challenge, " ")
+		if strings.EqualFold(scheme, "Basic") && hasCreds {
+			authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+		} else {
+			if !hasCreds {
+				username, password = "", ""
+			}
+			token, err := fetchRegistryToken(ctx, httpClient, challenge, username, password)
+			if err != nil {
+				return false, fmt.Errorf("failed to authenticate with registry %s: %v", host, err)
+			}
+			authorization = "Bearer " + token
+		}
+		res, err = requestManifest(ctx, httpClient, manifestURL, authorization)
+		if err != nil {
+			return false, err
+		}
+		res.Body.Close()
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("registry %s returned unexpected status code %d for %s", host, res.StatusCode, manifestURL)
+	}
+}
+
+// Send a HEAD request for the manifest with the optional authorization header
+func requestManifest(ctx context.Context, httpClient *http.Client, manifestURL, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return httpClient.Do(req)
+}
+
+// Fetch a token from the realm given in the WWW-Authenticate challenge.
+// The token is anonymous, unless a username is given.
+func fetchRegistryToken(ctx context.Context, httpClient *http.Client, challenge, username, password string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported authentication challenge \"%s\"", challenge)
+	}
+
+	values := parseAuthChallengeParams(params)
+	realm := values["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("authentication challenge is missing the realm")
+	}
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid realm \"%s\": %v", realm, err)
+	}
+	query := tokenURL.Query()
+	for _, key := range []string{"service", "scope"} {
+		if values[key] != "" {
+			query.Set(key, values[key])
+		}
+	}
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request returned status code %d", res.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	var token registryToken
+	err = json.Unmarshal(body, &token)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse token response: %v", err)
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// Parse the comma separated key="value" pairs of an authentication challenge.
+// Values are either tokens or quoted strings, which may contain commas and escaped characters.
+func parseAuthChallengeParams(params string) map[string]string {
+	values := make(map[string]string)
+	for params != "" {
+		params = strings.TrimLeft(params, " \t,")
+		key, rest, ok := strings.Cut(params, "=")
+		if !ok {
+			break
+		}
+		key = strings.TrimSpace(key)
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value strings.Builder
+		quoted := strings.HasPrefix(rest, "\"")
+		if quoted {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			rest = rest[min(i+1, len(rest)):]
+		}
+		// Tokens end at the next comma, anything after a quoted string is skipped
+		token, remaining, _ := strings.Cut(rest, ",")
+		if !quoted {
+			value.WriteString(strings.TrimSpace(token))
+		}
+		params = remaining
+		if key != "" {
+			values[strings.ToLower(key)] = value.String()
+		}
+	}
+	return values
+}
+
+// Split the repository into the registry host and the image name.
+// Follows the same rules as container runtimes, falling back to docker hub when no registry is given.
+func splitImageRepository(repository string) (string, string) {
+	host, name, ok := strings.Cut(repository, "/")
+	if !ok || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		host, name = dockerHubRegistry, repository
+	}
+
+	if host == dockerHubRegistry {
+		host = dockerHubRegistryHost
+		if !strings.Contains(name, "/") {
+			name = "library/" + name
+		}
+	}
+	return host, name
+}
@@ -0,0 +1,259 @@
+package controller
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestImageTagExists(t *testing.T) {
+	srv := newFakeRegistry(t, "v1.35.2")
+	host := strings.TrimPrefix(srv.URL, "https://")
+	privateSrv := newFakePrivateRegistry(t, "user", "secret", "v1.35.2")
+	privateHost := strings.TrimPrefix(privateSrv.URL, "https://")
+
+	creds := &registryCredentials{Auths: map[string]registryAuth{
+		privateHost:       {Auth: base64.StdEncoding.EncodeToString([]byte("user:secret"))},
+		"other.example":   {Username: "other", Password: "other"},
+		"https://" + host: {Username: "user", Password: "secret"},
+	}}
+	wrongCreds := &registryCredentials{Auths: map[string]registryAuth{
+		privateHost: {Username: "user", Password: "wrong"},
+	}}
+
+	tMatrix := []struct {
+		Name, Repository, Tag string
+		Creds                 *registryCredentials
+		Exists, Error         bool
+	}{
+		{
+			Name:       "TagExists",
+			Repository: host + "/heathcliff26/fcos-k8s",
+			Tag:        "v1.35.2",
+			Exists:     true,
+		},
+		{
+			Name:       "TagMissing",
+			Repository: host + "/heathcliff26/fcos-k8s",
+			Tag:        "v1.35.3",
+		},
+		{
+			Name:       "UnexpectedStatus",
+			Repository: host + "/error/fcos-k8s",
+			Tag:        "v1.35.2",
+			Error:      true,
+		},
+		{
+			Name:       "PrivateTagExists",
+			Repository: privateHost + "/heathcliff26/fcos-k8s",
+			Tag:        "v1.35.2",
+			Creds:      creds,
+			Exists:     true,
+		},
+		{
+			Name:       "PrivateTagMissing",
+			Repository: privateHost + "/heathcliff26/fcos-k8s",
+			Tag:        "v1.35.3",
+			Creds:      creds,
+		},
+		{
+			Name:       "PrivateBasicAuth",
+			Repository: privateHost + "/basic/fcos-k8s",
+			Tag:        "v1.35.2",
+			Creds:      creds,
+			Exists:     true,
+		},
+		{
+			Name:       "PrivateWithoutCredentials",
+			Repository: privateHost + "/heathcliff26/fcos-k8s",
+			Tag:        "v1.35.2",
+			Error:      true,
+		},
+		{
+			Name:       "PrivateWrongCredentials",
+			Repository: privateHost + "/heathcliff26/fcos-k8s",
+			Tag:        "v1.35.2",
+			Creds:      wrongCreds,
+			Error:      true,
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			exists, err := imageTagExists(t.Context(), srv.Client(), tCase.Repository, tCase.Tag, tCase.Creds)
+
+			if tCase.Error {
+				assert.Error(err, "Should return an error")
+			} else {
+				assert.NoError(err, "Should not return an error")
+			}
+			assert.Equal(tCase.Exists, exists, "Should return if the tag exists")
+		})
+	}
+}
+
+func TestFetchRegistryToken(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := fetchRegistryToken(t.Context(), http.DefaultClient, "Basic realm=\"registry\"", "", "")
+	assert.ErrorContains(err, "unsupported authentication challenge", "Should only support bearer tokens")
+
+	_, err = fetchRegistryToken(t.Context(), http.DefaultClient, "Bearer service=\"registry\"", "", "")
+	assert.ErrorContains(err, "missing the realm", "Should require a realm")
+}
+
+func TestParseAuthChallengeParams(t *testing.T) {
+	tMatrix := []struct {
+		Name   string
+		Params string
+		Result map[string]string
+	}{
+		{
+			Name:   "Quoted",
+			Params: `realm="https://ghcr.io/token",service="ghcr.io",scope="repository:heathcliff26/fcos-k8s:pull"`,
+			Result: map[string]string{"realm": "https://ghcr.io/token", "service": "ghcr.io", "scope": "repository:heathcliff26/fcos-k8s:pull"},
+		},
+		{
+			Name:   "CommaInQuotedValue",
+			Params: `realm="https://registry.example.com/token",scope="repository:a:pull,push",service="registry"`,
+			Result: map[string]string{"realm": "https://registry.example.com/token", "scope": "repository:a:pull,push", "service": "registry"},
+		},
+		{
+			Name:   "EscapedQuote",
+			Params: `realm="https://registry.example.com/token", error="insufficient \"scope\""`,
+			Result: map[string]string{"realm": "https://registry.example.com/token", "error": `insufficient "scope"`},
+		},
+		{
+			Name:   "Tokens",
+			Params: `Realm=https://registry.example.com/token , service=registry`,
+			Result: map[string]string{"realm": "https://registry.example.com/token", "service": "registry"},
+		},
+		{
+			Name:   "Empty",
+			Params: "",
+			Result: map[string]string{},
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert.Equal(t, tCase.Result, parseAuthChallengeParams(tCase.Params), "Should parse the parameters")
+		})
+	}
+}
+
+func TestSplitImageRepository(t *testing.T) {
+	tMatrix := []struct {
+		Repository, Host, Name string
+	}{
+		{"ghcr.io/heathcliff26/fcos-k8s", "ghcr.io", "heathcliff26/fcos-k8s"},
+		{"localhost:5000/fcos-k8s", "localhost:5000", "fcos-k8s"},
+		{"localhost/fcos-k8s", "localhost", "fcos-k8s"},
+		{"heathcliff26/fcos-k8s", dockerHubRegistryHost, "heathcliff26/fcos-k8s"},
+		{"docker.io/heathcliff26/fcos-k8s", dockerHubRegistryHost, "heathcliff26/fcos-k8s"},
+		{"fedora", dockerHubRegistryHost, "library/fedora"},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Repository, func(t *testing.T) {
+			host, name := splitImageRepository(tCase.Repository)
+			assert.Equal(t, tCase.Host, host, "Should return the registry host")
+			assert.Equal(t, tCase.Name, name, "Should return the image name")
+		})
+	}
+}
+
+func TestGetRegistryCredentials(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c := createFakeController(nil, nil, nil, &api.KubeUpgradePlan{ObjectMeta: metav1.ObjectMeta{Name: "upgrade-plan"}})
+	require.NoError(c.Create(t.Context(), newFakePullSecret(c.namespace, "pull-secret", "registry.example.com", "user", "secret")))
+	require.NoError(c.Create(t.Context(), &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: c.namespace, Name: "opaque"}}))
+
+	creds, err := c.getRegistryCredentials(t.Context(), "pull-secret")
+	require.NoError(err, "Should read the pull secret")
+	username, password, ok := creds.lookup("registry.example.com")
+	assert.True(ok, "Should find credentials for the registry")
+	assert.Equal("user", username)
+	assert.Equal("secret", password)
+	_, _, ok = creds.lookup("other.example.com")
+	assert.False(ok, "Should not return credentials for other registries")
+
+	_, err = c.getRegistryCredentials(t.Context(), "opaque")
+	assert.ErrorContains(err, "missing the key", "Should require a dockerconfigjson")
+	_, err = c.getRegistryCredentials(t.Context(), "missing")
+	assert.Error(err, "Should fail for missing secrets")
+}
+
+// Create a Secret of type kubernetes.io/dockerconfigjson with the credentials for the registry
+func newFakePullSecret(namespace, name, registry, username, password string) *corev1.Secret {
+	auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{"` + registry + `":{"auth":"` + auth + `"}}}`),
+		},
+	}
+}
+
+// Create a fake registry requiring anonymous bearer tokens, which contains the given tags.
+// Requests for images in the "error" namespace fail with an internal server error.
+func newFakeRegistry(t *testing.T, tags ...string) *httptest.Server {
+	return newFakePrivateRegistry(t, "", "", tags...)
+}
+
+// Create a fake registry, which only hands out tokens for the given credentials, unless the username is empty.
+// Images in the "basic" namespace require basic authentication instead of tokens.
+func newFakePrivateRegistry(t *testing.T, username, password string, tags ...string) *httptest.Server {
+	t.Helper()
+
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			if user, pass, _ := req.BasicAuth(); username != "" && (user != username || pass != password) {
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = rw.Write([]byte(`{"token":"test-token"}`))
+			return
+		}
+		if strings.HasPrefix(req.URL.Path, "/v2/basic/") {
+			if user, pass, ok := req.BasicAuth(); !ok || user != username || pass != password {
+				rw.Header().Set("WWW-Authenticate", "Basic realm=\"fake-registry\"")
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			req.Header.Set("Authorization", "Bearer test-token")
+		}
+		if req.Header.Get("Authorization") != "Bearer test-token" {
+			rw.Header().Set("WWW-Authenticate", "Bearer realm=\""+srv.URL+"/token\",service=\"fake-registry\",scope=\"repository:fcos-k8s:pull\"")
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if strings.HasPrefix(req.URL.Path, "/v2/error/") {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		for _, tag := range tags {
+			if strings.HasSuffix(req.URL.Path, "/manifests/"+tag) {
+				rw.WriteHeader(http.StatusOK)
+				return
+			}
+		}
+		rw.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
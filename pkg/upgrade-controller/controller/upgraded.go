@@ -107,8 +107,6 @@ func (c *controller) NewUpgradedDaemonSet(plan, group string) *appv1.DaemonSet {
 
 	attachVolumeMountHostPath(ds, "host-run", "/run", "/run")
 	attachVolumeMountHostPath(ds, "rootfs", "/", "/host")
-	// Contains certificates referenced by kubelet config.
-	attachVolumeMountHostPath(ds, "kubelet-pki", "/var/lib/kubelet/pki", "/var/lib/kubelet/pki")
 	attachVolumeMountHostPath(ds, "machine-id", "/etc/machine-id", "/etc/machine-id")
 
 	return ds
@@ -135,11 +133,16 @@ func (c *controller) NewUpgradedConfigMap(plan, group string, cfg *api.UpgradedC
 	}, nil
 }
 
-// Reconcile the given ConfigMap with the expected state from the given config.
-func (c *controller) reconcileUpgradedConfigMap(ctx context.Context, plan *api.KubeUpgradePlan, logger *slog.Logger, cm *corev1.ConfigMap, group string) error {
+// Create the ConfigMap for the group with the combined config of the plan and the group.
+// Does not contain the owner reference.
+func (c *controller) expectedUpgradedConfigMap(plan *api.KubeUpgradePlan, group string) (*corev1.ConfigMap, error) {
 	upgradedCfg := combineConfig(plan.Spec.Upgraded, plan.Spec.Groups[group].Upgraded)
+	return c.NewUpgradedConfigMap(plan.Name, group, upgradedCfg)
+}
 
-	expectedCM, err := c.NewUpgradedConfigMap(plan.Name, group, upgradedCfg)
+// Reconcile the given ConfigMap with the expected state from the given config.
+func (c *controller) reconcileUpgradedConfigMap(ctx context.Context, plan *api.KubeUpgradePlan, logger *slog.Logger, cm *corev1.ConfigMap, group string) error {
+	expectedCM, err := c.expectedUpgradedConfigMap(plan, group)
 	if err != nil {
 		return err
 	}
@@ -182,11 +185,36 @@ func (c *controller) reconcileUpgradedConfigMap(ctx context.Context, plan *api.K
 	return nil
 }
 
-// Reconcile the given DaemonSet with the expected spec.
-func (c *controller) reconcileUpgradedDaemonSet(ctx context.Context, plan *api.KubeUpgradePlan, logger *slog.Logger, ds *appv1.DaemonSet, groupName string, group api.KubeUpgradePlanGroup) error {
+// Create the DaemonSet for the group with the node selector, tolerations and volumes required by the combined config.
+// Does not contain the owner reference.
+func (c *controller) expectedUpgradedDaemonSet(plan *api.KubeUpgradePlan, groupName string, group api.KubeUpgradePlanGroup) *appv1.DaemonSet {
 	expectedDS := c.NewUpgradedDaemonSet(plan.Name, groupName)
 	expectedDS.Spec.Template.Spec.NodeSelector = group.Labels
 	expectedDS.Spec.Template.Spec.Tolerations = group.Tolerations
+
+	upgradedCfg := combineConfig(plan.Spec.Upgraded, group.Upgraded)
+	if upgradedCfg.ServiceAccountName != "" {
+		expectedDS.Spec.Template.Spec.ServiceAccountName = upgradedCfg.ServiceAccountName
+	} else {
+		// Contains certificates referenced by kubelet config.
+		attachVolumeMountHostPath(expectedDS, "kubelet-pki", "/var/lib/kubelet/pki", "/var/lib/kubelet/pki")
+	}
+	if upgradedCfg.KubeadmDownload != nil && upgradedCfg.KubeadmDownload.Secret != "" {
+		attachVolumeMountSecret(expectedDS, "kubeadm-download", upgradedCfg.KubeadmDownload.Secret, upgradedconfig.KubeadmDownloadSecretDir)
+	}
+	if upgradedCfg.PullSecret != "" {
+		attachVolumeMountSecret(expectedDS, "pull-secret", upgradedCfg.PullSecret, upgradedconfig.PullSecretDir)
+	}
+	if upgradedCfg.NodeHooks != nil && upgradedCfg.NodeHooks.ConfigMap != "" {
+		attachVolumeMountConfigMap(expectedDS, "node-hooks", upgradedCfg.NodeHooks.ConfigMap, upgradedconfig.NodeHooksConfigMapDir)
+	}
+	return expectedDS
+}
+
+// Reconcile the given DaemonSet with the expected spec.
+func (c *controller) reconcileUpgradedDaemonSet(ctx context.Context, plan *api.KubeUpgradePlan, logger *slog.Logger, ds *appv1.DaemonSet, groupName string, group api.KubeUpgradePlanGroup) error {
+	expectedDS := c.expectedUpgradedDaemonSet(plan, groupName, group)
+
 	err := controllerutil.SetControllerReference(plan, expectedDS, c.Scheme())
 	if err != nil {
 		return err
@@ -265,6 +293,40 @@ func attachVolumeMountHostPath(ds *appv1.DaemonSet, name, hostPath, mountPath st
 	})
 }
 
+func attachVolumeMountSecret(ds *appv1.DaemonSet, name, secret, mountPath string) {
+	ds.Spec.Template.Spec.Volumes = append(ds.Spec.Template.Spec.Volumes, corev1.Volume{
+		Name: name,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: secret,
+			},
+		},
+	})
+	ds.Spec.Template.Spec.Containers[0].VolumeMounts = append(ds.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      name,
+		MountPath: mountPath,
+		ReadOnly:  true,
+	})
+}
+
+func attachVolumeMountConfigMap(ds *appv1.DaemonSet, name, configMap, mountPath string) {
+	ds.Spec.Template.Spec.Volumes = append(ds.Spec.Template.Spec.Volumes, corev1.Volume{
+		Name: name,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: configMap,
+				},
+			},
+		},
+	})
+	ds.Spec.Template.Spec.Containers[0].VolumeMounts = append(ds.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      name,
+		MountPath: mountPath,
+		ReadOnly:  true,
+	})
+}
+
 func upgradedLabels(planName, groupName string) map[string]string {
 	return map[string]string{
 		constants.LabelPlanName:  planName,
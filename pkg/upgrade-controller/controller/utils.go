@@ -4,19 +4,31 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"net/http"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
 	"github.com/heathcliff26/kube-upgrade/pkg/version"
+	"golang.org/x/mod/semver"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
 
 const namespaceKubeUpgrade = "kube-upgrade"
 
+// The daemon renews its heartbeat every minute, so this allows for a few missed heartbeats
+const daemonHeartbeatTimeout = 5 * time.Minute
+
 // Read the namespace from the inserted serviceaccount file. Fallback to default if the file does not exist.
 func GetNamespace() (string, error) {
 	data, err := os.ReadFile(serviceAccountNamespaceFile)
@@ -40,10 +52,15 @@ func Pointer[T any](v T) *T {
 	return &v
 }
 
+// Check if the status is complete, which includes groups with failed nodes within their failure budget
+func statusComplete(status string) bool {
+	return status == api.PlanStatusComplete || strings.HasPrefix(status, api.PlanStatusComplete+":")
+}
+
 // Check if the given group needs to wait on another one
 func groupWaitForDependency(deps []string, status map[string]string) bool {
 	for _, d := range deps {
-		if status[d] != api.PlanStatusComplete {
+		if !statusComplete(status[d]) {
 			return true
 		}
 	}
@@ -58,13 +75,19 @@ func createStatusSummary(status map[string]string) string {
 	waiting := false
 	unknown := false
 	progressing := make([]string, 0, len(status))
+	stalled := make([]string, 0, len(status))
 	errorGroups := make([]string, 0, len(status))
+	failedGroups := make([]string, 0, len(status))
 
 	for group, s := range status {
 		switch {
 		case s == api.PlanStatusComplete:
+		case statusComplete(s):
+			failedGroups = append(failedGroups, group)
 		case strings.HasPrefix(s, api.PlanStatusProgressing):
 			progressing = append(progressing, group)
+		case strings.HasPrefix(s, api.PlanStatusStalled):
+			stalled = append(stalled, group)
 		case s == api.PlanStatusWaiting:
 			waiting = true
 		case strings.HasPrefix(s, api.PlanStatusError):
@@ -80,13 +103,148 @@ func createStatusSummary(status map[string]string) string {
 		return fmt.Sprintf("%s: Some groups encountered errors %v", api.PlanStatusError, errorGroups)
 	} else if len(progressing) > 0 {
 		return fmt.Sprintf("%s: Upgrading groups %v", api.PlanStatusProgressing, progressing)
+	} else if len(stalled) > 0 {
+		slices.Sort(stalled)
+		return fmt.Sprintf("%s: No node is making progress in groups %v", api.PlanStatusStalled, stalled)
 	} else if waiting {
 		return api.PlanStatusWaiting
+	} else if len(failedGroups) > 0 {
+		slices.Sort(failedGroups)
+		return fmt.Sprintf("%s: Some groups have failed nodes %v", api.PlanStatusComplete, failedGroups)
 	} else {
 		return api.PlanStatusComplete
 	}
 }
 
+// Check if the daemon on the node renewed its heartbeat recently.
+// Nodes without a heartbeat get the same time after their last status change, for the daemon to start.
+// Nodes without either are assumed to be fine, as there is nothing to go by.
+func nodeDaemonAlive(node *corev1.Node, now time.Time) bool {
+	lastSeen, err := time.Parse(time.RFC3339, node.Annotations[constants.NodeHeartbeat])
+	if err != nil {
+		lastSeen, err = time.Parse(time.RFC3339, node.Annotations[constants.NodeStatusTime])
+		if err != nil {
+			return true
+		}
+	}
+	return now.Sub(lastSeen) <= daemonHeartbeatTimeout
+}
+
+// Summarize the error reasons of nodes by how often they occur, e.g. "2 nodes: KubeadmUpgradeFailed, 1 node: RebaseFailed".
+// The most common reason comes first.
+func summarizeErrorReasons(reasons []string) string {
+	counts := make(map[string]int, len(reasons))
+	for _, reason := range reasons {
+		counts[reason]++
+	}
+	keys := slices.Collect(maps.Keys(counts))
+	slices.SortFunc(keys, func(a, b string) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return strings.Compare(a, b)
+	})
+
+	summary := make([]string, 0, len(keys))
+	for _, reason := range keys {
+		nodes := "nodes"
+		if counts[reason] == 1 {
+			nodes = "node"
+		}
+		summary = append(summary, fmt.Sprintf("%d %s: %s", counts[reason], nodes, reason))
+	}
+	return strings.Join(summary, ", ")
+}
+
+// Check if the operator annotation of the node is set to "true"
+func nodeAnnotationEnabled(node *corev1.Node, key string) bool {
+	return node.Annotations[key] == "true"
+}
+
+// Check if the node has been put on hold or is upgraded by other means
+func nodeExcludedFromRollout(node *corev1.Node) bool {
+	return nodeAnnotationEnabled(node, constants.NodeHold) || nodeAnnotationEnabled(node, constants.NodeSkip)
+}
+
+// Return how often the upgrade to the current target version has been attempted on the node
+func nodeUpgradeAttempts(node *corev1.Node) int {
+	attempts, err := strconv.Atoi(node.Annotations[constants.NodeUpgradeAttempts])
+	if err != nil || attempts < 1 {
+		return 1
+	}
+	return attempts
+}
+
+// Return the time after which the errored node may be retried.
+// The backoff of the policy is doubled with every attempt.
+func nodeRetryTime(node *corev1.Node, attempts int, policy api.FailurePolicy) time.Time {
+	errorTime, err := time.Parse(time.RFC3339, node.Annotations[constants.NodeLastErrorTime])
+	if err != nil {
+		// Retry right away when the daemon did not record the time of the error
+		return time.Time{}
+	}
+
+	backoff, err := time.ParseDuration(policy.Backoff)
+	if err != nil {
+		// Should not happen, as the policy is validated by the webhook.
+		backoff, _ = time.ParseDuration(api.DefaultFailurePolicyBackoff)
+	}
+	for range attempts - 1 {
+		backoff *= 2
+	}
+	return errorTime.Add(backoff)
+}
+
+// Return the failure policy of the group with the defaults applied
+func failurePolicy(group api.KubeUpgradePlanGroup) api.FailurePolicy {
+	var policy api.FailurePolicy
+	if group.FailurePolicy != nil {
+		policy = *group.FailurePolicy
+	}
+	api.SetObjectDefaults_FailurePolicy(&policy)
+	return policy
+}
+
+// Return the timeout for each node status configured in the plan.
+// Statuses without a timeout are omitted.
+func phaseTimeouts(spec api.KubeUpgradeSpec) map[string]time.Duration {
+	if spec.Timeouts == nil {
+		return map[string]time.Duration{}
+	}
+	cfg := spec.Timeouts
+
+	timeouts := make(map[string]time.Duration, 4)
+	for status, value := range map[string]string{
+		constants.NodeUpgradeStatusPending:   cfg.Pending,
+		constants.NodeUpgradeStatusUpgrading: cfg.Upgrading,
+		constants.NodeUpgradeStatusRebasing:  cfg.Rebasing,
+		constants.NodeUpgradeStatusVerifying: cfg.Verifying,
+	} {
+		if value == "" {
+			continue
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			// Should not happen, as the timeouts are validated by the webhook.
+			slog.Warn("Invalid timeout, the phase has no timeout", "phase", status, "timeout", value, "err", err)
+			continue
+		}
+		if timeout > 0 {
+			timeouts[status] = timeout
+		}
+	}
+	return timeouts
+}
+
+// Return the minor version of the given semantic version, e.g. 31 for v1.31.0
+func minorVersion(version string) (int, error) {
+	_, minor, ok := strings.Cut(semver.MajorMinor(version), ".")
+	if !ok {
+		return 0, fmt.Errorf("\"%s\" is not a valid semantic version", version)
+	}
+	return strconv.Atoi(minor)
+}
+
 // Return the upgraded image to use based on environment variables
 func GetUpgradedImage() string {
 	logger := slog.With("env", upgradedImageEnv)
@@ -104,6 +262,41 @@ func GetUpgradedImage() string {
 	return fmt.Sprintf("%s:%s", image, tag)
 }
 
+// Return the username of the service account used by the controller, based on the environment variable
+func GetServiceAccountUsername(namespace string) string {
+	name := getServiceAccountName()
+	if os.Getenv(serviceAccountNameEnv) == "" {
+		slog.Info("Service account name is not set, falling back to default", "env", serviceAccountNameEnv, "name", defaultServiceAccountName)
+	}
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name)
+}
+
+// Return the name of the service account used by the controller, based on the environment variable
+func getServiceAccountName() string {
+	name := os.Getenv(serviceAccountNameEnv)
+	if name == "" {
+		return defaultServiceAccountName
+	}
+	return name
+}
+
+// Return the http client used for external requests
+func (c *controller) getHTTPClient() *http.Client {
+	if c.httpClient == nil {
+		return http.DefaultClient
+	}
+	return c.httpClient
+}
+
+// Return the reader for objects outside of the cache of the manager, e.g. secrets.
+// Falls back to the client when there is no manager.
+func (c *controller) getAPIReader() client.Reader {
+	if c.manager == nil {
+		return c.Client
+	}
+	return c.manager.GetAPIReader()
+}
+
 // Return a new scheme with already registered standard types and kube-upgrade types
 func newScheme() (*runtime.Scheme, error) {
 	scheme := runtime.NewScheme()
@@ -3,10 +3,14 @@ package controller
 import (
 	"os"
 	"testing"
+	"time"
 
 	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
 	"github.com/heathcliff26/kube-upgrade/pkg/version"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestGetNamespace(t *testing.T) {
@@ -137,6 +141,15 @@ func TestCreateStatusSummary(t *testing.T) {
 			},
 			Result: api.PlanStatusComplete,
 		},
+		{
+			Name: "CompleteWithFailedNodes",
+			Status: map[string]string{
+				"foo":    api.PlanStatusComplete,
+				"bar":    api.PlanStatusComplete + ": 1 nodes failed [node]",
+				"foobar": api.PlanStatusComplete,
+			},
+			Result: api.PlanStatusComplete + ": Some groups have failed nodes [bar]",
+		},
 		{
 			Status: map[string]string{
 				"foo":    api.PlanStatusComplete,
@@ -154,6 +167,24 @@ func TestCreateStatusSummary(t *testing.T) {
 			},
 			Result: api.PlanStatusProgressing + ": Upgrading groups [bar]",
 		},
+		{
+			Name: api.PlanStatusStalled,
+			Status: map[string]string{
+				"foo":    api.PlanStatusComplete,
+				"bar":    api.PlanStatusStalled + ": 0/1 nodes upgraded, no live daemon on [node]",
+				"foobar": api.PlanStatusWaiting,
+			},
+			Result: api.PlanStatusStalled + ": No node is making progress in groups [bar]",
+		},
+		{
+			Name: "ProgressingWhileStalled",
+			Status: map[string]string{
+				"foo":    api.PlanStatusProgressing + ": 0/1 nodes upgraded",
+				"bar":    api.PlanStatusStalled + ": 0/1 nodes upgraded, no live daemon on [node]",
+				"foobar": api.PlanStatusComplete,
+			},
+			Result: api.PlanStatusProgressing + ": Upgrading groups [foo]",
+		},
 		{
 			Status: map[string]string{
 				"foo":    api.PlanStatusUnknown,
@@ -231,3 +262,132 @@ func TestGetUpgradedImage(t *testing.T) {
 		})
 	}
 }
+
+func TestGetServiceAccountUsername(t *testing.T) {
+	t.Run("EnvSet", func(t *testing.T) {
+		t.Setenv(serviceAccountNameEnv, "custom")
+
+		assert.Equal(t, "system:serviceaccount:test-ns:custom", GetServiceAccountUsername("test-ns"), "Should use the name from the environment")
+	})
+	t.Run("EnvNotSet", func(t *testing.T) {
+		t.Setenv(serviceAccountNameEnv, "")
+
+		assert.Equal(t, "system:serviceaccount:test-ns:"+defaultServiceAccountName, GetServiceAccountUsername("test-ns"), "Should use the default name")
+	})
+}
+
+func TestMinorVersion(t *testing.T) {
+	tMatrix := []struct {
+		Version  string
+		Expected int
+		Error    bool
+	}{
+		{Version: "v1.31.0", Expected: 31},
+		{Version: "v1.9.2", Expected: 9},
+		{Version: "v1.32.0-rc.1", Expected: 32},
+		{Version: "v1", Expected: 0},
+		{Version: "not-a-version", Error: true},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Version, func(t *testing.T) {
+			assert := assert.New(t)
+
+			minor, err := minorVersion(tCase.Version)
+
+			if tCase.Error {
+				assert.Error(err, "Should not parse version")
+			} else {
+				assert.NoError(err, "Should parse version")
+				assert.Equal(tCase.Expected, minor, "Should return the minor version")
+			}
+		})
+	}
+}
+
+func TestNodeDaemonAlive(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tMatrix := []struct {
+		Name        string
+		Annotations map[string]string
+		Result      bool
+	}{
+		{
+			Name: "RecentHeartbeat",
+			Annotations: map[string]string{
+				constants.NodeHeartbeat:  now.Add(-time.Minute).Format(time.RFC3339),
+				constants.NodeStatusTime: now.Add(-time.Hour).Format(time.RFC3339),
+			},
+			Result: true,
+		},
+		{
+			Name: "MissedHeartbeats",
+			Annotations: map[string]string{
+				constants.NodeHeartbeat:  now.Add(-10 * time.Minute).Format(time.RFC3339),
+				constants.NodeStatusTime: now.Format(time.RFC3339),
+			},
+			Result: false,
+		},
+		{
+			Name: "NoHeartbeatWithinGracePeriod",
+			Annotations: map[string]string{
+				constants.NodeStatusTime: now.Add(-time.Minute).Format(time.RFC3339),
+			},
+			Result: true,
+		},
+		{
+			Name: "NoHeartbeatAfterGracePeriod",
+			Annotations: map[string]string{
+				constants.NodeStatusTime: now.Add(-10 * time.Minute).Format(time.RFC3339),
+			},
+			Result: false,
+		},
+		{
+			Name:        "NoTimes",
+			Annotations: map[string]string{},
+			Result:      true,
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: tCase.Annotations,
+				},
+			}
+			assert.Equal(t, tCase.Result, nodeDaemonAlive(node, now))
+		})
+	}
+}
+
+func TestSummarizeErrorReasons(t *testing.T) {
+	tMatrix := []struct {
+		Name    string
+		Reasons []string
+		Result  string
+	}{
+		{
+			Name:    "NoReasons",
+			Reasons: []string{},
+			Result:  "",
+		},
+		{
+			Name:    "SingleNode",
+			Reasons: []string{constants.NodeErrorReasonRebaseFailed},
+			Result:  "1 node: RebaseFailed",
+		},
+		{
+			Name:    "MostCommonFirst",
+			Reasons: []string{constants.NodeErrorReasonTimeout, constants.NodeErrorReasonKubeadmUpgradeFailed, constants.NodeErrorReasonRebaseFailed, constants.NodeErrorReasonKubeadmUpgradeFailed},
+			Result:  "2 nodes: KubeadmUpgradeFailed, 1 node: RebaseFailed, 1 node: Timeout",
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert.Equal(t, tCase.Result, summarizeErrorReasons(tCase.Reasons))
+		})
+	}
+}
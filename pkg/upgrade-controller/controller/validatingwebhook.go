@@ -4,17 +4,32 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
+	"strings"
 
 	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"golang.org/x/mod/semver"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
 )
 
+// The maximum number of minor versions a kubelet may be older than the kube-apiserver.
+// See https://kubernetes.io/releases/version-skew-policy/#kubelet
+const maxKubeletMinorVersionSkew = 3
+
 // +kubebuilder:webhook:path=/validate-kubeupgrade-heathcliff-eu-v1alpha3-kubeupgradeplan,mutating=false,failurePolicy=fail,groups=kubeupgrade.heathcliff.eu,resources=kubeupgradeplans,verbs=create;update,versions=v1alpha3,name=kubeupgrade.heathcliff.eu,admissionReviewVersions=v1,sideEffects=None
 
 // planValidatingHook validates the plan
 type planValidatingHook struct {
 	client.Client
+	// Used to read objects outside of the controller namespace, as the cache is limited to it.
+	// Falls back to the client when not set.
+	APIReader client.Reader
 }
 
 // Validate all values of the plan and check if they are sensible
@@ -23,6 +38,14 @@ func (*planValidatingHook) validate(plan *api.KubeUpgradePlan) (admission.Warnin
 	if err != nil {
 		return nil, err
 	}
+	err = validateHookServiceAccounts(plan, getServiceAccountName())
+	if err != nil {
+		return nil, err
+	}
+	err = validateKubeadmSource(plan)
+	if err != nil {
+		return nil, err
+	}
 
 	var warnings []string
 	if plan.Spec.AllowDowngrade {
@@ -39,10 +62,154 @@ func (*planValidatingHook) validate(plan *api.KubeUpgradePlan) (admission.Warnin
 	if allowUnsignedOstreeImages {
 		warnings = append(warnings, "AllowUnsignedOstreeImages is set to true, this lowers security. Consider signing your custom images with cosign.")
 	}
+	if groups := groupsWithoutSignaturePolicy(plan); len(groups) > 0 {
+		warnings = append(warnings, fmt.Sprintf("The groups %v set neither signaturePolicy nor allowUnsignedOstreeImages, their nodes fail to rebase with StreamUnverifiable unless the host policy already requires signatures for the stream.", groups))
+	}
+
+	if semver.Prerelease(plan.Spec.KubernetesVersion) != "" {
+		warnings = append(warnings, fmt.Sprintf("KubernetesVersion %s is a pre-release, it should not be used in production.", plan.Spec.KubernetesVersion))
+	} else if strings.HasPrefix(plan.Spec.KubernetesVersion, "latest-") && api.IsVersionChannel(plan.Spec.KubernetesVersion) {
+		warnings = append(warnings, fmt.Sprintf("KubernetesVersion %s is a channel that includes pre-releases, they should not be used in production.", plan.Spec.KubernetesVersion))
+	}
 
 	return warnings, nil
 }
 
+// Return the groups that rely on the existing host policy to verify the stream
+func groupsWithoutSignaturePolicy(plan *api.KubeUpgradePlan) []string {
+	groups := make([]string, 0)
+	for _, name := range slices.Sorted(maps.Keys(plan.Spec.Groups)) {
+		cfg := combineConfig(plan.Spec.Upgraded, plan.Spec.Groups[name].Upgraded)
+		if cfg.SignaturePolicy == nil && !cfg.AllowUnsignedOstreeImages {
+			groups = append(groups, name)
+		}
+	}
+	return groups
+}
+
+// Check that kubeadm is only extracted from stream images, that are verified by the host.
+// The extracted binary is run as root without any further verification.
+func validateKubeadmSource(plan *api.KubeUpgradePlan) error {
+	check := func(field string, cfg *api.UpgradedConfig, allowUnsigned bool) error {
+		if allowUnsigned && cfg.KubeadmDownload != nil && cfg.KubeadmDownload.Source == api.KubeadmSourceImage {
+			return fmt.Errorf("%s.kubeadmDownload.source can't be %s while allowUnsignedOstreeImages is set, as kubeadm would be extracted from an unverified image", field, api.KubeadmSourceImage)
+		}
+		return nil
+	}
+
+	err := check("spec.upgraded", &plan.Spec.Upgraded, plan.Spec.Upgraded.AllowUnsignedOstreeImages)
+	if err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(plan.Spec.Groups)) {
+		group := plan.Spec.Groups[name]
+		if group.Upgraded == nil {
+			continue
+		}
+		allowUnsigned := plan.Spec.Upgraded.AllowUnsignedOstreeImages || group.Upgraded.AllowUnsignedOstreeImages
+		err = check("spec.groups."+name+".upgraded", combineConfig(plan.Spec.Upgraded, group.Upgraded), allowUnsigned)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check the version change against the upstream version skew policy and the current state of the cluster.
+// The oldPlan is nil when the plan is created.
+func (p *planValidatingHook) validateVersionSkew(ctx context.Context, oldPlan, plan *api.KubeUpgradePlan) (admission.Warnings, error) {
+	version := plan.Spec.KubernetesVersion
+	// Force only overrides a single version change, it must not be left in place for the next one
+	if plan.Spec.Force && (oldPlan == nil || oldPlan.Spec.KubernetesVersion == version) {
+		return nil, fmt.Errorf("force can only be set together with a change of kubernetesVersion, unset it after the version has been changed")
+	}
+	if oldPlan != nil && oldPlan.Spec.KubernetesVersion == version {
+		return nil, nil
+	}
+
+	var warnings []string
+	if oldPlan != nil && (strings.HasPrefix(oldPlan.Status.Summary, api.PlanStatusProgressing) || strings.HasPrefix(oldPlan.Status.Summary, api.PlanStatusStalled)) {
+		if !plan.Spec.Force {
+			return nil, fmt.Errorf("can't change kubernetesVersion from %s to %s while the previous rollout is still in progress, set force to override", oldPlan.Spec.KubernetesVersion, version)
+		}
+		warnings = append(warnings, "Force is set to true, changing the kubernetesVersion while a rollout is still in progress may leave nodes with mixed versions.")
+	}
+
+	// Channels are pinned to a minor version, which is all the skew checks need.
+	targetVersion := version
+	if api.IsVersionChannel(version) {
+		targetVersion = api.VersionChannelMajorMinor(version)
+	}
+
+	targetMinor, err := minorVersion(targetVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clusters not set up by kubeadm have no kubeadm-config, the kubelet versions are still checked for them.
+	apiserverVersion, err := p.getClusterVersion(ctx)
+	if apierrors.IsNotFound(err) {
+		warnings = append(warnings, fmt.Sprintf("The ConfigMap %s/%s does not exist, skipping the version skew check against the control-plane.", constants.KubeadmConfigMapNamespace, constants.KubeadmConfigMapName))
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch kubeadm-config: %w", err)
+	} else {
+		if semver.Major(apiserverVersion) != semver.Major(targetVersion) {
+			return nil, fmt.Errorf("can't change the major version from %s to %s", apiserverVersion, version)
+		}
+		apiserverMinor, err := minorVersion(apiserverVersion)
+		if err != nil {
+			return nil, err
+		}
+		if targetMinor-apiserverMinor > 1 {
+			return nil, fmt.Errorf("can't upgrade from %s to %s, kubernetes only supports upgrading one minor version at a time", apiserverVersion, version)
+		}
+	}
+
+	nodeList := &corev1.NodeList{}
+	err = p.List(ctx, nodeList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+	for _, node := range nodeList.Items {
+		kubeletVersion := node.Status.NodeInfo.KubeletVersion
+		kubeletMinor, err := minorVersion(kubeletVersion)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Could not parse kubelet version \"%s\" of node %s, skipping version skew check for it.", kubeletVersion, node.GetName()))
+			continue
+		}
+		if targetMinor-kubeletMinor > maxKubeletMinorVersionSkew {
+			return nil, fmt.Errorf("node %s kubelet version %s would be more than %d minor versions older than %s", node.GetName(), kubeletVersion, maxKubeletMinorVersionSkew, version)
+		}
+	}
+
+	return warnings, nil
+}
+
+// Read the current kubernetes version of the control-plane from the kubeadm-config.
+// Returns the error of the client unchanged when it can't be fetched.
+func (p *planValidatingHook) getClusterVersion(ctx context.Context) (string, error) {
+	reader := p.APIReader
+	if reader == nil {
+		reader = p.Client
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := reader.Get(ctx, client.ObjectKey{Namespace: constants.KubeadmConfigMapNamespace, Name: constants.KubeadmConfigMapName}, cm)
+	if err != nil {
+		return "", err
+	}
+
+	var clusterConfig constants.KubeadmClusterConfiguration
+	err = yaml.Unmarshal([]byte(cm.Data[constants.KubeadmClusterConfigurationKey]), &clusterConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse kubeadm-config: %v", err)
+	}
+	if !semver.IsValid(clusterConfig.KubernetesVersion) {
+		return "", fmt.Errorf("kubeadm-config contains invalid kubernetesVersion \"%s\"", clusterConfig.KubernetesVersion)
+	}
+	return clusterConfig.KubernetesVersion, nil
+}
+
 // ValidateCreate validates the object on creation.
 // The optional warnings will be added to the response as warning messages.
 // Return an error if the object is invalid.
@@ -61,14 +228,36 @@ func (p *planValidatingHook) ValidateCreate(ctx context.Context, plan *api.KubeU
 		return nil, fmt.Errorf("KubeUpgradePlan already exists")
 	}
 
-	return p.validate(plan)
+	warnings, err := p.validate(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	skewWarnings, err := p.validateVersionSkew(ctx, nil, plan)
+	if err != nil {
+		return nil, err
+	}
+	return append(warnings, skewWarnings...), nil
 }
 
 // ValidateUpdate validates the object on update.
 // The optional warnings will be added to the response as warning messages.
 // Return an error if the object is invalid.
-func (p *planValidatingHook) ValidateUpdate(_ context.Context, _ *api.KubeUpgradePlan, newPlan *api.KubeUpgradePlan) (admission.Warnings, error) {
-	return p.validate(newPlan)
+func (p *planValidatingHook) ValidateUpdate(ctx context.Context, oldPlan *api.KubeUpgradePlan, newPlan *api.KubeUpgradePlan) (admission.Warnings, error) {
+	if p.Client == nil {
+		return nil, fmt.Errorf("no client provided for validating webhook, please report a bug")
+	}
+
+	warnings, err := p.validate(newPlan)
+	if err != nil {
+		return nil, err
+	}
+
+	skewWarnings, err := p.validateVersionSkew(ctx, oldPlan, newPlan)
+	if err != nil {
+		return nil, err
+	}
+	return append(warnings, skewWarnings...), nil
 }
 
 // ValidateDelete validates the object on deletion.
@@ -4,12 +4,25 @@ import (
 	"testing"
 
 	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
+// Public key for the signature policy of test plans, the plans would warn about an unverified stream without it
+const testSignaturePublicKey = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE2G2Y+2tabdTV5BcGiBIx0a9fAFwr
+kBbmLSGtks4L3qX6yYY0zufBnhC8Ur/iy55GhWP/9A/bY2LhC30M9+RYtw==
+-----END PUBLIC KEY-----
+`
+
 func TestValidate(t *testing.T) {
 	minimumValidPlan := &api.KubeUpgradePlan{
 		Spec: api.KubeUpgradeSpec{
@@ -20,7 +33,8 @@ func TestValidate(t *testing.T) {
 				},
 			},
 			Upgraded: api.UpgradedConfig{
-				FleetlockURL: "https://fleetlock.example.com",
+				FleetlockURL:    "https://fleetlock.example.com",
+				SignaturePolicy: &api.SignaturePolicy{PublicKey: testSignaturePublicKey},
 			},
 		},
 	}
@@ -40,8 +54,38 @@ func TestValidate(t *testing.T) {
 		},
 	}
 
-	validKubernetesVersionWithPreRelease := minimumValidPlan.DeepCopy()
-	validKubernetesVersionWithPreRelease.Spec.KubernetesVersion = "v1.31.0-rc.0"
+	validVersionChannel := minimumValidPlan.DeepCopy()
+	validVersionChannel.Spec.KubernetesVersion = "stable-1.31"
+	validVersionChannel.Spec.VersionChannel = &api.VersionChannelConfig{
+		ConfigMap: "kube-upgrade-channels",
+	}
+
+	invalidVersionChannel := minimumValidPlan.DeepCopy()
+	invalidVersionChannel.Spec.KubernetesVersion = "stable-1"
+
+	invalidVersionChannelURL := validVersionChannel.DeepCopy()
+	invalidVersionChannelURL.Spec.VersionChannel.URL = "not-a-url"
+
+	invalidVersionChannelInterval := validVersionChannel.DeepCopy()
+	invalidVersionChannelInterval.Spec.VersionChannel.Interval = "not-a-duration"
+
+	validGroupFailurePolicy := minimumValidPlan.DeepCopy()
+	maxFailures := intstr.FromString("10%")
+	validGroupFailurePolicy.Spec.Groups["control-plane"] = api.KubeUpgradePlanGroup{
+		Labels: map[string]string{labelControl: labelValue},
+		FailurePolicy: &api.FailurePolicy{
+			MaxAttempts: 5,
+			Backoff:     "10m",
+			MaxFailures: &maxFailures,
+		},
+	}
+
+	invalidFailurePolicyBackoff := validGroupFailurePolicy.DeepCopy()
+	invalidFailurePolicyBackoff.Spec.Groups["control-plane"].FailurePolicy.Backoff = "not-a-duration"
+
+	invalidFailurePolicyMaxFailures := validGroupFailurePolicy.DeepCopy()
+	invalidMaxFailures := intstr.FromString("abc%")
+	invalidFailurePolicyMaxFailures.Spec.Groups["control-plane"].FailurePolicy.MaxFailures = &invalidMaxFailures
 
 	invalidKubernetesVersion := minimumValidPlan.DeepCopy()
 	invalidKubernetesVersion.Spec.KubernetesVersion = "testv1.0.0"
@@ -90,6 +134,67 @@ func TestValidate(t *testing.T) {
 	invalidRetryInterval := minimumValidPlan.DeepCopy()
 	invalidRetryInterval.Spec.Upgraded.RetryInterval = "not-a-duration"
 
+	invalidRetryMaxInterval := minimumValidPlan.DeepCopy()
+	invalidRetryMaxInterval.Spec.Upgraded.Retry = &api.RetryConfig{
+		MaxInterval: "not-a-duration",
+	}
+
+	validTimeouts := minimumValidPlan.DeepCopy()
+	validTimeouts.Spec.Timeouts = &api.PhaseTimeouts{
+		Pending:   "6h",
+		Upgrading: "0s",
+	}
+
+	invalidTimeouts := minimumValidPlan.DeepCopy()
+	invalidTimeouts.Spec.Timeouts = &api.PhaseTimeouts{
+		Rebasing: "not-a-duration",
+	}
+
+	invalidRetryAttempts := minimumValidPlan.DeepCopy()
+	invalidRetryAttempts.Spec.Upgraded.Retry = &api.RetryConfig{
+		LockAttempts: -1,
+	}
+
+	validNodeHooks := minimumValidPlan.DeepCopy()
+	validNodeHooks.Spec.Upgraded.NodeHooks = &api.NodeHooksConfig{
+		Dir:           "/etc/kube-upgraded/hooks.d",
+		ConfigMap:     "kube-upgraded-hooks",
+		FailurePolicy: api.NodeHooksFailurePolicyAbort,
+		Timeout:       "5m",
+	}
+
+	invalidNodeHooksDir := validNodeHooks.DeepCopy()
+	invalidNodeHooksDir.Spec.Upgraded.NodeHooks.Dir = "hooks.d"
+
+	invalidNodeHooksFailurePolicy := validNodeHooks.DeepCopy()
+	invalidNodeHooksFailurePolicy.Spec.Upgraded.NodeHooks.FailurePolicy = "Retry"
+
+	invalidNodeHooksTimeout := validNodeHooks.DeepCopy()
+	invalidNodeHooksTimeout.Spec.Upgraded.NodeHooks.Timeout = "0s"
+
+	validHooks := minimumValidPlan.DeepCopy()
+	validHooks.Spec.PreUpgrade = newTestHookTemplate()
+	validHooks.Spec.Groups["control-plane"] = api.KubeUpgradePlanGroup{
+		Labels:      map[string]string{labelControl: labelValue},
+		PostUpgrade: newTestHookTemplate(),
+	}
+
+	invalidHookWithoutContainer := minimumValidPlan.DeepCopy()
+	invalidHookWithoutContainer.Spec.PostUpgrade = &batchv1.JobTemplateSpec{}
+
+	invalidHookRestartPolicy := validHooks.DeepCopy()
+	invalidHookRestartPolicy.Spec.Groups["control-plane"].PostUpgrade.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyAlways
+
+	validHookServiceAccount := validHooks.DeepCopy()
+	validHookServiceAccount.Spec.PreUpgrade.Spec.Template.Spec.ServiceAccountName = "hook"
+
+	invalidHookControllerServiceAccount := validHooks.DeepCopy()
+	invalidHookControllerServiceAccount.Spec.PreUpgrade.Spec.Template.Spec.ServiceAccountName = defaultServiceAccountName
+
+	invalidHookUpgradedServiceAccount := validHooks.DeepCopy()
+	invalidHookUpgradedServiceAccount.Spec.Upgraded.ServiceAccountName = "upgraded"
+	invalidHookUpgradedServiceAccount.Spec.Groups["control-plane"].PostUpgrade.Spec.Template.Spec.DeprecatedServiceAccount = "upgraded"
+
 	tMatrix := []struct {
 		Name  string
 		Plan  *api.KubeUpgradePlan
@@ -113,8 +218,37 @@ func TestValidate(t *testing.T) {
 			Plan: validGroupWithUpgradedConfig,
 		},
 		{
-			Name: "ValidKubernetesVersionWithPreRelease",
-			Plan: validKubernetesVersionWithPreRelease,
+			Name: "ValidVersionChannel",
+			Plan: validVersionChannel,
+		},
+		{
+			Name:  "InvalidVersionChannel",
+			Plan:  invalidVersionChannel,
+			Error: true,
+		},
+		{
+			Name:  "InvalidVersionChannelURL",
+			Plan:  invalidVersionChannelURL,
+			Error: true,
+		},
+		{
+			Name:  "InvalidVersionChannelInterval",
+			Plan:  invalidVersionChannelInterval,
+			Error: true,
+		},
+		{
+			Name: "ValidGroupFailurePolicy",
+			Plan: validGroupFailurePolicy,
+		},
+		{
+			Name:  "InvalidFailurePolicyBackoff",
+			Plan:  invalidFailurePolicyBackoff,
+			Error: true,
+		},
+		{
+			Name:  "InvalidFailurePolicyMaxFailures",
+			Plan:  invalidFailurePolicyMaxFailures,
+			Error: true,
 		},
 		{
 			Name:  "InvalidKubernetesVersion",
@@ -176,6 +310,72 @@ func TestValidate(t *testing.T) {
 			Plan:  invalidRetryInterval,
 			Error: true,
 		},
+		{
+			Name:  "InvalidRetryMaxInterval",
+			Plan:  invalidRetryMaxInterval,
+			Error: true,
+		},
+		{
+			Name: "ValidTimeouts",
+			Plan: validTimeouts,
+		},
+		{
+			Name:  "InvalidTimeouts",
+			Plan:  invalidTimeouts,
+			Error: true,
+		},
+		{
+			Name:  "InvalidRetryAttempts",
+			Plan:  invalidRetryAttempts,
+			Error: true,
+		},
+		{
+			Name: "ValidNodeHooks",
+			Plan: validNodeHooks,
+		},
+		{
+			Name:  "InvalidNodeHooksDir",
+			Plan:  invalidNodeHooksDir,
+			Error: true,
+		},
+		{
+			Name:  "InvalidNodeHooksFailurePolicy",
+			Plan:  invalidNodeHooksFailurePolicy,
+			Error: true,
+		},
+		{
+			Name:  "InvalidNodeHooksTimeout",
+			Plan:  invalidNodeHooksTimeout,
+			Error: true,
+		},
+		{
+			Name: "ValidHooks",
+			Plan: validHooks,
+		},
+		{
+			Name:  "InvalidHookWithoutContainer",
+			Plan:  invalidHookWithoutContainer,
+			Error: true,
+		},
+		{
+			Name:  "InvalidHookRestartPolicy",
+			Plan:  invalidHookRestartPolicy,
+			Error: true,
+		},
+		{
+			Name: "ValidHookServiceAccount",
+			Plan: validHookServiceAccount,
+		},
+		{
+			Name:  "InvalidHookControllerServiceAccount",
+			Plan:  invalidHookControllerServiceAccount,
+			Error: true,
+		},
+		{
+			Name:  "InvalidHookUpgradedServiceAccount",
+			Plan:  invalidHookUpgradedServiceAccount,
+			Error: true,
+		},
 	}
 
 	for _, tCase := range tMatrix {
@@ -206,6 +406,22 @@ func TestValidate(t *testing.T) {
 		assert.NoError(err, "Plan should be valid")
 		assert.Contains(warn, "AllowDowngrade is set to true, downgrading a cluster is not supported by upstream Kubernetes and likely will cause issues. Use at your own risk.", "Should return downgrade warning")
 	})
+	t.Run("MissingSignaturePolicy", func(t *testing.T) {
+		assert := assert.New(t)
+		plan := validMultipleGroups.DeepCopy()
+		plan.Spec.Upgraded.SignaturePolicy = nil
+		plan.Spec.Groups["control-plane"] = api.KubeUpgradePlanGroup{
+			Labels: map[string]string{labelControl: labelValue},
+			Upgraded: &api.UpgradedConfig{
+				SignaturePolicy: &api.SignaturePolicy{PublicKey: testSignaturePublicKey},
+			},
+		}
+
+		warn, err := (&planValidatingHook{}).validate(plan)
+
+		assert.NoError(err, "Plan should be valid")
+		assert.Equal(admission.Warnings{"The groups [compute] set neither signaturePolicy nor allowUnsignedOstreeImages, their nodes fail to rebase with StreamUnverifiable unless the host policy already requires signatures for the stream."}, warn, "Should warn about the groups relying on the host policy")
+	})
 	t.Run("AllowUnsignedOstreeImages", func(t *testing.T) {
 		assert := assert.New(t)
 		msg := "AllowUnsignedOstreeImages is set to true, this lowers security. Consider signing your custom images with cosign."
@@ -229,13 +445,78 @@ func TestValidate(t *testing.T) {
 		assert.NoError(err, "Plan should be valid")
 		assert.Contains(warn, msg, "Should contain unsigned ostree images warning")
 	})
+	t.Run("KubeadmFromUnsignedImage", func(t *testing.T) {
+		assert := assert.New(t)
+		plan := minimumValidPlan.DeepCopy()
+		plan.Spec.Upgraded.KubeadmDownload = &api.KubeadmDownloadConfig{Source: api.KubeadmSourceImage}
+
+		_, err := (&planValidatingHook{}).validate(plan)
+		assert.NoError(err, "Should allow extracting kubeadm from signed images")
+
+		plan.Spec.Upgraded.AllowUnsignedOstreeImages = true
+		_, err = (&planValidatingHook{}).validate(plan)
+		assert.ErrorContains(err, "spec.upgraded.kubeadmDownload.source", "Should not extract kubeadm from unsigned images")
+
+		plan.Spec.Upgraded.AllowUnsignedOstreeImages = false
+		group := plan.Spec.Groups["control-plane"]
+		group.Upgraded = &api.UpgradedConfig{
+			AllowUnsignedOstreeImages: true,
+		}
+		plan.Spec.Groups["control-plane"] = group
+		_, err = (&planValidatingHook{}).validate(plan)
+		assert.ErrorContains(err, "spec.groups.control-plane.upgraded.kubeadmDownload.source", "Should check the config of the group")
+
+		group.Upgraded.KubeadmDownload = &api.KubeadmDownloadConfig{Source: api.KubeadmSourceURL}
+		_, err = (&planValidatingHook{}).validate(plan)
+		assert.NoError(err, "Should allow downloading kubeadm for groups with unsigned images")
+	})
+	t.Run("KubeadmRekor", func(t *testing.T) {
+		assert := assert.New(t)
+		plan := minimumValidPlan.DeepCopy()
+		plan.Spec.Upgraded.KubeadmDownload = &api.KubeadmDownloadConfig{
+			RekorURL:       "https://rekor.example.com",
+			RekorPublicKey: testSignaturePublicKey,
+		}
+
+		_, err := (&planValidatingHook{}).validate(plan)
+		assert.NoError(err, "Should allow a custom rekor instance")
+
+		plan.Spec.Upgraded.KubeadmDownload.RekorURL = "not-a-url"
+		_, err = (&planValidatingHook{}).validate(plan)
+		assert.ErrorContains(err, "kubeadmDownload.rekorUrl", "Should reject an invalid rekor url")
+
+		plan.Spec.Upgraded.KubeadmDownload.RekorURL = ""
+		plan.Spec.Upgraded.KubeadmDownload.RekorPublicKey = "not-a-key"
+		_, err = (&planValidatingHook{}).validate(plan)
+		assert.ErrorContains(err, "kubeadmDownload.rekorPublicKey", "Should reject an invalid rekor public key")
+	})
+	t.Run("KubernetesVersionWithPreRelease", func(t *testing.T) {
+		assert := assert.New(t)
+		plan := minimumValidPlan.DeepCopy()
+		plan.Spec.KubernetesVersion = "v1.31.0-rc.0"
+
+		warn, err := (&planValidatingHook{}).validate(plan)
+
+		assert.NoError(err, "Plan should be valid")
+		assert.Contains(warn, "KubernetesVersion v1.31.0-rc.0 is a pre-release, it should not be used in production.", "Should return pre-release warning")
+	})
+	t.Run("LatestVersionChannel", func(t *testing.T) {
+		assert := assert.New(t)
+		plan := minimumValidPlan.DeepCopy()
+		plan.Spec.KubernetesVersion = "latest-1.31"
+
+		warn, err := (&planValidatingHook{}).validate(plan)
+
+		assert.NoError(err, "Plan should be valid")
+		assert.Contains(warn, "KubernetesVersion latest-1.31 is a channel that includes pre-releases, they should not be used in production.", "Should return pre-release warning")
+	})
 }
 
 func TestValidateCreate(t *testing.T) {
 
 	scheme, _ := newScheme()
 	webhook := &planValidatingHook{
-		Client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(newFakeKubeadmConfigMap("v1.30.4")).Build(),
 	}
 	validPlan := &api.KubeUpgradePlan{
 		ObjectMeta: metav1.ObjectMeta{
@@ -249,7 +530,8 @@ func TestValidateCreate(t *testing.T) {
 				},
 			},
 			Upgraded: api.UpgradedConfig{
-				FleetlockURL: "https://fleetlock.example.com",
+				FleetlockURL:    "https://fleetlock.example.com",
+				SignaturePolicy: &api.SignaturePolicy{PublicKey: testSignaturePublicKey},
 			},
 		},
 	}
@@ -276,7 +558,7 @@ func TestValidateCreate(t *testing.T) {
 		plan2 := validPlan.DeepCopy()
 		plan2.Name = "second-plan"
 		webhook := &planValidatingHook{
-			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(plan).Build(),
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(plan, newFakeKubeadmConfigMap("v1.30.4")).Build(),
 		}
 
 		warn, err := webhook.ValidateCreate(t.Context(), plan2)
@@ -293,15 +575,131 @@ func TestValidateCreate(t *testing.T) {
 			assert.Error(err, "Should return an error without a client")
 		}, "Should not panic without a client")
 	})
+	t.Run("MissingKubeadmConfig", func(t *testing.T) {
+		assert := assert.New(t)
+		webhook := &planValidatingHook{
+			Client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+		}
+
+		warn, err := webhook.ValidateCreate(t.Context(), validPlan.DeepCopy())
+
+		assert.NoError(err, "Should not need the kubeadm-config")
+		assert.Equal(admission.Warnings{"The ConfigMap kube-system/kubeadm-config does not exist, skipping the version skew check against the control-plane."}, warn, "Should warn about the skipped check")
+
+		plan := validPlan.DeepCopy()
+		plan.Spec.KubernetesVersion = "v1.35.0"
+		webhook.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(newFakeKubeletNode("node-1", "v1.31.0")).Build()
+
+		_, err = webhook.ValidateCreate(t.Context(), plan)
+
+		assert.Error(err, "Should still check the kubelet versions")
+	})
+	t.Run("UsesAPIReader", func(t *testing.T) {
+		assert := assert.New(t)
+		webhook := &planValidatingHook{
+			Client:    fake.NewClientBuilder().WithScheme(scheme).Build(),
+			APIReader: fake.NewClientBuilder().WithScheme(scheme).WithObjects(newFakeKubeadmConfigMap("v1.30.4")).Build(),
+		}
+
+		_, err := webhook.ValidateCreate(t.Context(), validPlan.DeepCopy())
+
+		assert.NoError(err, "Should read kubeadm-config with the APIReader")
+	})
+	t.Run("MinorVersionJump", func(t *testing.T) {
+		assert := assert.New(t)
+		plan := validPlan.DeepCopy()
+		plan.Spec.KubernetesVersion = "v1.32.0"
+
+		warn, err := webhook.ValidateCreate(t.Context(), plan)
+
+		assert.Nil(warn, "Should not return a warning")
+		assert.ErrorContains(err, "kubernetes only supports upgrading one minor version at a time", "Should not allow skipping minor versions")
+	})
+	t.Run("VersionChannelMinorVersionJump", func(t *testing.T) {
+		assert := assert.New(t)
+		plan := validPlan.DeepCopy()
+		plan.Spec.KubernetesVersion = "stable-1.32"
+
+		_, err := webhook.ValidateCreate(t.Context(), plan)
+
+		assert.ErrorContains(err, "kubernetes only supports upgrading one minor version at a time", "Should check the minor version of the channel")
+
+		plan.Spec.KubernetesVersion = "stable-1.31"
+		_, err = webhook.ValidateCreate(t.Context(), plan)
+
+		assert.NoError(err, "Should allow the next minor channel")
+	})
+	t.Run("MajorVersionChange", func(t *testing.T) {
+		assert := assert.New(t)
+		plan := validPlan.DeepCopy()
+		plan.Spec.KubernetesVersion = "v2.0.0"
+
+		_, err := webhook.ValidateCreate(t.Context(), plan)
+
+		assert.ErrorContains(err, "can't change the major version", "Should not allow major version changes")
+	})
+	t.Run("KubeletTooOld", func(t *testing.T) {
+		assert := assert.New(t)
+		webhook := &planValidatingHook{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				newFakeKubeadmConfigMap("v1.30.4"),
+				newFakeKubeletNode(nodeControlName, "v1.30.4"),
+				newFakeKubeletNode(nodeComputeName, "v1.27.8"),
+			).Build(),
+		}
+
+		warn, err := webhook.ValidateCreate(t.Context(), validPlan.DeepCopy())
+
+		assert.Nil(warn, "Should not return a warning")
+		assert.ErrorContains(err, "node node-compute kubelet version v1.27.8 would be more than 3 minor versions older than v1.31.0", "Should enforce kubelet version skew")
+	})
+	t.Run("KubeletWithinSkew", func(t *testing.T) {
+		assert := assert.New(t)
+		webhook := &planValidatingHook{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				newFakeKubeadmConfigMap("v1.30.4"),
+				newFakeKubeletNode(nodeControlName, "v1.30.4"),
+				newFakeKubeletNode(nodeComputeName, "v1.28.2"),
+			).Build(),
+		}
+
+		warn, err := webhook.ValidateCreate(t.Context(), validPlan.DeepCopy())
+
+		assert.Nil(warn, "Should not return a warning")
+		assert.NoError(err, "Should allow kubelets within the supported skew")
+	})
+	t.Run("InvalidKubeletVersion", func(t *testing.T) {
+		assert := assert.New(t)
+		webhook := &planValidatingHook{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				newFakeKubeadmConfigMap("v1.30.4"),
+				newFakeKubeletNode(nodeControlName, ""),
+			).Build(),
+		}
+
+		warn, err := webhook.ValidateCreate(t.Context(), validPlan.DeepCopy())
+
+		assert.NoError(err, "Should not fail on nodes without a kubelet version")
+		assert.Len(warn, 1, "Should warn about the node")
+	})
 }
 
 func TestValidateUpdate(t *testing.T) {
 	assert := assert.New(t)
 
 	ctx := t.Context()
+	scheme, _ := newScheme()
+	webhook := &planValidatingHook{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(newFakeKubeadmConfigMap("v1.30.4")).Build(),
+	}
 
 	warn, err := (&planValidatingHook{}).ValidateUpdate(ctx, &api.KubeUpgradePlan{}, &api.KubeUpgradePlan{})
 
+	assert.Nil(warn, "Should not return a warning")
+	assert.Error(err, "Should return an error without a client")
+
+	warn, err = webhook.ValidateUpdate(ctx, &api.KubeUpgradePlan{}, &api.KubeUpgradePlan{})
+
 	assert.Nil(warn, "Should not return a warning")
 	assert.Error(err, "Should return an error")
 
@@ -314,15 +712,79 @@ func TestValidateUpdate(t *testing.T) {
 				},
 			},
 			Upgraded: api.UpgradedConfig{
-				FleetlockURL: "https://fleetlock.example.com",
+				FleetlockURL:    "https://fleetlock.example.com",
+				SignaturePolicy: &api.SignaturePolicy{PublicKey: testSignaturePublicKey},
 			},
 		},
 	}
 
-	warn, err = (&planValidatingHook{}).ValidateUpdate(ctx, &api.KubeUpgradePlan{}, plan)
+	warn, err = webhook.ValidateUpdate(ctx, &api.KubeUpgradePlan{}, plan)
 
 	assert.Nil(warn, "Should not return a warning")
 	assert.NoError(err, "Should not return an error")
+
+	oldPlan := plan.DeepCopy()
+	oldPlan.Spec.KubernetesVersion = "v1.30.4"
+	oldPlan.Status.Summary = api.PlanStatusProgressing + ": Upgrading groups [control-plane]"
+
+	warn, err = webhook.ValidateUpdate(ctx, oldPlan, plan)
+
+	assert.Nil(warn, "Should not return a warning")
+	assert.ErrorContains(err, "while the previous rollout is still in progress", "Should not allow changing the version during a rollout")
+
+	stalledPlan := oldPlan.DeepCopy()
+	stalledPlan.Status.Summary = api.PlanStatusStalled + ": No node is making progress in groups [control-plane]"
+
+	_, err = webhook.ValidateUpdate(ctx, stalledPlan, plan)
+
+	assert.ErrorContains(err, "while the previous rollout is still in progress", "Should not allow changing the version during a stalled rollout")
+
+	forcedPlan := plan.DeepCopy()
+	forcedPlan.Spec.Force = true
+
+	warn, err = webhook.ValidateUpdate(ctx, oldPlan, forcedPlan)
+
+	assert.NoError(err, "Should allow changing the version during a rollout with force")
+	assert.Len(warn, 1, "Should warn about forcing the version change")
+
+	warn, err = webhook.ValidateUpdate(ctx, forcedPlan, forcedPlan.DeepCopy())
+
+	assert.Nil(warn, "Should not return a warning")
+	assert.ErrorContains(err, "force can only be set together with a change of kubernetesVersion", "Should not keep force after the version change")
+
+	_, err = webhook.ValidateUpdate(ctx, forcedPlan, plan.DeepCopy())
+
+	assert.NoError(err, "Should allow unsetting force")
+
+	warn, err = (&planValidatingHook{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}).ValidateUpdate(ctx, plan, plan.DeepCopy())
+
+	assert.Nil(warn, "Should not return a warning")
+	assert.NoError(err, "Should skip version skew checks when the version did not change")
+}
+
+func newFakeKubeadmConfigMap(version string) client.Object {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      constants.KubeadmConfigMapName,
+			Namespace: constants.KubeadmConfigMapNamespace,
+		},
+		Data: map[string]string{
+			constants.KubeadmClusterConfigurationKey: "kubernetesVersion: " + version,
+		},
+	}
+}
+
+func newFakeKubeletNode(name, kubeletVersion string) client.Object {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Status: corev1.NodeStatus{
+			NodeInfo: corev1.NodeSystemInfo{
+				KubeletVersion: kubeletVersion,
+			},
+		},
+	}
 }
 
 func TestValidateDelete(t *testing.T) {
@@ -0,0 +1,472 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	webhookCertSecretEnv     = "WEBHOOK_CERT_SECRET"
+	webhookServiceNameEnv    = "WEBHOOK_SERVICE"
+	webhookConfigurationsEnv = "WEBHOOK_CONFIGURATIONS"
+
+	webhookCACertKey = "ca.crt"
+	webhookCAKeyKey  = "ca.key"
+
+	webhookCAValidity   = 5 * 365 * 24 * time.Hour
+	webhookCertValidity = 365 * 24 * time.Hour
+	webhookCertInterval = 10 * time.Minute
+)
+
+// Same as the default of the controller-runtime webhook server
+var webhookCertDir = filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs")
+
+// webhookCertManager manages a self-signed CA and the serving certificate for the webhooks.
+// It runs on every replica, so all of them pick up rotated certificates.
+type webhookCertManager struct {
+	client.Client
+	// Used to read objects outside of the cache, as it is limited to the controller namespace and not started on the first sync
+	APIReader client.Reader
+
+	Namespace   string
+	SecretName  string
+	ServiceName string
+	// Names of the validating and mutating webhook configurations the CA is injected into.
+	// Only these are read, so the RBAC can be limited to them.
+	WebhookConfigurations []string
+	CertDir               string
+
+	now func() time.Time
+}
+
+// Create a new webhookCertManager based on the environment variables.
+// Returns nil if the certificates should not be managed by the controller.
+func newWebhookCertManager(c client.Client, reader client.Reader, namespace, certDir string) *webhookCertManager {
+	secret := os.Getenv(webhookCertSecretEnv)
+	if secret == "" {
+		return nil
+	}
+	service := os.Getenv(webhookServiceNameEnv)
+	if service == "" {
+		slog.Info("Webhook service name is not set, falling back to default", "env", webhookServiceNameEnv, "service", defaultServiceAccountName+"-webhooks")
+		service = defaultServiceAccountName + "-webhooks"
+	}
+	configurations := []string{defaultServiceAccountName + "-webhook", defaultServiceAccountName + "-node-webhook"}
+	if env := os.Getenv(webhookConfigurationsEnv); env != "" {
+		configurations = strings.Split(env, ",")
+	} else {
+		slog.Info("Webhook configurations are not set, falling back to default", "env", webhookConfigurationsEnv, "configurations", configurations)
+	}
+	return &webhookCertManager{
+		Client:                c,
+		APIReader:             reader,
+		Namespace:             namespace,
+		SecretName:            secret,
+		ServiceName:           service,
+		WebhookConfigurations: configurations,
+		CertDir:               certDir,
+		now:                   time.Now,
+	}
+}
+
+// Start runs the certificate sync periodically until the context is cancelled.
+func (m *webhookCertManager) Start(ctx context.Context) error {
+	ticker := time.NewTicker(webhookCertInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			err := m.Sync(ctx)
+			if err != nil {
+				slog.Error("Failed to sync webhook certificates", "err", err)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection returns false, as every replica needs to write the certificates for it's webhook server.
+func (*webhookCertManager) NeedLeaderElection() bool {
+	return false
+}
+
+// Sync ensures valid certificates exist, injects the CA into the webhook configurations and writes the certificates to disk.
+func (m *webhookCertManager) Sync(ctx context.Context) error {
+	secret, err := m.ensureSecret(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = m.injectCABundle(ctx, secret.Data[webhookCACertKey])
+	if err != nil {
+		return err
+	}
+
+	return m.writeCerts(secret)
+}
+
+// Fetch the secret and create or rotate the certificates when needed.
+func (m *webhookCertManager) ensureSecret(ctx context.Context) (*corev1.Secret, error) {
+	logger := slog.With("secret", m.SecretName, "namespace", m.Namespace)
+
+	secret := &corev1.Secret{}
+	err := m.APIReader.Get(ctx, client.ObjectKey{Namespace: m.Namespace, Name: m.SecretName}, secret)
+	if errors.IsNotFound(err) {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      m.SecretName,
+				Namespace: m.Namespace,
+			},
+			Type: corev1.SecretTypeTLS,
+		}
+		err = m.renew(secret)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Creating webhook certificates")
+		err = m.Create(ctx, secret)
+		if errors.IsAlreadyExists(err) {
+			// Another replica was faster
+			return m.ensureSecret(ctx)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to create webhook certificate secret: %v", err)
+		}
+		return secret, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get webhook certificate secret: %v", err)
+	}
+
+	if !m.needsRenewal(secret) {
+		return secret, nil
+	}
+
+	err = m.renew(secret)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Rotating webhook certificates")
+	err = m.Update(ctx, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update webhook certificate secret: %v", err)
+	}
+	return secret, nil
+}
+
+// Check if the certificates in the secret are missing, invalid or close to their expiry.
+func (m *webhookCertManager) needsRenewal(secret *corev1.Secret) bool {
+	caCerts, _, err := parseCA(secret.Data)
+	if err != nil || m.closeToExpiry(caCerts[0]) {
+		return true
+	}
+
+	keyPair, err := parseKeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil || m.closeToExpiry(keyPair) {
+		return true
+	}
+	if keyPair.CheckSignatureFrom(caCerts[0]) != nil {
+		return true
+	}
+	return !slices.Equal(keyPair.DNSNames, m.dnsNames())
+}
+
+// Create a new serving certificate, when necessary with a new CA.
+// A previous CA is kept in the bundle until it expires, so clients trust the certificates of all replicas during the rotation.
+func (m *webhookCertManager) renew(secret *corev1.Secret) error {
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+
+	caCerts, caKey, err := parseCA(secret.Data)
+	if err != nil || m.closeToExpiry(caCerts[0]) {
+		var previous []*x509.Certificate
+		if err == nil {
+			previous = caCerts
+		}
+		caCerts, caKey, err = m.newCA()
+		if err != nil {
+			return err
+		}
+		caCerts = append(caCerts, previous...)
+
+		caKeyDER, err := x509.MarshalPKCS8PrivateKey(caKey)
+		if err != nil {
+			return fmt.Errorf("failed to marshal CA key: %v", err)
+		}
+		secret.Data[webhookCAKeyKey] = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: caKeyDER})
+	}
+	caCerts = slices.DeleteFunc(caCerts, func(cert *x509.Certificate) bool {
+		return !m.now().Before(cert.NotAfter)
+	})
+	secret.Data[webhookCACertKey] = encodeCertificates(caCerts...)
+
+	certPEM, keyPEM, err := m.newServingCert(caCerts[0], caKey)
+	if err != nil {
+		return err
+	}
+	secret.Data[corev1.TLSCertKey] = certPEM
+	secret.Data[corev1.TLSPrivateKeyKey] = keyPEM
+	return nil
+}
+
+// Create a new self-signed CA
+func (m *webhookCertManager) newCA() ([]*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate CA key: %v", err)
+	}
+
+	now := m.now()
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("%s-ca@%d", m.ServiceName, now.Unix())},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(webhookCAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	template.SerialNumber, err = newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA certificate: %v", err)
+	}
+	return []*x509.Certificate{cert}, key, nil
+}
+
+// Create a new serving certificate for the webhook service signed by the CA
+func (m *webhookCertManager) newServingCert(ca *x509.Certificate, caKey crypto.Signer) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serving key: %v", err)
+	}
+
+	now := m.now()
+	notAfter := now.Add(webhookCertValidity)
+	if notAfter.After(ca.NotAfter) {
+		notAfter = ca.NotAfter
+	}
+	dnsNames := m.dnsNames()
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: dnsNames[len(dnsNames)-2]},
+		DNSNames:    dnsNames,
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	template.SerialNumber, err = newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create serving certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal serving key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// Set the CA bundle for the webhooks of the configured webhook configurations using the webhook service.
+// Missing configurations are skipped, as not all of them need to be deployed.
+func (m *webhookCertManager) injectCABundle(ctx context.Context, caBundle []byte) error {
+	for _, name := range m.WebhookConfigurations {
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		err := m.APIReader.Get(ctx, client.ObjectKey{Name: name}, validating)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get ValidatingWebhookConfiguration %s: %v", name, err)
+		} else if err == nil {
+			updated := false
+			for j := range validating.Webhooks {
+				updated = m.setCABundle(&validating.Webhooks[j].ClientConfig, caBundle) || updated
+			}
+			if updated {
+				slog.Info("Injecting CA bundle into webhook configuration", "validatingwebhookconfiguration", name)
+				err = m.Update(ctx, validating)
+				if err != nil {
+					return fmt.Errorf("failed to update ValidatingWebhookConfiguration %s: %v", name, err)
+				}
+			}
+		}
+
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		err = m.APIReader.Get(ctx, client.ObjectKey{Name: name}, mutating)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get MutatingWebhookConfiguration %s: %v", name, err)
+		} else if err == nil {
+			updated := false
+			for j := range mutating.Webhooks {
+				updated = m.setCABundle(&mutating.Webhooks[j].ClientConfig, caBundle) || updated
+			}
+			if updated {
+				slog.Info("Injecting CA bundle into webhook configuration", "mutatingwebhookconfiguration", name)
+				err = m.Update(ctx, mutating)
+				if err != nil {
+					return fmt.Errorf("failed to update MutatingWebhookConfiguration %s: %v", name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Set the CA bundle if the client config points to the webhook service.
+// Returns true if the client config has been changed.
+func (m *webhookCertManager) setCABundle(clientConfig *admissionregistrationv1.WebhookClientConfig, caBundle []byte) bool {
+	svc := clientConfig.Service
+	if svc == nil || svc.Namespace != m.Namespace || svc.Name != m.ServiceName {
+		return false
+	}
+	if bytes.Equal(clientConfig.CABundle, caBundle) {
+		return false
+	}
+	clientConfig.CABundle = caBundle
+	return true
+}
+
+// Write the serving certificate to the directory of the webhook server, unless it is already up to date.
+func (m *webhookCertManager) writeCerts(secret *corev1.Secret) error {
+	// #nosec G301: The directory only contains the public certificate and a key only readable by the owner
+	err := os.MkdirAll(m.CertDir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook certificate directory: %v", err)
+	}
+
+	for _, key := range []string{corev1.TLSPrivateKeyKey, corev1.TLSCertKey} {
+		path := filepath.Join(m.CertDir, key)
+		// #nosec G304: The path is fixed
+		current, err := os.ReadFile(path)
+		if err == nil && bytes.Equal(current, secret.Data[key]) {
+			continue
+		}
+		tmp := path + ".tmp"
+		err = os.WriteFile(tmp, secret.Data[key], 0600)
+		if err != nil {
+			return fmt.Errorf("failed to write webhook certificate: %v", err)
+		}
+		err = os.Rename(tmp, path)
+		if err != nil {
+			return fmt.Errorf("failed to write webhook certificate: %v", err)
+		}
+		slog.Debug("Wrote webhook certificate", "path", path)
+	}
+	return nil
+}
+
+// Check if less than a third of the lifetime of the certificate is remaining
+func (m *webhookCertManager) closeToExpiry(cert *x509.Certificate) bool {
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	return m.now().After(cert.NotAfter.Add(-lifetime / 3))
+}
+
+// Return the DNS names of the webhook service
+func (m *webhookCertManager) dnsNames() []string {
+	return []string{
+		m.ServiceName,
+		fmt.Sprintf("%s.%s", m.ServiceName, m.Namespace),
+		fmt.Sprintf("%s.%s.svc", m.ServiceName, m.Namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", m.ServiceName, m.Namespace),
+	}
+}
+
+// Parse the CA certificates and the key of the current CA, which is the first certificate in the bundle
+func parseCA(data map[string][]byte) ([]*x509.Certificate, crypto.Signer, error) {
+	var certs []*x509.Certificate
+	rest := data[webhookCACertKey]
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse CA certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, nil, fmt.Errorf("no CA certificate found")
+	}
+
+	block, _ := pem.Decode(data[webhookCAKeyKey])
+	if block == nil {
+		return nil, nil, fmt.Errorf("no CA key found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA key: %v", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("CA key can't be used for signing")
+	}
+	if !caKeyMatches(signer, certs[0]) {
+		return nil, nil, fmt.Errorf("CA key does not match the CA certificate")
+	}
+	return certs, signer, nil
+}
+
+// Check if the public key of the certificate belongs to the key
+func caKeyMatches(key crypto.Signer, cert *x509.Certificate) bool {
+	pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && pub.Equal(cert.PublicKey)
+}
+
+// Parse the serving certificate and ensure it matches the key
+func parseKeyPair(certPEM, keyPEM []byte) (*x509.Certificate, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid serving certificate: %v", err)
+	}
+	return x509.ParseCertificate(pair.Certificate[0])
+}
+
+func encodeCertificates(certs ...*x509.Certificate) []byte {
+	var buf bytes.Buffer
+	for _, cert := range certs {
+		_ = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return buf.Bytes()
+}
+
+func newSerialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+	return serial, nil
+}
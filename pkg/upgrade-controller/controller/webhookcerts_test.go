@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestWebhookCertManager(t *testing.T, objs ...client.Object) (*webhookCertManager, *time.Time) {
+	scheme, err := newScheme()
+	require.NoError(t, err, "Should create scheme")
+
+	objs = append(objs,
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "kube-upgrade-webhook"},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{
+				{
+					Name: "kubeupgrade.heathcliff.eu",
+					ClientConfig: admissionregistrationv1.WebhookClientConfig{
+						Service: &admissionregistrationv1.ServiceReference{Namespace: "kube-upgrade", Name: "kube-upgrade-webhooks"},
+					},
+				},
+			},
+		},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-webhook"},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{
+				{
+					Name: "other.example.com",
+					ClientConfig: admissionregistrationv1.WebhookClientConfig{
+						Service:  &admissionregistrationv1.ServiceReference{Namespace: "other", Name: "kube-upgrade-webhooks"},
+						CABundle: []byte("other"),
+					},
+				},
+			},
+		},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "foreign-webhook"},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{
+				{
+					Name: "foreign.example.com",
+					ClientConfig: admissionregistrationv1.WebhookClientConfig{
+						Service:  &admissionregistrationv1.ServiceReference{Namespace: "kube-upgrade", Name: "kube-upgrade-webhooks"},
+						CABundle: []byte("foreign"),
+					},
+				},
+			},
+		},
+		&admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "kube-upgrade-webhook"},
+			Webhooks: []admissionregistrationv1.MutatingWebhook{
+				{
+					Name: "kubeupgrade.heathcliff.eu",
+					ClientConfig: admissionregistrationv1.WebhookClientConfig{
+						Service: &admissionregistrationv1.ServiceReference{Namespace: "kube-upgrade", Name: "kube-upgrade-webhooks"},
+					},
+				},
+			},
+		},
+	)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+
+	now := time.Now()
+	m := &webhookCertManager{
+		Client:      c,
+		APIReader:   c,
+		Namespace:             "kube-upgrade",
+		SecretName:            "kube-upgrade-webhook-cert",
+		ServiceName:           "kube-upgrade-webhooks",
+		WebhookConfigurations: []string{"kube-upgrade-webhook", "kube-upgrade-node-webhook", "other-webhook"},
+		CertDir:               t.TempDir(),
+		now: func() time.Time {
+			return now
+		},
+	}
+	return m, &now
+}
+
+func getTestWebhookCertSecret(t *testing.T, m *webhookCertManager) *corev1.Secret {
+	secret := &corev1.Secret{}
+	err := m.Get(t.Context(), client.ObjectKey{Namespace: m.Namespace, Name: m.SecretName}, secret)
+	require.NoError(t, err, "Should get secret")
+	return secret
+}
+
+func parseTestCertificates(t *testing.T, data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		require.NoError(t, err, "Should parse certificate")
+		certs = append(certs, cert)
+	}
+}
+
+func TestNewWebhookCertManager(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		t.Setenv(webhookCertSecretEnv, "")
+
+		assert.Nil(t, newWebhookCertManager(nil, nil, "kube-upgrade", webhookCertDir), "Should not manage certificates")
+	})
+	t.Run("Enabled", func(t *testing.T) {
+		assert := assert.New(t)
+
+		t.Setenv(webhookCertSecretEnv, "webhook-cert")
+		t.Setenv(webhookServiceNameEnv, "webhook-service")
+		t.Setenv(webhookConfigurationsEnv, "webhook,node-webhook")
+
+		m := newWebhookCertManager(nil, nil, "kube-upgrade", webhookCertDir)
+
+		if !assert.NotNil(m, "Should manage certificates") {
+			t.FailNow()
+		}
+		assert.Equal("webhook-cert", m.SecretName, "Should use the secret")
+		assert.Equal("webhook-service", m.ServiceName, "Should use the service")
+		assert.Equal([]string{"webhook", "node-webhook"}, m.WebhookConfigurations, "Should use the webhook configurations")
+		assert.Equal("kube-upgrade", m.Namespace, "Should use the namespace")
+		assert.Equal(webhookCertDir, m.CertDir, "Should use the given directory")
+	})
+	t.Run("DefaultService", func(t *testing.T) {
+		t.Setenv(webhookCertSecretEnv, "webhook-cert")
+		t.Setenv(webhookServiceNameEnv, "")
+		t.Setenv(webhookConfigurationsEnv, "")
+
+		m := newWebhookCertManager(nil, nil, "kube-upgrade", webhookCertDir)
+
+		assert.Equal(t, "kube-upgrade-webhooks", m.ServiceName, "Should use the default service")
+		assert.Equal(t, []string{"kube-upgrade-webhook", "kube-upgrade-node-webhook"}, m.WebhookConfigurations, "Should use the default webhook configurations")
+	})
+}
+
+func TestWebhookCertManagerSync(t *testing.T) {
+	t.Run("CreateCertificates", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		m, _ := newTestWebhookCertManager(t)
+
+		require.NoError(m.Sync(t.Context()), "Should sync certificates")
+
+		secret := getTestWebhookCertSecret(t, m)
+		assert.Equal(corev1.SecretTypeTLS, secret.Type, "Should create a tls secret")
+
+		caCerts := parseTestCertificates(t, secret.Data[webhookCACertKey])
+		require.Len(caCerts, 1, "Should contain a single CA")
+		servingCerts := parseTestCertificates(t, secret.Data[corev1.TLSCertKey])
+		require.Len(servingCerts, 1, "Should contain the serving certificate")
+
+		roots := x509.NewCertPool()
+		roots.AddCert(caCerts[0])
+		_, err := servingCerts[0].Verify(x509.VerifyOptions{
+			DNSName: "kube-upgrade-webhooks.kube-upgrade.svc",
+			Roots:   roots,
+		})
+		assert.NoError(err, "Serving certificate should be valid for the service")
+
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(m.Get(t.Context(), client.ObjectKey{Name: "kube-upgrade-webhook"}, validating))
+		assert.Equal(secret.Data[webhookCACertKey], validating.Webhooks[0].ClientConfig.CABundle, "Should inject the CA into the validating webhook")
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		require.NoError(m.Get(t.Context(), client.ObjectKey{Name: "kube-upgrade-webhook"}, mutating))
+		assert.Equal(secret.Data[webhookCACertKey], mutating.Webhooks[0].ClientConfig.CABundle, "Should inject the CA into the mutating webhook")
+		other := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(m.Get(t.Context(), client.ObjectKey{Name: "other-webhook"}, other))
+		assert.Equal([]byte("other"), other.Webhooks[0].ClientConfig.CABundle, "Should not touch other webhooks")
+		foreign := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(m.Get(t.Context(), client.ObjectKey{Name: "foreign-webhook"}, foreign))
+		assert.Equal([]byte("foreign"), foreign.Webhooks[0].ClientConfig.CABundle, "Should not touch webhook configurations that are not configured")
+
+		for _, key := range []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey} {
+			data, err := os.ReadFile(filepath.Join(m.CertDir, key))
+			assert.NoError(err, "Should have written %s", key)
+			assert.Equal(secret.Data[key], data, "Should have written %s from the secret", key)
+		}
+		assert.NoFileExists(filepath.Join(m.CertDir, webhookCAKeyKey), "Should not write the CA key")
+	})
+	t.Run("KeepValidCertificates", func(t *testing.T) {
+		require := require.New(t)
+
+		m, now := newTestWebhookCertManager(t)
+		require.NoError(m.Sync(t.Context()), "Should sync certificates")
+		before := getTestWebhookCertSecret(t, m)
+
+		*now = now.Add(30 * 24 * time.Hour)
+		require.NoError(m.Sync(t.Context()), "Should sync certificates again")
+
+		assert.Equal(t, before.Data, getTestWebhookCertSecret(t, m).Data, "Should not change valid certificates")
+	})
+	t.Run("RenewServingCertificate", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		m, now := newTestWebhookCertManager(t)
+		require.NoError(m.Sync(t.Context()), "Should sync certificates")
+		before := getTestWebhookCertSecret(t, m)
+
+		*now = now.Add(300 * 24 * time.Hour)
+		require.NoError(m.Sync(t.Context()), "Should sync certificates again")
+
+		after := getTestWebhookCertSecret(t, m)
+		assert.Equal(before.Data[webhookCACertKey], after.Data[webhookCACertKey], "Should keep the CA")
+		assert.NotEqual(before.Data[corev1.TLSCertKey], after.Data[corev1.TLSCertKey], "Should renew the serving certificate")
+
+		data, err := os.ReadFile(filepath.Join(m.CertDir, corev1.TLSCertKey))
+		require.NoError(err, "Should read serving certificate")
+		assert.Equal(after.Data[corev1.TLSCertKey], data, "Should have written the renewed certificate")
+	})
+	t.Run("RotateCA", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		m, now := newTestWebhookCertManager(t)
+		require.NoError(m.Sync(t.Context()), "Should sync certificates")
+		before := getTestWebhookCertSecret(t, m)
+		oldCA := parseTestCertificates(t, before.Data[webhookCACertKey])[0]
+
+		*now = now.Add(4 * 365 * 24 * time.Hour)
+		require.NoError(m.Sync(t.Context()), "Should sync certificates again")
+
+		after := getTestWebhookCertSecret(t, m)
+		caCerts := parseTestCertificates(t, after.Data[webhookCACertKey])
+		require.Len(caCerts, 2, "Should keep the previous CA in the bundle")
+		assert.NotEqual(oldCA.Raw, caCerts[0].Raw, "Should have created a new CA")
+		assert.Equal(oldCA.Raw, caCerts[1].Raw, "Should keep the previous CA")
+
+		servingCert := parseTestCertificates(t, after.Data[corev1.TLSCertKey])[0]
+		assert.NoError(servingCert.CheckSignatureFrom(caCerts[0]), "Serving certificate should be signed by the new CA")
+
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		require.NoError(m.Get(t.Context(), client.ObjectKey{Name: "kube-upgrade-webhook"}, validating))
+		assert.Equal(after.Data[webhookCACertKey], validating.Webhooks[0].ClientConfig.CABundle, "Should inject the new bundle")
+
+		*now = now.Add(2 * 365 * 24 * time.Hour)
+		require.NoError(m.Sync(t.Context()), "Should sync certificates again")
+		assert.Len(parseTestCertificates(t, getTestWebhookCertSecret(t, m).Data[webhookCACertKey]), 1, "Should drop the expired CA on the next rotation")
+	})
+	t.Run("InvalidSecret", func(t *testing.T) {
+		require := require.New(t)
+
+		m, _ := newTestWebhookCertManager(t, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kube-upgrade", Name: "kube-upgrade-webhook-cert"},
+			Type:       corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey: []byte("not-a-cert"),
+			},
+		})
+
+		require.NoError(m.Sync(t.Context()), "Should sync certificates")
+
+		secret := getTestWebhookCertSecret(t, m)
+		require.Len(parseTestCertificates(t, secret.Data[webhookCACertKey]), 1, "Should have created a CA")
+		require.Len(parseTestCertificates(t, secret.Data[corev1.TLSCertKey]), 1, "Should have created a serving certificate")
+	})
+	t.Run("ServiceChanged", func(t *testing.T) {
+		require := require.New(t)
+
+		m, _ := newTestWebhookCertManager(t)
+		require.NoError(m.Sync(t.Context()), "Should sync certificates")
+
+		m.ServiceName = "renamed-webhooks"
+		require.NoError(m.Sync(t.Context()), "Should sync certificates again")
+
+		servingCert := parseTestCertificates(t, getTestWebhookCertSecret(t, m).Data[corev1.TLSCertKey])[0]
+		assert.Contains(t, servingCert.DNSNames, "renamed-webhooks.kube-upgrade.svc", "Should reissue the certificate for the new service")
+	})
+}
@@ -0,0 +1,136 @@
+package upgradecontroller
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/heathcliff26/kube-upgrade/pkg/upgrade-controller/controller"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+const defaultRenderNamespace = "kube-upgrade"
+
+// Create the command for validating a plan without a cluster
+func newValidateCommand() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate a KubeUpgradePlan offline",
+		Long:  "Apply the defaults to a KubeUpgradePlan and validate it the same way as the webhook. Checks that need the cluster, like the version skew, are skipped.",
+		Args:  cobra.NoArgs,
+		// Errors are logged by Execute, usage is only printed for invalid flags
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+
+			plan, err := readValidPlan(cmd, file)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "Plan %s is valid\n", plan.Name)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "", "Path to the plan, \"-\" reads from stdin")
+	_ = cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+// Create the command for rendering the resources the controller creates for a plan
+func newRenderCommand() *cobra.Command {
+	var file, namespace, upgradedImage string
+
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "Print the ConfigMaps and DaemonSets the controller creates for a KubeUpgradePlan",
+		Long:  "Validate a KubeUpgradePlan and print the ConfigMaps and DaemonSets the controller creates for each group. The ConfigMaps contain the upgraded config merged from the plan and the group.",
+		Args:  cobra.NoArgs,
+		// Errors are logged by Execute, usage is only printed for invalid flags
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+
+			plan, err := readValidPlan(cmd, file)
+			if err != nil {
+				return err
+			}
+			if upgradedImage == "" {
+				upgradedImage = controller.GetUpgradedImage()
+			}
+
+			objs, err := controller.RenderPlan(plan, namespace, upgradedImage)
+			if err != nil {
+				return err
+			}
+			for _, obj := range objs {
+				data, err := renderObject(obj)
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprint(cmd.OutOrStdout(), "---\n"+string(data))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "", "Path to the plan, \"-\" reads from stdin")
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", defaultRenderNamespace, "Namespace of the controller")
+	cmd.Flags().StringVar(&upgradedImage, "upgraded-image", "", "Image of upgraded, defaults to the image used by the controller")
+	_ = cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+// Read, default and validate the plan. Warnings are printed to stderr.
+func readValidPlan(cmd *cobra.Command, file string) (*api.KubeUpgradePlan, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(cmd.InOrStdin())
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %v", err)
+	}
+
+	plan, err := controller.DecodePlan(data)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings, err := controller.ValidatePlan(plan)
+	for _, warning := range warnings {
+		cmd.PrintErrf("Warning: %s\n", warning)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("plan %s is invalid: %v", plan.Name, err)
+	}
+	return plan, nil
+}
+
+// Convert the object to yaml, without the empty status and creationTimestamp
+func renderObject(obj runtime.Object) ([]byte, error) {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert object: %v", err)
+	}
+	delete(u, "status")
+	unstructured.RemoveNestedField(u, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(u, "spec", "template", "metadata", "creationTimestamp")
+
+	data, err := yaml.Marshal(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert object to yaml: %v", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,81 @@
+package upgradecontroller
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func executeTestCommand(t *testing.T, stdin string, args ...string) (string, string, error) {
+	cmd := NewUpgradeController()
+	var stdout, stderr bytes.Buffer
+	cmd.SetIn(strings.NewReader(stdin))
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	cmd.SetArgs(args)
+
+	err := cmd.Execute()
+	return stdout.String(), stderr.String(), err
+}
+
+func TestValidateCommand(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		stdout, _, err := executeTestCommand(t, "", "validate", "-f", "testdata/plan.yaml")
+
+		assert.NoError(t, err, "Should succeed")
+		assert.Equal(t, "Plan upgrade-plan is valid\n", stdout, "Should report the plan as valid")
+	})
+	t.Run("Stdin", func(t *testing.T) {
+		stdout, stderr, err := executeTestCommand(t, "apiVersion: kubeupgrade.heathcliff.eu/v1alpha3\nkind: KubeUpgradePlan\nmetadata:\n  name: stdin\nspec:\n  kubernetesVersion: v1.36.1\n  allowDowngrade: true\n  upgraded:\n    fleetlockUrl: http://fleetlock.example.com\n  groups:\n    all:\n      labels:\n        foo: bar\n", "validate", "-f", "-")
+
+		assert.NoError(t, err, "Should succeed")
+		assert.Equal(t, "Plan stdin is valid\n", stdout, "Should read the plan from stdin")
+		assert.Contains(t, stderr, "Warning: AllowDowngrade", "Should print the warnings")
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, _, err := executeTestCommand(t, "", "validate", "-f", "testdata/invalid-plan.yaml")
+
+		assert.ErrorContains(t, err, "plan upgrade-plan is invalid", "Should fail")
+	})
+	t.Run("MissingFile", func(t *testing.T) {
+		_, _, err := executeTestCommand(t, "", "validate", "-f", "testdata/not-a-file.yaml")
+
+		assert.Error(t, err, "Should fail")
+	})
+	t.Run("MissingFlag", func(t *testing.T) {
+		_, _, err := executeTestCommand(t, "", "validate")
+
+		assert.Error(t, err, "Should require the file")
+	})
+}
+
+func TestRenderCommand(t *testing.T) {
+	t.Run("Render", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		stdout, _, err := executeTestCommand(t, "", "render", "-f", "testdata/plan.yaml", "-n", "test-ns", "--upgraded-image", "upgraded:test")
+		require.NoError(err, "Should succeed")
+
+		docs := strings.Split(strings.TrimPrefix(stdout, "---\n"), "---\n")
+		require.Len(docs, 4, "Should render a ConfigMap and DaemonSet per group")
+		for _, doc := range docs {
+			var obj map[string]interface{}
+			require.NoError(yaml.Unmarshal([]byte(doc), &obj), "Should be valid yaml")
+			assert.NotContains(obj, "status", "Should not contain the status")
+			assert.Equal("test-ns", obj["metadata"].(map[string]interface{})["namespace"], "Should use the namespace")
+		}
+		assert.NotContains(stdout, "creationTimestamp", "Should not contain empty timestamps")
+		assert.Contains(docs[1], "image: upgraded:test", "Should use the image")
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		stdout, _, err := executeTestCommand(t, "", "render", "-f", "testdata/invalid-plan.yaml")
+
+		assert.Error(t, err, "Should fail")
+		assert.Empty(t, stdout, "Should not render anything")
+	})
+}
@@ -4,15 +4,21 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/heathcliff26/kube-upgrade/pkg/upgrade-controller/controller"
 	"github.com/heathcliff26/kube-upgrade/pkg/version"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 const Name = "upgrade-controller"
 
+// Prefix of the environment variables for the flags, so they don't collide with
+// common variables like KUBECONFIG or NAMESPACE set for other tools.
+const flagEnvPrefix = "KUBE_UPGRADE_"
+
 func Execute() {
 	cmd := NewUpgradeController()
 	err := cmd.Execute()
@@ -28,24 +34,69 @@ func NewUpgradeController() *cobra.Command {
 		},
 	)
 
+	opts := controller.DefaultOptions()
+
 	rootCmd := &cobra.Command{
 		Use:   Name,
 		Short: Name + " runs the controller to orchestrate cluster wide kubernetes upgrades.",
+		PreRunE: func(cmd *cobra.Command, _ []string) error {
+			return flagsFromEnv(cmd.Flags())
+		},
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			run()
+			run(opts)
 			return nil
 		},
 	}
 
+	flags := rootCmd.Flags()
+	flags.StringVar(&opts.Kubeconfig, "kubeconfig", opts.Kubeconfig, "Path to a kubeconfig, uses the in-cluster config when empty")
+	flags.StringVarP(&opts.Namespace, "namespace", "n", opts.Namespace, "Namespace of the controller, read from the ServiceAccount when empty")
+	flags.StringVar(&opts.MetricsBindAddress, "metrics-bind-address", opts.MetricsBindAddress, "Address the metrics endpoint binds to, \"0\" disables it")
+	flags.StringVar(&opts.HealthProbeBindAddress, "health-probe-bind-address", opts.HealthProbeBindAddress, "Address the health probes bind to, \"0\" disables them")
+	flags.StringVar(&opts.WebhookBindAddress, "webhook-bind-address", opts.WebhookBindAddress, "Address the webhook server binds to")
+	flags.StringVar(&opts.WebhookCertDir, "webhook-cert-dir", opts.WebhookCertDir, "Directory containing tls.crt and tls.key for the webhook server")
+	flags.BoolVar(&opts.DisableWebhooks, "disable-webhooks", opts.DisableWebhooks, "Do not serve the webhooks")
+	flags.BoolVar(&opts.LeaderElection, "leader-elect", opts.LeaderElection, "Use leader election, only one active controller is allowed when disabled")
+	flags.DurationVar(&opts.LeaseDuration, "leader-elect-lease-duration", opts.LeaseDuration, "Duration non-leaders wait before trying to acquire leadership")
+	flags.DurationVar(&opts.RenewDeadline, "leader-elect-renew-deadline", opts.RenewDeadline, "Duration the leader retries to renew leadership before giving it up")
+	flags.DurationVar(&opts.RetryPeriod, "leader-elect-retry-period", opts.RetryPeriod, "Duration between leader election attempts")
+
 	rootCmd.AddCommand(
+		newValidateCommand(),
+		newRenderCommand(),
 		version.NewCommand(Name),
 	)
 
 	return rootCmd
 }
 
-func run() {
-	ctrl, err := controller.NewController(Name)
+// Set all flags that have not been given on the command line from their environment variable.
+// The variable is the prefixed upper case flag name with "_" instead of "-", e.g. --leader-elect becomes KUBE_UPGRADE_LEADER_ELECT.
+func flagsFromEnv(flags *pflag.FlagSet) error {
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "help" {
+			return
+		}
+		env := flagEnvName(f.Name)
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			return
+		}
+		if setErr := flags.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value \"%s\" for %s: %v", value, env, setErr)
+		}
+	})
+	return err
+}
+
+// Return the name of the environment variable for the flag
+func flagEnvName(name string) string {
+	return flagEnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+func run(opts controller.Options) {
+	ctrl, err := controller.NewController(Name, opts)
 	if err != nil {
 		fatalf("Failed to create controller: %v", err)
 	}
@@ -2,8 +2,11 @@ package upgradecontroller
 
 import (
 	"testing"
+	"time"
 
+	"github.com/heathcliff26/kube-upgrade/pkg/upgrade-controller/controller"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewRootCommand(t *testing.T) {
@@ -11,3 +14,86 @@ func TestNewRootCommand(t *testing.T) {
 
 	assert.Equal(t, Name, cmd.Use)
 }
+
+func TestRootCommandFlags(t *testing.T) {
+	defaults := controller.DefaultOptions()
+
+	tMatrix := []struct {
+		Flag    string
+		Default string
+	}{
+		{"kubeconfig", ""},
+		{"namespace", ""},
+		{"metrics-bind-address", defaults.MetricsBindAddress},
+		{"health-probe-bind-address", defaults.HealthProbeBindAddress},
+		{"webhook-bind-address", defaults.WebhookBindAddress},
+		{"webhook-cert-dir", defaults.WebhookCertDir},
+		{"disable-webhooks", "false"},
+		{"leader-elect", "true"},
+		{"leader-elect-lease-duration", defaults.LeaseDuration.String()},
+		{"leader-elect-renew-deadline", defaults.RenewDeadline.String()},
+		{"leader-elect-retry-period", defaults.RetryPeriod.String()},
+	}
+
+	cmd := NewUpgradeController()
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Flag, func(t *testing.T) {
+			f := cmd.Flags().Lookup(tCase.Flag)
+			if !assert.NotNil(t, f, "Flag should exist") {
+				return
+			}
+			assert.Equal(t, tCase.Default, f.DefValue, "Should have the default value")
+		})
+	}
+}
+
+func TestFlagsFromEnv(t *testing.T) {
+	t.Run("SetFromEnv", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		t.Setenv("KUBE_UPGRADE_KUBECONFIG", "/home/dev/.kube/config")
+		t.Setenv("KUBE_UPGRADE_LEADER_ELECT", "false")
+		t.Setenv("KUBE_UPGRADE_LEADER_ELECT_LEASE_DURATION", "30s")
+		t.Setenv("KUBE_UPGRADE_NAMESPACE", "from-env")
+
+		cmd := NewUpgradeController()
+		require.NoError(cmd.Flags().Parse([]string{"--namespace", "from-flag"}))
+		require.NoError(flagsFromEnv(cmd.Flags()))
+
+		kubeconfig, _ := cmd.Flags().GetString("kubeconfig")
+		assert.Equal("/home/dev/.kube/config", kubeconfig, "Should read the kubeconfig from the env")
+		leaderElect, _ := cmd.Flags().GetBool("leader-elect")
+		assert.False(leaderElect, "Should read leader election from the env")
+		leaseDuration, _ := cmd.Flags().GetDuration("leader-elect-lease-duration")
+		assert.Equal(30*time.Second, leaseDuration, "Should read the duration from the env")
+		namespace, _ := cmd.Flags().GetString("namespace")
+		assert.Equal("from-flag", namespace, "Flags should take precedence over the env")
+	})
+	t.Run("IgnoreUnprefixed", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		t.Setenv("KUBECONFIG", "/home/dev/.kube/config")
+		t.Setenv("NAMESPACE", "from-env")
+
+		cmd := NewUpgradeController()
+		require.NoError(flagsFromEnv(cmd.Flags()))
+
+		kubeconfig, _ := cmd.Flags().GetString("kubeconfig")
+		assert.Empty(kubeconfig, "Should not use the kubeconfig of other tools")
+		namespace, _ := cmd.Flags().GetString("namespace")
+		assert.Empty(namespace, "Should not use unrelated namespace variables")
+	})
+	t.Run("InvalidValue", func(t *testing.T) {
+		t.Setenv("KUBE_UPGRADE_LEADER_ELECT_RETRY_PERIOD", "not-a-duration")
+
+		cmd := NewUpgradeController()
+
+		assert.Error(t, flagsFromEnv(cmd.Flags()), "Should fail to parse the value")
+	})
+}
+
+func TestFlagEnvName(t *testing.T) {
+	assert.Equal(t, "KUBE_UPGRADE_HEALTH_PROBE_BIND_ADDRESS", flagEnvName("health-probe-bind-address"))
+}
@@ -14,6 +14,13 @@ const (
 	DefaultConfigDir  = "/etc/kube-upgraded/"
 	DefaultConfigFile = "config.yaml"
 	DefaultConfigPath = DefaultConfigDir + DefaultConfigFile
+
+	// Mount path for the secret referenced by kubeadmDownload.secret
+	KubeadmDownloadSecretDir = "/etc/kube-upgraded-secrets/kubeadm-download/"
+	// Mount path for the secret referenced by pullSecret
+	PullSecretDir = "/etc/kube-upgraded-secrets/pull-secret/"
+	// Mount path for the ConfigMap referenced by nodeHooks.configMap
+	NodeHooksConfigMapDir = "/etc/kube-upgraded-hooks/"
 )
 
 var logLevel = &slog.LevelVar{}
@@ -100,7 +107,7 @@ func ValidateConfig(cfg *api.UpgradedConfig) error {
 	if cfg.FleetlockGroup == "" {
 		return fmt.Errorf("invalid config, missing fleetlockGroup")
 	}
-	if cfg.KubeletConfig == "" {
+	if cfg.KubeletConfig == "" && cfg.ServiceAccountName == "" {
 		return fmt.Errorf("invalid config, missing kubeletConfig")
 	}
 	return nil
@@ -24,6 +24,21 @@ func TestValidConfig(t *testing.T) {
 
 }
 
+func TestValidServiceAccountConfig(t *testing.T) {
+	c := DefaultConfig()
+	c.LogLevel = "debug"
+	c.FleetlockURL = "https://fleetlock.example.com"
+	c.KubeletConfig = ""
+	c.ServiceAccountName = "kube-upgraded"
+
+	res, err := LoadConfig("testdata/valid-serviceaccount.yaml")
+
+	assert := assert.New(t)
+
+	assert.NoError(err, "Should not require kubeletConfig when using a ServiceAccount")
+	assert.Equal(c, res)
+}
+
 func TestSetLogLevel(t *testing.T) {
 	tMatrix := []struct {
 		Name  string
@@ -0,0 +1,195 @@
+package upgraded
+
+import (
+	"context"
+	"encoding/json/v2"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"text/tabwriter"
+	"time"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/daemon"
+	"github.com/spf13/cobra"
+)
+
+const controlTimeout = 5 * time.Minute
+
+// Client for the control socket of a running daemon
+type controlClient struct {
+	http *http.Client
+}
+
+// Create a new client that sends all requests to the given unix socket
+func newControlClient(socket string) *controlClient {
+	return &controlClient{
+		http: &http.Client{
+			Timeout: controlTimeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// Send a request to the daemon and decode the response into out, if given
+func (c *controlClient) do(ctx context.Context, method, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, "http://upgraded"+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to upgraded, is the daemon running: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		var controlErr daemon.ControlError
+		err = json.UnmarshalRead(res.Body, &controlErr)
+		if err != nil || controlErr.Error == "" {
+			return fmt.Errorf("upgraded returned status %s", res.Status)
+		}
+		return fmt.Errorf("upgraded returned an error: %s", controlErr.Error)
+	}
+
+	if out == nil {
+		return nil
+	}
+	err = json.UnmarshalRead(res.Body, out)
+	if err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+	return nil
+}
+
+func (c *controlClient) Status(ctx context.Context) (daemon.Status, error) {
+	var status daemon.Status
+	err := c.do(ctx, http.MethodGet, "/status", &status)
+	return status, err
+}
+
+func (c *controlClient) Check(ctx context.Context) (daemon.CheckResult, error) {
+	var result daemon.CheckResult
+	err := c.do(ctx, http.MethodPost, "/check", &result)
+	return result, err
+}
+
+func (c *controlClient) UpgradeNow(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/upgrade-now", nil)
+}
+
+func newStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show what the daemon on this node is doing",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+			status, err := controlClientFromFlags(cmd).Status(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return printStatus(cmd.OutOrStdout(), status)
+		},
+	}
+	addSocketFlag(cmd)
+	return cmd
+}
+
+func newCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check once if the os or kubernetes need an upgrade, without upgrading",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+			result, err := controlClientFromFlags(cmd).Check(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return printCheckResult(cmd.OutOrStdout(), result)
+		},
+	}
+	addSocketFlag(cmd)
+	return cmd
+}
+
+func newUpgradeNowCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "upgrade-now",
+		Short: "Make the daemon check for upgrades immediately and apply them",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+			err := controlClientFromFlags(cmd).UpgradeNow(cmd.Context())
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), "Triggered upgrade check")
+			return err
+		},
+	}
+	addSocketFlag(cmd)
+	return cmd
+}
+
+func addSocketFlag(cmd *cobra.Command) {
+	cmd.Flags().String("socket", daemon.DefaultSocketPath, "Path to the control socket of the daemon")
+}
+
+func controlClientFromFlags(cmd *cobra.Command) *controlClient {
+	socket, _ := cmd.Flags().GetString("socket")
+	return newControlClient(socket)
+}
+
+// Print the status of the daemon in a human readable format
+func printStatus(out io.Writer, status daemon.Status) error {
+	nextCheck := "-"
+	if !status.NextCheck.IsZero() {
+		nextCheck = status.NextCheck.Local().Format(time.RFC3339)
+	}
+	lock := "released"
+	if status.LockHeld {
+		lock = "held"
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "Node:\t%s\n", status.Node)
+	fmt.Fprintf(w, "Phase:\t%s\n", status.Phase)
+	fmt.Fprintf(w, "Node status:\t%s\n", orDash(status.NodeStatus))
+	fmt.Fprintf(w, "Target version:\t%s\n", orDash(status.KubernetesVersion))
+	fmt.Fprintf(w, "Booted image:\t%s\n", orDash(status.BootedImage))
+	fmt.Fprintf(w, "Staged image:\t%s\n", orDash(status.StagedImage))
+	fmt.Fprintf(w, "Lock:\t%s\n", lock)
+	fmt.Fprintf(w, "Next check:\t%s\n", nextCheck)
+	return w.Flush()
+}
+
+// Print the result of an upgrade check in a human readable format
+func printCheckResult(out io.Writer, result daemon.CheckResult) error {
+	os := "up to date"
+	if result.OSUpgradeAvailable {
+		os = "upgrade available"
+	}
+	kubernetes := "up to date"
+	if result.KubernetesUpgradeNeeded {
+		kubernetes = "upgrade to " + orDash(result.KubernetesVersion) + " needed"
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "OS:\t%s\n", os)
+	fmt.Fprintf(w, "Kubernetes:\t%s\n", kubernetes)
+	return w.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
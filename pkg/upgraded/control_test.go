@@ -0,0 +1,150 @@
+package upgraded
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/daemon"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlCommands(t *testing.T) {
+	tMatrix := []struct {
+		Name     string
+		Command  func() *cobra.Command
+		Handler  http.HandlerFunc
+		Expected string
+		Error    string
+	}{
+		{
+			Name:    "Status",
+			Command: newStatusCommand,
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "GET /status", r.Method+" "+r.URL.Path)
+				_, _ = w.Write([]byte(`{"node":"testnode","phase":"idle","nodeStatus":"completed","kubernetesVersion":"v1.35.0","bootedImage":"ghcr.io/heathcliff26/fcos-k8s:v1.35.0","lockHeld":false}`))
+			},
+			Expected: "Node:           testnode\n" +
+				"Phase:          idle\n" +
+				"Node status:    completed\n" +
+				"Target version: v1.35.0\n" +
+				"Booted image:   ghcr.io/heathcliff26/fcos-k8s:v1.35.0\n" +
+				"Staged image:   -\n" +
+				"Lock:           released\n" +
+				"Next check:     -\n",
+		},
+		{
+			Name:    "Check",
+			Command: newCheckCommand,
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "POST /check", r.Method+" "+r.URL.Path)
+				_, _ = w.Write([]byte(`{"osUpgradeAvailable":true,"kubernetesVersion":"v1.35.0","kubernetesUpgradeNeeded":true}`))
+			},
+			Expected: "OS:         upgrade available\n" +
+				"Kubernetes: upgrade to v1.35.0 needed\n",
+		},
+		{
+			Name:    "UpgradeNow",
+			Command: newUpgradeNowCommand,
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "POST /upgrade-now", r.Method+" "+r.URL.Path)
+				w.WriteHeader(http.StatusAccepted)
+			},
+			Expected: "Triggered upgrade check\n",
+		},
+		{
+			Name:    "DaemonError",
+			Command: newStatusCommand,
+			Handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(`{"error":"failed to get node"}`))
+			},
+			Error: "upgraded returned an error: failed to get node",
+		},
+		{
+			Name:    "UnexpectedResponse",
+			Command: newCheckCommand,
+			Handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			Error: "upgraded returned status 404 Not Found",
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			socket := newFakeControlSocket(t, tCase.Handler)
+
+			var out bytes.Buffer
+			cmd := tCase.Command()
+			cmd.SetOut(&out)
+			cmd.SetErr(&out)
+			cmd.SetArgs([]string{"--socket", socket})
+
+			err := cmd.Execute()
+
+			if tCase.Error != "" {
+				assert.ErrorContains(err, tCase.Error, "Should return the error from the daemon")
+				return
+			}
+			assert.NoError(err, "Should succeed")
+			assert.Equal(tCase.Expected, out.String(), "Should print the response")
+		})
+	}
+}
+
+func TestControlCommandDaemonNotRunning(t *testing.T) {
+	cmd := newStatusCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--socket", filepath.Join(t.TempDir(), "upgraded.sock")})
+
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "is the daemon running", "Should explain that the daemon can't be reached")
+}
+
+func TestPrintStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+	err := printStatus(&out, daemon.Status{
+		Node:        "testnode",
+		Phase:       daemon.PhaseUpgrading,
+		BootedImage: "ghcr.io/heathcliff26/fcos-k8s:v1.34.2",
+		StagedImage: "ghcr.io/heathcliff26/fcos-k8s:v1.35.0",
+		LockHeld:    true,
+		NextCheck:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local),
+	})
+
+	assert.NoError(err, "Should succeed")
+	assert.Contains(out.String(), "Staged image:   ghcr.io/heathcliff26/fcos-k8s:v1.35.0\n", "Should print the staged image")
+	assert.Contains(out.String(), "Lock:           held\n", "Should print that the lock is held")
+	assert.Contains(out.String(), "Next check:     "+time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local).Format(time.RFC3339)+"\n", "Should print the next check")
+}
+
+func newFakeControlSocket(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "upgraded.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err, "Should create socket")
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: time.Second,
+	}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		server.Close()
+	})
+	return socket
+}
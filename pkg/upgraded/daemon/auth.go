@@ -0,0 +1,266 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	// The pull secret mounted by the controller
+	pullSecretFile = config.PullSecretDir + corev1.DockerConfigJsonKey
+	// Credentials used by rpm-ostree for container images.
+	// rpm-ostree has no option to pass credentials per invocation, so the pull secret is merged into it.
+	ostreeAuthFile = "/etc/ostree/auth.json"
+	// Takes precedence over /etc/ostree/auth.json, as only the first existing file is used.
+	// Older versions merged the pull secret into it, so their credentials are removed from it again.
+	runtimeOstreeAuthFile = "/run/ostree/auth.json"
+	// Credentials only used by upgraded, passed to podman on every invocation
+	registryAuthFile = "/run/kube-upgraded/auth.json"
+)
+
+// Records which credentials on the host have been written by upgraded, so only those are changed or removed
+const ostreeAuthMarkerSuffix = ".kube-upgraded"
+
+// The changes upgraded made to the credentials used by rpm-ostree
+type ostreeAuthMarker struct {
+	// The registries added from the pull secret
+	Registries []string `json:"registries"`
+	// The file did not exist before upgraded wrote it
+	Created bool `json:"created"`
+}
+
+// Write the credentials from the mounted pull secret to the host, so rpm-ostree and podman can use them.
+// Updates them when the secret has changed and removes them when no pull secret is mounted anymore.
+// Credentials for other registries on the host are kept and take precedence over the pull secret.
+// Returns the path of the credentials for podman on the host, or an empty string when there are none.
+func syncRegistryAuth() (string, error) {
+	var auths map[string]jsontext.Value
+
+	// #nosec G304: The path is fixed
+	secret, err := os.ReadFile(pullSecretFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("failed to read pull secret: %v", err)
+	} else if err == nil {
+		auths, err = parseRegistryAuths(secret)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse pull secret: %v", err)
+		}
+	}
+
+	err = syncOstreeAuth(runtimeOstreeAuthFile, nil)
+	if err != nil {
+		return "", err
+	}
+	if len(auths) > 0 {
+		_, err = os.Stat(hostPrefix + runtimeOstreeAuthFile)
+		if err == nil {
+			slog.Warn("Registry credentials on the host take precedence over the pull secret", slog.String("path", runtimeOstreeAuthFile))
+		}
+	}
+	err = syncOstreeAuth(ostreeAuthFile, auths)
+	if err != nil {
+		return "", err
+	}
+
+	authFile := hostPrefix + registryAuthFile
+	if secret == nil {
+		err = os.Remove(authFile)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to remove registry credentials: %v", err)
+		}
+		return "", nil
+	}
+
+	// #nosec G304: The path is fixed
+	current, err := os.ReadFile(authFile)
+	if err != nil || !bytes.Equal(current, secret) {
+		err = writeFileAtomic(authFile, secret, 0700)
+		if err != nil {
+			return "", fmt.Errorf("failed to write registry credentials: %v", err)
+		}
+	}
+	return registryAuthFile, nil
+}
+
+// Merge the credentials of the pull secret into the credentials used by rpm-ostree at the given path.
+// Removes the credentials previously added by upgraded, that are no longer part of the pull secret.
+func syncOstreeAuth(path string, auths map[string]jsontext.Value) error {
+	authFile := hostPrefix + path
+	markerFile := authFile + ostreeAuthMarkerSuffix
+
+	// Other fields like credHelpers are kept untouched
+	cfg := make(map[string]jsontext.Value)
+	hostAuths := make(map[string]jsontext.Value)
+	// #nosec G304: The path is fixed
+	current, err := os.ReadFile(authFile)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read registry credentials: %v", err)
+	} else if exists {
+		err = json.Unmarshal(current, &cfg)
+		if err != nil {
+			return fmt.Errorf("refusing to change registry credentials %s, failed to parse them: %v", path, err)
+		}
+		hostAuths, err = parseRegistryAuths(current)
+		if err != nil {
+			return fmt.Errorf("refusing to change registry credentials %s, failed to parse them: %v", path, err)
+		}
+	}
+
+	marker, err := readOstreeAuthMarker(markerFile, hostAuths)
+	if err != nil {
+		return err
+	}
+	if marker == nil && len(auths) == 0 {
+		return nil
+	}
+	if marker == nil {
+		marker = &ostreeAuthMarker{Created: !exists}
+	}
+
+	for _, registry := range marker.Registries {
+		delete(hostAuths, registry)
+	}
+	newMarker := ostreeAuthMarker{Registries: make([]string, 0, len(auths)), Created: marker.Created}
+	for _, registry := range slices.Sorted(maps.Keys(auths)) {
+		if _, ok := hostAuths[registry]; ok {
+			slog.Warn("Keeping registry credentials on the host, that have not been written by upgraded", slog.String("registry", registry), slog.String("path", path))
+			continue
+		}
+		hostAuths[registry] = auths[registry]
+		newMarker.Registries = append(newMarker.Registries, registry)
+	}
+
+	if len(newMarker.Registries) == 0 {
+		delete(cfg, "auths")
+		if len(hostAuths) > 0 || !newMarker.Created {
+			cfg["auths"], err = json.Marshal(hostAuths, json.Deterministic(true))
+			if err != nil {
+				return err
+			}
+		}
+		if len(cfg) == 0 {
+			slog.Info("Removing registry credentials from host", slog.String("path", path))
+			err = os.Remove(authFile)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("failed to remove registry credentials: %v", err)
+			}
+		} else {
+			err = writeOstreeAuth(path, current, cfg)
+			if err != nil {
+				return err
+			}
+		}
+		err = os.Remove(markerFile)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove registry credentials marker: %v", err)
+		}
+		return nil
+	}
+
+	cfg["auths"], err = json.Marshal(hostAuths, json.Deterministic(true))
+	if err != nil {
+		return err
+	}
+	// The marker is written first, so the added credentials are always known
+	markerData, err := json.Marshal(newMarker)
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(markerFile, markerData, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to write registry credentials marker: %v", err)
+	}
+	return writeOstreeAuth(path, current, cfg)
+}
+
+// Write the credentials for rpm-ostree, unless they did not change
+func writeOstreeAuth(path string, current []byte, cfg map[string]jsontext.Value) error {
+	data, err := json.Marshal(cfg, json.Deterministic(true))
+	if err != nil {
+		return err
+	}
+	if current != nil {
+		compact := jsontext.Value(bytes.Clone(current))
+		if compact.Compact() == nil && bytes.Equal(compact, data) {
+			return nil
+		}
+	}
+
+	slog.Info("Updating registry credentials on host from pull secret", slog.String("path", path))
+	// #nosec G301: The directory is used by ostree and needs to be readable
+	err = writeFileAtomic(hostPrefix+path, data, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to write registry credentials: %v", err)
+	}
+	return nil
+}
+
+// Read the changes upgraded made to the credentials on the host, returns nil when there are none.
+// An empty marker has been written by older versions, that owned the whole file.
+func readOstreeAuthMarker(markerFile string, hostAuths map[string]jsontext.Value) (*ostreeAuthMarker, error) {
+	// #nosec G304: The path is fixed
+	data, err := os.ReadFile(markerFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read registry credentials marker: %v", err)
+	}
+
+	if len(data) == 0 {
+		return &ostreeAuthMarker{Registries: slices.Collect(maps.Keys(hostAuths)), Created: true}, nil
+	}
+	marker := &ostreeAuthMarker{}
+	err = json.Unmarshal(data, marker)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse registry credentials marker: %v", err)
+	}
+	return marker, nil
+}
+
+// Return the credentials per registry of the given auth file
+func parseRegistryAuths(data []byte) (map[string]jsontext.Value, error) {
+	var cfg struct {
+		Auths map[string]jsontext.Value `json:"auths"`
+	}
+	err := json.Unmarshal(data, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Auths == nil {
+		cfg.Auths = make(map[string]jsontext.Value)
+	}
+	return cfg.Auths, nil
+}
+
+// Replace the file atomically, creating the parent directory with the given mode if needed.
+// The file is only readable by root, as it contains credentials.
+func writeFileAtomic(path string, data []byte, dirMode os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(path), dirMode)
+	if err != nil {
+		return err
+	}
+	tmpFile := path + ".tmp"
+	err = os.WriteFile(tmpFile, data, 0600)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpFile, path)
+	if err != nil {
+		_ = os.Remove(tmpFile)
+		return err
+	}
+	return nil
+}
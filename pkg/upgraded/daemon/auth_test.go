@@ -0,0 +1,202 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncRegistryAuth(t *testing.T) {
+	setup := func(t *testing.T) (string, string) {
+		oldHostPrefix := hostPrefix
+		oldPullSecretFile := pullSecretFile
+		hostPrefix = t.TempDir()
+		pullSecretFile = filepath.Join(t.TempDir(), ".dockerconfigjson")
+		t.Cleanup(func() {
+			hostPrefix = oldHostPrefix
+			pullSecretFile = oldPullSecretFile
+		})
+		return hostPrefix + ostreeAuthFile, pullSecretFile
+	}
+
+	t.Run("NoPullSecret", func(t *testing.T) {
+		assert := assert.New(t)
+
+		authFile, _ := setup(t)
+
+		path, err := syncRegistryAuth()
+		assert.NoError(err, "Should succeed")
+		assert.Empty(path, "Should not return credentials")
+		assert.NoFileExists(authFile, "Should not create credentials")
+	})
+	t.Run("WriteAndRotate", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		authFile, secretFile := setup(t)
+
+		require.NoError(os.WriteFile(secretFile, []byte(`{"auths":{"registry.example.com":{"auth":"Zm9vOmJhcg=="}}}`), 0600))
+		path, err := syncRegistryAuth()
+		require.NoError(err, "Should write credentials")
+		assert.Equal(registryAuthFile, path, "Should return the path of the credentials for podman on the host")
+
+		data, err := os.ReadFile(hostPrefix + registryAuthFile)
+		require.NoError(err, "Should have created credentials for podman")
+		assert.JSONEq(`{"auths":{"registry.example.com":{"auth":"Zm9vOmJhcg=="}}}`, string(data), "Should contain the pull secret")
+
+		data, err = os.ReadFile(authFile)
+		require.NoError(err, "Should have created credentials")
+		assert.JSONEq(`{"auths":{"registry.example.com":{"auth":"Zm9vOmJhcg=="}}}`, string(data), "Should contain the pull secret")
+		info, err := os.Stat(authFile)
+		require.NoError(err)
+		assert.Equal(os.FileMode(0600), info.Mode().Perm(), "Should only be readable by root")
+
+		require.NoError(os.WriteFile(secretFile, []byte(`{"auths":{"registry.example.com":{"auth":"Zm9vOmJheg=="}}}`), 0600))
+		_, err = syncRegistryAuth()
+		require.NoError(err, "Should update credentials")
+
+		data, err = os.ReadFile(authFile)
+		require.NoError(err, "Should have kept credentials")
+		assert.JSONEq(`{"auths":{"registry.example.com":{"auth":"Zm9vOmJheg=="}}}`, string(data), "Should contain the rotated pull secret")
+	})
+	t.Run("RemoveOwnedCredentials", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		authFile, secretFile := setup(t)
+
+		require.NoError(os.WriteFile(secretFile, []byte(`{"auths":{"registry.example.com":{"auth":"Zm9vOmJhcg=="}}}`), 0600))
+		_, err := syncRegistryAuth()
+		require.NoError(err, "Should write credentials")
+
+		require.NoError(os.Remove(secretFile))
+		path, err := syncRegistryAuth()
+		assert.NoError(err, "Should remove credentials")
+		assert.Empty(path, "Should not return credentials")
+		assert.NoFileExists(authFile, "Should have removed credentials")
+		assert.NoFileExists(authFile+ostreeAuthMarkerSuffix, "Should have removed marker")
+		assert.NoFileExists(hostPrefix+registryAuthFile, "Should have removed credentials for podman")
+	})
+	t.Run("MergeWithForeignCredentials", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		authFile, secretFile := setup(t)
+
+		foreign := `{"auths":{"quay.io":{"auth":"aG9zdDpxdWF5"},"registry.example.com":{"auth":"aG9zdDpyZWc="}},"credHelpers":{"example.org":"helper"}}`
+		require.NoError(os.MkdirAll(filepath.Dir(authFile), 0755))
+		require.NoError(os.WriteFile(authFile, []byte(foreign), 0600))
+
+		require.NoError(os.WriteFile(secretFile, []byte(`{"auths":{"registry.example.com":{"auth":"Zm9vOmJhcg=="},"ghcr.io":{"auth":"Zm9vOmJheg=="}}}`), 0600))
+		_, err := syncRegistryAuth()
+		require.NoError(err, "Should merge credentials")
+
+		data, err := os.ReadFile(authFile)
+		require.NoError(err, "Should have kept credentials")
+		assert.JSONEq(`{"auths":{"quay.io":{"auth":"aG9zdDpxdWF5"},"registry.example.com":{"auth":"aG9zdDpyZWc="},"ghcr.io":{"auth":"Zm9vOmJheg=="}},"credHelpers":{"example.org":"helper"}}`, string(data), "Should add the pull secret without replacing existing credentials")
+
+		require.NoError(os.Remove(secretFile))
+		_, err = syncRegistryAuth()
+		require.NoError(err, "Should remove added credentials")
+
+		data, err = os.ReadFile(authFile)
+		require.NoError(err, "Should have kept credentials")
+		assert.JSONEq(foreign, string(data), "Should restore the original credentials")
+		assert.NoFileExists(authFile+ostreeAuthMarkerSuffix, "Should have removed marker")
+	})
+	t.Run("KeepIgnitionCredentials", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		authFile, secretFile := setup(t)
+
+		ignition := `{"auths":{"quay.io":{"auth":"aG9zdDpxdWF5"}}}`
+		require.NoError(os.MkdirAll(filepath.Dir(authFile), 0755))
+		require.NoError(os.WriteFile(authFile, []byte(ignition), 0600))
+
+		require.NoError(os.WriteFile(secretFile, []byte(`{"auths":{"registry.example.com":{"auth":"Zm9vOmJhcg=="}}}`), 0600))
+		_, err := syncRegistryAuth()
+		require.NoError(err, "Should merge credentials")
+
+		assert.NoFileExists(hostPrefix+runtimeOstreeAuthFile, "Should not create credentials taking precedence over the existing ones")
+		data, err := os.ReadFile(authFile)
+		require.NoError(err, "Should have kept credentials")
+		assert.JSONEq(`{"auths":{"quay.io":{"auth":"aG9zdDpxdWF5"},"registry.example.com":{"auth":"Zm9vOmJhcg=="}}}`, string(data), "Should keep the credentials for other registries")
+
+		require.NoError(os.Remove(secretFile))
+		_, err = syncRegistryAuth()
+		require.NoError(err, "Should remove added credentials")
+
+		data, err = os.ReadFile(authFile)
+		require.NoError(err, "Should have kept credentials")
+		assert.JSONEq(ignition, string(data), "Should restore the original credentials")
+	})
+	t.Run("RemoveFromRuntimeCredentials", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		authFile, secretFile := setup(t)
+		runtimeAuthFile := hostPrefix + runtimeOstreeAuthFile
+
+		require.NoError(os.MkdirAll(filepath.Dir(runtimeAuthFile), 0755))
+		require.NoError(os.WriteFile(runtimeAuthFile, []byte(`{"auths":{"registry.example.com":{"auth":"Zm9vOmJhcg=="}}}`), 0600))
+		require.NoError(os.WriteFile(runtimeAuthFile+ostreeAuthMarkerSuffix, []byte(`{"registries":["registry.example.com"],"created":true}`), 0600))
+
+		require.NoError(os.WriteFile(secretFile, []byte(`{"auths":{"registry.example.com":{"auth":"Zm9vOmJhcg=="}}}`), 0600))
+		_, err := syncRegistryAuth()
+		require.NoError(err, "Should move credentials")
+
+		assert.NoFileExists(runtimeAuthFile, "Should remove credentials written by older versions")
+		assert.NoFileExists(runtimeAuthFile+ostreeAuthMarkerSuffix, "Should remove marker written by older versions")
+		assert.FileExists(authFile, "Should write credentials")
+	})
+	t.Run("RefuseInvalidCredentials", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		authFile, secretFile := setup(t)
+
+		require.NoError(os.MkdirAll(filepath.Dir(authFile), 0755))
+		require.NoError(os.WriteFile(authFile, []byte("not json"), 0600))
+		require.NoError(os.WriteFile(secretFile, []byte(`{"auths":{"registry.example.com":{"auth":"Zm9vOmJhcg=="}}}`), 0600))
+
+		_, err := syncRegistryAuth()
+		assert.ErrorContains(err, "refusing to change registry credentials", "Should not overwrite credentials it can't parse")
+
+		data, err := os.ReadFile(authFile)
+		require.NoError(err, "Should have kept credentials")
+		assert.Equal("not json", string(data), "Should not change the credentials")
+	})
+	t.Run("LegacyMarker", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		setup(t)
+		authFile := hostPrefix + runtimeOstreeAuthFile
+
+		require.NoError(os.MkdirAll(filepath.Dir(authFile), 0755))
+		require.NoError(os.WriteFile(authFile, []byte(`{"auths":{"registry.example.com":{"auth":"Zm9vOmJhcg=="}}}`), 0600))
+		require.NoError(os.WriteFile(authFile+ostreeAuthMarkerSuffix, nil, 0600))
+
+		_, err := syncRegistryAuth()
+		assert.NoError(err, "Should remove credentials")
+		assert.NoFileExists(authFile, "Should remove credentials written by older versions")
+		assert.NoFileExists(authFile+ostreeAuthMarkerSuffix, "Should have removed marker")
+	})
+	t.Run("KeepForeignCredentials", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		authFile, _ := setup(t)
+
+		require.NoError(os.MkdirAll(filepath.Dir(authFile), 0755))
+		require.NoError(os.WriteFile(authFile, []byte(`{"auths":{}}`), 0600))
+
+		_, err := syncRegistryAuth()
+		assert.NoError(err, "Should succeed")
+		assert.FileExists(authFile, "Should not remove credentials it did not write")
+	})
+}
@@ -35,6 +35,24 @@ func (d *daemon) updateFromConfig(cfg *api.UpgradedConfig) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse retry interval \"%s\": %v", cfg.RetryInterval, err)
 	}
+	retryConfig := api.RetryConfig{}
+	if cfg.Retry != nil {
+		retryConfig = *cfg.Retry
+	}
+	api.SetObjectDefaults_RetryConfig(&retryConfig)
+	retryMaxInterval, err := time.ParseDuration(retryConfig.MaxInterval)
+	if err != nil {
+		return fmt.Errorf("failed to parse max retry interval \"%s\": %v", retryConfig.MaxInterval, err)
+	}
+	nodeHooks := api.NodeHooksConfig{}
+	if cfg.NodeHooks != nil {
+		nodeHooks = *cfg.NodeHooks
+	}
+	api.SetObjectDefaults_NodeHooksConfig(&nodeHooks)
+	nodeHooksTimeout, err := time.ParseDuration(nodeHooks.Timeout)
+	if err != nil {
+		return fmt.Errorf("failed to parse node hooks timeout \"%s\": %v", nodeHooks.Timeout, err)
+	}
 
 	fleetlockClient, err := fleetlock.NewClient(cfg.FleetlockURL, cfg.FleetlockGroup)
 	if err != nil {
@@ -48,7 +66,23 @@ func (d *daemon) updateFromConfig(cfg *api.UpgradedConfig) error {
 	d.fleetlock = fleetlockClient
 	d.checkInterval = checkInterval
 	d.retryInterval = retryInterval
+	d.retryMaxInterval = retryMaxInterval
+	d.retryConfig = retryConfig
 	d.allowUnsignedOstreeImages = cfg.AllowUnsignedOstreeImages
+	d.taintUpgradingNodes = cfg.TaintUpgradingNodes
+	d.signaturePolicy = cfg.SignaturePolicy.DeepCopy()
+	d.kubeadmDownload = api.KubeadmDownloadConfig{}
+	if cfg.KubeadmDownload != nil {
+		d.kubeadmDownload = *cfg.KubeadmDownload
+	}
+	api.SetObjectDefaults_KubeadmDownloadConfig(&d.kubeadmDownload)
+	d.kubeadmCache = api.KubeadmCacheConfig{}
+	if cfg.KubeadmCache != nil {
+		d.kubeadmCache = *cfg.KubeadmCache
+	}
+	api.SetObjectDefaults_KubeadmCacheConfig(&d.kubeadmCache)
+	d.nodeHooks = nodeHooks
+	d.nodeHooksTimeout = nodeHooksTimeout
 
 	slog.Info("Finished updating configuration")
 	return nil
@@ -137,3 +171,69 @@ func (d *daemon) RetryInterval() time.Duration {
 
 	return d.retryInterval
 }
+
+// Get the maximum interval between retries
+func (d *daemon) RetryMaxInterval() time.Duration {
+	d.configLock.RLock()
+	defer d.configLock.RUnlock()
+
+	return d.retryMaxInterval
+}
+
+// Get the retry configuration with the attempts per operation
+func (d *daemon) RetryConfig() api.RetryConfig {
+	d.configLock.RLock()
+	defer d.configLock.RUnlock()
+
+	cfg := d.retryConfig
+	api.SetObjectDefaults_RetryConfig(&cfg)
+	return cfg
+}
+
+// Get the kubeadm download configuration
+func (d *daemon) KubeadmDownload() api.KubeadmDownloadConfig {
+	d.configLock.RLock()
+	defer d.configLock.RUnlock()
+
+	return d.kubeadmDownload
+}
+
+// Get the kubeadm cache configuration
+func (d *daemon) KubeadmCache() api.KubeadmCacheConfig {
+	d.configLock.RLock()
+	defer d.configLock.RUnlock()
+
+	return d.kubeadmCache
+}
+
+// Get the signature policy for the stream, nil if none is configured
+func (d *daemon) SignaturePolicy() *api.SignaturePolicy {
+	d.configLock.RLock()
+	defer d.configLock.RUnlock()
+
+	return d.signaturePolicy
+}
+
+// Check if nodes should be tainted while upgrading
+func (d *daemon) TaintUpgradingNodes() bool {
+	d.configLock.RLock()
+	defer d.configLock.RUnlock()
+
+	return d.taintUpgradingNodes
+}
+
+// Get the configuration of the node hooks
+func (d *daemon) NodeHooks() api.NodeHooksConfig {
+	d.configLock.RLock()
+	defer d.configLock.RUnlock()
+
+	return d.nodeHooks
+}
+
+// Get the time a single node hook may run
+func (d *daemon) NodeHooksTimeout() time.Duration {
+	d.configLock.RLock()
+	defer d.configLock.RUnlock()
+
+	return d.nodeHooksTimeout
+}
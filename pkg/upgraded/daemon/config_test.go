@@ -59,7 +59,11 @@ func TestUpdateFromConfig(t *testing.T) {
 		retryInterval, _ := time.ParseDuration(cfg.RetryInterval)
 		assert.Equal(checkInterval, d.CheckInterval(), "Check interval should match")
 		assert.Equal(retryInterval, d.RetryInterval(), "Retry interval should match")
+		assert.Equal(30*time.Minute, d.RetryMaxInterval(), "Should use the default max retry interval")
+		assert.Equal(int32(api.DefaultRetryRebaseAttempts), d.RetryConfig().RebaseAttempts, "Should use the default rebase attempts")
 		assert.Equal(cfg.AllowUnsignedOstreeImages, d.allowUnsignedOstreeImages, "Allow unsigned ostree images should match")
+		assert.Equal(api.NodeHooksFailurePolicyError, d.NodeHooks().FailurePolicy, "Should use the default node hooks failure policy")
+		assert.Equal(10*time.Minute, d.NodeHooksTimeout(), "Should use the default node hooks timeout")
 	})
 	tMatrix := []struct {
 		Name string
@@ -83,6 +87,24 @@ func TestUpdateFromConfig(t *testing.T) {
 				RetryInterval: "not-a-duration",
 			},
 		},
+		{
+			Name: "MisformedRetryMaxInterval",
+			Cfg: &api.UpgradedConfig{
+				FleetlockURL: "https://fleetlock.example.com",
+				Retry: &api.RetryConfig{
+					MaxInterval: "not-a-duration",
+				},
+			},
+		},
+		{
+			Name: "MisformedNodeHooksTimeout",
+			Cfg: &api.UpgradedConfig{
+				FleetlockURL: "https://fleetlock.example.com",
+				NodeHooks: &api.NodeHooksConfig{
+					Timeout: "not-a-duration",
+				},
+			},
+		},
 	}
 
 	for _, tCase := range tMatrix {
@@ -0,0 +1,218 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+)
+
+// Path of the control socket, /run is shared with the host
+const DefaultSocketPath = "/run/kube-upgraded/upgraded.sock"
+
+const (
+	PhaseStarting        = "starting"
+	PhaseIdle            = "idle"
+	PhaseChecking        = "checking"
+	PhaseUpgrading       = "upgrading"
+	PhaseUpgradingNode   = "upgrading-node"
+	controlServerTimeout = 10 * time.Second
+)
+
+// Status of the daemon as returned by the control socket
+type Status struct {
+	Node              string    `json:"node"`
+	Phase             string    `json:"phase"`
+	NodeStatus        string    `json:"nodeStatus,omitempty"`
+	KubernetesVersion string    `json:"kubernetesVersion,omitempty"`
+	BootedImage       string    `json:"bootedImage"`
+	StagedImage       string    `json:"stagedImage,omitempty"`
+	LockHeld          bool      `json:"lockHeld"`
+	NextCheck         time.Time `json:"nextCheck,omitzero"`
+}
+
+// Result of a single upgrade evaluation as returned by the control socket
+type CheckResult struct {
+	OSUpgradeAvailable      bool   `json:"osUpgradeAvailable"`
+	KubernetesVersion       string `json:"kubernetesVersion,omitempty"`
+	KubernetesUpgradeNeeded bool   `json:"kubernetesUpgradeNeeded"`
+}
+
+// Returned by the check while an upgrade is running, as it would use rpm-ostree at the same time
+var errDaemonBusy = errors.New("upgraded is busy upgrading the node, try again later")
+
+// Error returned by the control socket
+type ControlError struct {
+	Error string `json:"error"`
+}
+
+// Serve the control API on the unix socket until the context is cancelled
+func (d *daemon) serveControlSocket(listener net.Listener) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", d.handleStatus)
+	mux.HandleFunc("POST /check", d.handleCheck)
+	mux.HandleFunc("POST /upgrade-now", d.handleUpgradeNow)
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: controlServerTimeout,
+	}
+	go func() {
+		<-d.ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), controlServerTimeout)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}()
+
+	slog.Info("Serving control socket", slog.String("path", listener.Addr().String()))
+	err := server.Serve(listener)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Control socket server exited with error", "err", err)
+	}
+}
+
+// Create the unix socket for the control API.
+// Removes any stale socket and restricts access to root.
+func listenControlSocket(path string) (net.Listener, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %v", err)
+	}
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket: %v", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on socket: %v", err)
+	}
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %v", err)
+	}
+	return listener, nil
+}
+
+func (d *daemon) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	status, err := d.status()
+	if err != nil {
+		writeControlError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (d *daemon) handleCheck(w http.ResponseWriter, _ *http.Request) {
+	result, err := d.check()
+	if errors.Is(err, errDaemonBusy) {
+		writeJSON(w, http.StatusConflict, ControlError{Error: err.Error()})
+		return
+	} else if err != nil {
+		writeControlError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (d *daemon) handleUpgradeNow(w http.ResponseWriter, _ *http.Request) {
+	slog.Info("Received request to check for upgrades immediately")
+	d.triggerCheck()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Return the current status of the daemon
+func (d *daemon) status() (Status, error) {
+	phase, lockHeld, nextCheck := d.State()
+	status := Status{
+		Node:        d.node,
+		Phase:       phase,
+		BootedImage: d.bootedImageRef,
+		LockHeld:    lockHeld,
+		NextCheck:   nextCheck,
+	}
+
+	node, err := d.getNode()
+	if err != nil {
+		return status, fmt.Errorf("failed to get node: %v", err)
+	}
+	status.NodeStatus = node.Annotations[constants.NodeUpgradeStatus]
+	status.KubernetesVersion = node.Annotations[constants.NodeKubernetesVersion]
+
+	// rpm-ostree is busy while upgrading and the deployment is staged right before rebooting
+	if phase != PhaseUpgrading && phase != PhaseUpgradingNode {
+		status.StagedImage, err = d.rpmostree.GetStagedImageRef()
+		if err != nil {
+			return status, fmt.Errorf("failed to get staged deployment: %v", err)
+		}
+	}
+	return status, nil
+}
+
+// Evaluate if the os or kubernetes need an upgrade, without acting on the result.
+// Refuses to run during an upgrade and holds off upgrades until it is done.
+func (d *daemon) check() (CheckResult, error) {
+	var result CheckResult
+
+	if !d.upgrade.TryLock() {
+		return result, errDaemonBusy
+	}
+	defer d.upgrade.Unlock()
+
+	node, err := d.getNode()
+	if err != nil {
+		return result, fmt.Errorf("failed to get node: %v", err)
+	}
+	result.KubernetesVersion = node.Annotations[constants.NodeKubernetesVersion]
+	result.KubernetesUpgradeNeeded = d.nodeNeedsUpgradeOrRebase(node)
+
+	_, err = syncRegistryAuth()
+	if err != nil {
+		return result, fmt.Errorf("failed to sync registry credentials: %v", err)
+	}
+	result.OSUpgradeAvailable, err = d.rpmostree.CheckForUpgrade()
+	if err != nil {
+		return result, fmt.Errorf("failed to check for os upgrade: %v", err)
+	}
+	return result, nil
+}
+
+// Trigger an immediate check for os and kubernetes upgrades
+func (d *daemon) triggerCheck() {
+	select {
+	case d.checkNow <- struct{}{}:
+	default:
+		// A check is already pending
+	}
+	go func() {
+		node, err := d.getNode()
+		if err != nil {
+			slog.Error("Failed to get node", "err", err, slog.String("node", d.node))
+			return
+		}
+		d.checkNodeStatus(node)
+	}()
+}
+
+func writeControlError(w http.ResponseWriter, err error) {
+	slog.Error("Failed to handle control request", "err", err)
+	writeJSON(w, http.StatusInternalServerError, ControlError{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	err := json.MarshalWrite(w, v)
+	if err != nil {
+		slog.Error("Failed to write control response", "err", err)
+	}
+}
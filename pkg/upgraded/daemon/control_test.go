@@ -0,0 +1,213 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json/v2"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	rpmostree "github.com/heathcliff26/kube-upgrade/pkg/upgraded/rpm-ostree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestListenControlSocket(t *testing.T) {
+	t.Run("CreatesDirectoryAndSocket", func(t *testing.T) {
+		assert := assert.New(t)
+
+		path := filepath.Join(t.TempDir(), "kube-upgraded", "upgraded.sock")
+
+		listener, err := listenControlSocket(path)
+		require.NoError(t, err, "Should create socket")
+		t.Cleanup(func() {
+			listener.Close()
+		})
+
+		info, err := os.Stat(path)
+		require.NoError(t, err, "Socket should exist")
+		assert.Equal(os.ModeSocket, info.Mode().Type(), "Should be a socket")
+		assert.Equal(os.FileMode(0600), info.Mode().Perm(), "Should only be accessible by the owner")
+	})
+	t.Run("RemovesStaleSocket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "upgraded.sock")
+		require.NoError(t, os.WriteFile(path, []byte("stale"), 0600), "Should create stale file")
+
+		listener, err := listenControlSocket(path)
+		require.NoError(t, err, "Should replace stale socket")
+		listener.Close()
+	})
+}
+
+func TestControlSocket(t *testing.T) {
+	oldHostPrefix := hostPrefix
+	oldPullSecretFile := pullSecretFile
+	hostPrefix = t.TempDir()
+	pullSecretFile = filepath.Join(t.TempDir(), ".dockerconfigjson")
+	t.Cleanup(func() {
+		hostPrefix = oldHostPrefix
+		pullSecretFile = oldPullSecretFile
+	})
+
+	newTestDaemon := func(t *testing.T, rpmOstreePath, nodeStatus string) (*daemon, *http.Client) {
+		t.Helper()
+
+		rpmOstreeCMD, err := rpmostree.New(rpmOstreePath)
+		require.NoError(t, err, "Should create rpm-ostree cmd wrapper")
+
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "testnode",
+				Annotations: map[string]string{
+					constants.NodeKubernetesVersion: "v1.35.0",
+					constants.NodeUpgradeStatus:     nodeStatus,
+				},
+			},
+		}
+
+		ctx, cancel := context.WithCancel(t.Context())
+		d := &daemon{
+			ctx:            ctx,
+			cancel:         cancel,
+			stream:         "ghcr.io/heathcliff26/fcos-k8s",
+			rpmostree:      rpmOstreeCMD,
+			client:         fake.NewClientset(node),
+			node:           node.GetName(),
+			bootedImageRef: "ostree-unverified-registry:ghcr.io/heathcliff26/fcos-k8s:v1.34.2",
+			checkNow:       make(chan struct{}, 1),
+			phase:          PhaseIdle,
+			lockHeld:       true,
+			nextCheck:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+
+		socket := filepath.Join(t.TempDir(), "upgraded.sock")
+		listener, err := listenControlSocket(socket)
+		require.NoError(t, err, "Should create socket")
+
+		done := make(chan struct{})
+		go func() {
+			d.serveControlSocket(listener)
+			close(done)
+		}()
+		t.Cleanup(func() {
+			cancel()
+			<-done
+		})
+
+		client := &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		}
+		return d, client
+	}
+
+	t.Run("Status", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, client := newTestDaemon(t, "testdata/print-status.sh", constants.NodeUpgradeStatusPending)
+
+		res, err := client.Get("http://upgraded/status")
+		require.NoError(t, err, "Should send request")
+		defer res.Body.Close()
+
+		assert.Equal(http.StatusOK, res.StatusCode, "Should succeed")
+		var status Status
+		require.NoError(t, json.UnmarshalRead(res.Body, &status), "Should return a status")
+
+		assert.Equal(Status{
+			Node:              "testnode",
+			Phase:             PhaseIdle,
+			NodeStatus:        constants.NodeUpgradeStatusPending,
+			KubernetesVersion: "v1.35.0",
+			BootedImage:       "ostree-unverified-registry:ghcr.io/heathcliff26/fcos-k8s:v1.34.2",
+			StagedImage:       "ostree-unverified-registry:ghcr.io/heathcliff26/fcos-k8s:v1.35.0",
+			LockHeld:          true,
+			NextCheck:         time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		}, status, "Should return the state of the daemon")
+	})
+	t.Run("StatusError", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, client := newTestDaemon(t, "testdata/exit-1.sh", constants.NodeUpgradeStatusPending)
+
+		res, err := client.Get("http://upgraded/status")
+		require.NoError(t, err, "Should send request")
+		defer res.Body.Close()
+
+		assert.Equal(http.StatusInternalServerError, res.StatusCode, "Should fail")
+		var controlErr ControlError
+		require.NoError(t, json.UnmarshalRead(res.Body, &controlErr), "Should return an error")
+		assert.Contains(controlErr.Error, "failed to get staged deployment", "Should return the reason")
+	})
+	t.Run("Check", func(t *testing.T) {
+		assert := assert.New(t)
+
+		d, client := newTestDaemon(t, "testdata/exit-0.sh", constants.NodeUpgradeStatusPending)
+
+		res, err := client.Post("http://upgraded/check", "", nil)
+		require.NoError(t, err, "Should send request")
+		defer res.Body.Close()
+
+		assert.Equal(http.StatusOK, res.StatusCode, "Should succeed")
+		var result CheckResult
+		require.NoError(t, json.UnmarshalRead(res.Body, &result), "Should return a result")
+		assert.Equal(CheckResult{
+			OSUpgradeAvailable:      true,
+			KubernetesVersion:       "v1.35.0",
+			KubernetesUpgradeNeeded: true,
+		}, result, "Should return the result of the check")
+		assert.Empty(d.checkNow, "Should not trigger an upgrade")
+	})
+	t.Run("CheckWhileUpgrading", func(t *testing.T) {
+		assert := assert.New(t)
+
+		d, client := newTestDaemon(t, "testdata/exit-0.sh", constants.NodeUpgradeStatusPending)
+		d.upgrade.Lock()
+		defer d.upgrade.Unlock()
+
+		res, err := client.Post("http://upgraded/check", "", nil)
+		require.NoError(t, err, "Should send request")
+		defer res.Body.Close()
+
+		assert.Equal(http.StatusConflict, res.StatusCode, "Should refuse to check during an upgrade")
+		var controlErr ControlError
+		require.NoError(t, json.UnmarshalRead(res.Body, &controlErr), "Should return an error")
+		assert.Equal(errDaemonBusy.Error(), controlErr.Error, "Should return the reason")
+	})
+	t.Run("UpgradeNow", func(t *testing.T) {
+		assert := assert.New(t)
+
+		// The node is up to date, so the triggered kubernetes check does nothing
+		d, client := newTestDaemon(t, "testdata/exit-0.sh", constants.NodeUpgradeStatusCompleted)
+		d.bootedImageRef = "ostree-unverified-registry:ghcr.io/heathcliff26/fcos-k8s:v1.35.0"
+
+		for range 2 {
+			res, err := client.Post("http://upgraded/upgrade-now", "", nil)
+			require.NoError(t, err, "Should send request")
+			res.Body.Close()
+			assert.Equal(http.StatusAccepted, res.StatusCode, "Should accept the request")
+		}
+
+		assert.Len(d.checkNow, 1, "Should queue a single check")
+	})
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		_, client := newTestDaemon(t, "testdata/exit-0.sh", constants.NodeUpgradeStatusPending)
+
+		res, err := client.Get("http://upgraded/upgrade-now")
+		require.NoError(t, err, "Should send request")
+		res.Body.Close()
+
+		assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode, "Should only allow POST")
+	})
+}
@@ -4,27 +4,39 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	fleetlock "github.com/heathcliff26/fleetlock/pkg/client"
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
 	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/config"
 	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/kubeadm"
 	rpmostree "github.com/heathcliff26/kube-upgrade/pkg/upgraded/rpm-ostree"
 	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/utils"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 var (
 	hostPrefix       = "/host"
 	rpmOstreeCMDPath = "/usr/bin/rpm-ostree"
+	kubeadmCacheDir  = "/var/lib/kube-upgraded/kubeadm"
+	// Output of kubeadm and rpm-ostree, kept on the host to survive the reboot after a rebase
+	commandLogDir = "/var/log/kube-upgraded"
 )
 
+// Up to this percentage is subtracted randomly from each retry interval,
+// so nodes that fail at the same time don't retry in lockstep
+const retryJitterPercent = 20
+
 type daemon struct {
 	cfgPath string
 
@@ -32,7 +44,15 @@ type daemon struct {
 	fleetlock                 *fleetlock.FleetlockClient
 	checkInterval             time.Duration
 	retryInterval             time.Duration
+	retryMaxInterval          time.Duration
+	retryConfig               api.RetryConfig
 	allowUnsignedOstreeImages bool
+	taintUpgradingNodes       bool
+	kubeadmDownload           api.KubeadmDownloadConfig
+	kubeadmCache              api.KubeadmCacheConfig
+	signaturePolicy           *api.SignaturePolicy
+	nodeHooks                 api.NodeHooksConfig
+	nodeHooksTimeout          time.Duration
 
 	rpmostree *rpmostree.RPMOStreeCMD
 	kubeadm   *kubeadm.KubeadmCMD
@@ -46,7 +66,15 @@ type daemon struct {
 
 	configWatcher *fsnotify.Watcher
 
+	socketPath string
+	checkNow   chan struct{}
+
+	phase     string
+	lockHeld  bool
+	nextCheck time.Time
+
 	configLock sync.RWMutex
+	stateLock  sync.RWMutex
 	upgrade    sync.Mutex
 }
 
@@ -73,9 +101,9 @@ func NewDaemon(cfgPath string) (*daemon, error) {
 		}
 	}
 
-	config, err := clientcmd.BuildConfigFromFlags("", hostPrefix+cfg.KubeletConfig)
+	config, err := kubeClientConfig(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read kubeconfig: %v", err)
+		return nil, err
 	}
 	kubeClient, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -97,6 +125,8 @@ func NewDaemon(cfgPath string) (*daemon, error) {
 		return nil, fmt.Errorf("failed to get ostree image ref: %v", err)
 	}
 
+	utils.CommandLogDir = hostPrefix + commandLogDir
+
 	d := &daemon{
 		cfgPath: cfgPath,
 
@@ -106,6 +136,10 @@ func NewDaemon(cfgPath string) (*daemon, error) {
 		node:           node,
 		bootedImageRef: bootedImageRef,
 		client:         kubeClient,
+
+		socketPath: DefaultSocketPath,
+		checkNow:   make(chan struct{}, 1),
+		phase:      PhaseStarting,
 	}
 
 	err = d.updateFromConfig(cfg)
@@ -115,30 +149,107 @@ func NewDaemon(cfgPath string) (*daemon, error) {
 	return d, nil
 }
 
-// Retries the given function until it succeeds
-func (d *daemon) retry(f func() bool) {
-	for !f() {
+// Return the client config for the ServiceAccount of the pod when configured, otherwise use the kubelet credentials of the node
+func kubeClientConfig(cfg *api.UpgradedConfig) (*rest.Config, error) {
+	if cfg.ServiceAccountName != "" {
+		slog.Info("Using ServiceAccount credentials", slog.String("serviceAccount", cfg.ServiceAccountName))
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create in-cluster config: %v", err)
+		}
+		return config, nil
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", hostPrefix+cfg.KubeletConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubeconfig: %v", err)
+	}
+	return config, nil
+}
+
+// Retries the given function with exponential backoff until it succeeds.
+// Gives up after maxAttempts when it is greater than 0 and returns the last error.
+// Returns the error of the context when it is cancelled.
+func (d *daemon) retry(maxAttempts int32, f func() error) error {
+	interval := d.RetryInterval()
+	maxInterval := d.RetryMaxInterval()
+
+	for attempt := int32(1); ; attempt++ {
+		err := f()
+		if err == nil {
+			return nil
+		}
+		if maxAttempts > 0 && attempt >= maxAttempts {
+			if attempt == 1 {
+				return err
+			}
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
 		select {
 		case <-d.ctx.Done():
-			return
-		case <-time.After(d.RetryInterval()):
+			return d.ctx.Err()
+		case <-time.After(jitter(interval)):
+		}
+
+		if interval < maxInterval {
+			interval = min(interval*2, maxInterval)
 		}
 	}
 }
 
+// Randomly shorten the interval by up to retryJitterPercent
+func jitter(interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return interval
+	}
+	return interval - time.Duration(rand.Int64N(int64(interval)*retryJitterPercent/100+1))
+}
+
+// Acquire the lock and remember that this node holds it
+func (d *daemon) acquireLock() error {
+	err := d.Fleetlock().Lock()
+	if err != nil {
+		return err
+	}
+	d.setLockHeld(true)
+	d.annotateLockHeld(true)
+	return nil
+}
+
+// Acquire the lock, retrying up to the configured attempts
+func (d *daemon) acquireLockWithRetry() error {
+	return d.retry(d.RetryConfig().LockAttempts, func() error {
+		err := d.acquireLock()
+		if err != nil {
+			slog.Warn("Failed to acquire lock", "err", err)
+		}
+		return err
+	})
+}
+
 // Will try to release the lock until successful
 func (d *daemon) releaseLock() {
-	d.retry(func() bool {
+	_ = d.retry(0, func() error {
 		err := d.Fleetlock().Release()
-		if err == nil {
-			return true
+		if err != nil {
+			slog.Warn("Failed to release lock", "err", err)
+			return err
 		}
-
-		slog.Warn("Failed to release lock", "err", err)
-		return false
+		d.setLockHeld(false)
+		d.annotateLockHeld(false)
+		return nil
 	})
 }
 
+// Record on the node if it holds the lock, so the controller can report excluded nodes still holding it
+func (d *daemon) annotateLockHeld(held bool) {
+	err := d.updateNodeAnnotations(map[string]string{constants.NodeLockHeld: strconv.FormatBool(held)})
+	if err != nil {
+		slog.Warn("Failed to record the lock on the node", slog.Bool("held", held), "err", err)
+	}
+}
+
 // Run the main daemon loop
 func (d *daemon) Run() error {
 	stop := make(chan os.Signal, 1)
@@ -179,7 +290,29 @@ func (d *daemon) Run() error {
 		return fmt.Errorf("failed to annotate node with upgraded version: %v", err)
 	}
 
-	if !nodeNeedsUpgrade(node) && d.nodeHasCorrectStream(node) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.renewHeartbeat()
+		slog.Info("Stopped renewing heartbeat")
+	}()
+
+	listener, err := listenControlSocket(d.socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to create control socket: %v", err)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.serveControlSocket(listener)
+		slog.Info("Stopped serving control socket")
+	}()
+
+	if !d.nodeNeedsUpgradeOrRebase(node) {
+		if node.Annotations[constants.NodeUpgradeStatus] == constants.NodeUpgradeStatusCompleted {
+			// Clear anything left behind, in case the daemon was interrupted after completing the upgrade
+			d.updateMaintenance(constants.NodeUpgradeStatusCompleted)
+		}
 		slog.Debug("Releasing any log that may be held by this machine")
 		d.releaseLock()
 		if d.ctx.Err() != nil {
@@ -191,6 +324,7 @@ func (d *daemon) Run() error {
 	}
 
 	slog.Info("Starting daemon")
+	d.setPhase(PhaseIdle)
 
 	go func() {
 		defer wg.Done()
@@ -206,3 +340,32 @@ func (d *daemon) Run() error {
 	wg.Wait()
 	return nil
 }
+
+// Get the current phase, if the node holds the lock and the time of the next check for os upgrades
+func (d *daemon) State() (string, bool, time.Time) {
+	d.stateLock.RLock()
+	defer d.stateLock.RUnlock()
+
+	return d.phase, d.lockHeld, d.nextCheck
+}
+
+func (d *daemon) setPhase(phase string) {
+	d.stateLock.Lock()
+	defer d.stateLock.Unlock()
+
+	d.phase = phase
+}
+
+func (d *daemon) setLockHeld(held bool) {
+	d.stateLock.Lock()
+	defer d.stateLock.Unlock()
+
+	d.lockHeld = held
+}
+
+func (d *daemon) setNextCheck(next time.Time) {
+	d.stateLock.Lock()
+	defer d.stateLock.Unlock()
+
+	d.nextCheck = next
+}
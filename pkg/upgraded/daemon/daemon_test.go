@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -43,11 +44,17 @@ func TestNewDaemon(t *testing.T) {
 			Path:  "testdata/config/invalid-kubeletConfig.yaml",
 			Error: "failed to read kubeconfig:",
 		},
+		{
+			Name:  "ServiceAccountOutsideCluster",
+			Path:  "testdata/config/valid-serviceaccount.yaml",
+			Error: "failed to create in-cluster config:",
+		},
 	}
 
 	for _, tCase := range tMatrix {
 		t.Run(tCase.Name, func(t *testing.T) {
 			assert := assert.New(t)
+			t.Setenv("KUBERNETES_SERVICE_HOST", "")
 
 			d, err := NewDaemon(tCase.Path)
 
@@ -67,15 +74,108 @@ func TestRetry(t *testing.T) {
 	cancelOnTimeout(t, ctx, cancel)
 
 	count := 0
-	d.retry(func() bool {
+	err := d.retry(0, func() error {
 		count++
-		return count > 5
+		if count > 5 {
+			return nil
+		}
+		return errors.New("failed")
 	})
 	t.Cleanup(cancel)
 
+	assert.NoError(t, err, "Should succeed")
 	assert.Equal(t, 6, count, "Should have run the function exactly 6 times")
 }
 
+func TestRetryMaxAttempts(t *testing.T) {
+	tMatrix := []struct {
+		Name        string
+		MaxAttempts int32
+		Error       string
+	}{
+		{
+			Name:        "SingleAttempt",
+			MaxAttempts: 1,
+			Error:       "failed",
+		},
+		{
+			Name:        "MultipleAttempts",
+			MaxAttempts: 3,
+			Error:       "giving up after 3 attempts: failed",
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			d := &daemon{
+				retryInterval:    time.Millisecond,
+				retryMaxInterval: time.Millisecond,
+				ctx:              t.Context(),
+			}
+
+			count := int32(0)
+			err := d.retry(tCase.MaxAttempts, func() error {
+				count++
+				return errors.New("failed")
+			})
+
+			assert.EqualError(err, tCase.Error, "Should return the last error")
+			assert.Equal(tCase.MaxAttempts, count, "Should stop after the max attempts")
+		})
+	}
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		d := &daemon{
+			retryInterval: time.Hour,
+			ctx:           ctx,
+		}
+
+		err := d.retry(0, func() error {
+			cancel()
+			return errors.New("failed")
+		})
+
+		assert.ErrorIs(t, err, context.Canceled, "Should stop when the context is cancelled")
+	})
+}
+
+func TestRetryBackoff(t *testing.T) {
+	assert := assert.New(t)
+
+	d := &daemon{
+		retryInterval:    10 * time.Millisecond,
+		retryMaxInterval: 40 * time.Millisecond,
+		ctx:              t.Context(),
+	}
+
+	var calls []time.Time
+	_ = d.retry(5, func() error {
+		calls = append(calls, time.Now())
+		return errors.New("failed")
+	})
+
+	assert.Len(calls, 5, "Should have tried 5 times")
+	for i, expected := range []time.Duration{10, 20, 40, 40} {
+		expected *= time.Millisecond
+		wait := calls[i+1].Sub(calls[i])
+		assert.GreaterOrEqual(wait, expected*(100-retryJitterPercent)/100, "Wait %d should not be shorter than the interval minus the jitter", i+1)
+		assert.Less(wait, expected+20*time.Millisecond, "Wait %d should not be longer than the interval", i+1)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(time.Duration(0), jitter(0), "Should not change an empty interval")
+	for range 100 {
+		wait := jitter(time.Minute)
+		assert.LessOrEqual(wait, time.Minute, "Should not extend the interval")
+		assert.GreaterOrEqual(wait, time.Minute*(100-retryJitterPercent)/100, "Should shorten the interval by at most the jitter")
+	}
+}
+
 func TestRun(t *testing.T) {
 	tMatrix := []struct {
 		Name, RPMOStreePath string
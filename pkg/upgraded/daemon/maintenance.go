@@ -0,0 +1,140 @@
+package daemon
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reasons of the upgrade condition of the node
+const (
+	conditionReasonUpgrading = "Upgrading"
+	conditionReasonRebasing  = "Rebasing"
+	conditionReasonVerifying = "Verifying"
+	conditionReasonCompleted = "UpgradeCompleted"
+)
+
+// Mark the node as under maintenance while it is upgrading or rebasing and clear it once the upgrade completed.
+// Nodes in error stay marked, as their state is not verified.
+// Failures are only logged, as they should not block the upgrade.
+func (d *daemon) updateMaintenance(status string) {
+	var err error
+	switch status {
+	case constants.NodeUpgradeStatusUpgrading:
+		err = d.markUpgradeInProgress(conditionReasonUpgrading, "kubeadm is upgrading the node")
+	case constants.NodeUpgradeStatusRebasing:
+		err = d.markUpgradeInProgress(conditionReasonRebasing, "The node is rebasing to the new kubernetes version and will reboot")
+	case constants.NodeUpgradeStatusVerifying:
+		err = d.markUpgradeInProgress(conditionReasonVerifying, "The node booted the new kubernetes version and is being verified")
+	case constants.NodeUpgradeStatusCompleted:
+		err = d.clearUpgradeInProgress()
+	default:
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to update the upgrade condition and taint of the node", slog.String("node", d.node), slog.String("status", status), "err", err)
+	}
+}
+
+// Set the upgrade condition of the node and the taint, when enabled
+func (d *daemon) markUpgradeInProgress(reason, message string) error {
+	err := d.setUpgradeCondition(corev1.ConditionTrue, reason, message)
+	if err != nil {
+		return err
+	}
+	if !d.TaintUpgradingNodes() {
+		return nil
+	}
+	return d.setUpgradeTaint(true)
+}
+
+// Set the upgrade condition of the node to false and remove the taint.
+// The taint is removed even when disabled, as it might have been enabled when the upgrade started.
+func (d *daemon) clearUpgradeInProgress() error {
+	err := d.setUpgradeCondition(corev1.ConditionFalse, conditionReasonCompleted, "The upgrade of the node completed")
+	if err != nil {
+		return err
+	}
+	return d.setUpgradeTaint(false)
+}
+
+// Set the upgrade condition on the node status.
+// Does not add the condition when clearing it, so nodes that never upgraded don't get it.
+func (d *daemon) setUpgradeCondition(status corev1.ConditionStatus, reason, message string) error {
+	return retryOnConflict(func() error {
+		node, err := d.getNode()
+		if err != nil {
+			return err
+		}
+
+		now := metav1.Now()
+		i := slices.IndexFunc(node.Status.Conditions, func(c corev1.NodeCondition) bool {
+			return c.Type == constants.NodeConditionUpgradeInProgress
+		})
+		if i < 0 {
+			if status != corev1.ConditionTrue {
+				return nil
+			}
+			node.Status.Conditions = append(node.Status.Conditions, corev1.NodeCondition{
+				Type:               constants.NodeConditionUpgradeInProgress,
+				LastTransitionTime: now,
+			})
+			i = len(node.Status.Conditions) - 1
+		}
+
+		condition := &node.Status.Conditions[i]
+		if condition.Status == status && condition.Reason == reason {
+			return nil
+		}
+		if condition.Status != status {
+			condition.LastTransitionTime = now
+		}
+		condition.Status = status
+		condition.Reason = reason
+		condition.Message = message
+		condition.LastHeartbeatTime = now
+
+		_, err = d.client.CoreV1().Nodes().UpdateStatus(d.ctx, node, metav1.UpdateOptions{})
+		if err == nil {
+			slog.Debug("Set upgrade condition of the node", slog.String("status", string(status)), slog.String("reason", reason))
+		}
+		return err
+	})
+}
+
+// Add or remove the NoSchedule taint for upgrading nodes
+func (d *daemon) setUpgradeTaint(taint bool) error {
+	return retryOnConflict(func() error {
+		node, err := d.getNode()
+		if err != nil {
+			return err
+		}
+
+		hasTaint := slices.ContainsFunc(node.Spec.Taints, func(t corev1.Taint) bool {
+			return t.Key == constants.NodeTaintUpgrading
+		})
+		if hasTaint == taint {
+			return nil
+		}
+
+		if taint {
+			node.Spec.Taints = append(node.Spec.Taints, corev1.Taint{
+				Key:    constants.NodeTaintUpgrading,
+				Effect: corev1.TaintEffectNoSchedule,
+			})
+		} else {
+			node.Spec.Taints = slices.DeleteFunc(node.Spec.Taints, func(t corev1.Taint) bool {
+				return t.Key == constants.NodeTaintUpgrading
+			})
+		}
+
+		_, err = d.client.CoreV1().Nodes().Update(d.ctx, node, metav1.UpdateOptions{})
+		if err == nil {
+			slog.Debug("Updated upgrade taint of the node", slog.Bool("taint", taint))
+		}
+		return err
+	})
+}
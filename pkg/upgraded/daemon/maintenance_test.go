@@ -0,0 +1,141 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestUpdateMaintenance(t *testing.T) {
+	otherTaint := corev1.Taint{
+		Key:    "example.com/other",
+		Effect: corev1.TaintEffectNoExecute,
+	}
+	readyCondition := corev1.NodeCondition{
+		Type:   corev1.NodeReady,
+		Status: corev1.ConditionTrue,
+	}
+
+	newTestDaemon := func(t *testing.T, taint bool) *daemon {
+		t.Helper()
+
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "testnode",
+			},
+			Spec: corev1.NodeSpec{
+				Taints: []corev1.Taint{otherTaint},
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{readyCondition},
+			},
+		}
+		return &daemon{
+			ctx:                 t.Context(),
+			client:              fake.NewClientset(node),
+			node:                node.GetName(),
+			taintUpgradingNodes: taint,
+		}
+	}
+	upgradeCondition := func(t *testing.T, node *corev1.Node) *corev1.NodeCondition {
+		t.Helper()
+
+		for i := range node.Status.Conditions {
+			if node.Status.Conditions[i].Type == constants.NodeConditionUpgradeInProgress {
+				return &node.Status.Conditions[i]
+			}
+		}
+		return nil
+	}
+
+	t.Run("Upgrading", func(t *testing.T) {
+		assert := assert.New(t)
+
+		d := newTestDaemon(t, true)
+
+		d.updateMaintenance(constants.NodeUpgradeStatusUpgrading)
+
+		node, err := d.getNode()
+		require.NoError(t, err, "Should get node")
+		condition := upgradeCondition(t, node)
+		require.NotNil(t, condition, "Should add the condition")
+		assert.Equal(corev1.ConditionTrue, condition.Status, "Should mark the upgrade as in progress")
+		assert.Equal(conditionReasonUpgrading, condition.Reason, "Should set the reason")
+		assert.Contains(node.Status.Conditions, readyCondition, "Should keep other conditions")
+		assert.Equal([]corev1.Taint{otherTaint, {Key: constants.NodeTaintUpgrading, Effect: corev1.TaintEffectNoSchedule}}, node.Spec.Taints, "Should add the taint")
+	})
+	t.Run("Rebasing", func(t *testing.T) {
+		assert := assert.New(t)
+
+		d := newTestDaemon(t, true)
+
+		d.updateMaintenance(constants.NodeUpgradeStatusUpgrading)
+		node, _ := d.getNode()
+		transition := upgradeCondition(t, node).LastTransitionTime
+
+		d.updateMaintenance(constants.NodeUpgradeStatusRebasing)
+
+		node, _ = d.getNode()
+		condition := upgradeCondition(t, node)
+		require.NotNil(t, condition, "Should keep the condition")
+		assert.Equal(conditionReasonRebasing, condition.Reason, "Should update the reason")
+		assert.Equal(transition, condition.LastTransitionTime, "Should keep the transition time, as the status did not change")
+		assert.Len(node.Spec.Taints, 2, "Should only add the taint once")
+	})
+	t.Run("TaintDisabled", func(t *testing.T) {
+		assert := assert.New(t)
+
+		d := newTestDaemon(t, false)
+
+		d.updateMaintenance(constants.NodeUpgradeStatusUpgrading)
+
+		node, _ := d.getNode()
+		assert.NotNil(upgradeCondition(t, node), "Should add the condition")
+		assert.Equal([]corev1.Taint{otherTaint}, node.Spec.Taints, "Should not add the taint")
+	})
+	t.Run("Completed", func(t *testing.T) {
+		assert := assert.New(t)
+
+		d := newTestDaemon(t, true)
+		d.updateMaintenance(constants.NodeUpgradeStatusRebasing)
+		// The taint is removed, even when it has been disabled in the meantime
+		d.taintUpgradingNodes = false
+
+		d.updateMaintenance(constants.NodeUpgradeStatusCompleted)
+
+		node, _ := d.getNode()
+		condition := upgradeCondition(t, node)
+		require.NotNil(t, condition, "Should keep the condition")
+		assert.Equal(corev1.ConditionFalse, condition.Status, "Should mark the upgrade as done")
+		assert.Equal(conditionReasonCompleted, condition.Reason, "Should set the reason")
+		assert.Equal([]corev1.Taint{otherTaint}, node.Spec.Taints, "Should remove the taint")
+	})
+	t.Run("CompletedWithoutUpgrade", func(t *testing.T) {
+		assert := assert.New(t)
+
+		d := newTestDaemon(t, true)
+
+		d.updateMaintenance(constants.NodeUpgradeStatusCompleted)
+
+		node, _ := d.getNode()
+		assert.Nil(upgradeCondition(t, node), "Should not add the condition")
+		assert.Equal([]corev1.Taint{otherTaint}, node.Spec.Taints, "Should not change the taints")
+	})
+	t.Run("ErrorKeepsMaintenance", func(t *testing.T) {
+		assert := assert.New(t)
+
+		d := newTestDaemon(t, true)
+		d.updateMaintenance(constants.NodeUpgradeStatusUpgrading)
+
+		d.updateMaintenance(constants.NodeUpgradeStatusError)
+
+		node, _ := d.getNode()
+		assert.Equal(corev1.ConditionTrue, upgradeCondition(t, node).Status, "Should keep the condition")
+		assert.Len(node.Spec.Taints, 2, "Should keep the taint")
+	})
+}
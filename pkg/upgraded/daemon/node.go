@@ -1,23 +1,46 @@
 package daemon
 
 import (
+	"context"
+	"encoding/json/v2"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"path/filepath"
 	"strings"
 	"time"
 
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
 	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/config"
 	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/kubeadm"
+	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/policy"
+	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/utils"
 	"github.com/heathcliff26/kube-upgrade/pkg/version"
 	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/yaml"
 )
 
-const kubernetesTMPDir = "/etc/kubernetes/tmp"
+const (
+	kubernetesTMPDir = "/etc/kubernetes/tmp"
+	// Keep the error annotation short, the full error is in the log of upgraded
+	maxLastErrorLength = 1024
+	// The controller considers the daemon dead after a few missed heartbeats
+	heartbeatInterval = time.Minute
+	// How often an update of the node is retried when it was modified concurrently
+	maxNodeUpdateConflicts = 5
+	// Limit of the API server for the note of an Event
+	maxEventNoteLength       = 1024
+	eventReportingController = "kube-upgrade.heathcliff.eu/upgraded"
+)
 
 // Watch for node upgrades and perform them if necessary
 func (d *daemon) watchForNodeUpgrade() {
@@ -49,22 +72,51 @@ func (d *daemon) watchForNodeUpgrade() {
 
 // Check if we need to upgrade the node and trigger the upgrade if needed
 func (d *daemon) checkNodeStatus(node *corev1.Node) {
-	if !nodeNeedsUpgrade(node) && d.nodeHasCorrectStream(node) {
+	if nodeExcludedFromUpgrade(node) {
+		d.releaseLockOfExcludedNode()
+		return
+	}
+	if !d.nodeNeedsUpgradeOrRebase(node) {
 		return
 	}
 
 	d.doNodeUpgradeWithRetry(nil)
 }
 
-// Update the node until it succeeds
+// Release the lock when the node has been excluded from the upgrade after acquiring it, e.g. while it is in error.
+// A running upgrade keeps the lock until it finishes or fails.
+func (d *daemon) releaseLockOfExcludedNode() {
+	if !d.upgrade.TryLock() {
+		return
+	}
+	defer d.upgrade.Unlock()
+
+	if _, lockHeld, _ := d.State(); lockHeld {
+		slog.Info("Releasing lock, as the node has been excluded from the upgrade", slog.String("node", d.node))
+		d.releaseLock()
+	}
+}
+
+// Update the node until it succeeds or the node is set to error
 func (d *daemon) doNodeUpgradeWithRetry(node *corev1.Node) {
-	d.retry(func() bool {
+	_ = d.retry(0, func() error {
 		err := d.doNodeUpgrade(node)
 		if err == nil {
-			return true
+			return nil
 		}
 		slog.Error("Failed to upgrade node", "err", err, slog.String("node", d.node))
-		return false
+
+		var upgradeErr *nodeUpgradeError
+		if errors.As(err, &upgradeErr) {
+			slog.Info("Waiting for the controller to retry the upgrade", slog.String("node", d.node))
+			return nil
+		}
+		var abortedErr *nodeUpgradeAbortedError
+		if errors.As(err, &abortedErr) {
+			slog.Info("Aborted the upgrade, trying again at the next check", slog.String("node", d.node))
+			return nil
+		}
+		return err
 	})
 }
 
@@ -89,22 +141,62 @@ func (d *daemon) doNodeUpgrade(node *corev1.Node) error {
 	phase := node.Annotations[constants.NodeUpgradeStatus]
 	slog.Info("Attempting node upgrade to new kubernetes version", slog.String("node", node.GetName()), slog.String("version", version), slog.String("phase", phase))
 
-	err = d.Fleetlock().Lock()
-	if err != nil {
+	// The lock is still held when resuming after the reboot
+	resumed := phase == constants.NodeUpgradeStatusRebasing || phase == constants.NodeUpgradeStatusVerifying
+	if !resumed {
+		err = d.runNodeUpgradeHooks(nodeHookPreLock, version)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = d.acquireLockWithRetry()
+	if errors.Is(err, context.Canceled) {
 		return fmt.Errorf("failed to acquire lock: %v", err)
+	} else if err != nil {
+		return d.returnNodeUpgradeError(constants.NodeErrorReasonLockFailed, fmt.Errorf("failed to acquire lock: %v", err))
 	}
 
-	if phase != constants.NodeUpgradeStatusRebasing {
-		if d.kubeadm == nil {
-			d.kubeadm, err = kubeadm.NewFromVersion(hostPrefix, version)
+	phaseBefore, _, _ := d.State()
+	d.setPhase(PhaseUpgradingNode)
+	defer d.setPhase(phaseBefore)
+
+	// The stream is only verified, when the node is rebased into it
+	err = d.syncSignaturePolicy(version, !d.nodeHasCorrectStream(node))
+	if err != nil {
+		return d.returnNodeUpgradeError(constants.NodeErrorReasonStreamUnverifiable, fmt.Errorf("stream can't be verified by the host: %v", err))
+	}
+
+	if !resumed {
+		err = d.runNodeUpgradeHooks(nodeHookPreKubeadm, version)
+		if err != nil {
+			return err
+		}
+
+		// Only use a fixed kubeadm when the path is configured, otherwise select the binary for the target version
+		kubeadmCMD := d.kubeadm
+		if kubeadmCMD == nil {
+			err = d.retry(d.RetryConfig().KubeadmAttempts, func() error {
+				cmd, err := d.newKubeadm(version)
+				if err != nil {
+					slog.Warn("Failed to acquire kubeadm", "err", err)
+					return err
+				}
+				kubeadmCMD = cmd
+				return nil
+			})
 			if err != nil {
-				return d.returnNodeUpgradeError(fmt.Errorf("failed to download kubeadm: %v", err))
+				reason := constants.NodeErrorReasonKubeadmDownloadFailed
+				if kubeadm.IsVerificationError(err) {
+					reason = constants.NodeErrorReasonKubeadmVerificationFailed
+				}
+				return d.returnNodeUpgradeError(reason, fmt.Errorf("failed to acquire kubeadm: %w", err))
 			}
 		}
 
-		err = d.nodeKubeadmUpgrade(version)
+		err = d.nodeKubeadmUpgrade(kubeadmCMD, version)
 		if err != nil {
-			return fmt.Errorf("failed to run kubeadm upgrade: %v", err)
+			return fmt.Errorf("failed to run kubeadm upgrade: %w", err)
 		}
 	} else {
 		slog.Debug("Skipping kubeadm upgrade since it already succeeded")
@@ -116,14 +208,42 @@ func (d *daemon) doNodeUpgrade(node *corev1.Node) error {
 		if err != nil {
 			return fmt.Errorf("failed to update node status: %v", err)
 		}
-		err = d.rpmostree.Rebase(d.Stream()+":"+version, d.allowUnsignedOstreeImages)
+		_, err = syncRegistryAuth()
+		if err != nil {
+			return d.returnNodeUpgradeError(constants.NodeErrorReasonRegistryAuthFailed, fmt.Errorf("failed to sync registry credentials: %v", err))
+		}
+		err = d.runNodeUpgradeHooks(nodeHookPreReboot, version)
+		if err != nil {
+			return err
+		}
+		err = d.retry(d.RetryConfig().RebaseAttempts, func() error {
+			err := d.rpmostree.Rebase(d.Stream()+":"+version, d.allowUnsignedOstreeImages)
+			if err != nil {
+				slog.Warn("Failed to rebase node", "err", err)
+			}
+			return err
+		})
 		if err != nil {
-			return d.returnNodeUpgradeError(fmt.Errorf("failed to rebase node: %v", err))
+			return d.returnNodeUpgradeError(constants.NodeErrorReasonRebaseFailed, fmt.Errorf("failed to rebase node: %w", err))
 		}
 		// This return is here purely for testing, as a successful rebase does not return, but instead reboots the system
 		return nil
 	}
 
+	// The node booted the new version, which is verified before the upgrade is completed
+	if resumed {
+		if phase == constants.NodeUpgradeStatusRebasing {
+			err = d.updateNodeStatus(constants.NodeUpgradeStatusVerifying)
+			if err != nil {
+				return fmt.Errorf("failed to update node status: %v", err)
+			}
+		}
+		err = d.runNodeUpgradeHooks(nodeHookPostBoot, version)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = d.updateNodeStatus(constants.NodeUpgradeStatusCompleted)
 	if err != nil {
 		return fmt.Errorf("failed to update node status: %v", err)
@@ -135,7 +255,7 @@ func (d *daemon) doNodeUpgrade(node *corev1.Node) error {
 }
 
 // Run kubeadm upgrade for the node
-func (d *daemon) nodeKubeadmUpgrade(version string) error {
+func (d *daemon) nodeKubeadmUpgrade(kubeadmCMD *kubeadm.KubeadmCMD, version string) error {
 	slog.Info("Updating node via kubeadm")
 
 	err := d.updateNodeStatus(constants.NodeUpgradeStatusUpgrading)
@@ -143,28 +263,35 @@ func (d *daemon) nodeKubeadmUpgrade(version string) error {
 		return fmt.Errorf("failed to update node status: %v", err)
 	}
 
-	kubeadmConfigMap, err := d.client.CoreV1().ConfigMaps("kube-system").Get(d.ctx, "kubeadm-config", metav1.GetOptions{})
+	kubeadmConfigMap, err := d.client.CoreV1().ConfigMaps(constants.KubeadmConfigMapNamespace).Get(d.ctx, constants.KubeadmConfigMapName, metav1.GetOptions{})
 	if err != nil {
-		return d.returnNodeUpgradeError(fmt.Errorf("failed to fetch kubeadm-config: %v", err))
+		return d.returnNodeUpgradeError(constants.NodeErrorReasonKubeadmConfigInvalid, fmt.Errorf("failed to fetch kubeadm-config: %v", err))
 	}
 	if kubeadmConfigMap.Data == nil {
-		return d.returnNodeUpgradeError(fmt.Errorf("kubeadm configmap contains no data"))
+		return d.returnNodeUpgradeError(constants.NodeErrorReasonKubeadmConfigInvalid, fmt.Errorf("kubeadm configmap contains no data"))
 	}
-	var kubeadmConfig kubeadm.ClusterConfiguration
-	err = yaml.Unmarshal([]byte(kubeadmConfigMap.Data["ClusterConfiguration"]), &kubeadmConfig)
+	var kubeadmConfig constants.KubeadmClusterConfiguration
+	err = yaml.Unmarshal([]byte(kubeadmConfigMap.Data[constants.KubeadmClusterConfigurationKey]), &kubeadmConfig)
 	if err != nil {
-		return d.returnNodeUpgradeError(fmt.Errorf("failed to parse kubeadm-config: %v", err))
+		return d.returnNodeUpgradeError(constants.NodeErrorReasonKubeadmConfigInvalid, fmt.Errorf("failed to parse kubeadm-config: %v", err))
 	}
 
-	if version != kubeadmConfig.KubernetesVersion {
-		slog.Info("kubeadm-config kubernetesVersion does not match requested version, initializing upgrade", slog.String("kubernetesVersion", kubeadmConfig.KubernetesVersion), slog.String("version", version))
-		err = d.kubeadm.Apply(version)
-	} else {
-		slog.Debug("Cluster upgrade is already initialized, upgrading node")
-		err = d.kubeadm.Node()
-	}
+	err = d.retry(d.RetryConfig().KubeadmAttempts, func() error {
+		var err error
+		if version != kubeadmConfig.KubernetesVersion {
+			slog.Info("kubeadm-config kubernetesVersion does not match requested version, initializing upgrade", slog.String("kubernetesVersion", kubeadmConfig.KubernetesVersion), slog.String("version", version))
+			err = kubeadmCMD.Apply(version)
+		} else {
+			slog.Debug("Cluster upgrade is already initialized, upgrading node")
+			err = kubeadmCMD.Node()
+		}
+		if err != nil {
+			slog.Warn("Failed to run kubeadm", "err", err)
+		}
+		return err
+	})
 	if err != nil {
-		return d.returnNodeUpgradeError(fmt.Errorf("failed run kubeadm: %v", err))
+		return d.returnNodeUpgradeError(constants.NodeErrorReasonKubeadmUpgradeFailed, fmt.Errorf("failed run kubeadm: %w", err))
 	}
 
 	// Cleanup tmp directory created by kubeadm.
@@ -176,21 +303,168 @@ func (d *daemon) nodeKubeadmUpgrade(version string) error {
 	return nil
 }
 
-// Update the kube-upgrade node status annotation with the given status
+// Acquire kubeadm for the given version, either from the cache or from the configured source
+func (d *daemon) newKubeadm(version string) (*kubeadm.KubeadmCMD, error) {
+	cacheCfg := d.KubeadmCache()
+	api.SetObjectDefaults_KubeadmCacheConfig(&cacheCfg)
+	cache := kubeadm.NewCache(hostPrefix, kubeadmCacheDir, int(cacheCfg.MaxEntries), cacheCfg.MaxSize.Value())
+
+	source := d.kubeadmSource()
+	cmd := cache.Get(version, source)
+	if cmd != nil {
+		slog.Info("Using cached kubeadm", slog.String("version", version))
+		return cmd, nil
+	}
+
+	cmd, err := d.fetchKubeadm(version)
+	if err != nil {
+		return nil, err
+	}
+
+	cachedCMD, err := cache.Add(version, source, cmd)
+	if err != nil {
+		slog.Warn("Failed to add kubeadm to cache", slog.String("version", version), "err", err)
+		return cmd, nil
+	}
+	return cachedCMD, nil
+}
+
+// Describe where kubeadm is acquired from and how it is verified, so cached binaries are only
+// used as long as the configured source would have accepted them.
+func (d *daemon) kubeadmSource() string {
+	cfg := d.KubeadmDownload()
+	if cfg.Source == api.KubeadmSourceImage {
+		return strings.Join([]string{cfg.Source, d.Stream(), cfg.ImagePath}, " ")
+	}
+	return strings.Join([]string{api.KubeadmSourceURL, cfg.URL, cfg.Verification, cfg.CertificateIdentity, cfg.CertificateOIDCIssuer, cfg.RekorURL, cfg.RekorPublicKey}, " ")
+}
+
+// Fetch kubeadm for the given version from the configured source
+func (d *daemon) fetchKubeadm(version string) (*kubeadm.KubeadmCMD, error) {
+	cfg := d.KubeadmDownload()
+	if cfg.Source == api.KubeadmSourceImage {
+		// The binary is not verified on its own, so it needs to come from an image verified by the host
+		if d.allowUnsignedOstreeImages {
+			return nil, kubeadm.NewErrUnverifiedImage(d.Stream())
+		}
+		authFile, err := syncRegistryAuth()
+		if err != nil {
+			return nil, fmt.Errorf("failed to sync registry credentials: %v", err)
+		}
+		return kubeadm.NewFromImage(hostPrefix, d.Stream(), version, cfg.ImagePath, authFile)
+	}
+
+	opts, err := kubeadm.NewDownloadOptions(cfg, config.KubeadmDownloadSecretDir)
+	if err != nil {
+		return nil, err
+	}
+	return kubeadm.NewFromVersion(hostPrefix, version, opts)
+}
+
+// Install the signature policy for the stream on the host.
+// Unless unsigned images are allowed, ensures the host will verify the image for the given version when rebasing into it.
+func (d *daemon) syncSignaturePolicy(version string, rebase bool) error {
+	stream := d.Stream()
+
+	var req map[string]any
+	if cfg := d.SignaturePolicy(); cfg != nil {
+		req = policy.NewRequirement(*cfg)
+	}
+	err := policy.Install(hostPrefix, stream, req)
+	if err != nil {
+		return fmt.Errorf("failed to install signature policy: %v", err)
+	}
+
+	if d.allowUnsignedOstreeImages || !rebase {
+		return nil
+	}
+	return policy.CheckSigned(hostPrefix, stream+":"+version)
+}
+
+// Update the kube-upgrade node status annotation with the given status.
+// Marks the node as under maintenance while upgrading and clears it again once completed.
 func (d *daemon) updateNodeStatus(status string) error {
-	node, err := d.getNode()
+	err := d.updateNodeAnnotations(map[string]string{constants.NodeUpgradeStatus: status})
 	if err != nil {
 		return err
 	}
-	if node.Annotations == nil {
-		node.Annotations = make(map[string]string)
+	d.updateMaintenance(status)
+	return nil
+}
+
+// Set the given annotations on the node.
+// Records the time when the status changes, so the controller can detect stuck upgrades.
+// Retries on conflicts, as the node may be updated at the same time.
+func (d *daemon) updateNodeAnnotations(annotations map[string]string) error {
+	return retryOnConflict(func() error {
+		node, err := d.getNode()
+		if err != nil {
+			return err
+		}
+		if node.Annotations == nil {
+			node.Annotations = make(map[string]string)
+		}
+		if status, ok := annotations[constants.NodeUpgradeStatus]; ok && status != node.Annotations[constants.NodeUpgradeStatus] {
+			node.Annotations[constants.NodeStatusTime] = time.Now().UTC().Format(time.RFC3339)
+		}
+		maps.Copy(node.Annotations, annotations)
+
+		_, err = d.client.CoreV1().Nodes().Update(d.ctx, node, metav1.UpdateOptions{})
+		if err == nil {
+			slog.Debug("Set node annotations", slog.Any("annotations", annotations))
+		}
+		return err
+	})
+}
+
+// Run the update of the node again, when the node was modified concurrently
+func retryOnConflict(update func() error) error {
+	var err error
+	for range maxNodeUpdateConflicts {
+		err = update()
+		if !apierrors.IsConflict(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// Renew the heartbeat of the daemon on the node until the context is cancelled.
+// The controller uses it to detect nodes without a running daemon.
+func (d *daemon) renewHeartbeat() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		err := d.heartbeat()
+		if err != nil && d.ctx.Err() == nil {
+			slog.Error("Failed to renew heartbeat", "err", err, slog.String("node", d.node))
+		}
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
-	node.Annotations[constants.NodeUpgradeStatus] = status
+}
 
-	_, err = d.client.CoreV1().Nodes().Update(d.ctx, node, metav1.UpdateOptions{})
-	if err == nil {
-		slog.Debug("Set node status", slog.String("status", status))
+// Record that the daemon is alive, together with the version it is running.
+// Uses a merge patch of only these annotations, so the heartbeat neither needs to read the node
+// nor conflicts with other updates of it.
+func (d *daemon) heartbeat() error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]string{
+				constants.NodeHeartbeat:       time.Now().UTC().Format(time.RFC3339),
+				constants.NodeUpgradedVersion: version.Version(),
+			},
+		},
+	})
+	if err != nil {
+		return err
 	}
+	_, err = d.client.CoreV1().Nodes().Patch(d.ctx, d.node, types.MergePatchType, patch, metav1.PatchOptions{})
 	return err
 }
 
@@ -199,15 +473,80 @@ func (d *daemon) getNode() (*corev1.Node, error) {
 	return d.client.CoreV1().Nodes().Get(d.ctx, d.node, metav1.GetOptions{})
 }
 
-// Return the given error, but also set the node status to error
-func (d *daemon) returnNodeUpgradeError(err error) error {
-	statusErr := d.updateNodeStatus(constants.NodeUpgradeStatusError)
+// Return the given error, but also set the node status to error and record the error with its reason on the node.
+// The reason is one of the NodeErrorReason constants, so the controller can aggregate them.
+// The controller decides if and when the upgrade is retried.
+func (d *daemon) returnNodeUpgradeError(reason string, err error) error {
+	msg := err.Error()
+	if len(msg) > maxLastErrorLength {
+		msg = msg[:maxLastErrorLength] + "..."
+	}
+
+	statusErr := d.updateNodeAnnotations(map[string]string{
+		constants.NodeUpgradeStatus:   constants.NodeUpgradeStatusError,
+		constants.NodeLastError:       msg,
+		constants.NodeLastErrorReason: reason,
+		constants.NodeLastErrorTime:   time.Now().UTC().Format(time.RFC3339),
+	})
 	if statusErr != nil {
 		slog.Error("Failed to set node to error status", slog.Any("error", statusErr))
+		return err
+	}
+
+	var cmdErr *utils.CommandError
+	if errors.As(err, &cmdErr) {
+		eventErr := d.publishCommandOutput(reason, cmdErr)
+		if eventErr != nil {
+			slog.Error("Failed to publish the output of the failed command", slog.String("command", cmdErr.Command), "err", eventErr)
+		}
+	}
+	return &nodeUpgradeError{err: err}
+}
+
+// Publish the end of the output of a failed command as a warning Event for the node.
+// The full output is in the command log on the host.
+func (d *daemon) publishCommandOutput(reason string, cmdErr *utils.CommandError) error {
+	note := fmt.Sprintf("%s failed, see %s on the host for the full output:\n", cmdErr.Command, filepath.Join(commandLogDir, cmdErr.Command+".log"))
+	output := cmdErr.Output
+	if maxOutput := maxEventNoteLength - len(note); len(output) > maxOutput {
+		output = output[len(output)-maxOutput:]
 	}
+
+	event := &eventsv1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: d.node + ".",
+			Namespace:    metav1.NamespaceDefault,
+		},
+		EventTime:           metav1.NewMicroTime(time.Now()),
+		ReportingController: eventReportingController,
+		ReportingInstance:   d.node,
+		Action:              "Upgrade",
+		Reason:              reason,
+		Regarding: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Node",
+			Name:       d.node,
+		},
+		Note: note + output,
+		Type: corev1.EventTypeWarning,
+	}
+	_, err := d.client.EventsV1().Events(metav1.NamespaceDefault).Create(d.ctx, event, metav1.CreateOptions{})
 	return err
 }
 
+// Error that has been recorded on the node, the upgrade is not retried by the daemon
+type nodeUpgradeError struct {
+	err error
+}
+
+func (e *nodeUpgradeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *nodeUpgradeError) Unwrap() error {
+	return e.err
+}
+
 // Annotate the node with the current upgraded version
 func (d *daemon) annotateNodeWithUpgradedVersion(node *corev1.Node) (*corev1.Node, error) {
 	if node.Annotations == nil {
@@ -222,9 +561,19 @@ func (d *daemon) annotateNodeWithUpgradedVersion(node *corev1.Node) (*corev1.Nod
 	return d.client.CoreV1().Nodes().Update(d.ctx, node, metav1.UpdateOptions{})
 }
 
-// Check if the current image stream matches the requested one
+// Check if the node needs to upgrade kubernetes or rebase into the correct stream.
+// Nodes in error wait for the controller to retry the upgrade.
+func (d *daemon) nodeNeedsUpgradeOrRebase(node *corev1.Node) bool {
+	if node.Annotations[constants.NodeUpgradeStatus] == constants.NodeUpgradeStatusError {
+		return false
+	}
+	return nodeNeedsUpgrade(node) || !d.nodeHasCorrectStream(node)
+}
+
+// Check if the current image stream matches the requested one.
+// Nodes excluded from the upgrade always have the correct stream.
 func (d *daemon) nodeHasCorrectStream(node *corev1.Node) bool {
-	if node.Annotations == nil {
+	if node.Annotations == nil || nodeExcludedFromUpgrade(node) {
 		return true
 	}
 
@@ -2,12 +2,18 @@ package daemon
 
 import (
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
 	"github.com/heathcliff26/kube-upgrade/pkg/constants"
 	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/kubeadm"
+	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/policy"
 	rpmostree "github.com/heathcliff26/kube-upgrade/pkg/upgraded/rpm-ostree"
+	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/utils"
 	"github.com/heathcliff26/kube-upgrade/pkg/version"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -38,6 +44,60 @@ func TestDoNodeUpgradeWithRetry(t *testing.T) {
 	}
 }
 
+func TestDoNodeUpgradeWithRetryStopsOnError(t *testing.T) {
+	rpmOstreeCMD, err := rpmostree.New("testdata/exit-1.sh")
+	require.NoError(t, err, "Failed to create rpm-ostree command")
+
+	d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusRebasing)
+	d.rpmostree = rpmOstreeCMD
+	d.retryInterval = time.Millisecond
+
+	done := make(chan struct{}, 1)
+	go func() {
+		d.doNodeUpgradeWithRetry(node)
+		done <- struct{}{}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Should stop retrying once the node is set to error")
+	}
+
+	node, err = d.client.CoreV1().Nodes().Get(t.Context(), node.GetName(), metav1.GetOptions{})
+	require.NoError(t, err, "Should get node")
+	assert.Equal(t, constants.NodeUpgradeStatusError, node.Annotations[constants.NodeUpgradeStatus], "Should leave the node in error")
+	assert.False(t, d.nodeNeedsUpgradeOrRebase(node), "Should wait for the controller to retry")
+}
+
+func TestCheckNodeStatusReleasesLockOfExcludedNode(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	rpmOstreeCMD, err := rpmostree.New("testdata/exit-1.sh")
+	require.NoError(err, "Failed to create rpm-ostree command")
+
+	d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusRebasing)
+	d.rpmostree = rpmOstreeCMD
+
+	require.Error(d.doNodeUpgrade(node), "Should fail the upgrade")
+	_, lockHeld, _ := d.State()
+	require.True(lockHeld, "Should keep the lock when the node is in error")
+
+	node, err = d.client.CoreV1().Nodes().Get(t.Context(), node.GetName(), metav1.GetOptions{})
+	require.NoError(err, "Should get node")
+	assert.Equal("true", node.Annotations[constants.NodeLockHeld], "Should record the lock on the node")
+
+	node.Annotations[constants.NodeHold] = "true"
+	d.checkNodeStatus(node)
+
+	_, lockHeld, _ = d.State()
+	assert.False(lockHeld, "Should release the lock once the node is put on hold")
+	node, err = d.client.CoreV1().Nodes().Get(t.Context(), node.GetName(), metav1.GetOptions{})
+	require.NoError(err, "Should get node")
+	assert.Equal("false", node.Annotations[constants.NodeLockHeld], "Should record the release on the node")
+}
+
 func TestDoNodeUpgrade(t *testing.T) {
 	t.Run("LockAlreadyReserved", func(t *testing.T) {
 		assert := assert.New(t)
@@ -47,21 +107,38 @@ func TestDoNodeUpgrade(t *testing.T) {
 			srv.Close()
 		})
 
-		d := &daemon{
-			fleetlock: client,
-		}
-		node := &corev1.Node{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: "testnode",
-				Annotations: map[string]string{
-					constants.NodeKubernetesVersion: "v1.31.0",
-				},
-			},
-		}
+		d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusPending)
+		d.fleetlock = client
+		d.retryInterval = time.Millisecond
+		d.retryConfig = api.RetryConfig{LockAttempts: 2}
 
 		err := d.doNodeUpgrade(node)
 
-		assert.ErrorContains(err, "failed to acquire lock:")
+		node, _ = d.client.CoreV1().Nodes().Get(t.Context(), node.GetName(), metav1.GetOptions{})
+
+		assert.ErrorContains(err, "failed to acquire lock: giving up after 2 attempts:")
+		assert.Equal(constants.NodeUpgradeStatusError, node.Annotations[constants.NodeUpgradeStatus], "Should set the node to error after the last attempt")
+		assert.Equal(constants.NodeErrorReasonLockFailed, node.Annotations[constants.NodeLastErrorReason], "Should record the reason of the error")
+	})
+	t.Run("RetryRebase", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		rpmOstreeCMD, err := rpmostree.New("testdata/exit-1.sh")
+		require.NoError(err, "Failed to create rpm-ostree command")
+
+		d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusRebasing)
+		d.rpmostree = rpmOstreeCMD
+		d.retryInterval = time.Millisecond
+		d.retryConfig = api.RetryConfig{RebaseAttempts: 3}
+
+		err = d.doNodeUpgrade(node)
+
+		node, _ = d.client.CoreV1().Nodes().Get(t.Context(), node.GetName(), metav1.GetOptions{})
+
+		assert.ErrorContains(err, "failed to rebase node: giving up after 3 attempts:", "Should retry the rebase")
+		assert.Equal(constants.NodeUpgradeStatusError, node.Annotations[constants.NodeUpgradeStatus], "Should set the node to error after the last attempt")
+		assert.Contains(node.Annotations[constants.NodeLastError], "giving up after 3 attempts", "Should record the reason on the node")
 	})
 	t.Run("FailedOstreeRebase", func(t *testing.T) {
 		assert := assert.New(t)
@@ -79,6 +156,48 @@ func TestDoNodeUpgrade(t *testing.T) {
 
 		assert.Error(err, "Should exit with error")
 		assert.Equal(constants.NodeUpgradeStatusError, node.Annotations[constants.NodeUpgradeStatus], "Should have set correct node status")
+		assert.Equal(err.Error(), node.Annotations[constants.NodeLastError], "Should record the error on the node")
+		assert.Equal(constants.NodeErrorReasonRebaseFailed, node.Annotations[constants.NodeLastErrorReason], "Should record the reason of the error")
+		_, timeErr := time.Parse(time.RFC3339, node.Annotations[constants.NodeLastErrorTime])
+		assert.NoError(timeErr, "Should record the time of the error")
+	})
+	t.Run("PublishCommandOutput", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		rpmOstreeCMD, err := rpmostree.New("testdata/rebase-fail.sh")
+		require.NoError(err, "Failed to create rpm-ostree command")
+
+		d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusRebasing)
+		d.rpmostree = rpmOstreeCMD
+
+		err = d.doNodeUpgrade(node)
+		assert.Error(err, "Should exit with error")
+
+		events, err := d.client.EventsV1().Events(metav1.NamespaceDefault).List(t.Context(), metav1.ListOptions{})
+		require.NoError(err, "Should list events")
+		require.Len(events.Items, 1, "Should publish an event")
+		event := events.Items[0]
+		assert.Equal(corev1.EventTypeWarning, event.Type, "Should be a warning")
+		assert.Equal(constants.NodeErrorReasonRebaseFailed, event.Reason, "Should use the error reason")
+		assert.Equal("Node", event.Regarding.Kind, "Should be about the node")
+		assert.Equal(node.GetName(), event.Regarding.Name, "Should be about the node")
+		assert.Contains(event.Note, "/var/log/kube-upgraded/rpm-ostree.log", "Should point to the full log")
+		assert.Contains(event.Note, "error: Failed to pull image: manifest unknown", "Should contain the output of the command")
+	})
+	t.Run("TruncateCommandOutput", func(t *testing.T) {
+		assert := assert.New(t)
+
+		d, _ := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusRebasing)
+
+		output := strings.Repeat("a", 2*maxEventNoteLength) + "the end"
+		err := d.publishCommandOutput(constants.NodeErrorReasonKubeadmUpgradeFailed, &utils.CommandError{Command: "kubeadm", Output: output})
+		assert.NoError(err, "Should publish the event")
+
+		events, _ := d.client.EventsV1().Events(metav1.NamespaceDefault).List(t.Context(), metav1.ListOptions{})
+		require.Len(t, events.Items, 1, "Should publish an event")
+		assert.Len(events.Items[0].Note, maxEventNoteLength, "Should respect the size limit of the note")
+		assert.True(strings.HasSuffix(events.Items[0].Note, "the end"), "Should keep the end of the output")
 	})
 	t.Run("SucceededOstreeRebase", func(t *testing.T) {
 		assert := assert.New(t)
@@ -102,11 +221,11 @@ func TestDoNodeUpgrade(t *testing.T) {
 
 		oldHostPrefix := hostPrefix
 		hostPrefix = ""
-		oldCosignBinary := kubeadm.CosignBinary
-		kubeadm.CosignBinary = "../../../bin/cosign"
+		oldKubeadmCacheDir := kubeadmCacheDir
+		kubeadmCacheDir = t.TempDir()
 		t.Cleanup(func() {
 			hostPrefix = oldHostPrefix
-			kubeadm.CosignBinary = oldCosignBinary
+			kubeadmCacheDir = oldKubeadmCacheDir
 		})
 
 		d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusPending)
@@ -118,6 +237,183 @@ func TestDoNodeUpgrade(t *testing.T) {
 		assert.ErrorContains(err, "failed to fetch kubeadm-config", "Should fail to fetch kubeadm config map")
 		assert.Equal(constants.NodeUpgradeStatusError, node.Annotations[constants.NodeUpgradeStatus], "Should have set correct node status")
 	})
+	t.Run("KubeadmUpgradeFailed", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		binary, err := filepath.Abs("testdata/fake-kubeadm-fail.sh")
+		require.NoError(err, "Should resolve fake kubeadm path")
+		kubeadmCMD, err := kubeadm.NewFromPath("", binary)
+		require.NoError(err, "Should create kubeadm command")
+
+		d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusPending)
+		d.kubeadm = kubeadmCMD
+		_, err = d.client.CoreV1().ConfigMaps(constants.KubeadmConfigMapNamespace).Create(t.Context(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      constants.KubeadmConfigMapName,
+				Namespace: constants.KubeadmConfigMapNamespace,
+			},
+			Data: map[string]string{
+				constants.KubeadmClusterConfigurationKey: "kubernetesVersion: v1.34.2",
+			},
+		}, metav1.CreateOptions{})
+		require.NoError(err, "Should create kubeadm-config")
+
+		err = d.doNodeUpgrade(node)
+
+		node, _ = d.client.CoreV1().Nodes().Get(t.Context(), node.GetName(), metav1.GetOptions{})
+
+		assert.ErrorContains(err, "failed run kubeadm: exit status 1", "Should report the exit code of kubeadm")
+		assert.Equal(constants.NodeUpgradeStatusError, node.Annotations[constants.NodeUpgradeStatus], "Should have set correct node status")
+		assert.Equal(constants.NodeErrorReasonKubeadmUpgradeFailed, node.Annotations[constants.NodeLastErrorReason], "Should record the reason of the error")
+	})
+	t.Run("UnverifiableStream", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		oldHostPrefix := hostPrefix
+		hostPrefix = t.TempDir()
+		t.Cleanup(func() {
+			hostPrefix = oldHostPrefix
+		})
+		require.NoError(os.MkdirAll(hostPrefix+"/etc/containers", 0755))
+		require.NoError(os.WriteFile(hostPrefix+policy.PolicyFile, []byte(`{"default":[{"type":"insecureAcceptAnything"}]}`), 0644))
+
+		d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusPending)
+		d.stream = "registry.example.com/fcos-k8s"
+		d.allowUnsignedOstreeImages = false
+
+		err := d.doNodeUpgrade(node)
+
+		node, _ = d.client.CoreV1().Nodes().Get(t.Context(), node.GetName(), metav1.GetOptions{})
+
+		assert.ErrorContains(err, "stream can't be verified by the host: the policy for registry.example.com/fcos-k8s:v1.35.0 (scope \"default\") accepts unsigned images", "Should fail before upgrading")
+		assert.Equal(constants.NodeUpgradeStatusError, node.Annotations[constants.NodeUpgradeStatus], "Should have set correct node status")
+		assert.Equal(constants.NodeErrorReasonStreamUnverifiable, node.Annotations[constants.NodeLastErrorReason], "Should record the reason of the error")
+	})
+	t.Run("UnverifiableStreamWithoutRebase", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		oldHostPrefix := hostPrefix
+		hostPrefix = t.TempDir()
+		t.Cleanup(func() {
+			hostPrefix = oldHostPrefix
+		})
+		require.NoError(os.MkdirAll(hostPrefix+"/etc/containers", 0755))
+		require.NoError(os.WriteFile(hostPrefix+policy.PolicyFile, []byte(`{"default":[{"type":"insecureAcceptAnything"}]}`), 0644))
+
+		d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusRebasing)
+		d.stream = "registry.example.com/fcos-k8s"
+		d.bootedImageRef = "ostree-unverified-registry:" + d.stream + ":v1.35.0"
+		d.allowUnsignedOstreeImages = false
+
+		err := d.doNodeUpgrade(node)
+
+		node, _ = d.client.CoreV1().Nodes().Get(t.Context(), node.GetName(), metav1.GetOptions{})
+
+		assert.NoError(err, "Should only verify the stream when rebasing into it")
+		assert.Equal(constants.NodeUpgradeStatusCompleted, node.Annotations[constants.NodeUpgradeStatus], "Should complete the upgrade")
+	})
+	t.Run("InstallSignaturePolicy", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		oldHostPrefix := hostPrefix
+		hostPrefix = t.TempDir()
+		t.Cleanup(func() {
+			hostPrefix = oldHostPrefix
+		})
+		require.NoError(os.MkdirAll(hostPrefix+"/etc/containers", 0755))
+		require.NoError(os.WriteFile(hostPrefix+policy.PolicyFile, []byte(`{"default":[{"type":"insecureAcceptAnything"}]}`), 0644))
+
+		rpmOstreeCMD, err := rpmostree.New("testdata/exit-0.sh")
+		require.NoError(err, "Failed to create rpm-ostree command")
+
+		d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusRebasing)
+		d.rpmostree = rpmOstreeCMD
+		d.stream = "registry.example.com/fcos-k8s"
+		d.allowUnsignedOstreeImages = false
+		d.signaturePolicy = &api.SignaturePolicy{
+			Keyless: &api.KeylessSignaturePolicy{
+				SubjectEmail: "release@example.com",
+				OIDCIssuer:   "https://accounts.example.com",
+			},
+		}
+
+		err = d.doNodeUpgrade(node)
+
+		assert.NoError(err, "Should rebase with the installed policy")
+		assert.FileExists(hostPrefix+policy.RegistriesFile, "Should have enabled sigstore signatures")
+	})
+	t.Run("CachedKubeadm", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		oldHostPrefix := hostPrefix
+		hostPrefix = ""
+		oldKubeadmCacheDir := kubeadmCacheDir
+		kubeadmCacheDir = t.TempDir()
+		t.Cleanup(func() {
+			hostPrefix = oldHostPrefix
+			kubeadmCacheDir = oldKubeadmCacheDir
+		})
+
+		data, err := os.ReadFile("testdata/fake-kubeadm.sh")
+		require.NoError(err, "Should read fake kubeadm")
+		binary := filepath.Join(t.TempDir(), "kubeadm")
+		require.NoError(os.WriteFile(binary, data, 0755), "Should write fake kubeadm")
+		cmd, err := kubeadm.NewFromPath("", binary)
+		require.NoError(err, "Should create kubeadm command")
+		d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusPending)
+		_, err = kubeadm.NewCache("", kubeadmCacheDir, 3, 1<<20).Add("v1.35.0", d.kubeadmSource(), cmd)
+		require.NoError(err, "Should add kubeadm to cache")
+
+		err = d.doNodeUpgrade(node)
+
+		node, _ = d.client.CoreV1().Nodes().Get(t.Context(), node.GetName(), metav1.GetOptions{})
+
+		assert.ErrorContains(err, "failed to fetch kubeadm-config", "Should use the cached kubeadm without downloading it")
+		assert.Equal(constants.NodeErrorReasonKubeadmConfigInvalid, node.Annotations[constants.NodeLastErrorReason], "Should record the reason of the error")
+	})
+}
+
+func TestKubeadmSource(t *testing.T) {
+	assert := assert.New(t)
+
+	d := &daemon{stream: "ghcr.io/heathcliff26/fcos-k8s"}
+	d.kubeadmDownload = api.KubeadmDownloadConfig{Source: api.KubeadmSourceURL, URL: api.DefaultKubeadmDownloadURL, Verification: api.KubeadmVerificationSignature}
+	signature := d.kubeadmSource()
+
+	d.kubeadmDownload.Verification = api.KubeadmVerificationChecksum
+	checksum := d.kubeadmSource()
+	assert.NotEqual(signature, checksum, "Should differ by verification")
+
+	d.kubeadmDownload.Verification = api.KubeadmVerificationSignature
+	d.kubeadmDownload.RekorURL = "https://rekor.example.com"
+	assert.NotEqual(signature, d.kubeadmSource(), "Should differ by rekor instance")
+
+	d.kubeadmDownload = api.KubeadmDownloadConfig{Source: api.KubeadmSourceImage, ImagePath: api.DefaultKubeadmImagePath}
+	image := d.kubeadmSource()
+	assert.NotEqual(signature, image, "Should differ by source")
+
+	d.stream = "ghcr.io/example/fcos-k8s"
+	assert.NotEqual(image, d.kubeadmSource(), "Should differ by stream for images")
+}
+
+func TestFetchKubeadmFromUnverifiedImage(t *testing.T) {
+	assert := assert.New(t)
+
+	d := &daemon{
+		stream:                    "ghcr.io/heathcliff26/fcos-k8s",
+		allowUnsignedOstreeImages: true,
+		kubeadmDownload:           api.KubeadmDownloadConfig{Source: api.KubeadmSourceImage},
+	}
+
+	cmd, err := d.fetchKubeadm("v1.35.2")
+
+	assert.Nil(cmd, "Should not return kubeadm")
+	assert.True(kubeadm.IsVerificationError(err), "Should refuse to extract kubeadm from unverified images")
 }
 
 func TestUpdateNodeStatus(t *testing.T) {
@@ -175,9 +471,35 @@ func TestUpdateNodeStatus(t *testing.T) {
 				assert.NoError(d.updateNodeStatus("new-status"), "Should succeed")
 				node, _ := c.CoreV1().Nodes().Get(ctx, d.node, metav1.GetOptions{})
 				assert.Equal("new-status", node.GetAnnotations()[constants.NodeUpgradeStatus], "Should have set status")
+				_, err := time.Parse(time.RFC3339, node.GetAnnotations()[constants.NodeStatusTime])
+				assert.NoError(err, "Should record the time of the status change")
 			}
 		})
 	}
+	t.Run("UnchangedStatusKeepsTime", func(t *testing.T) {
+		assert := assert.New(t)
+
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "testnode",
+				Annotations: map[string]string{
+					constants.NodeUpgradeStatus: constants.NodeUpgradeStatusUpgrading,
+					constants.NodeStatusTime:    "2026-01-01T00:00:00Z",
+				},
+			},
+		}
+		c := fake.NewClientset(node)
+		d := &daemon{
+			client: c,
+			ctx:    t.Context(),
+			node:   node.GetName(),
+		}
+
+		assert.NoError(d.updateNodeStatus(constants.NodeUpgradeStatusUpgrading), "Should succeed")
+
+		node, _ = c.CoreV1().Nodes().Get(t.Context(), d.node, metav1.GetOptions{})
+		assert.Equal("2026-01-01T00:00:00Z", node.GetAnnotations()[constants.NodeStatusTime], "Should only record the time when the status changes")
+	})
 }
 
 func TestAnnotateNodeWithUpgradedVersion(t *testing.T) {
@@ -215,12 +537,47 @@ func TestAnnotateNodeWithUpgradedVersion(t *testing.T) {
 	assert.NoError(err, "Should not update node when version already matches")
 }
 
+func TestHeartbeat(t *testing.T) {
+	assert := assert.New(t)
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "testnode",
+			Annotations: map[string]string{
+				constants.NodeUpgradeStatus:   constants.NodeUpgradeStatusUpgrading,
+				constants.NodeStatusTime:      "2026-01-01T00:00:00Z",
+				constants.NodeUpgradedVersion: "old-version",
+			},
+		},
+	}
+	d := &daemon{
+		ctx:    t.Context(),
+		client: fake.NewClientset(node),
+		node:   node.GetName(),
+	}
+
+	assert.NoError(d.heartbeat(), "Should succeed")
+
+	actions := d.client.(*fake.Clientset).Actions()
+	if assert.Len(actions, 1, "Should only send a single request") {
+		assert.Equal("patch", actions[0].GetVerb(), "Should patch the node instead of updating it")
+	}
+
+	node, _ = d.client.CoreV1().Nodes().Get(t.Context(), d.node, metav1.GetOptions{})
+	heartbeat, err := time.Parse(time.RFC3339, node.Annotations[constants.NodeHeartbeat])
+	assert.NoError(err, "Should record the time of the heartbeat")
+	assert.WithinDuration(time.Now(), heartbeat, time.Minute, "Should record the current time")
+	assert.Equal(version.Version(), node.Annotations[constants.NodeUpgradedVersion], "Should report the running version")
+	assert.Equal("2026-01-01T00:00:00Z", node.Annotations[constants.NodeStatusTime], "Should not change the time of the last status change")
+}
+
 func TestNodeHasCorrectStream(t *testing.T) {
 	tMatrix := []struct {
 		Name           string
 		Version        string
 		Stream         string
 		BootedImageRef string
+		Hold           bool
 		Result         bool
 	}{
 		{
@@ -242,6 +599,14 @@ func TestNodeHasCorrectStream(t *testing.T) {
 			Stream:         "registry.example.com/fcos-k8s",
 			BootedImageRef: "ostree-unverified-registry:registry.example.com/fcos-k8s:v1.33.5",
 		},
+		{
+			Name:           "OnHold",
+			Version:        "v1.34.2",
+			Stream:         "registry.example.com/fcos-k8s",
+			BootedImageRef: "ostree-unverified-registry:registry.example.com/fcos-k8s:v1.33.5",
+			Hold:           true,
+			Result:         true,
+		},
 		{
 			Name:           "MissingVersionAnnotation",
 			Version:        "v1.34.2",
@@ -267,6 +632,10 @@ func TestNodeHasCorrectStream(t *testing.T) {
 				},
 			}
 
+			if tCase.Hold {
+				node.Annotations[constants.NodeHold] = "true"
+			}
+
 			assert.Equal(tCase.Result, d.nodeHasCorrectStream(node), "Should return correct result")
 		})
 	}
@@ -295,6 +664,8 @@ func newTestDoNodeUpgradeSetup(t *testing.T, nodeStatus string) (*daemon, *corev
 		fleetlock: client,
 		client:    fake.NewClientset(node),
 		node:      node.GetName(),
+		// Skip the signature policy check, as there is no policy on the host in tests
+		allowUnsignedOstreeImages: true,
 	}
 
 	return d, node
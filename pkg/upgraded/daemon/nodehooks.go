@@ -0,0 +1,169 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/config"
+	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/utils"
+)
+
+// The ConfigMap with the node hooks mounted by the controller
+var nodeHooksConfigMapDir = config.NodeHooksConfigMapDir
+
+// Points during the upgrade at which the node hooks are run
+const (
+	// Before the fleetlock is acquired
+	nodeHookPreLock = "pre-lock"
+	// Before kubeadm upgrades the node
+	nodeHookPreKubeadm = "pre-kubeadm"
+	// Before rpm-ostree rebases or upgrades the node, which reboots it
+	nodeHookPreReboot = "pre-reboot"
+	// After the node booted into the image of the new kubernetes version
+	nodeHookPostBoot = "post-boot"
+)
+
+// Environment variables passed to the node hooks
+const (
+	nodeHookEnvPhase   = "KUBE_UPGRADE_HOOK_PHASE"
+	nodeHookEnvVersion = "KUBE_UPGRADE_VERSION"
+	nodeHookEnvNode    = "KUBE_UPGRADE_NODE"
+)
+
+// Name of the command log for the output of the node hooks
+const nodeHookCommandLog = "hooks"
+
+// A command run as node hook
+type nodeHook struct {
+	name string
+	path string
+	args []string
+}
+
+// Run the node hooks for the given phase of a kubernetes upgrade.
+// Sets the node to error when a hook fails and the failure policy demands it.
+// Otherwise the upgrade is aborted and the lock released, so other nodes are not blocked until the next check.
+func (d *daemon) runNodeUpgradeHooks(phase, version string) error {
+	err := d.runNodeHooks(phase, version)
+	if err == nil {
+		return nil
+	}
+	if d.NodeHooks().FailurePolicy == api.NodeHooksFailurePolicyError {
+		return d.returnNodeUpgradeError(constants.NodeErrorReasonHookFailed, err)
+	}
+
+	if _, lockHeld, _ := d.State(); lockHeld {
+		slog.Info("Releasing lock after the node upgrade was aborted by a node hook", slog.String("phase", phase))
+		d.releaseLock()
+	}
+	return &nodeUpgradeAbortedError{err: err}
+}
+
+// Error of a node hook that aborted the upgrade, the upgrade is not retried until the next check
+type nodeUpgradeAbortedError struct {
+	err error
+}
+
+func (e *nodeUpgradeAbortedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *nodeUpgradeAbortedError) Unwrap() error {
+	return e.err
+}
+
+// Run all node hooks for the given phase in the host chroot, stopping at the first failure.
+// Failures are only logged when the failure policy ignores them.
+// The version is empty for os upgrades.
+func (d *daemon) runNodeHooks(phase, version string) error {
+	cfg := d.NodeHooks()
+	ignore := cfg.FailurePolicy == api.NodeHooksFailurePolicyIgnore
+
+	hooks, err := listNodeHooks(cfg)
+	if err != nil {
+		err = fmt.Errorf("failed to list node hooks: %v", err)
+		if !ignore {
+			return err
+		}
+		slog.Warn("Ignoring failed node hooks", slog.String("phase", phase), "err", err)
+	}
+
+	env := append(os.Environ(),
+		nodeHookEnvPhase+"="+phase,
+		nodeHookEnvVersion+"="+version,
+		nodeHookEnvNode+"="+d.node,
+	)
+	for _, hook := range hooks {
+		slog.Info("Running node hook", slog.String("hook", hook.name), slog.String("phase", phase))
+
+		ctx, cancel := context.WithTimeout(d.ctx, d.NodeHooksTimeout())
+		cmd := utils.CreateChrootCMDWithContext(ctx, hostPrefix, hook.path, hook.args...)
+		cmd.Env = env
+		err := utils.RunWithLog(nodeHookCommandLog, cmd)
+		cancel()
+		if err == nil {
+			continue
+		}
+
+		err = fmt.Errorf("node hook %s failed in phase %s: %w", hook.name, phase, err)
+		if !ignore {
+			return err
+		}
+		slog.Warn("Ignoring failed node hook", slog.String("hook", hook.name), slog.String("phase", phase), "err", err)
+	}
+	return nil
+}
+
+// Return the executables in the hook directory on the host, followed by the scripts of the ConfigMap, each in lexical order.
+// Missing directories are treated as empty, so the same config can be used for nodes with and without hooks.
+func listNodeHooks(cfg api.NodeHooksConfig) ([]nodeHook, error) {
+	hooks := make([]nodeHook, 0)
+
+	if cfg.Dir != "" {
+		entries, err := os.ReadDir(hostPrefix + cfg.Dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			path := filepath.Join(cfg.Dir, entry.Name())
+			info, err := os.Stat(hostPrefix + path)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			if utils.CheckExistsAndIsExecutable(hostPrefix+path) != nil {
+				slog.Debug("Skipping node hook that is not executable", slog.String("path", path))
+				continue
+			}
+			hooks = append(hooks, nodeHook{name: entry.Name(), path: path})
+		}
+	}
+
+	if cfg.ConfigMap != "" {
+		entries, err := os.ReadDir(nodeHooksConfigMapDir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			// Skip the symlinks kubernetes uses to update the files atomically
+			if strings.HasPrefix(entry.Name(), ".") || entry.IsDir() {
+				continue
+			}
+			// #nosec G304: The files are provided by the ConfigMap mounted by the controller
+			script, err := os.ReadFile(filepath.Join(nodeHooksConfigMapDir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			hooks = append(hooks, nodeHook{name: entry.Name(), path: "/bin/sh", args: []string{"-c", string(script), entry.Name()}})
+		}
+	}
+
+	return hooks, nil
+}
@@ -0,0 +1,269 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/heathcliff26/kube-upgrade/pkg/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestListNodeHooks(t *testing.T) {
+	t.Run("DirAndConfigMap", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		dir := setNodeHooksTestDirs(t)
+		writeNodeHook(t, dir+"/20-second", "exit 0")
+		writeNodeHook(t, dir+"/10-first", "exit 0")
+		require.NoError(os.WriteFile(dir+"/README", []byte("not a hook"), 0644))
+		require.NoError(os.Mkdir(dir+"/subdir", 0755))
+		require.NoError(os.WriteFile(filepath.Join(nodeHooksConfigMapDir, "30-script"), []byte("echo test"), 0644))
+		require.NoError(os.Mkdir(filepath.Join(nodeHooksConfigMapDir, "..data"), 0755))
+
+		hooks, err := listNodeHooks(api.NodeHooksConfig{Dir: dir, ConfigMap: "hooks"})
+
+		require.NoError(err, "Should list the hooks")
+		assert.Equal([]nodeHook{
+			{name: "10-first", path: filepath.Join(dir, "10-first")},
+			{name: "20-second", path: filepath.Join(dir, "20-second")},
+			{name: "30-script", path: "/bin/sh", args: []string{"-c", "echo test", "30-script"}},
+		}, hooks, "Should return the executables of the dir followed by the scripts of the ConfigMap")
+	})
+	t.Run("ConfigMapNotConfigured", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		setNodeHooksTestDirs(t)
+		require.NoError(os.WriteFile(filepath.Join(nodeHooksConfigMapDir, "script"), []byte("echo test"), 0644))
+
+		hooks, err := listNodeHooks(api.NodeHooksConfig{})
+
+		assert.NoError(err, "Should not return an error")
+		assert.Empty(hooks, "Should ignore the ConfigMap dir when no ConfigMap is configured")
+	})
+	t.Run("MissingDirs", func(t *testing.T) {
+		assert := assert.New(t)
+
+		setNodeHooksTestDirs(t)
+		nodeHooksConfigMapDir = filepath.Join(t.TempDir(), "missing")
+
+		hooks, err := listNodeHooks(api.NodeHooksConfig{Dir: "/missing", ConfigMap: "hooks"})
+
+		assert.NoError(err, "Should treat missing dirs as empty")
+		assert.Empty(hooks, "Should not return any hooks")
+	})
+}
+
+func TestRunNodeHooks(t *testing.T) {
+	t.Run("Environment", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		dir := setNodeHooksTestDirs(t)
+		out := filepath.Join(t.TempDir(), "out")
+		writeNodeHook(t, dir+"/env", "echo \"$KUBE_UPGRADE_HOOK_PHASE $KUBE_UPGRADE_VERSION $KUBE_UPGRADE_NODE\" >> "+out)
+		require.NoError(os.WriteFile(filepath.Join(nodeHooksConfigMapDir, "script"), []byte("echo \"script $KUBE_UPGRADE_HOOK_PHASE\" >> "+out), 0644))
+
+		d := newTestNodeHooksDaemon(t, dir, api.NodeHooksFailurePolicyError)
+
+		err := d.runNodeHooks(nodeHookPreKubeadm, "v1.35.0")
+
+		require.NoError(err, "Should run the hooks")
+		data, err := os.ReadFile(out)
+		require.NoError(err, "Hooks should have written the output")
+		assert.Equal("pre-kubeadm v1.35.0 testnode\nscript pre-kubeadm\n", string(data), "Should run all hooks in order with the phase and version")
+	})
+	t.Run("StopOnFailure", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := setNodeHooksTestDirs(t)
+		out := filepath.Join(t.TempDir(), "out")
+		writeNodeHook(t, dir+"/10-fail", "exit 1")
+		writeNodeHook(t, dir+"/20-touch", "touch "+out)
+
+		d := newTestNodeHooksDaemon(t, dir, api.NodeHooksFailurePolicyAbort)
+
+		err := d.runNodeHooks(nodeHookPreLock, "v1.35.0")
+
+		assert.ErrorContains(err, "node hook 10-fail failed in phase pre-lock", "Should return the failed hook")
+		assert.NoFileExists(out, "Should not run the remaining hooks")
+	})
+	t.Run("IgnoreFailure", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := setNodeHooksTestDirs(t)
+		out := filepath.Join(t.TempDir(), "out")
+		writeNodeHook(t, dir+"/10-fail", "exit 1")
+		writeNodeHook(t, dir+"/20-touch", "touch "+out)
+
+		d := newTestNodeHooksDaemon(t, dir, api.NodeHooksFailurePolicyIgnore)
+
+		err := d.runNodeHooks(nodeHookPreReboot, "v1.35.0")
+
+		assert.NoError(err, "Should ignore the failed hook")
+		assert.FileExists(out, "Should run the remaining hooks")
+	})
+	t.Run("Timeout", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := setNodeHooksTestDirs(t)
+		writeNodeHook(t, dir+"/sleep", "sleep 10")
+
+		d := newTestNodeHooksDaemon(t, dir, api.NodeHooksFailurePolicyAbort)
+		d.nodeHooksTimeout = 100 * time.Millisecond
+
+		start := time.Now()
+		err := d.runNodeHooks(nodeHookPostBoot, "v1.35.0")
+
+		assert.ErrorContains(err, "node hook sleep failed in phase post-boot", "Should fail the hook after the timeout")
+		assert.Less(time.Since(start), 5*time.Second, "Should kill the hook after the timeout")
+	})
+}
+
+func TestDoNodeUpgradeNodeHooks(t *testing.T) {
+	t.Run("FailurePolicyError", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := setNodeHooksTestDirs(t)
+		writeNodeHook(t, dir+"/fail", "exit 1")
+
+		d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusPending)
+		d.nodeHooks = api.NodeHooksConfig{Dir: dir, FailurePolicy: api.NodeHooksFailurePolicyError}
+		d.nodeHooksTimeout = time.Minute
+
+		err := d.doNodeUpgrade(node)
+
+		node, _ = d.client.CoreV1().Nodes().Get(t.Context(), node.GetName(), metav1.GetOptions{})
+
+		assert.ErrorContains(err, "node hook fail failed in phase pre-lock", "Should fail before acquiring the lock")
+		assert.Equal(constants.NodeUpgradeStatusError, node.Annotations[constants.NodeUpgradeStatus], "Should set the node to error")
+		assert.Equal(constants.NodeErrorReasonHookFailed, node.Annotations[constants.NodeLastErrorReason], "Should record the reason of the error")
+	})
+	t.Run("FailurePolicyAbort", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := setNodeHooksTestDirs(t)
+		writeNodeHook(t, dir+"/fail", "exit 1")
+
+		d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusPending)
+		d.nodeHooks = api.NodeHooksConfig{Dir: dir, FailurePolicy: api.NodeHooksFailurePolicyAbort}
+		d.nodeHooksTimeout = time.Minute
+
+		err := d.doNodeUpgrade(node)
+
+		node, _ = d.client.CoreV1().Nodes().Get(t.Context(), node.GetName(), metav1.GetOptions{})
+
+		assert.ErrorContains(err, "node hook fail failed in phase pre-lock", "Should fail before acquiring the lock")
+		assert.Equal(constants.NodeUpgradeStatusPending, node.Annotations[constants.NodeUpgradeStatus], "Should not change the node status")
+	})
+	t.Run("FailurePolicyAbortReleasesLock", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := setNodeHooksTestDirs(t)
+		// Only fail after the lock has been acquired
+		writeNodeHook(t, dir+"/fail", "test \"$KUBE_UPGRADE_HOOK_PHASE\" != pre-kubeadm")
+
+		d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusPending)
+		d.nodeHooks = api.NodeHooksConfig{Dir: dir, FailurePolicy: api.NodeHooksFailurePolicyAbort}
+		d.nodeHooksTimeout = time.Minute
+
+		done := make(chan struct{})
+		go func() {
+			d.doNodeUpgradeWithRetry(node)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("Should not retry the aborted upgrade")
+		}
+
+		node, _ = d.client.CoreV1().Nodes().Get(t.Context(), node.GetName(), metav1.GetOptions{})
+
+		_, lockHeld, _ := d.State()
+		assert.False(lockHeld, "Should release the lock")
+		assert.Equal(constants.NodeUpgradeStatusPending, node.Annotations[constants.NodeUpgradeStatus], "Should not change the node status")
+	})
+	t.Run("PostBoot", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		dir := setNodeHooksTestDirs(t)
+		out := filepath.Join(t.TempDir(), "out")
+		writeNodeHook(t, dir+"/phase", "echo $KUBE_UPGRADE_HOOK_PHASE >> "+out)
+
+		d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusRebasing)
+		d.nodeHooks = api.NodeHooksConfig{Dir: dir, FailurePolicy: api.NodeHooksFailurePolicyError}
+		d.nodeHooksTimeout = time.Minute
+		d.stream = "registry.example.org/fcos-k8s"
+		d.bootedImageRef = "ostree-unverified-registry:" + d.stream + ":v1.35.0"
+
+		err := d.doNodeUpgrade(node)
+
+		require.NoError(err, "Should finish the upgrade")
+		data, err := os.ReadFile(out)
+		require.NoError(err, "Hook should have written the output")
+		assert.Equal("post-boot\n", string(data), "Should only run the post-boot hooks after the reboot")
+
+		node, _ = d.client.CoreV1().Nodes().Get(t.Context(), node.GetName(), metav1.GetOptions{})
+		assert.Equal(constants.NodeUpgradeStatusCompleted, node.Annotations[constants.NodeUpgradeStatus], "Should complete the upgrade")
+	})
+	t.Run("PostBootVerifying", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := setNodeHooksTestDirs(t)
+		writeNodeHook(t, dir+"/fail", "exit 1")
+
+		d, node := newTestDoNodeUpgradeSetup(t, constants.NodeUpgradeStatusRebasing)
+		d.nodeHooks = api.NodeHooksConfig{Dir: dir, FailurePolicy: api.NodeHooksFailurePolicyAbort}
+		d.nodeHooksTimeout = time.Minute
+		d.stream = "registry.example.org/fcos-k8s"
+		d.bootedImageRef = "ostree-unverified-registry:" + d.stream + ":v1.35.0"
+
+		err := d.doNodeUpgrade(node)
+
+		node, _ = d.client.CoreV1().Nodes().Get(t.Context(), node.GetName(), metav1.GetOptions{})
+
+		assert.ErrorContains(err, "node hook fail failed in phase post-boot", "Should fail the verification")
+		assert.Equal(constants.NodeUpgradeStatusVerifying, node.Annotations[constants.NodeUpgradeStatus], "Should be verifying the node after the reboot")
+	})
+}
+
+// Run the node hooks without chroot and return a temporary hook dir
+func setNodeHooksTestDirs(t *testing.T) string {
+	t.Helper()
+
+	oldHostPrefix := hostPrefix
+	oldNodeHooksConfigMapDir := nodeHooksConfigMapDir
+	hostPrefix = ""
+	nodeHooksConfigMapDir = t.TempDir()
+	t.Cleanup(func() {
+		hostPrefix = oldHostPrefix
+		nodeHooksConfigMapDir = oldNodeHooksConfigMapDir
+	})
+
+	return t.TempDir()
+}
+
+// Write an executable shell script to the given path
+func writeNodeHook(t *testing.T, path, script string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+strings.TrimSpace(script)+"\n"), 0755))
+}
+
+func newTestNodeHooksDaemon(t *testing.T, dir, policy string) *daemon {
+	t.Helper()
+	return &daemon{
+		ctx:              t.Context(),
+		node:             "testnode",
+		nodeHooks:        api.NodeHooksConfig{Dir: dir, ConfigMap: "hooks", FailurePolicy: policy},
+		nodeHooksTimeout: time.Minute,
+	}
+}
@@ -11,35 +11,54 @@ import (
 func (d *daemon) watchForUpgrade() {
 	var needUpgrade bool
 	for {
-		d.retry(func() bool {
-			var err error
+		d.setPhase(PhaseChecking)
+		attempts := d.RetryConfig().OSCheckAttempts
+		err := d.retry(attempts, func() error {
+			_, err := syncRegistryAuth()
+			if err != nil {
+				slog.Error("Failed to sync registry credentials", "err", err)
+				return err
+			}
 			slog.Debug("Checking for upgrades via rpm-ostree")
 			needUpgrade, err = d.rpmostree.CheckForUpgrade()
-			if err == nil {
-				return true
+			if err != nil {
+				slog.Error("Failed to check if there is a new upgrade", "err", err)
+				return err
 			}
-			slog.Error("Failed to check if there is a new upgrade", "err", err)
-			return false
+			return nil
 		})
+		if err != nil {
+			needUpgrade = false
+			if d.ctx.Err() == nil {
+				slog.Error("Giving up checking for upgrades until the next check", "err", err)
+			}
+		}
 
 		if needUpgrade {
 			slog.Info("New upgrade is necessary, trying to start update")
-			d.retry(func() bool {
+			err = d.retry(attempts, func() error {
 				err := d.doUpgrade()
-				if err == nil {
-					return true
+				if err != nil {
+					slog.Error("Failed to perform rpm-ostree upgrade", "err", err)
 				}
-				slog.Error("Failed to perform rpm-ostree upgrade", "err", err)
-				return false
+				return err
 			})
+			if err != nil && d.ctx.Err() == nil {
+				slog.Error("Giving up upgrading until the next check", "err", err)
+			}
 		} else {
 			slog.Debug("No upgrades found")
 		}
 
+		interval := d.CheckInterval()
+		d.setNextCheck(time.Now().Add(interval))
+		d.setPhase(PhaseIdle)
 		select {
 		case <-d.ctx.Done():
 			return
-		case <-time.After(d.CheckInterval()):
+		case <-time.After(interval):
+		case <-d.checkNow:
+			slog.Info("Checking for upgrades on request")
 		}
 	}
 }
@@ -49,11 +68,29 @@ func (d *daemon) doUpgrade() error {
 	d.upgrade.Lock()
 	defer d.upgrade.Unlock()
 
-	err := d.Fleetlock().Lock()
+	_, err := syncRegistryAuth()
+	if err != nil {
+		return fmt.Errorf("failed to sync registry credentials: %v", err)
+	}
+
+	// OS upgrades have no kubernetes version, failures abort the upgrade unless they are ignored
+	err = d.runNodeHooks(nodeHookPreLock, "")
+	if err != nil {
+		return err
+	}
+
+	err = d.acquireLockWithRetry()
 	if err != nil {
 		return fmt.Errorf("failed to acquire lock: %v", err)
 	}
 
+	err = d.runNodeHooks(nodeHookPreReboot, "")
+	if err != nil {
+		d.releaseLock()
+		return err
+	}
+
+	d.setPhase(PhaseUpgrading)
 	err = d.rpmostree.Upgrade()
 	if err != nil {
 		return err
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	fleetlock "github.com/heathcliff26/fleetlock/pkg/client"
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
 	rpmostree "github.com/heathcliff26/kube-upgrade/pkg/upgraded/rpm-ostree"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -21,10 +22,11 @@ func TestDoUpgrade(t *testing.T) {
 			},
 		}
 		return &daemon{
-			fleetlock: fleetlock,
-			rpmostree: rpmostree,
-			node:      node.GetName(),
-			client:    fake.NewClientset(node),
+			fleetlock:   fleetlock,
+			rpmostree:   rpmostree,
+			node:        node.GetName(),
+			client:      fake.NewClientset(node),
+			retryConfig: api.RetryConfig{LockAttempts: 1},
 		}
 	}
 
@@ -0,0 +1,7 @@
+#!/bin/bash
+
+if [ "$1" == "version" ]; then
+    echo "v1.35.0"
+    exit 0
+fi
+exit 1
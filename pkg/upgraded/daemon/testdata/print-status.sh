@@ -0,0 +1,18 @@
+#!/bin/bash
+
+cat <<JSON
+{
+  "deployments": [
+    {
+      "booted": false,
+      "staged": true,
+      "container-image-reference": "ostree-unverified-registry:ghcr.io/heathcliff26/fcos-k8s:v1.35.0"
+    },
+    {
+      "booted": true,
+      "staged": false,
+      "container-image-reference": "ostree-unverified-registry:ghcr.io/heathcliff26/fcos-k8s:v1.34.2"
+    }
+  ]
+}
+JSON
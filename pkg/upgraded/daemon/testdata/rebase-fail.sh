@@ -0,0 +1,4 @@
+#!/bin/bash
+
+echo "error: Failed to pull image: manifest unknown" >&2
+exit 1
@@ -39,8 +39,11 @@ func nodeNeedsUpgrade(node *corev1.Node) bool {
 	if node.Annotations == nil {
 		return false
 	}
+	if nodeExcludedFromUpgrade(node) {
+		return false
+	}
 	status := node.Annotations[constants.NodeUpgradeStatus]
-	if status == constants.NodeUpgradeStatusCompleted {
+	if status == constants.NodeUpgradeStatusCompleted || status == constants.NodeUpgradeStatusError {
 		return false
 	}
 	if _, ok := node.Annotations[constants.NodeKubernetesVersion]; !ok {
@@ -50,6 +53,11 @@ func nodeNeedsUpgrade(node *corev1.Node) bool {
 	return true
 }
 
+// Check if the node has been put on hold or is upgraded by other means
+func nodeExcludedFromUpgrade(node *corev1.Node) bool {
+	return node.Annotations[constants.NodeHold] == "true" || node.Annotations[constants.NodeSkip] == "true"
+}
+
 // Delete the specified directory if it exists
 func deleteDir(path string) error {
 	if _, err := os.Stat(path); !os.IsNotExist(err) {
@@ -126,6 +126,44 @@ func TestNodeNeedsUpgrade(t *testing.T) {
 			},
 			Result: true,
 		},
+		{
+			Name: "WaitingForRetry",
+			Node: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						constants.NodeKubernetesVersion: "v1.31.0",
+						constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusError,
+					},
+				},
+			},
+			Result: false,
+		},
+		{
+			Name: "OnHold",
+			Node: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						constants.NodeKubernetesVersion: "v1.31.0",
+						constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusPending,
+						constants.NodeHold:              "true",
+					},
+				},
+			},
+			Result: false,
+		},
+		{
+			Name: "Skipped",
+			Node: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						constants.NodeKubernetesVersion: "v1.31.0",
+						constants.NodeUpgradeStatus:     constants.NodeUpgradeStatusError,
+						constants.NodeSkip:              "true",
+					},
+				},
+			},
+			Result: false,
+		},
 	}
 
 	for _, tCase := range tMatrix {
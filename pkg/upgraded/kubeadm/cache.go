@@ -0,0 +1,298 @@
+package kubeadm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	cacheBlobDir    = "blobs"
+	cacheVersionDir = "versions"
+	cacheBlobPrefix = "sha512-"
+)
+
+// Content addressed cache for kubeadm binaries.
+// Binaries are stored by their sha512 checksum, with a file per version and source containing the checksum.
+// The source describes where the binary came from and how it was verified, so a binary is never
+// used after changing to a source, that would not have accepted it.
+// The modification time of the version file is used to track when it was last used.
+type Cache struct {
+	chroot     string
+	dir        string
+	maxEntries int
+	maxSize    int64
+}
+
+// Create a new cache in the given directory inside the chroot.
+// When the limits are exceeded, the least recently used versions are removed.
+func NewCache(chroot, dir string, maxEntries int, maxSize int64) *Cache {
+	return &Cache{
+		chroot:     chroot,
+		dir:        dir,
+		maxEntries: maxEntries,
+		maxSize:    maxSize,
+	}
+}
+
+// Return kubeadm for the given version acquired from the source from the cache.
+// Returns nil if the version is not cached or the cached binary fails the integrity check.
+func (c *Cache) Get(version, source string) *KubeadmCMD {
+	if !validCacheVersion(version) {
+		return nil
+	}
+	entry := cacheEntryName(version, source)
+
+	digest, err := c.readDigest(entry)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		slog.Warn("Failed to read kubeadm cache entry, removing it", slog.String("version", version), "err", err)
+		c.remove(entry)
+		return nil
+	}
+
+	blob := c.blobPath(digest)
+	actual, err := sha512File(c.chroot + blob)
+	if err != nil || actual != digest {
+		slog.Warn("Cached kubeadm failed the integrity check, removing it", slog.String("version", version), slog.String("path", blob), "err", err)
+		c.remove(entry)
+		return nil
+	}
+
+	cmd, err := NewFromPath(c.chroot, blob)
+	if err != nil {
+		slog.Warn("Failed to use cached kubeadm, removing it", slog.String("version", version), slog.String("path", blob), "err", err)
+		c.remove(entry)
+		return nil
+	}
+
+	now := time.Now()
+	err = os.Chtimes(c.versionPath(entry), now, now)
+	if err != nil {
+		slog.Warn("Failed to update last use of cached kubeadm", slog.String("version", version), "err", err)
+	}
+
+	return cmd
+}
+
+// Move the binary of the given command into the cache and return a command using the cached binary.
+// The source needs to describe where the binary came from and how it has been verified.
+// Afterwards removes the least recently used versions exceeding the limits, but never the added version.
+func (c *Cache) Add(version, source string, cmd *KubeadmCMD) (*KubeadmCMD, error) {
+	if !validCacheVersion(version) {
+		return nil, fmt.Errorf("invalid version \"%s\" for kubeadm cache", version)
+	}
+
+	src := c.chroot + cmd.binary
+	digest, err := sha512File(src)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range []string{cacheBlobDir, cacheVersionDir} {
+		// #nosec G301: The binary is no secret, can be world readable/executable
+		err = os.MkdirAll(filepath.Join(c.chroot+c.dir, dir), 0755)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kubeadm cache directory: %v", err)
+		}
+	}
+
+	blob := c.blobPath(digest)
+	_, err = os.Stat(c.chroot + blob)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = moveFile(src, c.chroot+blob)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add kubeadm to cache: %v", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to check for cached kubeadm: %v", err)
+	} else {
+		_ = os.Remove(src)
+	}
+
+	// #nosec G306: The checksum is no secret
+	entry := cacheEntryName(version, source)
+	err = os.WriteFile(c.versionPath(entry), []byte(digest), 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write kubeadm cache entry: %v", err)
+	}
+
+	c.gc(entry)
+
+	return NewFromPath(c.chroot, blob)
+}
+
+// Remove the least recently used entries exceeding the limits and all binaries no longer referenced.
+// Entries of older versions without a source are removed the same way, as they are never used again.
+func (c *Cache) gc(keep string) {
+	entries, err := os.ReadDir(filepath.Join(c.chroot+c.dir, cacheVersionDir))
+	if err != nil {
+		slog.Warn("Failed to read kubeadm cache", "err", err)
+		return
+	}
+
+	type cacheEntry struct {
+		name   string
+		digest string
+		used   time.Time
+	}
+	versions := make([]cacheEntry, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		digest, err := c.readDigest(entry.Name())
+		if err != nil {
+			continue
+		}
+		versions = append(versions, cacheEntry{
+			name:   entry.Name(),
+			digest: digest,
+			used:   info.ModTime(),
+		})
+	}
+	// The kept entry first, then the most recently used
+	slices.SortFunc(versions, func(a, b cacheEntry) int {
+		if a.name == keep {
+			return -1
+		} else if b.name == keep {
+			return 1
+		}
+		return b.used.Compare(a.used)
+	})
+
+	referenced := make(map[string]bool, len(versions))
+	var size int64
+	count := 0
+	for _, entry := range versions {
+		var blobSize int64
+		if !referenced[entry.digest] {
+			info, err := os.Stat(c.chroot + c.blobPath(entry.digest))
+			if err == nil {
+				blobSize = info.Size()
+			}
+		}
+
+		if entry.name != keep && (count+1 > c.maxEntries || size+blobSize > c.maxSize) {
+			slog.Info("Removing kubeadm from cache", slog.String("entry", entry.name))
+			c.remove(entry.name)
+			continue
+		}
+		count++
+		size += blobSize
+		referenced[entry.digest] = true
+	}
+
+	blobs, err := os.ReadDir(filepath.Join(c.chroot+c.dir, cacheBlobDir))
+	if err != nil {
+		slog.Warn("Failed to read kubeadm cache", "err", err)
+		return
+	}
+	for _, blob := range blobs {
+		digest, ok := strings.CutPrefix(blob.Name(), cacheBlobPrefix)
+		if ok && referenced[digest] {
+			continue
+		}
+		err = os.Remove(filepath.Join(c.chroot+c.dir, cacheBlobDir, blob.Name()))
+		if err != nil {
+			slog.Warn("Failed to remove unused kubeadm from cache", slog.String("file", blob.Name()), "err", err)
+		}
+	}
+}
+
+// Remove the entry from the cache, the binary is removed by the next garbage collection if unused.
+func (c *Cache) remove(entry string) {
+	err := os.Remove(c.versionPath(entry))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("Failed to remove kubeadm cache entry", slog.String("entry", entry), "err", err)
+	}
+}
+
+func (c *Cache) readDigest(entry string) (string, error) {
+	// #nosec G304: The path is variable, that is indeed intended.
+	data, err := os.ReadFile(c.versionPath(entry))
+	if err != nil {
+		return "", err
+	}
+	digest := strings.TrimSpace(string(data))
+	if digest == "" || strings.ContainsAny(digest, "/.") {
+		return "", fmt.Errorf("invalid checksum \"%s\"", digest)
+	}
+	return digest, nil
+}
+
+// Path of the version file of the entry, including the chroot
+func (c *Cache) versionPath(entry string) string {
+	return filepath.Join(c.chroot+c.dir, cacheVersionDir, entry)
+}
+
+// Path of the binary, without the chroot
+func (c *Cache) blobPath(digest string) string {
+	return filepath.Join(c.dir, cacheBlobDir, cacheBlobPrefix+digest)
+}
+
+// Return the name of the version file for the version and source.
+// The source is hashed, as it may contain characters not allowed in file names.
+func cacheEntryName(version, source string) string {
+	hash := sha256.Sum256([]byte(source))
+	return version + "_" + hex.EncodeToString(hash[:8])
+}
+
+// Ensure the version can be safely used as a file name
+func validCacheVersion(version string) bool {
+	return version != "" && version != "." && version != ".." && filepath.Base(version) == version
+}
+
+// Move the file, falling back to copying when source and destination are on different filesystems.
+// The destination is written to a temporary file first, so it is never partially written.
+func moveFile(src, dest string) error {
+	err := os.Rename(src, dest)
+	if err == nil {
+		return nil
+	}
+
+	// #nosec G304: The path is variable, that is indeed intended.
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dest + ".tmp"
+	// #nosec G304: The path is variable, that is indeed intended.
+	// #nosec G302: The binary is no secret, can be world readable/executable
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	err = out.Close()
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	err = os.Rename(tmp, dest)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	_ = os.Remove(src)
+	return nil
+}
@@ -0,0 +1,120 @@
+package kubeadm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCacheSource = "url https://dl.k8s.io/release signature"
+
+func TestCache(t *testing.T) {
+	t.Run("AddAndGet", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		dir := t.TempDir()
+		cache := NewCache("", dir, 3, 1<<20)
+
+		assert.Nil(cache.Get("v1.35.0", testCacheSource), "Should not find uncached version")
+
+		src := newTestKubeadmCMD(t, "v1.35.0")
+		cmd, err := cache.Add("v1.35.0", testCacheSource, src)
+		require.NoError(err, "Should add kubeadm to cache")
+		assert.NoFileExists(src.binary, "Should move the binary into the cache")
+		assert.Equal("v1.35.0", cmd.Version(), "Should use the cached binary")
+		assert.True(filepath.IsAbs(cmd.binary) && filepath.Dir(cmd.binary) == filepath.Join(dir, cacheBlobDir), "Should store the binary in the cache")
+
+		cmd = cache.Get("v1.35.0", testCacheSource)
+		require.NotNil(cmd, "Should find cached version")
+		assert.Equal("v1.35.0", cmd.Version(), "Should return the cached binary")
+	})
+	t.Run("DifferentSource", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		dir := t.TempDir()
+		cache := NewCache("", dir, 3, 1<<20)
+
+		_, err := cache.Add("v1.35.0", testCacheSource, newTestKubeadmCMD(t, "v1.35.0"))
+		require.NoError(err, "Should add kubeadm to cache")
+
+		assert.Nil(cache.Get("v1.35.0", "url https://mirror.example.com checksum"), "Should not use binaries acquired from a different source")
+		assert.NotNil(cache.Get("v1.35.0", testCacheSource), "Should keep the binary of the other source")
+	})
+	t.Run("IntegrityCheck", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		dir := t.TempDir()
+		cache := NewCache("", dir, 3, 1<<20)
+
+		cmd, err := cache.Add("v1.35.0", testCacheSource, newTestKubeadmCMD(t, "v1.35.0"))
+		require.NoError(err, "Should add kubeadm to cache")
+		require.NoError(os.WriteFile(cmd.binary, []byte("#!/bin/bash\necho tampered\n"), 0755), "Should modify cached binary")
+
+		assert.Nil(cache.Get("v1.35.0", testCacheSource), "Should not return a modified binary")
+		assert.NoFileExists(cache.versionPath(cacheEntryName("v1.35.0", testCacheSource)), "Should remove the invalid entry")
+	})
+	t.Run("LimitEntries", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		dir := t.TempDir()
+		cache := NewCache("", dir, 2, 1<<20)
+
+		versions := []string{"v1.33.0", "v1.34.0", "v1.35.0"}
+		for i, version := range versions {
+			_, err := cache.Add(version, testCacheSource, newTestKubeadmCMD(t, version))
+			require.NoError(err, "Should add kubeadm to cache")
+			used := time.Now().Add(time.Duration(i-len(versions)) * time.Hour)
+			require.NoError(os.Chtimes(cache.versionPath(cacheEntryName(version, testCacheSource)), used, used))
+		}
+
+		assert.Nil(cache.Get("v1.33.0", testCacheSource), "Should have removed the least recently used version")
+		assert.NotNil(cache.Get("v1.34.0", testCacheSource), "Should keep recently used version")
+		assert.NotNil(cache.Get("v1.35.0", testCacheSource), "Should keep added version")
+
+		blobs, err := os.ReadDir(filepath.Join(dir, cacheBlobDir))
+		require.NoError(err, "Should read blobs")
+		assert.Len(blobs, 2, "Should remove unused binaries")
+	})
+	t.Run("LimitSize", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		dir := t.TempDir()
+		cache := NewCache("", dir, 3, 1)
+
+		_, err := cache.Add("v1.34.0", testCacheSource, newTestKubeadmCMD(t, "v1.34.0"))
+		require.NoError(err, "Should add kubeadm to cache")
+		_, err = cache.Add("v1.35.0", testCacheSource, newTestKubeadmCMD(t, "v1.35.0"))
+		require.NoError(err, "Should add kubeadm to cache")
+
+		assert.Nil(cache.Get("v1.34.0", testCacheSource), "Should have removed old version exceeding the size")
+		assert.NotNil(cache.Get("v1.35.0", testCacheSource), "Should always keep added version")
+	})
+	t.Run("InvalidVersion", func(t *testing.T) {
+		assert := assert.New(t)
+
+		cache := NewCache("", t.TempDir(), 3, 1<<20)
+
+		_, err := cache.Add("../v1.35.0", testCacheSource, newTestKubeadmCMD(t, "v1.35.0"))
+		assert.Error(err, "Should not accept path as version")
+		assert.Nil(cache.Get("..", testCacheSource), "Should not accept path as version")
+	})
+}
+
+// Create a fake kubeadm printing the given version
+func newTestKubeadmCMD(t *testing.T, version string) *KubeadmCMD {
+	path := filepath.Join(t.TempDir(), "kubeadm")
+	err := os.WriteFile(path, []byte("#!/bin/bash\necho "+version+"\n"), 0755)
+	require.NoError(t, err, "Should write fake kubeadm")
+	cmd, err := NewFromPath("", path)
+	require.NoError(t, err, "Should create fake kubeadm")
+	return cmd
+}
@@ -0,0 +1,148 @@
+package kubeadm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+)
+
+const (
+	SecretKeyCA       = "ca.crt"
+	SecretKeyUsername = "username"
+	SecretKeyPassword = "password"
+	SecretKeyToken    = "token"
+
+	downloadTimeout = 5 * time.Minute
+)
+
+// Options for downloading and verifying kubeadm
+type DownloadOptions struct {
+	// Base url, the binary is expected under "<url>/<version>/bin/linux/<arch>/kubeadm"
+	URL string
+	// Client used for downloads, uses the default client when nil
+	Client *http.Client
+
+	Username string
+	Password string
+	Token    string
+
+	// Either signature or checksum
+	Verification          string
+	CertificateIdentity   string
+	CertificateOIDCIssuer string
+	// Verifier for signatures, defaults to the sigstore public good instance with the configured identity and issuer
+	Verifier *SignatureVerifier
+	// Rekor instance the signatures are looked up in, defaults to the sigstore public good instance
+	RekorURL string
+	// PEM encoded public key of the rekor instance, defaults to the key of the sigstore public good instance
+	RekorPublicKey string
+}
+
+// Create the download options from the given config.
+// Credentials and the CA are read from secretDir, missing files are ignored.
+func NewDownloadOptions(cfg api.KubeadmDownloadConfig, secretDir string) (DownloadOptions, error) {
+	api.SetObjectDefaults_KubeadmDownloadConfig(&cfg)
+
+	opts := DownloadOptions{
+		URL:                   cfg.URL,
+		Verification:          cfg.Verification,
+		CertificateIdentity:   cfg.CertificateIdentity,
+		CertificateOIDCIssuer: cfg.CertificateOIDCIssuer,
+		RekorURL:              cfg.RekorURL,
+		RekorPublicKey:        cfg.RekorPublicKey,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Proxy != "" {
+		proxy, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return DownloadOptions{}, fmt.Errorf("invalid proxy url \"%s\": %v", cfg.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	var err error
+	if secretDir != "" {
+		opts.Username, err = readSecretFile(secretDir, SecretKeyUsername)
+		if err != nil {
+			return DownloadOptions{}, err
+		}
+		opts.Password, err = readSecretFile(secretDir, SecretKeyPassword)
+		if err != nil {
+			return DownloadOptions{}, err
+		}
+		opts.Token, err = readSecretFile(secretDir, SecretKeyToken)
+		if err != nil {
+			return DownloadOptions{}, err
+		}
+
+		ca, err := readSecretFile(secretDir, SecretKeyCA)
+		if err != nil {
+			return DownloadOptions{}, err
+		}
+		if ca != "" {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM([]byte(ca)) {
+				return DownloadOptions{}, fmt.Errorf("failed to parse any certificate from %s", filepath.Join(secretDir, SecretKeyCA))
+			}
+			transport.TLSClientConfig = &tls.Config{
+				RootCAs:    pool,
+				MinVersion: tls.VersionTLS12,
+			}
+		}
+	}
+
+	opts.Client = &http.Client{
+		Transport: transport,
+		Timeout:   downloadTimeout,
+	}
+
+	return opts, nil
+}
+
+// Return the configured client or the default client
+func (o DownloadOptions) client() *http.Client {
+	if o.Client == nil {
+		return http.DefaultClient
+	}
+	return o.Client
+}
+
+// Create a new GET request with the configured credentials
+func (o DownloadOptions) newRequest(url string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if o.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+o.Token)
+	} else if o.Username != "" || o.Password != "" {
+		req.SetBasicAuth(o.Username, o.Password)
+	}
+	return req, nil
+}
+
+// Read a single key of a mounted secret, returns an empty string if it does not exist
+func readSecretFile(dir, key string) (string, error) {
+	// #nosec G304: The path is variable, that is indeed intended.
+	data, err := os.ReadFile(filepath.Join(dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", filepath.Join(dir, key), err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
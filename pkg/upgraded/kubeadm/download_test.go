@@ -0,0 +1,101 @@
+package kubeadm
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDownloadOptions(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		opts, err := NewDownloadOptions(api.KubeadmDownloadConfig{}, "")
+		require.NoError(err, "Should create options")
+
+		assert.Equal(api.DefaultKubeadmDownloadURL, opts.URL, "Should use the default url")
+		assert.Equal(api.KubeadmVerificationSignature, opts.Verification, "Should verify signatures by default")
+		assert.Equal(api.DefaultKubeadmCertificateIdentity, opts.CertificateIdentity, "Should use the default identity")
+		assert.Equal(api.DefaultKubeadmCertificateOIDCIssuer, opts.CertificateOIDCIssuer, "Should use the default issuer")
+		assert.Equal(api.DefaultKubeadmRekorURL, opts.RekorURL, "Should use the default rekor")
+		assert.NotNil(opts.Client, "Should create a client")
+	})
+	t.Run("Secret", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		dir := t.TempDir()
+		require.NoError(os.WriteFile(filepath.Join(dir, SecretKeyUsername), []byte("user\n"), 0600))
+		require.NoError(os.WriteFile(filepath.Join(dir, SecretKeyPassword), []byte("password"), 0600))
+
+		cfg := api.KubeadmDownloadConfig{
+			URL:   "https://mirror.example.com/kubernetes",
+			Proxy: "http://proxy.example.com:3128",
+		}
+		opts, err := NewDownloadOptions(cfg, dir)
+		require.NoError(err, "Should create options")
+
+		assert.Equal("https://mirror.example.com/kubernetes", opts.URL, "Should use the configured url")
+		assert.Equal("user", opts.Username, "Should read the username without newline")
+		assert.Equal("password", opts.Password, "Should read the password")
+		assert.Empty(opts.Token, "Should ignore missing keys")
+
+		proxy, err := opts.Client.Transport.(*http.Transport).Proxy(&http.Request{})
+		require.NoError(err, "Should return the proxy")
+		assert.Equal("http://proxy.example.com:3128", proxy.String(), "Should use the configured proxy")
+	})
+	t.Run("InvalidCA", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, SecretKeyCA), []byte("not-a-certificate"), 0600))
+
+		_, err := NewDownloadOptions(api.KubeadmDownloadConfig{}, dir)
+		assert.ErrorContains(err, "failed to parse any certificate", "Should fail to parse the CA")
+	})
+}
+
+func TestNewRequest(t *testing.T) {
+	tMatrix := []struct {
+		Name     string
+		Opts     DownloadOptions
+		Expected string
+	}{
+		{
+			Name:     "None",
+			Opts:     DownloadOptions{},
+			Expected: "",
+		},
+		{
+			Name: "BasicAuth",
+			Opts: DownloadOptions{
+				Username: "user",
+				Password: "password",
+			},
+			Expected: "Basic dXNlcjpwYXNzd29yZA==",
+		},
+		{
+			Name: "TokenTakesPrecedence",
+			Opts: DownloadOptions{
+				Username: "user",
+				Password: "password",
+				Token:    "token",
+			},
+			Expected: "Bearer token",
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			req, err := tCase.Opts.newRequest("https://example.com")
+			require.NoError(t, err, "Should create a request")
+			assert.Equal(t, tCase.Expected, req.Header.Get("Authorization"), "Should set the expected authorization header")
+		})
+	}
+}
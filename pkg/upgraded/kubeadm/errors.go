@@ -0,0 +1,117 @@
+package kubeadm
+
+import (
+	"errors"
+	"fmt"
+)
+
+type ErrDownload struct {
+	url string
+	err error
+}
+
+func NewErrDownload(url string, err error) error {
+	return &ErrDownload{
+		url: url,
+		err: err,
+	}
+}
+
+func (e *ErrDownload) Error() string {
+	return fmt.Sprintf("failed to download '%s': %v", e.url, e.err)
+}
+
+func (e *ErrDownload) Unwrap() error {
+	return e.err
+}
+
+type ErrChecksumMismatch struct {
+	path     string
+	expected string
+	actual   string
+}
+
+func NewErrChecksumMismatch(path, expected, actual string) error {
+	return &ErrChecksumMismatch{
+		path:     path,
+		expected: expected,
+		actual:   actual,
+	}
+}
+
+func (e *ErrChecksumMismatch) Error() string {
+	return fmt.Sprintf("checksum mismatch for '%s', expected %s but got %s", e.path, e.expected, e.actual)
+}
+
+type ErrSignatureMismatch struct {
+	path string
+	err  error
+}
+
+func NewErrSignatureMismatch(path string, err error) error {
+	return &ErrSignatureMismatch{
+		path: path,
+		err:  err,
+	}
+}
+
+func (e *ErrSignatureMismatch) Error() string {
+	return fmt.Sprintf("signature mismatch for '%s': %v", e.path, e.err)
+}
+
+func (e *ErrSignatureMismatch) Unwrap() error {
+	return e.err
+}
+
+type ErrUntrustedCertificate struct {
+	reason string
+}
+
+func NewErrUntrustedCertificate(reason string) error {
+	return &ErrUntrustedCertificate{
+		reason: reason,
+	}
+}
+
+func (e *ErrUntrustedCertificate) Error() string {
+	return "untrusted signing certificate: " + e.reason
+}
+
+type ErrTransparencyLog struct {
+	reason string
+}
+
+func NewErrTransparencyLog(reason string) error {
+	return &ErrTransparencyLog{
+		reason: reason,
+	}
+}
+
+func (e *ErrTransparencyLog) Error() string {
+	return "failed to verify transparency log entry: " + e.reason
+}
+
+type ErrUnverifiedImage struct {
+	stream string
+}
+
+func NewErrUnverifiedImage(stream string) error {
+	return &ErrUnverifiedImage{
+		stream: stream,
+	}
+}
+
+func (e *ErrUnverifiedImage) Error() string {
+	return fmt.Sprintf("refusing to extract kubeadm from the unverified stream '%s', unsigned images are allowed", e.stream)
+}
+
+// Check if the error is caused by kubeadm failing the signature or checksum verification,
+// or coming from a source that can't be verified
+func IsVerificationError(err error) bool {
+	var checksumErr *ErrChecksumMismatch
+	var signatureErr *ErrSignatureMismatch
+	var certErr *ErrUntrustedCertificate
+	var tlogErr *ErrTransparencyLog
+	var imageErr *ErrUnverifiedImage
+	return errors.As(err, &checksumErr) || errors.As(err, &signatureErr) || errors.As(err, &certErr) || errors.As(err, &tlogErr) || errors.As(err, &imageErr)
+}
@@ -0,0 +1,54 @@
+package kubeadm
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsVerificationError(t *testing.T) {
+	tMatrix := []struct {
+		Name   string
+		Err    error
+		Result bool
+	}{
+		{
+			Name:   "ChecksumMismatch",
+			Err:    NewErrChecksumMismatch("kubeadm", "abc", "def"),
+			Result: true,
+		},
+		{
+			Name:   "SignatureMismatch",
+			Err:    NewErrSignatureMismatch("kubeadm", errors.New("invalid signature")),
+			Result: true,
+		},
+		{
+			Name:   "UntrustedCertificate",
+			Err:    fmt.Errorf("invalid kubeadm binary: %w", NewErrUntrustedCertificate("unknown issuer")),
+			Result: true,
+		},
+		{
+			Name:   "TransparencyLog",
+			Err:    fmt.Errorf("invalid kubeadm binary: %w", NewErrTransparencyLog("invalid signed entry timestamp")),
+			Result: true,
+		},
+		{
+			Name:   "UnverifiedImage",
+			Err:    fmt.Errorf("failed to acquire kubeadm: %w", NewErrUnverifiedImage("ghcr.io/heathcliff26/fcos-k8s")),
+			Result: true,
+		},
+		{
+			Name:   "Download",
+			Err:    fmt.Errorf("failed to download kubeadm binary: %w", NewErrDownload("https://dl.k8s.io", errors.New("not found"))),
+			Result: false,
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert.Equal(t, tCase.Result, IsVerificationError(tCase.Err))
+		})
+	}
+}
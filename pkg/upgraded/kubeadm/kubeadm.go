@@ -3,16 +3,21 @@ package kubeadm
 import (
 	"fmt"
 	"log/slog"
+	"os"
 	"os/exec"
 	"runtime"
 	"strings"
 	"sync"
+	"syscall"
 
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
 	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/utils"
 )
 
-const (
+var (
 	tmpDir = "/tmp/upgraded"
+
+	PodmanBinary = "/usr/bin/podman"
 )
 
 type KubeadmCMD struct {
@@ -45,30 +50,124 @@ func NewFromPath(chroot, path string) (*KubeadmCMD, error) {
 }
 
 // Download kubeadm and create a launch wrapper for it.
-func NewFromVersion(chroot, version string) (*KubeadmCMD, error) {
+func NewFromVersion(chroot, version string, opts DownloadOptions) (*KubeadmCMD, error) {
 	slog.Info("Downloading kubeadm", slog.String("version", version))
 
+	if opts.URL == "" {
+		opts.URL = api.DefaultKubeadmDownloadURL
+	}
+
 	kubeadmPath := tmpDir + "/kubeadm-" + version
 	kubeadmPathWithChroot := chroot + kubeadmPath
-	baseURL := fmt.Sprintf("https://dl.k8s.io/release/%s/bin/linux/%s/kubeadm", version, runtime.GOARCH)
+	baseURL := fmt.Sprintf("%s/%s/bin/linux/%s/kubeadm", strings.TrimSuffix(opts.URL, "/"), version, runtime.GOARCH)
 
-	err := downloadFile(baseURL, kubeadmPathWithChroot)
+	err := downloadFile(opts, baseURL, kubeadmPathWithChroot)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download kubeadm binary: %v", err)
+		return nil, fmt.Errorf("failed to download kubeadm binary: %w", err)
+	}
+	defer func() {
+		for _, ext := range []string{".sha512", ".sig", ".cert", ".bundle"} {
+			_ = os.Remove(kubeadmPathWithChroot + ext)
+		}
+	}()
+
+	switch opts.Verification {
+	case api.KubeadmVerificationChecksum:
+		err = downloadFile(opts, baseURL+".sha512", kubeadmPathWithChroot+".sha512")
+		if err != nil {
+			return nil, fmt.Errorf("failed to download kubeadm checksum: %w", err)
+		}
+		err = verifyArtifactWithChecksum(kubeadmPathWithChroot, kubeadmPathWithChroot+".sha512")
+	default:
+		verifier := opts.Verifier
+		if verifier == nil {
+			identity := opts.CertificateIdentity
+			if identity == "" {
+				identity = api.DefaultKubeadmCertificateIdentity
+			}
+			issuer := opts.CertificateOIDCIssuer
+			if issuer == "" {
+				issuer = api.DefaultKubeadmCertificateOIDCIssuer
+			}
+			verifier, err = NewSigstoreVerifier(identity, issuer, opts.RekorPublicKey)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		err = downloadFile(opts, baseURL+".sig", kubeadmPathWithChroot+".sig")
+		if err != nil {
+			return nil, fmt.Errorf("failed to download kubeadm signature: %w", err)
+		}
+		err = downloadFile(opts, baseURL+".cert", kubeadmPathWithChroot+".cert")
+		if err != nil {
+			return nil, fmt.Errorf("failed to download kubeadm certificate: %w", err)
+		}
+		err = fetchRekorBundle(opts, kubeadmPathWithChroot, kubeadmPathWithChroot+".sig", kubeadmPathWithChroot+".bundle")
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch transparency log entry of kubeadm: %w", err)
+		}
+		err = verifier.Verify(kubeadmPathWithChroot, kubeadmPathWithChroot+".sig", kubeadmPathWithChroot+".cert", kubeadmPathWithChroot+".bundle")
 	}
-	err = verifyArtifactWithCosign(kubeadmPathWithChroot, baseURL+".sig", baseURL+".cert")
 	if err != nil {
-		return nil, fmt.Errorf("invalid kubeadm binary: %v", err)
+		_ = os.Remove(kubeadmPathWithChroot)
+		return nil, fmt.Errorf("invalid kubeadm binary: %w", err)
 	}
 	return NewFromPath(chroot, kubeadmPath)
 }
 
+// Extract kubeadm from the image of the given stream and version and create a launch wrapper for it.
+// Uses podman on the host, so the host policy and credentials apply when pulling the image.
+// When authFile is set, it is used as credentials instead.
+func NewFromImage(chroot, stream, version, path, authFile string) (*KubeadmCMD, error) {
+	if path == "" {
+		path = api.DefaultKubeadmImagePath
+	}
+
+	image := stream + ":" + version
+	slog.Info("Extracting kubeadm from image", slog.String("image", image), slog.String("path", path))
+
+	kubeadmPath := tmpDir + "/kubeadm-" + version
+	// #nosec G301: The binary is no secret, can be world readable/executable
+	err := os.MkdirAll(chroot+tmpDir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory '%s': %v", chroot+tmpDir, err)
+	}
+
+	args := []string{"create", "--pull=missing"}
+	if authFile != "" {
+		args = append(args, "--authfile", authFile)
+	}
+	out, err := createPodmanCMD(chroot, append(args, image, "true")...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create container from image '%s': %v", image, err)
+	}
+	id := strings.TrimSpace(string(out))
+	defer func() {
+		err := createPodmanCMD(chroot, "rm", "--force", id).Run()
+		if err != nil {
+			slog.Warn("Failed to remove kubeadm extraction container", slog.String("id", id), "err", err)
+		}
+		err = createPodmanCMD(chroot, "rmi", image).Run()
+		if err != nil {
+			slog.Warn("Failed to remove image after extracting kubeadm", slog.String("image", image), "err", err)
+		}
+	}()
+
+	err = createPodmanCMD(chroot, "cp", id+":"+path, kubeadmPath).Run()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy kubeadm from image '%s': %v", image, err)
+	}
+
+	return NewFromPath(chroot, kubeadmPath)
+}
+
 // Run kubeadm upgrade apply
 func (k *KubeadmCMD) Apply(version string) error {
 	k.mutex.Lock()
 	defer k.mutex.Unlock()
 
-	return utils.CreateChrootCMDWithStdout(k.chroot, k.binary, "upgrade", "apply", "--yes", version).Run()
+	return utils.RunWithLog("kubeadm", utils.CreateChrootCMDWithStdout(k.chroot, k.binary, "upgrade", "apply", "--yes", version))
 }
 
 // Run kubeadm upgrade node
@@ -76,13 +175,26 @@ func (k *KubeadmCMD) Node() error {
 	k.mutex.Lock()
 	defer k.mutex.Unlock()
 
-	return utils.CreateChrootCMDWithStdout(k.chroot, k.binary, "upgrade", "node").Run()
+	return utils.RunWithLog("kubeadm", utils.CreateChrootCMDWithStdout(k.chroot, k.binary, "upgrade", "node"))
 }
 
 func (k *KubeadmCMD) Version() string {
 	return k.version
 }
 
+// Create a podman command running in the chroot, with stderr passed through
+func createPodmanCMD(chroot string, args ...string) *exec.Cmd {
+	// #nosec G204: Arguments are controlled by the configuration
+	cmd := exec.Command(PodmanBinary, args...)
+	cmd.Stderr = os.Stderr
+	if chroot != "" {
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Chroot: chroot,
+		}
+	}
+	return cmd
+}
+
 func (k *KubeadmCMD) getVersion() (string, error) {
 	// #nosec G204: Binary path is controlled by the user
 	out, err := exec.Command(k.chroot+k.binary, "version", "--output", "short").Output()
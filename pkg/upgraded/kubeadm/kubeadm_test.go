@@ -1,9 +1,18 @@
 package kubeadm
 
 import (
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"runtime"
 	"testing"
+	"time"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -27,18 +36,14 @@ func TestNewFromPath(t *testing.T) {
 }
 
 func TestNewFromVersion(t *testing.T) {
-	oldCosignBinary := CosignBinary
-	CosignBinary = "../../../bin/cosign"
-	t.Cleanup(func() {
-		CosignBinary = oldCosignBinary
-	})
+	signer := newTestSigner(t)
 
 	t.Run("Download", func(t *testing.T) {
 		t.Parallel()
 		require := require.New(t)
 		tmpDir := t.TempDir()
 
-		cmd, err := NewFromVersion(tmpDir, "v1.35.0")
+		cmd, err := NewFromVersion(tmpDir, "v1.35.0", DownloadOptions{})
 		require.NoError(err, "Should create a command")
 		require.Equal("v1.35.0", cmd.Version(), "Downloaded version should match")
 	})
@@ -47,21 +52,153 @@ func TestNewFromVersion(t *testing.T) {
 		require := require.New(t)
 		tmpDir := t.TempDir()
 
-		cmd, err := NewFromVersion(tmpDir, "invalid-version")
+		cmd, err := NewFromVersion(tmpDir, "invalid-version", DownloadOptions{})
 		require.Error(err, "Should fail to download invalid version")
 		require.Nil(cmd, "Should not return a command")
 	})
-	t.Run("CosignError", func(t *testing.T) {
-		oldCosignBinary := CosignBinary
-		CosignBinary = "testdata/exit-1.sh"
-		t.Cleanup(func() {
-			CosignBinary = oldCosignBinary
-		})
+	t.Run("Signature", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+
+		srv := newFakeMirror(t, "v1.35.0", true, signer)
+		tmpDir := t.TempDir()
+
+		opts := DownloadOptions{
+			URL:      srv.URL + "/release",
+			Username: "user",
+			Password: "password",
+			Verifier: signer.verifier(testIdentity, testIssuer),
+			RekorURL: srv.URL,
+		}
+
+		cmd, err := NewFromVersion(tmpDir, "v1.35.0", opts)
+		require.NoError(err, "Should verify the signature")
+		require.Equal("version --output short", cmd.Version(), "Should use the downloaded binary")
+	})
+	t.Run("SignatureMismatch", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+
+		srv := newFakeMirror(t, "v1.35.0", false, signer)
+		tmpDir := t.TempDir()
+
+		opts := DownloadOptions{
+			URL:      srv.URL + "/release",
+			Username: "user",
+			Password: "password",
+			Verifier: signer.verifier(testIdentity, testIssuer),
+			RekorURL: srv.URL,
+		}
+
+		cmd, err := NewFromVersion(tmpDir, "v1.35.0", opts)
+		var tlogErr *ErrTransparencyLog
+		assert.ErrorAs(err, &tlogErr, "Should fail as the signature is not logged for the binary")
+		assert.ErrorContains(err, "invalid kubeadm binary:", "Should fail to verify the binary")
+		assert.True(IsVerificationError(err), "Should be a verification error")
+		assert.Nil(cmd, "Should not return a command")
+	})
+	t.Run("SignatureNotLogged", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+
+		srv := newFakeMirror(t, "v1.35.0", true, signer)
+		rekor := newFakeRekor(t)
+		tmpDir := t.TempDir()
+
+		opts := DownloadOptions{
+			URL:      srv.URL + "/release",
+			Username: "user",
+			Password: "password",
+			Verifier: signer.verifier(testIdentity, testIssuer),
+			RekorURL: rekor.URL,
+		}
+
+		cmd, err := NewFromVersion(tmpDir, "v1.35.0", opts)
+		var tlogErr *ErrTransparencyLog
+		assert.ErrorAs(err, &tlogErr, "Should fail without a transparency log entry")
+		assert.Nil(cmd, "Should not return a command")
+	})
+	t.Run("Mirror", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+
+		srv := newFakeMirror(t, "v1.35.0", true, signer)
+		tmpDir := t.TempDir()
+
+		opts := DownloadOptions{
+			URL:          srv.URL + "/release/",
+			Username:     "user",
+			Password:     "password",
+			Verification: api.KubeadmVerificationChecksum,
+		}
+
+		cmd, err := NewFromVersion(tmpDir, "v1.35.0", opts)
+		require.NoError(err, "Should download from the mirror")
+		require.Equal("version --output short", cmd.Version(), "Should use the downloaded binary")
+	})
+	t.Run("MirrorUnauthorized", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+
+		srv := newFakeMirror(t, "v1.35.0", true, signer)
+		tmpDir := t.TempDir()
+
+		opts := DownloadOptions{
+			URL:          srv.URL + "/release",
+			Verification: api.KubeadmVerificationChecksum,
+		}
+
+		cmd, err := NewFromVersion(tmpDir, "v1.35.0", opts)
+		var downloadErr *ErrDownload
+		assert.ErrorAs(err, &downloadErr, "Should fail with a download error")
+		assert.ErrorContains(err, "received status code 401", "Should fail without credentials")
+		assert.Nil(cmd, "Should not return a command")
+	})
+	t.Run("ChecksumMismatch", func(t *testing.T) {
+		t.Parallel()
 		assert := assert.New(t)
+
+		srv := newFakeMirror(t, "v1.35.0", false, signer)
 		tmpDir := t.TempDir()
 
-		cmd, err := NewFromVersion(tmpDir, "v1.35.0")
-		assert.ErrorContains(err, "invalid kubeadm binary:", "Should fail due to cosign error")
+		opts := DownloadOptions{
+			URL:          srv.URL + "/release",
+			Username:     "user",
+			Password:     "password",
+			Verification: api.KubeadmVerificationChecksum,
+		}
+
+		cmd, err := NewFromVersion(tmpDir, "v1.35.0", opts)
+		var checksumErr *ErrChecksumMismatch
+		assert.ErrorAs(err, &checksumErr, "Should fail with a checksum mismatch")
+		assert.Nil(cmd, "Should not return a command")
+	})
+}
+
+func TestNewFromImage(t *testing.T) {
+	oldTmpDir := tmpDir
+	oldPodmanBinary := PodmanBinary
+	t.Cleanup(func() {
+		tmpDir = oldTmpDir
+		PodmanBinary = oldPodmanBinary
+	})
+	tmpDir = t.TempDir()
+
+	t.Run("Success", func(t *testing.T) {
+		PodmanBinary = "testdata/podman.sh"
+		require := require.New(t)
+
+		cmd, err := NewFromImage("", "ghcr.io/heathcliff26/fcos-k8s", "v1.35.0", "", "")
+		require.NoError(err, "Should extract kubeadm")
+		require.Equal(tmpDir+"/kubeadm-v1.35.0", cmd.binary, "Should use the extracted binary")
+		require.Equal("version --output short", cmd.Version(), "Should use the extracted binary")
+	})
+	t.Run("PodmanError", func(t *testing.T) {
+		PodmanBinary = "testdata/exit-1.sh"
+		assert := assert.New(t)
+
+		cmd, err := NewFromImage("", "ghcr.io/heathcliff26/fcos-k8s", "v1.35.0", "", "")
+		assert.ErrorContains(err, "failed to create container from image 'ghcr.io/heathcliff26/fcos-k8s:v1.35.0'", "Should fail to create the container")
 		assert.Nil(cmd, "Should not return a command")
 	})
 }
@@ -86,3 +223,44 @@ func TestApply(t *testing.T) {
 	assert.NoError(err, "Command should succeed")
 	assert.Equal("upgrade apply --yes test-version\n", string(stdout), "Should have added version to command args")
 }
+
+// Serve testdata/print-args.sh as kubeadm behind basic auth, with a valid or invalid checksum and signature.
+// The signature is logged in the rekor API served under the same url.
+func newFakeMirror(t *testing.T, version string, valid bool, signer *testSigner) *httptest.Server {
+	binary, err := os.ReadFile("testdata/print-args.sh")
+	require.NoError(t, err, "Should read test binary")
+
+	signed := binary
+	if !valid {
+		signed = []byte("invalid")
+	}
+	checksum := sha512.Sum512(signed)
+	sig, cert := signer.sign(t, signed, testIdentity, testIssuer)
+
+	path := fmt.Sprintf("/release/%s/bin/linux/%s/kubeadm", version, runtime.GOARCH)
+
+	mux := http.NewServeMux()
+	registerFakeRekor(t, mux, signer.bundle(t, signed, sig, cert, time.Now()))
+	mux.HandleFunc("/release/", func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case path:
+			_, _ = w.Write(binary)
+		case path + ".sha512":
+			_, _ = w.Write([]byte(hex.EncodeToString(checksum[:]) + "  kubeadm\n"))
+		case path + ".sig":
+			_, _ = w.Write(sig)
+		case path + ".cert":
+			_, _ = w.Write(cert)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
@@ -0,0 +1,244 @@
+package kubeadm
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json/v2"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+)
+
+// Entry of the rekor transparency log together with its signed entry timestamp (SET).
+// Uses the same format as the rekor bundle of cosign.
+type RekorBundle struct {
+	SignedEntryTimestamp []byte       `json:"SignedEntryTimestamp"`
+	Payload              RekorPayload `json:"Payload"`
+}
+
+// The log entry signed by rekor
+type RekorPayload struct {
+	// Base64 encoded entry
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogIndex       int64  `json:"logIndex"`
+	LogID          string `json:"logID"`
+}
+
+// Log entry as returned by the rekor API
+type rekorLogEntry struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogIndex       int64  `json:"logIndex"`
+	LogID          string `json:"logID"`
+	Verification   struct {
+		SignedEntryTimestamp []byte `json:"signedEntryTimestamp"`
+	} `json:"verification"`
+}
+
+// The parts of a hashedrekord entry that are checked against the artifact
+type hashedRekord struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content   string `json:"content"`
+			PublicKey struct {
+				Content string `json:"content"`
+			} `json:"publicKey"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+// Look up the log entry for the blob and signature in rekor and write it as bundle to dest.
+// Only the lookup is done here, the entry is verified together with the signature.
+func fetchRekorBundle(opts DownloadOptions, blob, sigFile, dest string) error {
+	rekorURL := strings.TrimSuffix(opts.RekorURL, "/")
+	if rekorURL == "" {
+		rekorURL = api.DefaultKubeadmRekorURL
+	}
+
+	// #nosec G304: The path is variable, that is indeed intended.
+	data, err := os.ReadFile(blob)
+	if err != nil {
+		return fmt.Errorf("failed to read '%s': %v", blob, err)
+	}
+	// #nosec G304: The path is variable, that is indeed intended.
+	sig, err := os.ReadFile(sigFile)
+	if err != nil {
+		return fmt.Errorf("failed to read signature: %v", err)
+	}
+	sig = decodeBase64IfEncoded(sig)
+	digest := sha256.Sum256(data)
+
+	query, err := json.Marshal(map[string]string{"hash": "sha256:" + hex.EncodeToString(digest[:])})
+	if err != nil {
+		return err
+	}
+	var uuids []string
+	err = rekorRequest(opts, http.MethodPost, rekorURL+"/api/v1/index/retrieve", query, &uuids)
+	if err != nil {
+		return err
+	}
+
+	for _, uuid := range uuids {
+		entries := make(map[string]rekorLogEntry, 1)
+		err = rekorRequest(opts, http.MethodGet, rekorURL+"/api/v1/log/entries/"+uuid, nil, &entries)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			rekord, err := decodeHashedRekord(entry.Body)
+			if err != nil || rekord.Spec.Signature.Content != base64.StdEncoding.EncodeToString(sig) {
+				continue
+			}
+			bundle, err := json.Marshal(RekorBundle{
+				SignedEntryTimestamp: entry.Verification.SignedEntryTimestamp,
+				Payload: RekorPayload{
+					Body:           entry.Body,
+					IntegratedTime: entry.IntegratedTime,
+					LogIndex:       entry.LogIndex,
+					LogID:          entry.LogID,
+				},
+			})
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(dest, bundle, 0644)
+		}
+	}
+	return NewErrTransparencyLog("no entry for the signature found in " + rekorURL)
+}
+
+// Send a request to the rekor API and decode the JSON response into result.
+// The credentials for the download are not sent to rekor.
+func rekorRequest(opts DownloadOptions, method, url string, body []byte, result any) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := opts.client().Do(req)
+	if err != nil {
+		return NewErrDownload(url, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return NewErrDownload(url, fmt.Errorf("received status code %d", res.StatusCode))
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return NewErrDownload(url, err)
+	}
+	err = json.Unmarshal(data, result)
+	if err != nil {
+		return NewErrDownload(url, fmt.Errorf("failed to parse response: %v", err))
+	}
+	return nil
+}
+
+// Verify that the bundle is signed by rekor and logs the signature with the certificate over the digest.
+// Returns the time the entry was integrated into the log.
+func verifyRekorBundle(key crypto.PublicKey, bundle *RekorBundle, digest, sig []byte, cert *x509.Certificate) (time.Time, error) {
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return time.Time{}, NewErrTransparencyLog(fmt.Sprintf("unsupported rekor public key type %T", key))
+	}
+	der, err := x509.MarshalPKIXPublicKey(ecdsaKey)
+	if err != nil {
+		return time.Time{}, NewErrTransparencyLog(err.Error())
+	}
+	logID := sha256.Sum256(der)
+	if bundle.Payload.LogID != hex.EncodeToString(logID[:]) {
+		return time.Time{}, NewErrTransparencyLog("entry is from an untrusted log " + bundle.Payload.LogID)
+	}
+
+	payload, err := canonicalRekorPayload(bundle.Payload)
+	if err != nil {
+		return time.Time{}, NewErrTransparencyLog(err.Error())
+	}
+	payloadDigest := sha256.Sum256(payload)
+	if !ecdsa.VerifyASN1(ecdsaKey, payloadDigest[:], bundle.SignedEntryTimestamp) {
+		return time.Time{}, NewErrTransparencyLog("invalid signed entry timestamp")
+	}
+
+	rekord, err := decodeHashedRekord(bundle.Payload.Body)
+	if err != nil {
+		return time.Time{}, NewErrTransparencyLog(err.Error())
+	}
+	if rekord.Spec.Data.Hash.Algorithm != "sha256" || rekord.Spec.Data.Hash.Value != hex.EncodeToString(digest) {
+		return time.Time{}, NewErrTransparencyLog("entry does not match the digest of the artifact")
+	}
+	if rekord.Spec.Signature.Content != base64.StdEncoding.EncodeToString(sig) {
+		return time.Time{}, NewErrTransparencyLog("entry does not match the signature")
+	}
+	certPEM, err := base64.StdEncoding.DecodeString(rekord.Spec.Signature.PublicKey.Content)
+	if err != nil {
+		return time.Time{}, NewErrTransparencyLog(fmt.Sprintf("failed to decode certificate of entry: %v", err))
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || !bytes.Equal(block.Bytes, cert.Raw) {
+		return time.Time{}, NewErrTransparencyLog("entry does not match the certificate")
+	}
+
+	return time.Unix(bundle.Payload.IntegratedTime, 0), nil
+}
+
+// Return the canonical JSON of the payload, as signed by rekor
+func canonicalRekorPayload(payload RekorPayload) ([]byte, error) {
+	// Deterministic encodes maps with sorted keys, which together with the plain values results in canonical JSON
+	return json.Marshal(map[string]any{
+		"body":           payload.Body,
+		"integratedTime": payload.IntegratedTime,
+		"logIndex":       payload.LogIndex,
+		"logID":          payload.LogID,
+	}, json.Deterministic(true))
+}
+
+// Decode the base64 encoded body of a log entry, only hashedrekord entries are supported
+func decodeHashedRekord(body string) (*hashedRekord, error) {
+	data, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode entry: %v", err)
+	}
+	var rekord hashedRekord
+	err = json.Unmarshal(data, &rekord)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse entry: %v", err)
+	}
+	if rekord.Kind != "hashedrekord" {
+		return nil, errors.New("unsupported entry kind " + rekord.Kind)
+	}
+	return &rekord, nil
+}
+
+// Parse a PEM encoded public key
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("no PEM encoded public key found")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
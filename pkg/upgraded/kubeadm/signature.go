@@ -0,0 +1,233 @@
+package kubeadm
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json/v2"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/sigstore"
+)
+
+var (
+	// Fulcio extension containing the OIDC issuer as raw string
+	oidFulcioIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	// Fulcio extension containing the OIDC issuer as DER encoded UTF8String
+	oidFulcioIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// Verifies keyless sigstore signatures in-process.
+// The signature needs to be logged in rekor while the short-lived signing certificate was valid,
+// the certificate chain is checked at the time the entry was integrated into the log.
+type SignatureVerifier struct {
+	Roots         *x509.CertPool
+	Intermediates *x509.CertPool
+	RekorKey      crypto.PublicKey
+	Identity      string
+	Issuer        string
+}
+
+// Create a new verifier trusting the sigstore public good instance.
+// The rekor public key can be given as PEM for a different rekor instance, otherwise the one of sigstore is used.
+func NewSigstoreVerifier(identity, issuer, rekorPublicKey string) (*SignatureVerifier, error) {
+	roots, intermediates, err := parseCertificatePools(sigstore.FulcioCertificates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sigstore certificates: %v", err)
+	}
+	rekorKeyPEM := sigstore.RekorPublicKey
+	if rekorPublicKey != "" {
+		rekorKeyPEM = []byte(rekorPublicKey)
+	}
+	rekorKey, err := parsePublicKey(rekorKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rekor public key: %v", err)
+	}
+	return &SignatureVerifier{
+		Roots:         roots,
+		Intermediates: intermediates,
+		RekorKey:      rekorKey,
+		Identity:      identity,
+		Issuer:        issuer,
+	}, nil
+}
+
+// Verify the blob with the given signature, certificate and rekor bundle files.
+// The signature and certificate may be PEM/raw or base64 encoded, as published by cosign.
+func (v *SignatureVerifier) Verify(blob, sigFile, certFile, bundleFile string) error {
+	// #nosec G304: The path is variable, that is indeed intended.
+	certData, err := os.ReadFile(certFile)
+	if err != nil {
+		return fmt.Errorf("failed to read certificate: %v", err)
+	}
+	cert, err := parseCertificate(certData)
+	if err != nil {
+		return NewErrUntrustedCertificate(err.Error())
+	}
+
+	// #nosec G304: The path is variable, that is indeed intended.
+	sig, err := os.ReadFile(sigFile)
+	if err != nil {
+		return fmt.Errorf("failed to read signature: %v", err)
+	}
+	sig = decodeBase64IfEncoded(sig)
+
+	// #nosec G304: The path is variable, that is indeed intended.
+	data, err := os.ReadFile(blob)
+	if err != nil {
+		return fmt.Errorf("failed to read '%s': %v", blob, err)
+	}
+	digest := sha256.Sum256(data)
+
+	// #nosec G304: The path is variable, that is indeed intended.
+	bundleData, err := os.ReadFile(bundleFile)
+	if err != nil {
+		return NewErrTransparencyLog(fmt.Sprintf("failed to read rekor bundle: %v", err))
+	}
+	var bundle RekorBundle
+	err = json.Unmarshal(bundleData, &bundle)
+	if err != nil {
+		return NewErrTransparencyLog(fmt.Sprintf("failed to parse rekor bundle: %v", err))
+	}
+	integratedTime, err := verifyRekorBundle(v.RekorKey, &bundle, digest[:], sig, cert)
+	if err != nil {
+		return err
+	}
+	if integratedTime.Before(cert.NotBefore) || integratedTime.After(cert.NotAfter) {
+		return NewErrUntrustedCertificate(fmt.Sprintf("signature was logged at %s, outside of the validity of the certificate", integratedTime.UTC().Format(time.RFC3339)))
+	}
+
+	err = v.verifyCertificate(cert, integratedTime)
+	if err != nil {
+		return err
+	}
+
+	err = verifySignature(cert, data, sig)
+	if err != nil {
+		return NewErrSignatureMismatch(blob, err)
+	}
+	return nil
+}
+
+// Check that the certificate chained up to the trusted roots at the given time and matches the expected identity and issuer.
+func (v *SignatureVerifier) verifyCertificate(cert *x509.Certificate, at time.Time) error {
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         v.Roots,
+		Intermediates: v.Intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return NewErrUntrustedCertificate(err.Error())
+	}
+
+	identities := slices.Clone(cert.EmailAddresses)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	if !slices.Contains(identities, v.Identity) {
+		return NewErrUntrustedCertificate(fmt.Sprintf("expected identity \"%s\", but certificate has %v", v.Identity, identities))
+	}
+
+	issuer, err := certificateIssuer(cert)
+	if err != nil {
+		return NewErrUntrustedCertificate(err.Error())
+	}
+	if issuer != v.Issuer {
+		return NewErrUntrustedCertificate(fmt.Sprintf("expected issuer \"%s\", but certificate has \"%s\"", v.Issuer, issuer))
+	}
+	return nil
+}
+
+// Read the OIDC issuer from the fulcio certificate extensions
+func certificateIssuer(cert *x509.Certificate) (string, error) {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidFulcioIssuerV2):
+			var issuer string
+			_, err := asn1.UnmarshalWithParams(ext.Value, &issuer, "utf8")
+			if err != nil {
+				return "", fmt.Errorf("failed to parse issuer extension: %v", err)
+			}
+			return issuer, nil
+		case ext.Id.Equal(oidFulcioIssuerV1):
+			return string(ext.Value), nil
+		}
+	}
+	return "", errors.New("certificate does not contain an OIDC issuer")
+}
+
+// Verify the signature over the data with the public key of the certificate
+func verifySignature(cert *x509.Certificate, data, sig []byte) error {
+	digest := sha256.Sum256(data)
+
+	switch key := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], sig) {
+			return errors.New("invalid ecdsa signature")
+		}
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, data, sig) {
+			return errors.New("invalid ed25519 signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", cert.PublicKey)
+	}
+	return nil
+}
+
+// Parse a single PEM certificate, which may additionally be base64 encoded
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	data = decodeBase64IfEncoded(data)
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// Split the PEM encoded certificates into self-signed roots and intermediates
+func parseCertificatePools(data []byte) (*x509.CertPool, *x509.CertPool, error) {
+	roots := x509.NewCertPool()
+	intermediates := x509.NewCertPool()
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		if bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil {
+			roots.AddCert(cert)
+		} else {
+			intermediates.AddCert(cert)
+		}
+	}
+	return roots, intermediates, nil
+}
+
+// Return the decoded data if it is base64 encoded, otherwise the data itself
+func decodeBase64IfEncoded(data []byte) []byte {
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return data
+	}
+	return decoded
+}
@@ -0,0 +1,472 @@
+package kubeadm
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json/v2"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/sigstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIdentity = "release@example.com"
+	testIssuer   = "https://accounts.example.com"
+)
+
+func TestNewSigstoreVerifier(t *testing.T) {
+	require := require.New(t)
+
+	v, err := NewSigstoreVerifier(testIdentity, testIssuer, "")
+	require.NoError(err, "Should load the embedded certificates")
+
+	roots, intermediates, err := parseCertificatePools(sigstore.FulcioCertificates)
+	require.NoError(err, "Should parse the embedded certificates")
+	require.True(roots.Equal(v.Roots), "Should trust the sigstore root")
+	require.True(intermediates.Equal(v.Intermediates), "Should use the sigstore intermediate")
+	require.NotNil(v.RekorKey, "Should trust the sigstore rekor")
+	require.Equal(testIdentity, v.Identity, "Should set the identity")
+	require.Equal(testIssuer, v.Issuer, "Should set the issuer")
+}
+
+func TestNewSigstoreVerifierWithRekorKey(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(err, "Should generate key")
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.NoError(err, "Should marshal key")
+
+		v, err := NewSigstoreVerifier(testIdentity, testIssuer, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+		require.NoError(err, "Should load the rekor key")
+
+		assert.True(key.PublicKey.Equal(v.RekorKey), "Should trust the given rekor key")
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := NewSigstoreVerifier(testIdentity, testIssuer, "not-a-key")
+
+		assert.ErrorContains(t, err, "failed to load rekor public key", "Should reject an invalid key")
+	})
+}
+
+func TestSignatureVerifierVerify(t *testing.T) {
+	signer := newTestSigner(t)
+	otherSigner := newTestSigner(t)
+	blob := []byte("kubeadm")
+
+	tMatrix := []struct {
+		Name     string
+		Signer   *testSigner
+		Identity string
+		Issuer   string
+		Blob     []byte
+		Raw      bool
+		// The log entry is for this blob instead of the signed one
+		LoggedBlob []byte
+		// Offset of the time the signature was logged to now
+		LoggedAt time.Duration
+		// The log entry is signed by this rekor instead of the trusted one
+		Rekor          *testSigner
+		TamperedBundle bool
+		NoBundle       bool
+		Err            any
+	}{
+		{
+			Name:     "Valid",
+			Signer:   signer,
+			Identity: testIdentity,
+			Issuer:   testIssuer,
+			Blob:     blob,
+		},
+		{
+			Name:     "ValidNotEncoded",
+			Signer:   signer,
+			Identity: testIdentity,
+			Issuer:   testIssuer,
+			Blob:     blob,
+			Raw:      true,
+		},
+		{
+			Name:     "WrongIdentity",
+			Signer:   signer,
+			Identity: "someone@example.com",
+			Issuer:   testIssuer,
+			Blob:     blob,
+			Err:      new(*ErrUntrustedCertificate),
+		},
+		{
+			Name:     "WrongIssuer",
+			Signer:   signer,
+			Identity: testIdentity,
+			Issuer:   "https://accounts.example.org",
+			Blob:     blob,
+			Err:      new(*ErrUntrustedCertificate),
+		},
+		{
+			Name:     "UntrustedCA",
+			Signer:   otherSigner,
+			Identity: testIdentity,
+			Issuer:   testIssuer,
+			Blob:     blob,
+			Rekor:    signer,
+			Err:      new(*ErrUntrustedCertificate),
+		},
+		{
+			Name:       "TamperedBlob",
+			Signer:     signer,
+			Identity:   testIdentity,
+			Issuer:     testIssuer,
+			Blob:       []byte("not-kubeadm"),
+			LoggedBlob: []byte("not-kubeadm"),
+			Err:        new(*ErrSignatureMismatch),
+		},
+		{
+			Name:     "TamperedBlobNotLogged",
+			Signer:   signer,
+			Identity: testIdentity,
+			Issuer:   testIssuer,
+			Blob:     []byte("not-kubeadm"),
+			Err:      new(*ErrTransparencyLog),
+		},
+		{
+			Name:     "MissingBundle",
+			Signer:   signer,
+			Identity: testIdentity,
+			Issuer:   testIssuer,
+			Blob:     blob,
+			NoBundle: true,
+			Err:      new(*ErrTransparencyLog),
+		},
+		{
+			Name:     "UntrustedLog",
+			Signer:   signer,
+			Identity: testIdentity,
+			Issuer:   testIssuer,
+			Blob:     blob,
+			Rekor:    otherSigner,
+			Err:      new(*ErrTransparencyLog),
+		},
+		{
+			Name:           "TamperedBundle",
+			Signer:         signer,
+			Identity:       testIdentity,
+			Issuer:         testIssuer,
+			Blob:           blob,
+			TamperedBundle: true,
+			Err:            new(*ErrTransparencyLog),
+		},
+		{
+			Name:     "LoggedAfterCertificateExpired",
+			Signer:   signer,
+			Identity: testIdentity,
+			Issuer:   testIssuer,
+			Blob:     blob,
+			LoggedAt: time.Hour,
+			Err:      new(*ErrUntrustedCertificate),
+		},
+		{
+			Name:     "LoggedBeforeCertificateIssued",
+			Signer:   signer,
+			Identity: testIdentity,
+			Issuer:   testIssuer,
+			Blob:     blob,
+			LoggedAt: -time.Hour,
+			Err:      new(*ErrUntrustedCertificate),
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			dir := t.TempDir()
+			sig, cert := tCase.Signer.sign(t, blob, testIdentity, testIssuer)
+
+			loggedBlob := blob
+			if tCase.LoggedBlob != nil {
+				loggedBlob = tCase.LoggedBlob
+			}
+			rekor := tCase.Rekor
+			if rekor == nil {
+				rekor = tCase.Signer
+			}
+			bundle := rekor.bundle(t, loggedBlob, sig, cert, time.Now().Add(tCase.LoggedAt))
+			if tCase.TamperedBundle {
+				bundle = bytes.Replace(bundle, []byte(`"logIndex":1`), []byte(`"logIndex":2`), 1)
+			}
+
+			if tCase.Raw {
+				sig = decodeBase64IfEncoded(sig)
+				cert = decodeBase64IfEncoded(cert)
+			}
+			require.NoError(os.WriteFile(filepath.Join(dir, "kubeadm"), tCase.Blob, 0644))
+			require.NoError(os.WriteFile(filepath.Join(dir, "kubeadm.sig"), sig, 0644))
+			require.NoError(os.WriteFile(filepath.Join(dir, "kubeadm.cert"), cert, 0644))
+			if !tCase.NoBundle {
+				require.NoError(os.WriteFile(filepath.Join(dir, "kubeadm.bundle"), bundle, 0644))
+			}
+
+			v := signer.verifier(tCase.Identity, tCase.Issuer)
+			err := v.Verify(filepath.Join(dir, "kubeadm"), filepath.Join(dir, "kubeadm.sig"), filepath.Join(dir, "kubeadm.cert"), filepath.Join(dir, "kubeadm.bundle"))
+
+			if tCase.Err == nil {
+				assert.NoError(err, "Should verify the signature")
+			} else {
+				assert.ErrorAs(err, tCase.Err, "Should return the expected error type")
+			}
+		})
+	}
+}
+
+func TestFetchRekorBundle(t *testing.T) {
+	signer := newTestSigner(t)
+	blob := []byte("kubeadm")
+
+	t.Run("Found", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		dir := t.TempDir()
+		otherSig, otherCert := signer.sign(t, blob, testIdentity, testIssuer)
+		sig, cert := signer.sign(t, blob, testIdentity, testIssuer)
+		srv := newFakeRekor(t, signer.bundle(t, blob, otherSig, otherCert, time.Now()), signer.bundle(t, blob, sig, cert, time.Now()))
+
+		require.NoError(os.WriteFile(filepath.Join(dir, "kubeadm"), blob, 0644))
+		require.NoError(os.WriteFile(filepath.Join(dir, "kubeadm.sig"), sig, 0644))
+		require.NoError(os.WriteFile(filepath.Join(dir, "kubeadm.cert"), cert, 0644))
+
+		err := fetchRekorBundle(DownloadOptions{RekorURL: srv.URL}, filepath.Join(dir, "kubeadm"), filepath.Join(dir, "kubeadm.sig"), filepath.Join(dir, "kubeadm.bundle"))
+		require.NoError(err, "Should fetch the entry")
+
+		v := signer.verifier(testIdentity, testIssuer)
+		err = v.Verify(filepath.Join(dir, "kubeadm"), filepath.Join(dir, "kubeadm.sig"), filepath.Join(dir, "kubeadm.cert"), filepath.Join(dir, "kubeadm.bundle"))
+		assert.NoError(err, "Should select the entry of the signature")
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		dir := t.TempDir()
+		otherSig, otherCert := signer.sign(t, blob, testIdentity, testIssuer)
+		sig, _ := signer.sign(t, blob, testIdentity, testIssuer)
+		srv := newFakeRekor(t, signer.bundle(t, blob, otherSig, otherCert, time.Now()))
+
+		require.NoError(os.WriteFile(filepath.Join(dir, "kubeadm"), blob, 0644))
+		require.NoError(os.WriteFile(filepath.Join(dir, "kubeadm.sig"), sig, 0644))
+
+		err := fetchRekorBundle(DownloadOptions{RekorURL: srv.URL}, filepath.Join(dir, "kubeadm"), filepath.Join(dir, "kubeadm.sig"), filepath.Join(dir, "kubeadm.bundle"))
+
+		var tlogErr *ErrTransparencyLog
+		assert.ErrorAs(err, &tlogErr, "Should fail without an entry for the signature")
+		assert.NoFileExists(filepath.Join(dir, "kubeadm.bundle"), "Should not write a bundle")
+	})
+}
+
+func TestCanonicalRekorPayload(t *testing.T) {
+	assert := assert.New(t)
+
+	canonical, err := canonicalRekorPayload(RekorPayload{
+		Body:           "PGJvZHk+",
+		IntegratedTime: 1700000000,
+		LogIndex:       42,
+		LogID:          "c0d23d6ad406973f",
+	})
+
+	assert.NoError(err, "Should encode the payload")
+	assert.Equal(`{"body":"PGJvZHk+","integratedTime":1700000000,"logID":"c0d23d6ad406973f","logIndex":42}`, string(canonical), "Should sort the keys without a trailing newline")
+}
+
+func TestCertificateIssuer(t *testing.T) {
+	t.Run("V1", func(t *testing.T) {
+		cert := &x509.Certificate{
+			Extensions: []pkix.Extension{{Id: oidFulcioIssuerV1, Value: []byte(testIssuer)}},
+		}
+		issuer, err := certificateIssuer(cert)
+		assert.NoError(t, err, "Should read the issuer")
+		assert.Equal(t, testIssuer, issuer, "Should return the issuer")
+	})
+	t.Run("Missing", func(t *testing.T) {
+		_, err := certificateIssuer(&x509.Certificate{})
+		assert.Error(t, err, "Should fail without issuer")
+	})
+}
+
+// Fake fulcio CA issuing short-lived signing certificates, together with a fake rekor logging the signatures
+type testSigner struct {
+	caCert   *x509.Certificate
+	caKey    *ecdsa.PrivateKey
+	rekorKey *ecdsa.PrivateKey
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Should generate CA key")
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "Should create CA certificate")
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err, "Should parse CA certificate")
+
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Should generate rekor key")
+
+	return &testSigner{
+		caCert:   cert,
+		caKey:    key,
+		rekorKey: rekorKey,
+	}
+}
+
+// Sign the data with a new certificate for the identity and issuer.
+// Returns the base64 encoded signature and PEM certificate, like cosign does.
+func (s *testSigner) sign(t *testing.T, data []byte, identity, issuer string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Should generate signing key")
+
+	issuerExt, err := asn1.MarshalWithParams(issuer, "utf8")
+	require.NoError(t, err, "Should encode issuer")
+
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       time.Now().Add(-time.Minute),
+		NotAfter:        time.Now().Add(10 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		EmailAddresses:  []string{identity},
+		ExtraExtensions: []pkix.Extension{{Id: oidFulcioIssuerV2, Value: issuerExt}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, s.caCert, &key.PublicKey, s.caKey)
+	require.NoError(t, err, "Should create signing certificate")
+
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err, "Should sign data")
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return []byte(base64.StdEncoding.EncodeToString(sig)), []byte(base64.StdEncoding.EncodeToString(cert))
+}
+
+// Log the signature and certificate over the data at the given time.
+// Returns the JSON encoded rekor bundle, signed by the fake rekor.
+func (s *testSigner) bundle(t *testing.T, data, sig, cert []byte, integratedTime time.Time) []byte {
+	digest := sha256.Sum256(data)
+	body, err := json.Marshal(map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]any{
+			"data": map[string]any{
+				"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(digest[:])},
+			},
+			"signature": map[string]any{
+				"content":   base64.StdEncoding.EncodeToString(decodeBase64IfEncoded(sig)),
+				"publicKey": map[string]string{"content": base64.StdEncoding.EncodeToString(decodeBase64IfEncoded(cert))},
+			},
+		},
+	})
+	require.NoError(t, err, "Should encode entry")
+
+	der, err := x509.MarshalPKIXPublicKey(&s.rekorKey.PublicKey)
+	require.NoError(t, err, "Should encode rekor key")
+	logID := sha256.Sum256(der)
+
+	payload := RekorPayload{
+		Body:           base64.StdEncoding.EncodeToString(body),
+		IntegratedTime: integratedTime.Unix(),
+		LogIndex:       1,
+		LogID:          hex.EncodeToString(logID[:]),
+	}
+	canonical, err := canonicalRekorPayload(payload)
+	require.NoError(t, err, "Should encode payload")
+	payloadDigest := sha256.Sum256(canonical)
+	set, err := ecdsa.SignASN1(rand.Reader, s.rekorKey, payloadDigest[:])
+	require.NoError(t, err, "Should sign payload")
+
+	bundle, err := json.Marshal(RekorBundle{SignedEntryTimestamp: set, Payload: payload})
+	require.NoError(t, err, "Should encode bundle")
+	return bundle
+}
+
+// Create a verifier trusting only this CA and rekor
+func (s *testSigner) verifier(identity, issuer string) *SignatureVerifier {
+	roots := x509.NewCertPool()
+	roots.AddCert(s.caCert)
+	return &SignatureVerifier{
+		Roots:    roots,
+		RekorKey: &s.rekorKey.PublicKey,
+		Identity: identity,
+		Issuer:   issuer,
+	}
+}
+
+// Serve the rekor API with the given bundles as entries, the index returns all of them for any hash
+func newFakeRekor(t *testing.T, bundles ...[]byte) *httptest.Server {
+	mux := http.NewServeMux()
+	registerFakeRekor(t, mux, bundles...)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// Register the handlers of the fake rekor API
+func registerFakeRekor(t *testing.T, mux *http.ServeMux, bundles ...[]byte) {
+	entries := make(map[string]rekorLogEntry, len(bundles))
+	uuids := make([]string, 0, len(bundles))
+	for i, data := range bundles {
+		var bundle RekorBundle
+		require.NoError(t, json.Unmarshal(data, &bundle), "Should decode bundle")
+
+		entry := rekorLogEntry{
+			Body:           bundle.Payload.Body,
+			IntegratedTime: bundle.Payload.IntegratedTime,
+			LogIndex:       bundle.Payload.LogIndex,
+			LogID:          bundle.Payload.LogID,
+		}
+		entry.Verification.SignedEntryTimestamp = bundle.SignedEntryTimestamp
+
+		uuid := fmt.Sprintf("uuid-%d", i)
+		entries[uuid] = entry
+		uuids = append(uuids, uuid)
+	}
+
+	mux.HandleFunc("POST /api/v1/index/retrieve", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.MarshalWrite(w, uuids)
+	})
+	mux.HandleFunc("GET /api/v1/log/entries/{uuid}", func(w http.ResponseWriter, r *http.Request) {
+		entry, ok := entries[r.PathValue("uuid")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.MarshalWrite(w, map[string]rekorLogEntry{r.PathValue("uuid"): entry})
+	})
+}
@@ -0,0 +1,12 @@
+#!/bin/bash
+
+# Fake podman, copies print-args.sh instead of extracting from an image
+case "$1" in
+create)
+    echo "fake-container-id"
+    ;;
+cp)
+    cp "$(dirname "$0")/print-args.sh" "$3"
+    ;;
+esac
+exit 0
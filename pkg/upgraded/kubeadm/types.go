@@ -1,5 +0,0 @@
-package kubeadm
-
-type ClusterConfiguration struct {
-	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
-}
@@ -1,18 +1,18 @@
 package kubeadm
 
 import (
+	"bufio"
+	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
-
-	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/utils"
+	"strings"
 )
 
-var CosignBinary = "cosign"
-
-func downloadFile(url, dest string) error {
+func downloadFile(opts DownloadOptions, url, dest string) error {
 	dir := filepath.Dir(dest)
 	// #nosec G301: The binary is no secret, can be world readable/executable
 	err := os.MkdirAll(dir, 0755)
@@ -20,15 +20,18 @@ func downloadFile(url, dest string) error {
 		return fmt.Errorf("failed to create directory '%s': %v", dir, err)
 	}
 
-	// #nosec G107: Yes, the url is variable, that is indeed intended.
-	res, err := http.Get(url)
+	req, err := opts.newRequest(url)
 	if err != nil {
 		return err
 	}
+	res, err := opts.client().Do(req)
+	if err != nil {
+		return NewErrDownload(url, err)
+	}
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download '%s', received status code %d", url, res.StatusCode)
+		return NewErrDownload(url, fmt.Errorf("received status code %d", res.StatusCode))
 	}
 
 	// #nosec G304: The path is variable, that is indeed intended.
@@ -41,18 +44,55 @@ func downloadFile(url, dest string) error {
 
 	_, err = io.Copy(f, res.Body)
 	if err != nil {
-		return fmt.Errorf("failed to download '%s' from '%s': %v", dest, url, err)
+		return NewErrDownload(url, err)
+	}
+	return nil
+}
+
+// Verify the sha512 checksum of the blob against the given checksum file.
+// The checksum file may either only contain the checksum or be in the format of sha512sum.
+func verifyArtifactWithChecksum(blob, checksumFile string) error {
+	// #nosec G304: The path is variable, that is indeed intended.
+	f, err := os.Open(checksumFile)
+	if err != nil {
+		return fmt.Errorf("failed to open checksum file: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return fmt.Errorf("checksum file '%s' is empty", checksumFile)
+	}
+	fields := strings.Fields(scanner.Text())
+	if len(fields) == 0 {
+		return fmt.Errorf("checksum file '%s' is empty", checksumFile)
+	}
+	expected := strings.ToLower(fields[0])
+
+	actual, err := sha512File(blob)
+	if err != nil {
+		return err
+	}
+
+	if actual != expected {
+		return NewErrChecksumMismatch(blob, expected, actual)
 	}
 	return nil
 }
 
-func verifyArtifactWithCosign(blob, sig, cert string) error {
-	args := []string{
-		"verify-blob", blob,
-		"--signature", sig,
-		"--certificate", cert,
-		"--certificate-identity", "krel-staging@k8s-releng-prod.iam.gserviceaccount.com",
-		"--certificate-oidc-issuer", "https://accounts.google.com",
+// Calculate the hex encoded sha512 checksum of the file
+func sha512File(path string) (string, error) {
+	// #nosec G304: The path is variable, that is indeed intended.
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open '%s': %v", path, err)
+	}
+	defer f.Close()
+
+	h := sha512.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", fmt.Errorf("failed to read '%s': %v", path, err)
 	}
-	return utils.CreateCMDWithStdout(CosignBinary, args...).Run()
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
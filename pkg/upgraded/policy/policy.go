@@ -0,0 +1,274 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/sigstore"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// The policy used by containers/image, which rpm-ostree uses for pulling container images
+	PolicyFile = "/etc/containers/policy.json"
+	// Enables sigstore signatures for the managed scopes and keeps track of them
+	RegistriesFile = "/etc/containers/registries.d/kube-upgraded.yaml"
+
+	transportDocker = "docker"
+
+	requirementInsecureAcceptAnything = "insecureAcceptAnything"
+	requirementReject                 = "reject"
+)
+
+type policyFile struct {
+	Default    []map[string]any                       `json:"default"`
+	Transports map[string]map[string][]map[string]any `json:"transports,omitempty"`
+}
+
+type registriesConfig struct {
+	Docker map[string]registryConfig `json:"docker,omitempty"`
+}
+
+type registryConfig struct {
+	UseSigstoreAttachments bool `json:"use-sigstore-attachments"`
+}
+
+// Create the policy requirement for the given signature policy
+func NewRequirement(cfg api.SignaturePolicy) map[string]any {
+	req := map[string]any{
+		"type": "sigstoreSigned",
+		"signedIdentity": map[string]any{
+			"type": "matchRepository",
+		},
+	}
+
+	if cfg.PublicKey != "" {
+		req["keyData"] = base64.StdEncoding.EncodeToString([]byte(cfg.PublicKey))
+		return req
+	}
+
+	fulcioCA := sigstore.FulcioCertificates
+	if cfg.Keyless.FulcioCA != "" {
+		fulcioCA = []byte(cfg.Keyless.FulcioCA)
+	}
+	rekorPublicKey := sigstore.RekorPublicKey
+	if cfg.Keyless.RekorPublicKey != "" {
+		rekorPublicKey = []byte(cfg.Keyless.RekorPublicKey)
+	}
+
+	req["fulcio"] = map[string]any{
+		"caData":       base64.StdEncoding.EncodeToString(fulcioCA),
+		"oidcIssuer":   cfg.Keyless.OIDCIssuer,
+		"subjectEmail": cfg.Keyless.SubjectEmail,
+	}
+	req["rekorPublicKeyData"] = base64.StdEncoding.EncodeToString(rekorPublicKey)
+	return req
+}
+
+// Install the requirement for the scope into the policy under root and enable sigstore signatures for it.
+// Scopes installed previously are removed from the policy, so when req is nil it only cleans up.
+// Fails when the policy already contains the scope without it being installed by upgraded, as it would be overwritten.
+func Install(root, scope string, req map[string]any) error {
+	previous, err := readManagedScopes(root)
+	if err != nil {
+		return err
+	}
+	if req == nil && len(previous) == 0 {
+		return nil
+	}
+
+	policy, err := readPolicy(root)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := policy.Transports[transportDocker][scope]; ok && req != nil && !slices.Contains(previous, scope) {
+		return fmt.Errorf("refusing to replace the existing requirements for \"%s\" in %s, remove them or the signature policy of the stream", scope, PolicyFile)
+	}
+
+	for _, s := range previous {
+		delete(policy.Transports[transportDocker], s)
+	}
+	if req != nil {
+		if policy.Transports == nil {
+			policy.Transports = make(map[string]map[string][]map[string]any)
+		}
+		if policy.Transports[transportDocker] == nil {
+			policy.Transports[transportDocker] = make(map[string][]map[string]any)
+		}
+		policy.Transports[transportDocker][scope] = []map[string]any{req}
+	}
+
+	data, err := json.Marshal(policy, json.Deterministic(true), jsontext.WithIndent("    "))
+	if err != nil {
+		return fmt.Errorf("failed to marshal container policy: %v", err)
+	}
+	err = writeFileIfChanged(root+PolicyFile, append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write container policy: %v", err)
+	}
+
+	if req == nil {
+		err = os.Remove(root + RegistriesFile)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove registries config: %v", err)
+		}
+		return nil
+	}
+
+	data, err = yaml.Marshal(registriesConfig{
+		Docker: map[string]registryConfig{
+			scope: {UseSigstoreAttachments: true},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal registries config: %v", err)
+	}
+	err = writeFileIfChanged(root+RegistriesFile, data)
+	if err != nil {
+		return fmt.Errorf("failed to write registries config: %v", err)
+	}
+	return nil
+}
+
+// Check that the policy under root requires signatures for the given image.
+// Uses the same scope lookup as containers/image for the docker transport.
+func CheckSigned(root, image string) error {
+	policy, err := readPolicy(root)
+	if err != nil {
+		return err
+	}
+
+	reqs, scope := policy.requirementsFor(image)
+	if len(reqs) == 0 {
+		return fmt.Errorf("no policy requirements found for %s", image)
+	}
+	for _, req := range reqs {
+		switch req["type"] {
+		case requirementInsecureAcceptAnything:
+			return fmt.Errorf("the policy for %s (scope \"%s\") accepts unsigned images, configure a signature policy or allow unsigned images", image, scope)
+		case requirementReject:
+			return fmt.Errorf("the policy for %s (scope \"%s\") rejects all images", image, scope)
+		}
+	}
+	return nil
+}
+
+// Return the requirements applying to the image and the scope they are defined for
+func (p *policyFile) requirementsFor(image string) ([]map[string]any, string) {
+	transport := p.Transports[transportDocker]
+	for _, scope := range imageScopes(image) {
+		if reqs, ok := transport[scope]; ok {
+			return reqs, scope
+		}
+	}
+	if reqs, ok := transport[""]; ok {
+		return reqs, ""
+	}
+	return p.Default, "default"
+}
+
+// Return the scopes matching the image, from the most to the least specific
+func imageScopes(image string) []string {
+	repo := image
+	if i := strings.LastIndex(repo, "@"); i >= 0 {
+		repo = repo[:i]
+	} else if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo = repo[:i]
+	}
+
+	scopes := make([]string, 0, 4)
+	if repo != image {
+		scopes = append(scopes, image)
+	}
+	for s := repo; ; {
+		scopes = append(scopes, s)
+		i := strings.LastIndex(s, "/")
+		if i < 0 {
+			break
+		}
+		s = s[:i]
+	}
+
+	host, _, _ := strings.Cut(repo, "/")
+	host, _, _ = strings.Cut(host, ":")
+	parts := strings.Split(host, ".")
+	for i := 1; i < len(parts); i++ {
+		scopes = append(scopes, "*."+strings.Join(parts[i:], "."))
+	}
+	return scopes
+}
+
+func readPolicy(root string) (*policyFile, error) {
+	// #nosec G304: The path is fixed below the given root
+	data, err := os.ReadFile(root + PolicyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read container policy: %v", err)
+	}
+	var policy policyFile
+	err = json.Unmarshal(data, &policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse container policy: %v", err)
+	}
+	return &policy, nil
+}
+
+// Return the scopes installed previously
+func readManagedScopes(root string) ([]string, error) {
+	// #nosec G304: The path is fixed below the given root
+	data, err := os.ReadFile(root + RegistriesFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read registries config: %v", err)
+	}
+	var cfg registriesConfig
+	err = yaml.Unmarshal(data, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse registries config: %v", err)
+	}
+
+	scopes := make([]string, 0, len(cfg.Docker))
+	for scope := range cfg.Docker {
+		scopes = append(scopes, scope)
+	}
+	slices.Sort(scopes)
+	return scopes, nil
+}
+
+// Atomically replace the file, unless it already has the given content
+func writeFileIfChanged(path string, data []byte) error {
+	// #nosec G304: The path is fixed below the given root
+	current, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+
+	// #nosec G301: The directories are world readable by default
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	// #nosec G306: The policy is no secret and needs to be readable
+	err = os.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
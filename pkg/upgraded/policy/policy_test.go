@@ -0,0 +1,215 @@
+package policy
+
+import (
+	"encoding/base64"
+	"os"
+	"testing"
+
+	api "github.com/heathcliff26/kube-upgrade/pkg/apis/kubeupgrade/v1alpha3"
+	"github.com/heathcliff26/kube-upgrade/pkg/upgraded/sigstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `{"default":[{"type":"insecureAcceptAnything"}],"transports":{"docker-daemon":{"":[{"type":"insecureAcceptAnything"}]}}}`
+
+func newTestRoot(t *testing.T, policy string) string {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(root+"/etc/containers", 0755), "Should create policy directory")
+	require.NoError(t, os.WriteFile(root+PolicyFile, []byte(policy), 0644), "Should write policy")
+	return root
+}
+
+func TestNewRequirement(t *testing.T) {
+	t.Run("PublicKey", func(t *testing.T) {
+		assert := assert.New(t)
+
+		req := NewRequirement(api.SignaturePolicy{PublicKey: "testkey"})
+
+		assert.Equal("sigstoreSigned", req["type"], "Should require sigstore signatures")
+		assert.Equal(base64.StdEncoding.EncodeToString([]byte("testkey")), req["keyData"], "Should contain the key")
+		assert.NotContains(req, "fulcio", "Should not contain keyless settings")
+	})
+	t.Run("KeylessDefaults", func(t *testing.T) {
+		assert := assert.New(t)
+
+		req := NewRequirement(api.SignaturePolicy{Keyless: &api.KeylessSignaturePolicy{
+			SubjectEmail: "release@example.com",
+			OIDCIssuer:   "https://accounts.example.com",
+		}})
+
+		fulcio := req["fulcio"].(map[string]any)
+		assert.Equal(base64.StdEncoding.EncodeToString(sigstore.FulcioCertificates), fulcio["caData"], "Should use the public good fulcio CA")
+		assert.Equal("https://accounts.example.com", fulcio["oidcIssuer"], "Should contain the issuer")
+		assert.Equal("release@example.com", fulcio["subjectEmail"], "Should contain the email")
+		assert.Equal(base64.StdEncoding.EncodeToString(sigstore.RekorPublicKey), req["rekorPublicKeyData"], "Should use the public good rekor key")
+		assert.NotContains(req, "keyData", "Should not contain a key")
+	})
+	t.Run("KeylessCustom", func(t *testing.T) {
+		assert := assert.New(t)
+
+		req := NewRequirement(api.SignaturePolicy{Keyless: &api.KeylessSignaturePolicy{
+			FulcioCA:       "ca",
+			RekorPublicKey: "rekor",
+		}})
+
+		fulcio := req["fulcio"].(map[string]any)
+		assert.Equal(base64.StdEncoding.EncodeToString([]byte("ca")), fulcio["caData"], "Should use the custom CA")
+		assert.Equal(base64.StdEncoding.EncodeToString([]byte("rekor")), req["rekorPublicKeyData"], "Should use the custom key")
+	})
+}
+
+func TestInstall(t *testing.T) {
+	req := NewRequirement(api.SignaturePolicy{PublicKey: "testkey"})
+
+	t.Run("NoPolicy", func(t *testing.T) {
+		root := newTestRoot(t, testPolicy)
+
+		assert.NoError(t, Install(root, "registry.example.com/fcos-k8s", nil), "Should succeed")
+
+		data, err := os.ReadFile(root + PolicyFile)
+		require.NoError(t, err, "Should read policy")
+		assert.Equal(t, testPolicy, string(data), "Should not touch the policy")
+		assert.NoFileExists(t, root+RegistriesFile, "Should not create registries config")
+	})
+	t.Run("AddAndSwitchScope", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		root := newTestRoot(t, testPolicy)
+
+		require.NoError(Install(root, "registry.example.com/fcos-k8s", req), "Should install policy")
+		assert.NoError(CheckSigned(root, "registry.example.com/fcos-k8s:v1.35.0"), "Stream should be verified")
+
+		registries, err := os.ReadFile(root + RegistriesFile)
+		require.NoError(err, "Should have written registries config")
+		assert.Contains(string(registries), "registry.example.com/fcos-k8s:\n    use-sigstore-attachments: true", "Should enable sigstore attachments")
+
+		require.NoError(Install(root, "registry.example.com/other", req), "Should switch scope")
+		assert.Error(CheckSigned(root, "registry.example.com/fcos-k8s:v1.35.0"), "Old scope should be removed")
+		assert.NoError(CheckSigned(root, "registry.example.com/other:v1.35.0"), "New scope should be verified")
+
+		policy, err := readPolicy(root)
+		require.NoError(err, "Should read policy")
+		assert.Contains(policy.Transports, "docker-daemon", "Should keep unmanaged transports")
+	})
+	t.Run("Cleanup", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		root := newTestRoot(t, testPolicy)
+
+		require.NoError(Install(root, "registry.example.com/fcos-k8s", req), "Should install policy")
+		require.NoError(Install(root, "registry.example.com/fcos-k8s", nil), "Should remove policy")
+
+		policy, err := readPolicy(root)
+		require.NoError(err, "Should read policy")
+		assert.NotContains(policy.Transports["docker"], "registry.example.com/fcos-k8s", "Should have removed the scope")
+		assert.NoFileExists(root+RegistriesFile, "Should have removed registries config")
+	})
+	t.Run("KeepUnmanagedScope", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		policy := `{"default":[{"type":"reject"}],"transports":{"docker":{"registry.example.com/fcos-k8s":[{"type":"signedBy","keyType":"GPGKeys","keyPath":"/etc/pki/key.gpg"}]}}}`
+		root := newTestRoot(t, policy)
+
+		err := Install(root, "registry.example.com/fcos-k8s", req)
+		assert.ErrorContains(err, "refusing to replace the existing requirements", "Should not overwrite requirements of the host")
+
+		data, err := os.ReadFile(root + PolicyFile)
+		require.NoError(err, "Should read policy")
+		assert.Equal(policy, string(data), "Should not touch the policy")
+		assert.NoFileExists(root+RegistriesFile, "Should not create registries config")
+	})
+	t.Run("MissingPolicy", func(t *testing.T) {
+		assert.ErrorContains(t, Install(t.TempDir(), "registry.example.com/fcos-k8s", req), "failed to read container policy", "Should fail without policy")
+	})
+}
+
+func TestCheckSigned(t *testing.T) {
+	tMatrix := []struct {
+		Name, Policy, Image, Error string
+	}{
+		{
+			Name:   "SignedScope",
+			Policy: `{"default":[{"type":"reject"}],"transports":{"docker":{"registry.example.com":[{"type":"sigstoreSigned","keyData":"a2V5"}]}}}`,
+			Image:  "registry.example.com/fcos-k8s:v1.35.0",
+		},
+		{
+			Name:   "SignedWildcard",
+			Policy: `{"default":[{"type":"reject"}],"transports":{"docker":{"*.example.com":[{"type":"sigstoreSigned","keyData":"a2V5"}]}}}`,
+			Image:  "registry.example.com/fcos-k8s:v1.35.0",
+		},
+		{
+			Name:   "InsecureDefault",
+			Policy: testPolicy,
+			Image:  "registry.example.com/fcos-k8s:v1.35.0",
+			Error:  "the policy for registry.example.com/fcos-k8s:v1.35.0 (scope \"default\") accepts unsigned images",
+		},
+		{
+			Name:   "InsecureTransportDefault",
+			Policy: `{"default":[{"type":"reject"}],"transports":{"docker":{"":[{"type":"insecureAcceptAnything"}]}}}`,
+			Image:  "registry.example.com/fcos-k8s:v1.35.0",
+			Error:  "(scope \"\") accepts unsigned images",
+		},
+		{
+			Name:   "Reject",
+			Policy: `{"default":[{"type":"insecureAcceptAnything"}],"transports":{"docker":{"registry.example.com/fcos-k8s":[{"type":"reject"}]}}}`,
+			Image:  "registry.example.com/fcos-k8s:v1.35.0",
+			Error:  "rejects all images",
+		},
+		{
+			Name:   "NoRequirements",
+			Policy: `{"default":[]}`,
+			Image:  "registry.example.com/fcos-k8s:v1.35.0",
+			Error:  "no policy requirements found",
+		},
+		{
+			Name:   "InvalidPolicy",
+			Policy: `not json`,
+			Image:  "registry.example.com/fcos-k8s:v1.35.0",
+			Error:  "failed to parse container policy",
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			root := newTestRoot(t, tCase.Policy)
+
+			err := CheckSigned(root, tCase.Image)
+
+			if tCase.Error == "" {
+				assert.NoError(t, err, "Should succeed")
+			} else {
+				assert.ErrorContains(t, err, tCase.Error, "Should return the correct error")
+			}
+		})
+	}
+}
+
+func TestImageScopes(t *testing.T) {
+	tMatrix := []struct {
+		Image  string
+		Scopes []string
+	}{
+		{
+			Image:  "registry.example.com/org/fcos-k8s:v1.35.0",
+			Scopes: []string{"registry.example.com/org/fcos-k8s:v1.35.0", "registry.example.com/org/fcos-k8s", "registry.example.com/org", "registry.example.com", "*.example.com", "*.com"},
+		},
+		{
+			Image:  "localhost:5000/fcos-k8s@sha256:abc",
+			Scopes: []string{"localhost:5000/fcos-k8s@sha256:abc", "localhost:5000/fcos-k8s", "localhost:5000"},
+		},
+		{
+			Image:  "localhost:5000/fcos-k8s",
+			Scopes: []string{"localhost:5000/fcos-k8s", "localhost:5000"},
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Image, func(t *testing.T) {
+			assert.Equal(t, tCase.Scopes, imageScopes(tCase.Image))
+		})
+	}
+}
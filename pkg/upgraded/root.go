@@ -45,6 +45,9 @@ func NewUpgraded() *cobra.Command {
 
 	rootCmd.Flags().StringP("config", "c", "", "Path to config file")
 	rootCmd.AddCommand(
+		newStatusCommand(),
+		newCheckCommand(),
+		newUpgradeNowCommand(),
 		version.NewCommand(Name),
 	)
 
@@ -11,3 +11,13 @@ func TestNewRootCommand(t *testing.T) {
 
 	assert.Equal(t, Name, cmd.Use)
 }
+
+func TestNewRootCommandSubcommands(t *testing.T) {
+	cmd := NewUpgraded()
+
+	for _, name := range []string{"status", "check", "upgrade-now", "version"} {
+		sub, _, err := cmd.Find([]string{name})
+		assert.NoError(t, err, "Should find subcommand %s", name)
+		assert.Equal(t, name, sub.Name(), "Should return the subcommand")
+	}
+}
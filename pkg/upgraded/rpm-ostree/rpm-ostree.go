@@ -53,14 +53,14 @@ func (r *RPMOStreeCMD) CheckForUpgrade() (bool, error) {
 	}
 }
 
-// Upgrade the system using rpm-ostree. Writes command output to stdout/stderr.
+// Upgrade the system using rpm-ostree. Writes command output to stdout/stderr and the command log.
 //
 // WARNING: Will reboot the system when successful.
 func (r *RPMOStreeCMD) Upgrade() error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	return utils.CreateCMDWithStdout(r.binary, "upgrade", "--reboot").Run()
+	return utils.RunWithLog("rpm-ostree", utils.CreateCMDWithStdout(r.binary, "upgrade", "--reboot"))
 }
 
 // Rebases the system to the given container image
@@ -74,7 +74,7 @@ func (r *RPMOStreeCMD) Rebase(image string, unverified bool) error {
 	if unverified {
 		prefix = "ostree-unverified-registry:"
 	}
-	return utils.CreateCMDWithStdout(r.binary, "rebase", "--reboot", prefix+image).Run()
+	return utils.RunWithLog("rpm-ostree", utils.CreateCMDWithStdout(r.binary, "rebase", "--reboot", prefix+image))
 }
 
 // Register upgraded as the driver for updates with rpm-ostree.
@@ -83,30 +83,55 @@ func (r *RPMOStreeCMD) RegisterAsDriver() error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	return utils.CreateCMDWithStdout(r.binary, "deploy", "--register-driver=upgraded").Run()
+	return utils.RunWithLog("rpm-ostree", utils.CreateCMDWithStdout(r.binary, "deploy", "--register-driver=upgraded"))
 }
 
 // Request the current status and return the booted image reference.
 func (r *RPMOStreeCMD) GetBootedImageRef() (string, error) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
-
-	// #nosec G204: Binary path is controlled by the user
-	out, err := exec.Command(r.binary, "status", "--json").Output()
+	status, err := r.status()
 	if err != nil {
 		return "", err
 	}
 
-	var status RPMOstreeStatus
-	err = json.Unmarshal(out, &status)
+	for _, deploy := range status.Deployments {
+		if deploy.Booted {
+			return deploy.ContainerImageReference, nil
+		}
+	}
+	return "", fmt.Errorf("no booted deployment found")
+}
+
+// Request the current status and return the image reference of the staged deployment.
+// Returns an empty string if no deployment is staged.
+func (r *RPMOStreeCMD) GetStagedImageRef() (string, error) {
+	status, err := r.status()
 	if err != nil {
 		return "", err
 	}
 
 	for _, deploy := range status.Deployments {
-		if deploy.Booted {
+		if deploy.Staged {
 			return deploy.ContainerImageReference, nil
 		}
 	}
-	return "", fmt.Errorf("no booted deployment found")
+	return "", nil
+}
+
+// Request the current status from rpm-ostree
+func (r *RPMOStreeCMD) status() (*RPMOstreeStatus, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// #nosec G204: Binary path is controlled by the user
+	out, err := exec.Command(r.binary, "status", "--json").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var status RPMOstreeStatus
+	err = json.Unmarshal(out, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
 }
@@ -109,3 +109,32 @@ func TestGetBootedImageRef(t *testing.T) {
 	assert.NoError(err, "Should succeed")
 	assert.Equal("ostree-unverified-registry:ghcr.io/heathcliff26/fcos-k8s:v1.34.2", ref, "Should return correct image ref")
 }
+
+func TestGetStagedImageRef(t *testing.T) {
+	tMatrix := []struct {
+		Name, Path, Result string
+	}{
+		{
+			Name: "NoStagedDeployment",
+			Path: "testdata/print-status.sh",
+		},
+		{
+			Name:   "StagedDeployment",
+			Path:   "testdata/print-status-staged.sh",
+			Result: "ostree-unverified-registry:ghcr.io/heathcliff26/fcos-k8s:v1.35.0",
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			cmd, err := New(tCase.Path)
+			require.NoError(t, err, "Should create a command")
+
+			ref, err := cmd.GetStagedImageRef()
+			assert.NoError(err, "Should succeed")
+			assert.Equal(tCase.Result, ref, "Should return correct image ref")
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package sigstore
+
+import (
+	_ "embed"
+)
+
+// Root and intermediate certificate of the sigstore public good instance of fulcio
+//
+//go:embed fulcio.pem
+var FulcioCertificates []byte
+
+// Public key of the sigstore public good instance of rekor
+//
+//go:embed rekor.pub
+var RekorPublicKey []byte
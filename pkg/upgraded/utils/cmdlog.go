@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Directory where the output of commands is logged, logging to files is disabled when empty.
+// Set by the daemon to a directory on the host, so the logs survive restarts of the pod.
+var CommandLogDir = ""
+
+const (
+	// The log of a command is rotated once it exceeds this size
+	commandLogMaxSize = 10 << 20
+	// Number of rotated logs kept per command
+	commandLogMaxBackups = 3
+	// The end of the output is kept in memory, so it can be reported when the command fails
+	commandOutputTailSize = 4 << 10
+)
+
+// Error of a failed command, containing the end of its output
+type CommandError struct {
+	Command string
+	Output  string
+	err     error
+}
+
+func (e *CommandError) Error() string {
+	return e.err.Error()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.err
+}
+
+// Run the command and additionally write its output to the log of the command in CommandLogDir.
+// When the command fails, the end of its output is returned as part of a CommandError.
+func RunWithLog(name string, cmd *exec.Cmd) error {
+	tail := &tailBuffer{size: commandOutputTailSize}
+	stdout := []io.Writer{tail}
+	stderr := []io.Writer{tail}
+	if cmd.Stdout != nil {
+		stdout = append(stdout, cmd.Stdout)
+	}
+	if cmd.Stderr != nil {
+		stderr = append(stderr, cmd.Stderr)
+	}
+
+	logFile, err := openCommandLog(name)
+	if err != nil {
+		slog.Warn("Failed to open command log, output is not persisted", slog.String("command", name), "err", err)
+	} else if logFile != nil {
+		defer logFile.Close()
+		fmt.Fprintf(logFile, "==> %s: %s\n", time.Now().UTC().Format(time.RFC3339), strings.Join(cmd.Args, " "))
+		stdout = append(stdout, logFile)
+		stderr = append(stderr, logFile)
+	}
+
+	cmd.Stdout = io.MultiWriter(stdout...)
+	cmd.Stderr = io.MultiWriter(stderr...)
+	err = cmd.Run()
+
+	if logFile != nil {
+		result := "success"
+		if err != nil {
+			result = err.Error()
+		}
+		fmt.Fprintf(logFile, "==> %s: finished with %s\n", time.Now().UTC().Format(time.RFC3339), result)
+	}
+	if err != nil {
+		return &CommandError{
+			Command: name,
+			Output:  tail.String(),
+			err:     err,
+		}
+	}
+	return nil
+}
+
+// Open the log for the command for appending, rotating it first when it is too large.
+// Returns nil if logging to files is disabled.
+func openCommandLog(name string) (*os.File, error) {
+	if CommandLogDir == "" {
+		return nil, nil
+	}
+	err := os.MkdirAll(CommandLogDir, 0750)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %v", err)
+	}
+
+	path := filepath.Join(CommandLogDir, name+".log")
+	info, err := os.Stat(path)
+	if err == nil && info.Size() >= commandLogMaxSize {
+		err = rotateLog(path, commandLogMaxBackups)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rotate log: %v", err)
+		}
+	}
+
+	// #nosec G304: The path is built from the log directory and a fixed command name
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+}
+
+// Move the log to path.1, shifting the existing backups and dropping the oldest one
+func rotateLog(path string, backups int) error {
+	for i := backups - 1; i >= 1; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(path, path+".1")
+}
+
+// Writer that only keeps the last bytes written to it.
+// Safe to use for stdout and stderr of a command at the same time.
+type tailBuffer struct {
+	size  int
+	buf   []byte
+	mutex sync.Mutex
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.size {
+		b.buf = append(b.buf[:0:0], b.buf[len(b.buf)-b.size:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return string(b.buf)
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWithLog(t *testing.T) {
+	oldCommandLogDir := CommandLogDir
+	CommandLogDir = filepath.Join(t.TempDir(), "kube-upgraded")
+	t.Cleanup(func() {
+		CommandLogDir = oldCommandLogDir
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		assert := assert.New(t)
+
+		err := RunWithLog("success", exec.Command("/bin/bash", "-c", "echo stdout && echo stderr >&2"))
+
+		assert.NoError(err, "Command should succeed")
+		log, err := os.ReadFile(filepath.Join(CommandLogDir, "success.log"))
+		require.NoError(t, err, "Should write the log")
+		assert.Contains(string(log), "/bin/bash -c echo stdout && echo stderr >&2\n", "Should log the command")
+		assert.Contains(string(log), "stdout\n", "Should log stdout")
+		assert.Contains(string(log), "stderr\n", "Should log stderr")
+		assert.Contains(string(log), "finished with success\n", "Should log the result")
+	})
+	t.Run("Failure", func(t *testing.T) {
+		assert := assert.New(t)
+
+		err := RunWithLog("failure", exec.Command("/bin/bash", "-c", "echo failed to upgrade >&2 && exit 3"))
+
+		var cmdErr *CommandError
+		require.True(t, errors.As(err, &cmdErr), "Should return a CommandError")
+		assert.Equal("failure", cmdErr.Command, "Should name the command")
+		assert.Equal("failed to upgrade\n", cmdErr.Output, "Should return the output")
+		assert.Equal("exit status 3", err.Error(), "Should keep the original error")
+
+		log, err := os.ReadFile(filepath.Join(CommandLogDir, "failure.log"))
+		require.NoError(t, err, "Should write the log")
+		assert.Contains(string(log), "finished with exit status 3\n", "Should log the result")
+	})
+	t.Run("AppendsToLog", func(t *testing.T) {
+		assert := assert.New(t)
+
+		for range 2 {
+			assert.NoError(RunWithLog("append", exec.Command("/bin/echo", "output")), "Command should succeed")
+		}
+
+		log, err := os.ReadFile(filepath.Join(CommandLogDir, "append.log"))
+		require.NoError(t, err, "Should write the log")
+		assert.Equal(2, strings.Count(string(log), "\noutput\n"), "Should keep the output of both runs")
+	})
+	t.Run("LoggingDisabled", func(t *testing.T) {
+		CommandLogDir = ""
+
+		err := RunWithLog("disabled", exec.Command("/bin/bash", "-c", "echo output && exit 1"))
+
+		var cmdErr *CommandError
+		require.True(t, errors.As(err, &cmdErr), "Should return a CommandError")
+		assert.Equal(t, "output\n", cmdErr.Output, "Should still return the output")
+	})
+}
+
+func TestRotateLog(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "kubeadm.log")
+	for _, name := range []string{path, path + ".1", path + ".2", path + ".3"} {
+		require.NoError(t, os.WriteFile(name, []byte(filepath.Base(name)), 0640), "Should create log")
+	}
+
+	assert.NoError(rotateLog(path, 3), "Should rotate the log")
+
+	assert.NoFileExists(path, "Should move the current log")
+	for i, expected := range []string{"kubeadm.log", "kubeadm.log.1", "kubeadm.log.2"} {
+		data, err := os.ReadFile(fmt.Sprintf("%s.%d", path, i+1))
+		require.NoError(t, err, "Should keep backup %d", i+1)
+		assert.Equal(expected, string(data), "Should shift backup %d", i+1)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := &tailBuffer{size: 8}
+	_, _ = buf.Write([]byte("1234"))
+	assert.Equal("1234", buf.String(), "Should keep short output")
+
+	_, _ = buf.Write([]byte("56789abc"))
+	assert.Equal("56789abc", buf.String(), "Should only keep the end of the output")
+}
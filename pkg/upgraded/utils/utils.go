@@ -1,11 +1,13 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
 	"syscall"
+	"time"
 )
 
 // Read the machine-id from /etc/machine-id
@@ -36,6 +38,25 @@ func CreateChrootCMDWithStdout(chrootPath string, name string, arg ...string) *e
 	return cmd
 }
 
+// Create a command that runs in a chroot and writes to stdout/stderr.
+// The command and all processes started by it are killed when the context is done.
+func CreateChrootCMDWithContext(ctx context.Context, chrootPath string, name string, arg ...string) *exec.Cmd {
+	// #nosec G204: Intended design
+	cmd := exec.CommandContext(ctx, name, arg...)
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Chroot:  chrootPath,
+		Setpgid: true,
+	}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// Don't wait forever on children that escaped the process group and keep the output open
+	cmd.WaitDelay = 10 * time.Second
+	return cmd
+}
+
 // Check if the given file exists and is executable
 func CheckExistsAndIsExecutable(path string) error {
 	f, err := os.Stat(path)
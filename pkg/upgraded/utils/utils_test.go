@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"io"
 	"os"
 	"testing"
@@ -54,6 +55,25 @@ func TestCreateChrootCMDWithStdout(t *testing.T) {
 	assert.Equal(os.Stderr, cmd.Stderr, "Command should use stderr")
 }
 
+func TestCreateChrootCMDWithContext(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cmd := CreateChrootCMDWithContext(ctx, "/chroot", "/bin/echo", "chrooted")
+	require.NotNil(t, cmd.SysProcAttr)
+
+	assert.Equal("/chroot", cmd.SysProcAttr.Chroot, "Chroot path should be set")
+	assert.Equal([]string{"/bin/echo", "chrooted"}, cmd.Args, "Command args should be set")
+	assert.Equal(os.Stdout, cmd.Stdout, "Command should use stdout")
+	assert.Equal(os.Stderr, cmd.Stderr, "Command should use stderr")
+
+	t.Run("KilledWhenContextDone", func(t *testing.T) {
+		cmd := CreateChrootCMDWithContext(ctx, "", "/bin/sleep", "10")
+		cancel()
+		assert.Error(cmd.Run(), "Command should be killed")
+	})
+}
+
 func TestCheckExistsAndIsExecutable(t *testing.T) {
 	tMatrix := []struct {
 		Name    string